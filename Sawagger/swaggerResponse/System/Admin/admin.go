@@ -23,6 +23,18 @@ type CreateAdminErrorResponse struct {
 	Data string `json:"data" example:"null"`
 }
 
+type SetupRootAdminSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data" example:"根管理员 xxx 已创建，请登录"`
+}
+
+type SetupRootAdminErrorResponse struct {
+	Code int    `json:"code" example:"-1"`
+	Msg  string `json:"msg"  example:"初始化链接无效、已使用或已过期"`
+	Data string `json:"data" example:"null"`
+}
+
 type AdminLoginSuccessResponse struct {
 	Code int    `json:"code" example:"1"`
 	Msg  string `json:"msg"  example:"ok"`
@@ -14,7 +14,7 @@ type GenerateAndInsertCodeErrorResponse struct {
 type GenerateAndInsertCodesSuccessResponse struct {
 	Code int    `json:"code" example:"1"`
 	Msg  string `json:"msg"  example:"ok"`
-	Data int    `json:"data"  example:"10"`
+	Data string `json:"data"  example:"{id: job id, total: 10000, inserted: 0, progress: 0, status: pending}"`
 }
 type GenerateAndInsertCodesErrorResponse struct {
 	Code int    `json:"code" example:"-1"`
@@ -74,3 +74,39 @@ type GetInviteCodeErrorResponse struct {
 	Msg  string `json:"msg"  example:"error msg"`
 	Data string `json:"data" example:"null"`
 }
+
+type CreateCampaignSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data" example:"campaign id string"`
+}
+type CreateCampaignErrorResponse struct {
+	Code int    `json:"code" example:"-1"`
+	Msg  string `json:"msg"  example:"error msg"`
+	Data string `json:"data" example:"null"`
+}
+type ListCampaignsSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data"  example:"{page:1, pagesize: 10, totalPages: 1,data: [campaign1_Detail, campaign2_Detail]}"`
+}
+type CampaignStatsSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data"  example:"{campaign_id: id, name: name, channel: wechat, generated: 100, redeemed: 20, expired: 5, max_redemptions: 50, remaining: 30}"`
+}
+type ListRedemptionsSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data"  example:"{page:1, pagesize: 10, totalPages: 1,data: [{id: id, code: code, campaign_id: id, user_id: id, username: name, redeemed_at: 2025-01-01T00:00:00Z}]}"`
+}
+type GenerateJobSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data"  example:"{id: job id, total: 10000, inserted: 4000, progress: 40, status: running, error: }"`
+}
+type ListGenerateJobsSuccessResponse struct {
+	Code int    `json:"code" example:"1"`
+	Msg  string `json:"msg"  example:"ok"`
+	Data string `json:"data"  example:"{page:1, pagesize: 10, totalPages: 1,data: [job1_Detail, job2_Detail]}"`
+}
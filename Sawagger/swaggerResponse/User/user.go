@@ -3,7 +3,7 @@ package userSwaggerResponse
 type RegisterUserSuccessResponse struct {
 	Code int    `json:"code" example:"1"`
 	Msg  string `json:"msg"  example:"ok"`
-	Data string `json:"data" example:"nil"`
+	Data string `json:"data" example:"{recovery_code: aB3dE5fG7hJ9}"`
 }
 type RegisterUserErrorResponse struct {
 	Code int    `json:"code" example:"-1"`
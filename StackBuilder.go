@@ -1,29 +1,123 @@
 package main
 
 import (
+	"context"
 	_ "github.com/StephenChristianW/go-movies-open/Sawagger/docs" // 引入 swaggerResponse 自动生成的文档
-	_ "github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/config"
 	"github.com/StephenChristianW/go-movies-open/config/initRootAdmin"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/migrations"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/logging"
 	"github.com/StephenChristianW/go-movies-open/routers"
 	InviteCodeService "github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
 	"github.com/StephenChristianW/go-movies-open/task"
+	"github.com/StephenChristianW/go-movies-open/tracing"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// logger 启动与关闭流程日志
+var logger = logging.For("main")
+
 func main() {
+	// ================== 校验配置 ==================
+	// 一次性输出全部配置错误，详情可通过 go run ./cmd/config check 查看
+	if err := config.Validate(); err != nil {
+		fatal("配置错误", err)
+	}
+
+	// ================== 链路追踪 ==================
+	// 开启 tracing.enabled 时通过 OTLP/HTTP 导出 span，关闭函数在服务退出时由 shutdown 调用
+	closeTracing := func(context.Context) error { return nil }
+	if cfg := config.Get(); cfg.Tracing.Enabled {
+		var err error
+		closeTracing, err = tracing.Init(context.Background(), tracing.Options{
+			Endpoint:    cfg.Tracing.Endpoint,
+			ServiceName: cfg.Tracing.ServiceName,
+			Environment: cfg.Env,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			fatal("链路追踪初始化失败", err)
+		}
+	}
+
 	// ================== 初始化 MongoDB 连接 ==================
-	// 获取全局 MongoDB 客户端并确保程序退出时自动关闭连接
-	defer db.MongoClient()()
+	// 获取全局 MongoDB 客户端，关闭函数在服务退出时由 shutdown 调用
+	closeMongo := db.MongoClient()
+
+	// 初始化令牌与登录失败记录存储（SESSION_STORE），使用 Redis 时不可用按退避重试
+	if err := Repository.InitSessionStores(context.Background()); err != nil {
+		fatal("会话存储初始化失败", err)
+	}
+
+	// 执行未完成的数据库结构迁移（分布式锁保证多实例只执行一次）
+	migrations.RunOnStartup()
+
+	// 按各集合的索引声明创建缺失索引，并输出与声明不一致的索引
+	collections.RunIndexesOnStartup()
 
 	// 初始化根管理员
-	initRootAdmin.InitRootAdmins()
+	// 未配置密码且不在终端中运行时打印一次性初始化链接，不会阻塞等待输入
+	if err := initRootAdmin.InitRootAdmins(); err != nil {
+		fatal("根管理员初始化失败", err)
+	}
 
 	// ================== 定时任务 ==================
 	// 每日零点执行一次 CheckExpiredCodes，处理过期邀请码
 	task.RunDaily("CheckExpiredCodes", InviteCodeService.CheckExpiredCodes)
 
+	// 续跑重启前未完成的邀请码批量生成任务
+	if err := InviteCodeService.ResumeGenerateJobs(); err != nil {
+		logger.Error("续跑邀请码批量生成任务失败", logging.Err(err))
+	}
+
+	// 收到 SIGINT/SIGTERM 时开始优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// ================== 配置热更新 ==================
+	// 收到 SIGHUP 或配置文件、.env 修改后热更新分页、锁定策略等可热更新的配置（见 config.Reload）
+	go config.Watch(ctx, time.Duration(config.Get().Reload.WatchSeconds)*time.Second)
+
 	// ================== 启动 HTTP 服务 ==================
-	// 启动 Gin HTTP 服务器，包括路由注册、swaggerResponse 和路由打印
-	routers.RunServer()
+	// 启动 Gin HTTP 服务器，包括路由注册、swaggerResponse 和路由打印；收到信号后等待进行中的请求结束再返回
+	serverErr := routers.RunServer(ctx)
+	stop()
+
+	// ================== 关闭 ==================
+	shutdown(closeMongo, closeTracing)
+	if serverErr != nil {
+		fatal("HTTP 服务异常退出", serverErr)
+	}
+}
+
+// shutdown 按依赖顺序释放资源：先停止定时任务与批量生成任务（任务中会访问数据库），再关闭 Redis 与 MongoDB，最后导出剩余的 span
+func shutdown(closeMongo func(), closeTracing func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Get().Server.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	if err := task.Stop(ctx); err != nil {
+		logger.Warn("等待定时任务结束超时", logging.Err(err))
+	}
+	if err := InviteCodeService.StopGenerateJobs(ctx); err != nil {
+		logger.Warn("等待批量生成任务结束超时", logging.Err(err))
+	}
+	if err := RedisService.Close(); err != nil {
+		logger.Error("关闭 Redis 失败", logging.Err(err))
+	}
+	closeMongo()
+	if err := closeTracing(ctx); err != nil {
+		logger.Warn("导出剩余 span 失败", logging.Err(err))
+	}
+}
 
+// fatal 记录错误并退出进程
+func fatal(msg string, err error) {
+	logger.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	AdminService "github.com/StephenChristianW/go-movies-open/services/System/Admin"
+	"os"
+	"strings"
+)
+
+var adminService AdminService.AdminInterface = &AdminService.AdminService{}
+
+func init() {
+	register(
+		command{Group: "admin", Name: "create", Summary: "创建管理员", Run: adminCreate},
+		command{Group: "admin", Name: "reset", Summary: "重置管理员密码并吊销其令牌", Sessions: true, Run: adminReset},
+		command{Group: "admin", Name: "ban", Summary: "禁用管理员并吊销其令牌", Sessions: true, Run: adminBan},
+		command{Group: "admin", Name: "unban", Summary: "启用已禁用的管理员", Run: adminUnban},
+	)
+}
+
+// passwordFlags 密码参数，-password 会留在 shell 历史中，脚本中建议使用 -password-stdin
+type passwordFlags struct {
+	password string
+	stdin    bool
+}
+
+func (p *passwordFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&p.password, "password", "", "密码")
+	fs.BoolVar(&p.stdin, "password-stdin", false, "从标准输入的第一行读取密码")
+}
+
+func (p *passwordFlags) read() (string, error) {
+	if p.stdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("读取密码失败: %w", err)
+		}
+		p.password = strings.TrimRight(line, "\r\n")
+	}
+	if p.password == "" {
+		return "", errors.New("请通过 -password 或 -password-stdin 提供密码")
+	}
+	return p.password, nil
+}
+
+func adminCreate(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("admin", "create", "-username NAME (-password PWD | -password-stdin) [-role ID]")
+	username := fs.String("username", "", "管理员用户名")
+	role := fs.String("role", "", "角色ID")
+	var pwd passwordFlags
+	pwd.bind(fs)
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	password, err := pwd.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := adminService.CreateAdmin(ctx, *username, password, *role); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("管理员 %s 已创建", *username)}, nil
+}
+
+func adminReset(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("admin", "reset", "-username NAME (-password PWD | -password-stdin)")
+	username := fs.String("username", "", "管理员用户名")
+	var pwd passwordFlags
+	pwd.bind(fs)
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	password, err := pwd.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := adminService.ChangeAdminPassword(ctx, *username, password); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("管理员 %s 的密码已重置", *username)}, nil
+}
+
+func adminBan(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("admin", "ban", "-username NAME")
+	username := fs.String("username", "", "管理员用户名")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	if err := adminService.BannedAdminUser(ctx, *username); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("管理员 %s 已禁用", *username)}, nil
+}
+
+func adminUnban(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("admin", "unban", "-username NAME")
+	username := fs.String("username", "", "管理员用户名")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	if err := adminService.ActiveAdminUser(ctx, *username); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("管理员 %s 已启用", *username)}, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/migrations"
+)
+
+func init() {
+	register(
+		command{Group: "migrate", Name: "up", Summary: "执行全部未完成的数据库迁移", Run: migrateUp},
+		command{Group: "migrate", Name: "down", Summary: "回滚最近的 N 个数据库迁移", Run: migrateDown},
+		command{Group: "migrate", Name: "status", Summary: "查看数据库迁移状态", Run: migrateStatus},
+		command{Group: "index", Name: "sync", Summary: "按声明创建缺失索引并报告差异", Run: indexSync},
+	)
+}
+
+// migrationNames 迁移的简要信息，Migration 含函数字段无法直接序列化
+func migrationNames(ms []migrations.Migration) []string {
+	names := make([]string, 0, len(ms))
+	for _, m := range ms {
+		names = append(names, fmt.Sprintf("%d %s", m.Version, m.Name))
+	}
+	return names
+}
+
+func migrateUp(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("migrate", "up", "")
+	_ = fs.Parse(args)
+
+	applied, err := migrations.Up(ctx)
+	if err != nil {
+		return &result{Data: migrationNames(applied)}, err
+	}
+	return &result{Msg: fmt.Sprintf("已执行 %d 个迁移", len(applied)), Data: migrationNames(applied)}, nil
+}
+
+func migrateDown(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("migrate", "down", "[-n STEPS]")
+	steps := fs.Int("n", 1, "回滚的迁移数量")
+	_ = fs.Parse(args)
+
+	reverted, err := migrations.Down(ctx, *steps)
+	if err != nil {
+		return &result{Data: migrationNames(reverted)}, err
+	}
+	return &result{Msg: fmt.Sprintf("已回滚 %d 个迁移", len(reverted)), Data: migrationNames(reverted)}, nil
+}
+
+func migrateStatus(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("migrate", "status", "")
+	_ = fs.Parse(args)
+
+	states, err := migrations.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, s := range states {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return &result{Msg: fmt.Sprintf("共 %d 个迁移，%d 个未执行", len(states), pending), Data: states}, nil
+}
+
+func indexSync(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("index", "sync", "")
+	_ = fs.Parse(args)
+
+	report, err := collections.ReconcileIndexes(ctx)
+	if err != nil {
+		return &result{Data: report}, err
+	}
+	msg := fmt.Sprintf("新建 %d 个、修改 %d 个索引", len(report.Created), len(report.Updated))
+	if len(report.Drift) > 0 {
+		msg += fmt.Sprintf("，%d 处差异需人工处理", len(report.Drift))
+	}
+	return &result{Msg: msg, Data: report}, nil
+}
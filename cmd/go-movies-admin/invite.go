@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	InviteCodeService "github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsExport"
+	"io"
+	"log"
+	"os"
+)
+
+// cliCreatedBy 命令行创建的批量生成任务的创建人
+const cliCreatedBy = "go-movies-admin"
+
+func init() {
+	register(
+		command{Group: "invite", Name: "generate", Summary: "批量生成邀请码（同步执行，Ctrl+C 取消）", Run: inviteGenerate},
+		command{Group: "invite", Name: "export", Summary: "导出邀请码为 CSV、XLSX 或 JSON Lines", Run: inviteExport},
+	)
+}
+
+func inviteGenerate(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("invite", "generate", "-n COUNT [-campaign ID] [-max-uses N]")
+	n := fs.Int("n", 0, "生成数量")
+	campaign := fs.String("campaign", "", "所属活动ID")
+	maxUses := fs.Int("max-uses", 1, "每个邀请码最大使用次数，-1 为不限")
+	_ = fs.Parse(args)
+	if *n <= 0 {
+		required(fs, map[string]string{"n": ""})
+	}
+
+	job, err := InviteCodeService.CreateInviteCode{}.RunGenerateJob(ctx, *n,
+		InviteCodeService.GenerateOptions{CampaignID: *campaign, MaxUses: *maxUses}, cliCreatedBy)
+	if err != nil {
+		return &result{Data: job}, err
+	}
+	return &result{Msg: fmt.Sprintf("已生成 %d 个邀请码", job.Inserted), Data: job}, nil
+}
+
+func inviteExport(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("invite", "export", "[-format csv|xlsx|json] [-out FILE] [-campaign ID] [-job ID]")
+	format := fs.String("format", UtilsExport.FormatCSV, "导出格式 csv / xlsx / json")
+	out := fs.String("out", "", "输出文件，默认标准输出")
+	campaign := fs.String("campaign", "", "按活动ID筛选")
+	job := fs.String("job", "", "按批量生成任务ID筛选")
+	_ = fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	filter := InviteCodeService.CreateInviteFilter{CampaignID: *campaign, JobID: *job}
+	count, err := writeInviteCodes(ctx, w, *format, filter)
+	if err != nil {
+		return nil, err
+	}
+	if *out == "" {
+		// 标准输出已被导出内容占用，结果写到日志
+		log.Printf("已导出 %d 个邀请码", count)
+		return nil, nil
+	}
+	return &result{Msg: fmt.Sprintf("已导出 %d 个邀请码到 %s", count, *out), Data: map[string]any{"count": count, "file": *out}}, nil
+}
+
+// writeInviteCodes 逐条写出邀请码，json 格式为每行一个对象（JSON Lines）
+func writeInviteCodes(ctx context.Context, w io.Writer, format string, filter InviteCodeService.CreateInviteFilter) (int, error) {
+	count := 0
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		err := InviteCodeService.CreateInviteCode{}.ExportInviteCodes(ctx, filter, func(code InviteCodeService.InviteCode) error {
+			count++
+			return enc.Encode(code)
+		})
+		return count, err
+	}
+
+	rw, err := UtilsExport.NewRowWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	if err := rw.WriteRow(InviteCodeService.ExportHeader); err != nil {
+		return 0, err
+	}
+	err = InviteCodeService.CreateInviteCode{}.ExportInviteCodes(ctx, filter, func(code InviteCodeService.InviteCode) error {
+		count++
+		return rw.WriteRow(InviteCodeService.ExportRow(code))
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, rw.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/security/IPManage"
+)
+
+func init() {
+	register(
+		command{Group: "ip", Name: "block", Summary: "封禁 IP", Run: ipBlock},
+		command{Group: "ip", Name: "unblock", Summary: "解封 IP", Run: ipUnblock},
+	)
+}
+
+func ipBlock(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("ip", "block", "-ip IP [-reason TEXT] [-username NAME]")
+	ip := fs.String("ip", "", "IP 地址")
+	reason := fs.String("reason", "管理员手动封禁", "封禁原因")
+	username := fs.String("username", "", "关联的用户名")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"ip": *ip})
+
+	if err := (IPManage.IPService{}).BlockIP(ctx, *username, *ip, *reason); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("IP %s 已封禁", *ip)}, nil
+}
+
+func ipUnblock(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("ip", "unblock", "-ip IP")
+	ip := fs.String("ip", "", "IP 地址")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"ip": *ip})
+
+	n, err := IPManage.IPService{}.UnblockIP(ctx, *ip)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("IP %s 未被封禁", *ip)
+	}
+	return &result{Msg: fmt.Sprintf("IP %s 已解封，删除 %d 条封禁记录", *ip, n), Data: map[string]int{"deleted": n}}, nil
+}
@@ -0,0 +1,187 @@
+// 运维命令行工具，直接调用服务层操作配置中的 MongoDB 与会话存储（SESSION_STORE）
+//
+// 用法：
+//
+//	go run ./cmd/go-movies-admin [-json] <分组> <命令> [参数]
+//	go run ./cmd/go-movies-admin help                 列出全部命令
+//	go run ./cmd/go-movies-admin <分组> <命令> -h     查看命令参数
+//
+// -json 时结果以与 HTTP 接口一致的 {"code","msg","data"} 输出到标准输出，失败时 code 为 -1；
+// 日志输出到标准错误。失败时退出码为 1，参数错误时为 2
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/services"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// result 命令执行结果，Data 在 -json 时原样输出，否则以 YAML 输出
+type result struct {
+	Msg  string
+	Data any
+}
+
+// command 一个子命令
+type command struct {
+	Group    string
+	Name     string
+	Summary  string
+	Sessions bool // 是否需要访问令牌与登录失败记录存储
+	// Run 执行命令，args 为命令名之后的参数；返回 nil 结果表示命令已自行输出
+	Run func(ctx context.Context, args []string) (*result, error)
+}
+
+// commands 全部子命令，按分组在各文件的 init 中注册
+var commands []command
+
+func register(cmds ...command) {
+	commands = append(commands, cmds...)
+}
+
+var jsonOutput bool
+
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "用法: go-movies-admin [-json] <分组> <命令> [参数]")
+	_, _ = fmt.Fprintln(os.Stderr)
+	sort.SliceStable(commands, func(i, j int) bool { return commands[i].Group < commands[j].Group })
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %s %s\t%s\n", c.Group, c.Name, c.Summary)
+	}
+	_ = w.Flush()
+	os.Exit(2)
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run 执行命令并返回退出码，保证延迟关闭连接在退出前执行
+func run() int {
+	global := flag.NewFlagSet("go-movies-admin", flag.ExitOnError)
+	global.BoolVar(&jsonOutput, "json", false, "以 JSON 输出结果")
+	global.Usage = usage
+	_ = global.Parse(os.Args[1:])
+	args := global.Args()
+	if len(args) < 2 {
+		usage()
+	}
+
+	cmd, ok := findCommand(args[0], args[1])
+	if !ok {
+		usage()
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Printf("配置错误:\n%v", err)
+		return 1
+	}
+	defer db.MongoClient()()
+
+	// Ctrl+C 时取消正在执行的命令，如批量生成邀请码
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if cmd.Sessions {
+		if err := Repository.InitSessionStores(ctx); err != nil {
+			log.Printf("会话存储初始化失败: %v", err)
+			return 1
+		}
+		defer RedisService.Close()
+	}
+
+	res, err := cmd.Run(ctx, args[2:])
+	output(res, err)
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func findCommand(group, name string) (command, bool) {
+	for _, c := range commands {
+		if c.Group == group && c.Name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// output 输出命令结果或错误
+func output(res *result, err error) {
+	if jsonOutput {
+		resp := services.Response{Code: -1}
+		if err != nil {
+			resp.Msg = err.Error()
+			if res != nil {
+				resp.Data = res.Data
+			}
+		} else if res != nil {
+			resp = services.Response{Code: 1, Msg: res.Msg, Data: res.Data}
+		} else {
+			return
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(resp)
+		return
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return
+	}
+	if res == nil {
+		return
+	}
+	fmt.Println(res.Msg)
+	if res.Data != nil {
+		// 通过 JSON 转换，使 YAML 输出的字段名与 -json 一致
+		var data any
+		b, _ := json.Marshal(res.Data)
+		_ = json.Unmarshal(b, &data)
+		out, _ := yaml.Marshal(data)
+		fmt.Print(string(out))
+	}
+}
+
+// newFlags 创建子命令参数解析器，-h 时输出参数说明
+func newFlags(group, name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(group+" "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "用法: go-movies-admin %s %s %s\n", group, name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// required 检查必填参数
+func required(fs *flag.FlagSet, values map[string]string) {
+	var missing []string
+	for name, v := range values {
+		if v == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		_, _ = fmt.Fprintf(os.Stderr, "缺少参数: %s\n", strings.Join(missing, ", "))
+		fs.Usage()
+		os.Exit(2)
+	}
+}
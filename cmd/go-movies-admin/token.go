@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func init() {
+	register(command{Group: "token", Name: "revoke", Summary: "吊销管理员或用户的访问令牌", Sessions: true, Run: tokenRevoke})
+}
+
+func tokenRevoke(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("token", "revoke", "(-admin NAME | -user NAME)")
+	admin := fs.String("admin", "", "管理员用户名")
+	user := fs.String("user", "", "用户名")
+	_ = fs.Parse(args)
+
+	switch {
+	case *admin != "" && *user != "":
+		return nil, errors.New("-admin 与 -user 只能指定一个")
+	case *admin != "":
+		if err := adminService.RevokeToken(ctx, *admin); err != nil {
+			return nil, err
+		}
+		return &result{Msg: fmt.Sprintf("管理员 %s 的令牌已吊销", *admin)}, nil
+	case *user != "":
+		if err := userService.RevokeToken(ctx, *user); err != nil {
+			return nil, err
+		}
+		return &result{Msg: fmt.Sprintf("用户 %s 的令牌已吊销", *user)}, nil
+	}
+	required(fs, map[string]string{"admin 或 -user": ""})
+	return nil, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	UserService "github.com/StephenChristianW/go-movies-open/services/User"
+)
+
+var userService UserService.UserInterface = &UserService.UserSchema{}
+
+func init() {
+	register(
+		command{Group: "user", Name: "get", Summary: "按用户名查询用户", Run: userGet},
+		command{Group: "user", Name: "ban", Summary: "禁用用户并吊销其令牌", Sessions: true, Run: userBan},
+	)
+}
+
+func userGet(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("user", "get", "-username NAME")
+	username := fs.String("username", "", "用户名")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	user, err := userService.GetUser(ctx, *username)
+	if err != nil {
+		return nil, err
+	}
+	return &result{Msg: "ok", Data: user}, nil
+}
+
+func userBan(ctx context.Context, args []string) (*result, error) {
+	fs := newFlags("user", "ban", "-username NAME")
+	username := fs.String("username", "", "用户名")
+	_ = fs.Parse(args)
+	required(fs, map[string]string{"username": *username})
+
+	if err := userService.BannedUser(ctx, *username); err != nil {
+		return nil, err
+	}
+	return &result{Msg: fmt.Sprintf("用户 %s 已禁用", *username)}, nil
+}
@@ -0,0 +1,84 @@
+// 数据库结构迁移命令
+//
+// 用法：
+//
+//	go run ./cmd/migrate up          执行全部未执行的迁移
+//	go run ./cmd/migrate down [-n N] 回滚最近执行的 N 个迁移（默认 1）
+//	go run ./cmd/migrate status      查看迁移状态
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/migrations"
+	"log"
+	"os"
+	"time"
+)
+
+// commandTimeout 单次命令（含等待迁移锁）的最长时间
+const commandTimeout = 30 * time.Minute
+
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "用法: migrate <up|down [-n N]|status>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("配置错误:\n%v", err)
+	}
+	defer db.MongoClient()()
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	switch os.Args[1] {
+	case "up":
+		done, err := migrations.Up(ctx)
+		printMigrations("已执行", done)
+		if err != nil {
+			log.Fatalf("迁移失败: %v", err)
+		}
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("n", 1, "回滚的迁移数量")
+		_ = fs.Parse(os.Args[2:])
+		done, err := migrations.Down(ctx, *steps)
+		printMigrations("已回滚", done)
+		if err != nil {
+			log.Fatalf("回滚失败: %v", err)
+		}
+	case "status":
+		states, err := migrations.Status(ctx)
+		if err != nil {
+			log.Fatalf("读取迁移状态失败: %v", err)
+		}
+		for _, s := range states {
+			appliedAt := "未执行"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-32s %s\n", s.Version, s.Name, appliedAt)
+		}
+	default:
+		usage()
+	}
+}
+
+// printMigrations 输出本次执行或回滚的迁移
+func printMigrations(action string, list []migrations.Migration) {
+	if len(list) == 0 {
+		fmt.Println("没有需要处理的迁移")
+		return
+	}
+	for _, m := range list {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+}
@@ -1,60 +1,103 @@
 package config
 
-// ======================= 全局环境常量 =======================
-// LOCAL: 本地开发环境
-// PROD: 生产环境
+import "time"
+
+// ======================= 内置环境 =======================
+// 可在配置文件 profiles 中定义更多环境，见 profiles.go
+const (
+	LOCAL   = "local"      // 本地开发环境
+	TEST    = "test"       // 测试环境，继承 local
+	STAGING = "staging"    // 预发布环境，继承 production
+	PROD    = "production" // 生产环境
+)
+
+// ======================= 会话存储常量 =======================
+// SESSION_STORE 可选值
+const (
+	SessionRedis  = "redis"  // Redis，多实例共享
+	SessionMongo  = "mongo"  // MongoDB，过期令牌由 TTL 索引清理
+	SessionMemory = "memory" // 进程内存储，仅适用于单实例部署，重启后失效
+)
+
+// ======================= 根管理员初始化方式 =======================
+// ROOT_ADMIN_BOOTSTRAP 可选值
 const (
-	LOCAL  = "local"
-	PROD   = "production"
-	envLog = "[ENV] " // 日志打印前缀
+	BootstrapAuto       = "auto"        // 依次使用配置的密码、密钥文件、哈希，终端中交互输入，否则生成一次性初始化链接
+	BootstrapSetupToken = "setup_token" // 忽略终端交互，未配置密码时一律生成一次性初始化链接
 )
 
 // ======================= 数据库集合常量 =======================
 // 用于统一管理 MongoDB 集合名称
 const (
-	InviteCodeColl = "invite_code"    // 邀请码集合，code字段为唯一索引
-	AdminColl      = "admin"          // 管理员集合
-	UserColl       = "user"           // 普通用户集合
-	BlockIps       = "block_ips"      // 被封禁 IP 集合
-	AdminRemember  = "admin_remember" // 管理员设备会话集合
+	InviteCodeColl = "invite_code"            // 邀请码集合，code字段为唯一索引（见 collections.declaredIndexes）
+	InviteCampaign = "invite_campaign"        // 邀请码活动集合（批次元数据与兑换配额）
+	InviteRedeem   = "invite_redemption"      // 邀请码兑换记录集合，每次注册兑换一条
+	InviteGenJob   = "invite_generate_job"    // 邀请码批量生成任务集合，记录进度以便重启后续跑
+	AdminColl      = "admin"                  // 管理员集合
+	UserColl       = "user"                   // 普通用户集合
+	BlockIps       = "block_ips"              // 被封禁 IP 集合
+	AdminRemember  = "admin_remember"         // 管理员设备会话集合
+	SchemaMigrate  = "schema_migrations"      // 已执行的数据库迁移记录集合
+	MigrateLock    = "schema_migrations_lock" // 迁移分布式锁集合，防止多实例同时迁移
+	SessionToken   = "session_token"          // 访问令牌集合，SESSION_STORE=mongo 时使用
+	FailedLogin    = "failed_login"           // 登录失败记录集合，SESSION_STORE=mongo 时使用
 )
 
+// 以下变量由 apply 按启动时的配置赋值，修改后需重启生效，字段含义见 Config；
+// 可热更新的配置通过 Get / Limits / Account / Lockout 等访问函数读取
 var (
-	// ======================= 服务端口 =======================
+	// ======================= 环境 & 服务端口 =======================
+
+	Env  string // 运行环境
+	Addr string // 服务监听地址
+	Port string // 服务监听端口
+
+	// ======================= 调试功能 =======================
+
+	DebugSwagger     bool // 注册 Swagger 文档路由
+	DebugPrintRoutes bool // 启动时打印已注册路由
+	DebugGin         bool // Gin 使用 debug 模式
 
-	Addr = getAddr() // 服务监听端口，默认从 ENV 获取
-	Port = getPort()
 	// ======================= JWT 配置 =======================
 
-	AdminSecret      = getenv("ADMIN_SECRET", "")   // 管理员访问令牌密钥
-	UserSecret       = getenv("USER_SECRET", "")    // 普通用户访问令牌密钥
-	AdminRSecret     = getenv("ADMIN_R_SECRET", "") // 管理员刷新令牌密钥
-	AdminExpiration  = getAdminExpirationDay()      // 管理员 token 过期天数
-	AdminRExpiration = getAdminRExpirationDay()     // 管理员记住密码 token 过期天数
-	UserExpiration   = getUserExpirationDay()       // 普通用户 token 过期天数
+	AdminSecret  string // 管理员访问令牌密钥
+	UserSecret   string // 普通用户访问令牌密钥
+	AdminRSecret string // 管理员刷新令牌密钥
 
 	// ======================= 数据库配置 =======================
 
-	DBName = getDBName() // MongoDB 数据库名称
-	DBUrl  = getDBUrl()  // MongoDB 连接 URL
+	DBName string // MongoDB 数据库名称
+	DBUrl  string // MongoDB 连接 URL
+
+	// ======================= 会话存储 =======================
+
+	SessionStore string // 访问令牌与登录失败记录存储: redis / mongo / memory（单机）
 
 	// ======================= Redis 配置 =======================
 
-	RedisAddr     = getRedisAddr()     // Redis 地址，格式 ip:port
-	RedisPassword = getRedisPassword() // Redis 密码
-	RedisDB       = getRedisDB()       // Redis 数据库编号
+	RedisAddr       string        // Redis 地址，格式 ip:port
+	RedisPassword   string        // Redis 密码
+	RedisDB         int           // Redis 数据库编号
+	RedisMode       string        // redis: 使用 Redis；memory: 单机模式，令牌与登录失败记录保存在进程内存
+	RedisMaxRetries int           // 命令失败重试次数，同时为启动时连接重试次数
+	RedisMinBackoff time.Duration // 重试最小退避时间
+	RedisMaxBackoff time.Duration // 重试最大退避时间
+
+	// ======================= 邀请码格式 =======================
+
+	InviteCodeLength       int    // 邀请码随机部分长度（不含前缀、分隔符与校验位）
+	InviteCodeAlphabet     string // 字符集: crockford / alnum / 自定义字符
+	InviteCodePrefix       string // 邀请码前缀，如 GM
+	InviteCodeGroup        int    // 每组字符数，组间以 - 分隔，0 不分组
+	InviteCodeChecksum     bool   // 是否追加校验位
+	InviteCodeAcceptLegacy bool   // 是否继续接受旧版 8 位字母数字邀请码
 
-	// ======================= 分页 & 批量操作配置 =======================
+	// ======================= 索引 =======================
 
-	MaxCreateBatchSize = getEnvInt("MAX_CREATE_BATCH_SIZE", 50000) // 最大一次批量创建数量
-	DefaultPageSize    = getEnvInt("DEFAULT_PAGE_SIZE", 20)        // 默认分页条数
-	MinPageSize        = getEnvInt("MIN_PAGE_SIZE", 10)            // 最小分页条数
-	MaxPageSize        = getEnvInt("MAX_PAGE_SIZE", 100)           // 最大分页条数
+	BlockIpTTLDays     int // 封禁 IP 记录保留天数，到期由 TTL 索引自动删除，0（默认）为永久保留
+	FailedLoginTTLDays int // SESSION_STORE=mongo 时登录失败记录自最后一次失败起保留天数，0 为永久保留（与 Redis 一致）
 
-	// ======================= 初始管理员 & 字段长度限制 =======================
+	// ======================= 初始管理员 =======================
 
-	RootAdmins       = getRootAdmins()                // 初始管理员列表，从 ENV 获取
-	LessLenAdminName = getEnvInt("LESS_LEN_ADMIN", 3) // 管理员用户名最小长度
-	LessLenUserName  = getEnvInt("LESS_LEN_USER", 3)  // 普通用户用户名最小长度
-	LessLenPwd       = getEnvInt("LESS_LEN_PWD", 6)   // 密码最小长度
+	RootAdmins map[string]struct{} // 初始管理员列表
 )
@@ -1,233 +1,154 @@
 package config
 
 import (
-	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
 	"github.com/StephenChristianW/go-movies-open/utils"
-	"github.com/joho/godotenv"
-	"log"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
-	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var once sync.Once
-var Env = getEnv()
+// snapshot 一次加载的结果，热更新时整体替换
+type snapshot struct {
+	cfg  Config
+	info LoadInfo
+}
 
-func ProdEnv() bool {
-	switch Env {
-	case PROD:
-		return true
-	case LOCAL:
-		return false
-	default:
-		panic("Unknown Environment: " + Env)
+// logger 配置加载与热更新日志
+var logger = logging.For("config")
+
+var (
+	loaded  atomic.Pointer[snapshot] // 当前生效的配置
+	loadDir string                   // 查找 .env 与配置文件的起始目录，热更新时沿用
+	loadErr error                    // 启动时加载过程中的错误，由 Validate 统一报告
+)
+
+// init 加载配置；出错时不 panic，已读取的字段照常生效，错误在启动时由 Validate 一次性报告
+func init() {
+	loadDir, _ = os.Getwd()
+	cfg, info, err := Load(os.LookupEnv, loadDir)
+	loadErr = err
+	loaded.Store(&snapshot{cfg: cfg, info: info})
+	apply(cfg)
+	file, dotenv := info.File, info.DotEnv
+	if file == "" {
+		file = "无"
+	}
+	if dotenv == "" {
+		dotenv = "无"
 	}
+	// 配置无效时保持默认日志配置，错误由 Validate 报告
+	_ = logging.Configure(os.Stderr, logging.Options{Format: cfg.Log.Format, Level: cfg.Log.Level, Levels: cfg.Log.Levels})
+	logger.Info("配置加载完成", "env", cfg.Env, "profiles", strings.Join(info.Profiles, " -> "),
+		"file", file, "dotenv", dotenv)
 }
 
-// ======================= 环境初始化 =======================
-
-func loadEnv() {
-	once.Do(func() {
-		cwd, _ := os.Getwd()
-		log.Println(envLog+"当前工作目录:", cwd)
-
-		// 尝试加载默认 .env
-		if err := godotenv.Load(".env"); err == nil {
-			log.Println(envLog + "环境配置文件加载成功")
-		} else {
-			altPath := cwd + "/.env"
-			if err := godotenv.Load(altPath); err == nil {
-				fmt.Println("从备用路径加载 .env 成功:", altPath)
-			} else {
-				fmt.Println("未找到 .env 文件，使用系统环境变量")
-			}
-		}
-
-		// 如果 ENV 为空，默认 local
-		if os.Getenv("ENV") == "" {
-			fmt.Println("ENV 未设置，默认使用 local")
-			_ = os.Setenv("ENV", LOCAL)
-		}
-		log.Println(envLog+"当前 ENV:", os.Getenv("ENV"))
-	})
-}
-
-// ======================= 获取环境变量 =======================
-func getPort() string {
-	loadEnv()
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	return port
+// Get 返回当前配置；可热更新的字段（见 Reload）应每次使用时通过 Get 或下列访问函数读取，不要缓存
+func Get() Config {
+	return loaded.Load().cfg
 }
-func getAddr() string {
-	loadEnv()
-	ip := os.Getenv("IP")
-	port := os.Getenv("PORT")
-	addr := os.Getenv("ADDR")
 
-	// 优先使用 ADDR
-	if addr != "" {
-		return addr
-	}
+// Limits 当前分页与批量操作上限
+func Limits() LimitsConfig {
+	return Get().Limits
+}
 
-	if port == "" {
-		port = "8080"
-	}
-	if ip == "" {
-		ip = "0.0.0.0"
-	}
-	return ip + ":" + port
+// Account 当前账号字段长度限制
+func Account() AccountConfig {
+	return Get().Account
 }
-func getenv(key, def string) string {
-	loadEnv()
-	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
-		return v
-	}
-	return def
+
+// Lockout 当前登录失败锁定策略
+func Lockout() LockoutConfig {
+	return Get().Lockout
 }
 
-func getEnvInt(key string, def int) int {
-	loadEnv()
-	val := strings.TrimSpace(os.Getenv(key))
-	if val == "" {
-		return def
-	}
-	i, err := strconv.Atoi(val)
-	if err != nil {
-		panic(fmt.Sprintf("环境变量 %s 格式错误: %v", key, err))
-	}
-	return i
+// Crash 当前崩溃报告配置
+func Crash() CrashConfig {
+	return Get().Crash
 }
 
-func getEnvList(key string) []string {
-	loadEnv()
-	val := strings.TrimSpace(os.Getenv(key))
-	if val == "" {
-		return []string{}
-	}
-	parts := strings.Split(val, ",")
-	for i := range parts {
-		parts[i] = strings.TrimSpace(parts[i])
-	}
-	return parts
+// AdminTokenTTL 管理员访问令牌有效期
+func AdminTokenTTL() time.Duration {
+	return days(Get().JWT.AdminExpirationDays)
 }
 
-// ======================= 配置获取函数 =======================
+// AdminRefreshTokenTTL 管理员刷新令牌（记住登录）有效期
+func AdminRefreshTokenTTL() time.Duration {
+	return days(Get().JWT.AdminRExpirationDays)
+}
 
-func getEnv() string {
-	loadEnv()
-	env := os.Getenv("ENV")
-	if env != LOCAL && env != PROD {
-		panic(fmt.Sprintf("未知 ENV=%s", env))
-	}
-	return env
+// UserTokenTTL 普通用户访问令牌有效期
+func UserTokenTTL() time.Duration {
+	return days(Get().JWT.UserExpirationDays)
 }
 
-func getDBName() string {
-	loadEnv()
-	if ProdEnv() {
-		dbName := os.Getenv("PROD_DB_NAME")
-		if dbName == "" {
-			panic("PROD_DB_NAME 未配置")
-		}
-		return dbName
-	}
-	dbName := os.Getenv("LOCAL_DB_NAME")
-	if dbName == "" {
-		panic("LOCAL_DB_NAME 未配置")
-	}
-	return dbName
+// OperationTimeout 单次业务操作的超时时间
+func OperationTimeout() time.Duration {
+	return seconds(Get().Timeouts.OperationSeconds)
 }
 
-func getDBUrl() string {
-	loadEnv()
-	if ProdEnv() {
-		url := os.Getenv("PROD_DB_URL")
-		if url == "" {
-			panic("PROD_DB_URL 未配置")
-		}
-		return url
-	}
-	url := os.Getenv("LOCAL_DB_URL")
-	if url == "" {
-		panic("LOCAL_DB_URL 未配置")
-	}
-	return url
+// ExportTimeout 导出等流式输出操作的超时时间
+func ExportTimeout() time.Duration {
+	return seconds(Get().Timeouts.ExportSeconds)
 }
 
-func getRedisAddr() string {
-	loadEnv()
-	if ProdEnv() {
-		return os.Getenv("PROD_REDIS_ADDR")
-	}
-	return os.Getenv("LOCAL_REDIS_ADDR")
+// JobChunkTimeout 批量生成任务中单块插入的超时时间
+func JobChunkTimeout() time.Duration {
+	return seconds(Get().Timeouts.JobChunkSeconds)
 }
 
-func getRedisPassword() string {
-	loadEnv()
-	if ProdEnv() {
-		return os.Getenv("PROD_REDIS_PASSWORD")
-	}
-	return os.Getenv("LOCAL_REDIS_PASSWORD")
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
 
-func getRedisDB() int {
-	if ProdEnv() {
-		return getEnvInt("PROD_REDIS_DB", 0)
-	}
-	return getEnvInt("LOCAL_REDIS_DB", 0)
-}
-func getAdminRExpirationDay() int64 {
-	loadEnv()
-	val := os.Getenv("ADMIN_R_EXPIRATION_DAY")
-	if val == "" {
-		log.Println("管理员 记住密码token 过期天数未配置, 使用默认值 30 天")
-		val = "30"
-	}
-	d, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		panic(err)
-	}
-	log.Println(envLog+"管理员 记住密码token  过期天数:", d)
-	return d
-}
-func getAdminExpirationDay() int64 {
-	loadEnv()
-	val := os.Getenv("ADMIN_EXPIRATION_DAY")
-	if val == "" {
-		log.Println("管理员 token 过期天数未配置, 使用默认值 5 天")
-		val = "5"
-	}
-	d, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		panic(err)
-	}
-	log.Println(envLog+"管理员 token 过期天数:", d)
-	return d
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
 }
 
-func getUserExpirationDay() int64 {
-	loadEnv()
-	val := os.Getenv("USER_EXPIRATION_DAY")
-	if val == "" {
-		log.Println("用户 token 过期天数未配置, 使用默认值 7 天")
-		val = "7"
-	}
-	d, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		panic(err)
-	}
-	log.Println(envLog+"用户 token 过期天数:", d)
-	return d
+// ProdEnv 当前环境是否为 production 或继承自 production
+func ProdEnv() bool {
+	return slices.Contains(Info().Profiles, PROD)
 }
 
-func getRootAdmins() map[string]struct{} {
-	loadEnv()
-	if ProdEnv() {
-		return utils.ListToMap(getEnvList("PROD_ROOT_ADMINS"))
-	}
-	return utils.ListToMap(getEnvList("LOCAL_ROOT_ADMINS"))
+// apply 将启动时的配置同步到包级变量；这些字段不可热更新，修改后需重启
+func apply(c Config) {
+	Env = c.Env
+	Addr = c.Server.ListenAddr()
+	Port = c.Server.ListenPort()
+
+	DebugSwagger = c.Debug.Swagger
+	DebugPrintRoutes = c.Debug.PrintRoutes
+	DebugGin = c.Debug.GinDebug
+
+	AdminSecret = c.JWT.AdminSecret
+	UserSecret = c.JWT.UserSecret
+	AdminRSecret = c.JWT.AdminRSecret
+
+	DBName = c.Mongo.DBName
+	DBUrl = c.Mongo.URL
+
+	SessionStore = c.Session.Store
+
+	RedisAddr = c.Redis.Addr
+	RedisPassword = c.Redis.Password
+	RedisDB = c.Redis.DB
+	RedisMode = c.Redis.Mode
+	RedisMaxRetries = c.Redis.MaxRetries
+	RedisMinBackoff = time.Duration(c.Redis.MinBackoffMs) * time.Millisecond
+	RedisMaxBackoff = time.Duration(c.Redis.MaxBackoffMs) * time.Millisecond
+
+	InviteCodeLength = c.Invite.CodeLength
+	InviteCodeAlphabet = c.Invite.Alphabet
+	InviteCodePrefix = c.Invite.Prefix
+	InviteCodeGroup = c.Invite.Group
+	InviteCodeChecksum = c.Invite.Checksum
+	InviteCodeAcceptLegacy = c.Invite.AcceptLegacy
+
+	BlockIpTTLDays = c.Retention.BlockIpTTLDays
+	FailedLoginTTLDays = c.Retention.FailedLoginTTLDays
+
+	RootAdmins = utils.ListToMap(c.Account.RootAdmins)
 }
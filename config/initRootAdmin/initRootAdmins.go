@@ -1,152 +1,182 @@
 package initRootAdmin
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/logging"
 	"github.com/StephenChristianW/go-movies-open/services/System/Admin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
-func InitRootAdmins() {
+var logger = logging.For("root")
+
+var adminService AdminService.AdminInterface = &AdminService.AdminService{}
+
+// InitRootAdmins 创建配置中尚不存在的根管理员（ROOT_ADMINS），密码来源按优先级：
+//  1. ROOT_ADMIN_PASSWORD 明文密码
+//  2. ROOT_ADMIN_PASSWORD_FILE 挂载的密钥文件；为目录时读取与用户名同名的文件（如 Kubernetes Secret 卷）
+//  3. ROOT_ADMIN_PASSWORD_HASH bcrypt 哈希
+//  4. 标准输入为终端时交互输入
+//  5. 以上均不可用（如容器中）或 ROOT_ADMIN_BOOTSTRAP=setup_token 时，打印一次性初始化链接，由首位管理员在浏览器中设置密码
+//
+// 不会阻塞等待输入；没有任何管理员且未生成初始化链接时返回错误
+func InitRootAdmins() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	var (
-		notCreated = make(map[string]string)
-		needOutput = false
-	)
-
-	// 检查是否需要输出（有需要创建的账户或错误）
+	account := config.Account()
+	var failed []string
 	for name := range config.RootAdmins {
-		if len(name) < config.LessLenAdminName {
-			notCreated[name] = fmt.Sprintf("用户名至少%d个字符", config.LessLenAdminName)
-			needOutput = true
+		if len(name) < account.MinAdminNameLen {
+			failed = append(failed, fmt.Sprintf("%s: 用户名至少%d个字符", name, account.MinAdminNameLen))
 			continue
 		}
-
-		if !adminExists(ctx, name) {
-			needOutput = true
+		exists, err := adminExists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := createRootAdmin(ctx, name, account); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
 		}
 	}
+	for _, msg := range failed {
+		logger.Error("根管理员创建失败", "detail", msg)
+	}
 
-	// 只有需要时才输出
-	if needOutput {
-		fmt.Println("==============================================")
-		fmt.Println("          开始初始化根管理员                ")
-		fmt.Println("==============================================")
-		fmt.Println()
-		time.Sleep(1 * time.Second)
+	count, err := collections.GetAdminCollection().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return err
 	}
+	if count == 0 && !AdminService.SetupPending() {
+		return errors.New("没有成功创建任何根管理员，请配置 ROOT_ADMINS 及 ROOT_ADMIN_PASSWORD / ROOT_ADMIN_PASSWORD_FILE / ROOT_ADMIN_PASSWORD_HASH")
+	}
+	return nil
+}
 
-	// 实际处理逻辑
-	for name := range config.RootAdmins {
-		if len(name) < config.LessLenAdminName {
-			if needOutput {
-				fmt.Printf("[ERROR] %s : 用户名至少%d个字符\n", name, config.LessLenAdminName)
-				time.Sleep(300 * time.Millisecond)
-			}
-			continue
-		}
+func adminExists(ctx context.Context, name string) (bool, error) {
+	err := collections.GetAdminCollection().FindOne(ctx, bson.M{"username": name}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-		if !adminExists(ctx, name) {
-			if needOutput {
-				fmt.Printf("[CREATE] %s", name)
-			}
-			createAdminWithPassword(name)
-			if needOutput {
-				fmt.Println(" : DONE")
-				time.Sleep(500 * time.Millisecond)
+// createRootAdmin 按优先级取得密码并创建根管理员
+func createRootAdmin(ctx context.Context, name string, account config.AccountConfig) error {
+	if account.RootPassword != "" {
+		return created(name, "ROOT_ADMIN_PASSWORD", adminService.CreateAdmin(ctx, name, account.RootPassword, ""))
+	}
+	if account.RootPasswordFile != "" {
+		pwd, ok, err := passwordFromFile(account.RootPasswordFile, name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if len(pwd) < account.MinPasswordLen {
+				return fmt.Errorf("密钥文件中的密码至少%d个字符", account.MinPasswordLen)
 			}
-		} else if needOutput {
-			fmt.Printf("[EXISTS] %s : 跳过创建\n", name)
-			time.Sleep(200 * time.Millisecond)
+			return created(name, "ROOT_ADMIN_PASSWORD_FILE", adminService.CreateAdmin(ctx, name, pwd, ""))
 		}
 	}
-
-	// 错误汇总
-	if len(notCreated) > 0 && needOutput {
-		time.Sleep(1 * time.Second)
-		fmt.Println()
-		fmt.Println("------------ 创建失败汇总 ------------")
-		for name, reason := range notCreated {
-			fmt.Printf("[FAILED] %s : %s\n", name, reason)
-			time.Sleep(500 * time.Millisecond)
+	if account.RootPasswordHash != "" {
+		return created(name, "ROOT_ADMIN_PASSWORD_HASH", adminService.CreateAdminWithHash(ctx, name, account.RootPasswordHash, ""))
+	}
+	if account.RootBootstrap == config.BootstrapAuto && isTerminal(os.Stdin) {
+		pwd, err := promptPassword(name, account.MinPasswordLen)
+		if err != nil {
+			return err
 		}
+		return created(name, "终端输入", adminService.CreateAdmin(ctx, name, pwd, ""))
 	}
+	return issueSetupLink(name)
+}
 
-	// 最终检查
-	count, err := collections.GetAdminCollection().CountDocuments(ctx, bson.M{})
-	if err != nil {
-		if needOutput {
-			fmt.Printf("\n[FATAL] %s\n", err.Error())
-			time.Sleep(1 * time.Second)
-		}
-		panic(err)
+func created(name, source string, err error) error {
+	if err == nil {
+		logger.Info("根管理员已创建", "admin", name, "source", source)
 	}
+	return err
+}
 
-	if count == 0 {
-		if needOutput {
-			fmt.Printf("\n[FATAL] 没有成功创建任何根管理员\n")
-			time.Sleep(1 * time.Second)
+// passwordFromFile 读取密钥文件中的密码，path 为目录时读取其中与用户名同名的文件，不存在时 ok 为 false
+func passwordFromFile(path, name string) (pwd string, ok bool, err error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if st.IsDir() {
+		path = filepath.Join(path, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
 		}
-		panic("错误: 没有成功创建任何根管理员")
 	}
-
-	// 只有有输出时才显示结束信息
-	if needOutput {
-		time.Sleep(1 * time.Second)
-		fmt.Println()
-		fmt.Println("==============================================")
-		fmt.Println("          根管理员初始化完成                ")
-		fmt.Println("==============================================")
-		fmt.Println()
-		time.Sleep(1 * time.Second)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
 	}
+	pwd = strings.TrimRight(string(data), "\r\n")
+	return pwd, pwd != "", nil
 }
-func adminExists(ctx context.Context, name string) bool {
-	result := collections.GetAdminCollection().FindOne(ctx, bson.M{"username": name})
-	if result.Err() == nil {
-		return true
-	}
 
-	if !errors.Is(result.Err(), mongo.ErrNoDocuments) {
-		panic(result.Err())
-	}
-	return false
+// isTerminal 文件是否为终端，容器与 systemd 中标准输入通常不是终端
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
 }
 
-func createAdminWithPassword(name string) {
-	fmt.Printf("\n正在创建初始管理员: %s\n", name)
+// promptPassword 在终端中交互输入密码，输入结束（EOF）时返回错误而不是无限等待
+func promptPassword(name string, minLen int) (string, error) {
+	in := bufio.NewScanner(os.Stdin)
 	for {
-		pwd := promptPassword(name)
-		if isValidPassword(pwd) {
-			createAdmins(name, pwd)
-			fmt.Printf("管理员 %s 创建成功!\n", name)
-			return
+		fmt.Printf("请输入根管理员 %s 的密码: ", name)
+		if !in.Scan() {
+			if err := in.Err(); err != nil {
+				return "", err
+			}
+			return "", io.ErrUnexpectedEOF
 		}
-		fmt.Printf("密码必须至少%d个字符，请重新输入\n", config.LessLenPwd)
+		if pwd := strings.TrimSpace(in.Text()); len(pwd) >= minLen {
+			return pwd, nil
+		}
+		fmt.Printf("密码必须至少%d个字符，请重新输入\n", minLen)
 	}
 }
 
-func promptPassword(name string) string {
-	fmt.Printf("请输入 %s 的密码: ", name)
-	var pwd string
-	_, _ = fmt.Scanf("%s\n", &pwd)
-	return pwd
+// issueSetupLink 生成一次性初始化令牌并打印初始化链接
+func issueSetupLink(name string) error {
+	token, err := AdminService.IssueSetupToken(name)
+	if err != nil {
+		return err
+	}
+	logger.Warn("请在有效期内访问初始化链接设置根管理员密码，链接仅可使用一次，重启服务后失效",
+		"admin", name, "ttl", AdminService.SetupTokenTTL.String(),
+		"url", baseURL()+AdminService.SetupPath+"?token="+token)
+	return nil
 }
 
-func isValidPassword(pwd string) bool {
-	return pwd != "" && len(pwd) >= config.LessLenPwd
-}
-func createAdmins(username string, password string) {
-	var adminService AdminService.AdminInterface = &AdminService.AdminService{}
-	err := adminService.CreateAdmin(username, password, "")
+// baseURL 服务的本机访问地址，监听所有地址时使用 localhost
+func baseURL() string {
+	scheme := config.Get().TLS.Scheme() + "://"
+	host, port, err := net.SplitHostPort(config.Addr)
 	if err != nil {
-		panic(err)
+		return scheme + config.Addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
 	}
+	return scheme + net.JoinHostPort(host, port)
 }
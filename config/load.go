@@ -0,0 +1,386 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 配置来源
+const (
+	SourceDefault = "默认值"
+	SourceFile    = "配置文件"
+	SourceDotEnv  = ".env"
+	SourceEnv     = "环境变量"
+)
+
+// configFileEnv 指定配置文件路径的环境变量，未设置时依次查找 config.yaml / config.yml / config.toml
+const configFileEnv = "CONFIG_FILE"
+
+var configFileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// LoadInfo 配置加载过程的记录，供 config check 输出
+type LoadInfo struct {
+	File     string            // 使用的配置文件，未使用时为空
+	DotEnv   string            // 使用的 .env 文件，未找到时为空
+	Profiles []string          // 环境继承链，从父到子，如 [production staging]
+	Sources  map[string]string // 字段路径 -> 来源，未记录的字段为默认值
+}
+
+// LookupFunc 读取环境变量，与 os.LookupEnv 一致
+type LookupFunc func(key string) (string, bool)
+
+// field 配置中的一个叶子字段
+type field struct {
+	Path    string // 配置文件中的路径，如 jwt.admin_secret
+	Env     string // 环境变量名
+	Profile bool   // 是否优先读取带环境前缀的变量
+	Secret  string // 脱敏方式，空为不脱敏
+	Reload  bool   // 是否可热更新
+	Value   reflect.Value
+}
+
+// fields 按声明顺序列出全部叶子字段，c 必须可寻址以便写入
+func fields(c *Config) []field {
+	var out []field
+	walkFields(reflect.ValueOf(c).Elem(), "", &out)
+	return out
+}
+
+func walkFields(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		path := sf.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			walkFields(v.Field(i), path, out)
+			continue
+		}
+		*out = append(*out, field{
+			Path:    path,
+			Env:     sf.Tag.Get("env"),
+			Profile: sf.Tag.Get("profile") == "true",
+			Secret:  sf.Tag.Get("secret"),
+			Reload:  sf.Tag.Get("reloadable") == "true",
+			Value:   v.Field(i),
+		})
+	}
+}
+
+// envKeys 字段对应的环境变量名，按优先级排列；chain 为环境继承链，从父到子
+func (f field) envKeys(chain []string) []string {
+	if !f.Profile {
+		return []string{f.Env}
+	}
+	keys := make([]string, 0, len(chain)+1)
+	for i := len(chain) - 1; i >= 0; i-- {
+		keys = append(keys, profilePrefix(chain[i])+f.Env)
+	}
+	return append(keys, f.Env)
+}
+
+// Load 按 默认值 < 内置环境 < 配置文件 < 配置文件中的环境 < .env < 环境变量 的优先级加载配置，
+// dir 为查找 .env 与配置文件的起始目录。读取或解析出错时继续加载其余字段，所有错误合并返回
+func Load(lookup LookupFunc, dir string) (Config, LoadInfo, error) {
+	cfg := Defaults()
+	info := LoadInfo{Sources: make(map[string]string)}
+	var errs []error
+
+	// .env 只读取不写入进程环境变量，优先级低于系统环境变量
+	dotenv := map[string]string{}
+	if path := findFile(dir, ".env"); path != "" {
+		m, err := godotenv.Read(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("读取 %s 失败: %w", path, err))
+		} else {
+			dotenv, info.DotEnv = m, path
+		}
+	}
+	// 按优先级从高到低排列的来源，空值视为未设置
+	sources := []struct {
+		name string
+		get  func(key string) string
+	}{
+		{SourceEnv, func(key string) string { v, _ := lookup(key); return strings.TrimSpace(v) }},
+		{SourceDotEnv, func(key string) string { return strings.TrimSpace(dotenv[key]) }},
+	}
+	// resolve 先按来源优先级、再按 keys 的顺序取第一个非空值，
+	// 使系统环境变量中的 X 优先于 .env 中带环境前缀的 PROD_X
+	resolve := func(keys ...string) (value, source, key string, ok bool) {
+		for _, src := range sources {
+			for _, key := range keys {
+				if v := src.get(key); v != "" {
+					return v, src.name, key, true
+				}
+			}
+		}
+		return "", "", "", false
+	}
+	get := func(key string) (value, source string, ok bool) {
+		value, source, _, ok = resolve(key)
+		return value, source, ok
+	}
+
+	// 配置文件
+	path, _, explicit := get(configFileEnv)
+	if !explicit {
+		for _, name := range configFileNames {
+			if path = findFile(dir, name); path != "" {
+				break
+			}
+		}
+	}
+	var raw map[string]interface{}
+	if path != "" {
+		m, err := readFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("配置文件 %s: %w", path, err))
+		} else {
+			raw, info.File = m, path
+		}
+	}
+	fileProfiles, err := splitProfiles(raw)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("配置文件 %s: %w", path, err))
+	}
+
+	// 环境名决定其余字段的取值，需最先确定：环境变量 > 配置文件 > 默认值
+	if v, ok := raw["env"].(string); ok && v != "" {
+		cfg.Env = v
+		info.Sources["env"] = SourceFile
+	}
+	if v, source, ok := get("ENV"); ok {
+		cfg.Env = v
+		info.Sources["env"] = source + " ENV"
+	}
+	env := cfg.Env
+	chain, err := resolveProfiles(env, fileProfiles)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	info.Profiles = chain
+
+	for _, name := range chain {
+		if p := builtinProfiles[name]; p.apply != nil {
+			applyTracked(&cfg, info.Sources, "环境 "+name, p.apply)
+		}
+	}
+	if raw != nil {
+		if err := overlay(&cfg, raw, info.Sources, SourceFile); err != nil {
+			errs = append(errs, fmt.Errorf("配置文件 %s: %w", path, err))
+		}
+	}
+	for _, name := range chain {
+		if m, ok := fileProfiles[name]; ok {
+			if err := overlay(&cfg, m, info.Sources, SourceFile+" profiles."+name); err != nil {
+				errs = append(errs, fmt.Errorf("配置文件 %s profiles.%s: %w", path, name, err))
+			}
+		}
+	}
+	cfg.Env = env
+
+	// .env 与环境变量
+	list := fields(&cfg)
+	known := map[string]struct{}{configFileEnv: {}}
+	for _, f := range list {
+		keys := f.envKeys(chain)
+		for _, key := range keys {
+			known[key] = struct{}{}
+		}
+		if f.Env == "ENV" {
+			continue
+		}
+		v, source, key, ok := resolve(keys...)
+		if !ok {
+			continue
+		}
+		if err := setString(f.Value, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q 格式错误: %v", key, v, err))
+		}
+		info.Sources[f.Path] = source + " " + key
+	}
+
+	// .env 中的未知键多为拼写错误或已废弃的配置，直接报错；其他环境的前缀变量不算未知
+	for key := range dotenv {
+		if _, ok := known[key]; ok || isOtherProfileKey(key, list) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s 中的 %s 不是有效的配置项", info.DotEnv, key))
+	}
+	return cfg, info, errors.Join(errs...)
+}
+
+// applyTracked 执行 fn 修改配置，取值发生变化的字段来源记为 source
+func applyTracked(cfg *Config, sources map[string]string, source string, fn func(*Config)) {
+	before := *cfg
+	old := fields(&before)
+	fn(cfg)
+	for i, f := range fields(cfg) {
+		if !reflect.DeepEqual(old[i].Value.Interface(), f.Value.Interface()) {
+			sources[f.Path] = source
+		}
+	}
+}
+
+// isOtherProfileKey key 是否为其他环境的带前缀变量，如 ENV=local 时的 PROD_DB_URL
+func isOtherProfileKey(key string, list []field) bool {
+	for _, f := range list {
+		if f.Profile && strings.HasSuffix(key, "_"+f.Env) && key != f.Env {
+			return true
+		}
+	}
+	return false
+}
+
+// setString 将环境变量的字符串值写入字段
+func setString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.New("应为整数")
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("应为数字")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("应为 true 或 false")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// 逗号分隔的字符串列表
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
+
+// readFile 按扩展名解析 YAML 或 TOML 配置文件
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		err = errors.New("不支持的配置文件格式，仅支持 .yaml / .yml / .toml")
+	}
+	return raw, err
+}
+
+// splitProfiles 从配置文件中取出 profiles，返回 环境名 -> 覆盖的配置
+func splitProfiles(raw map[string]interface{}) (map[string]map[string]interface{}, error) {
+	out := make(map[string]map[string]interface{})
+	v, ok := raw["profiles"]
+	if !ok {
+		return out, nil
+	}
+	delete(raw, "profiles")
+	profiles, ok := v.(map[string]interface{})
+	if !ok {
+		return out, errors.New("profiles 应为 环境名 -> 配置 的映射")
+	}
+	for name, p := range profiles {
+		m, ok := p.(map[string]interface{})
+		if !ok && p != nil {
+			return out, fmt.Errorf("profiles.%s 应为映射", name)
+		}
+		if _, ok := m["env"]; ok {
+			return out, fmt.Errorf("profiles.%s 中不能设置 env", name)
+		}
+		out[name] = m
+	}
+	return out, nil
+}
+
+// overlay 将配置文件中的一组配置写入 cfg，未知键视为错误；TOML 也经由 YAML 解码，两种格式的校验一致
+func overlay(cfg *Config, m map[string]interface{}, sources map[string]string, source string) error {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != profileExtendsKey {
+			values[k] = v
+		}
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	for _, f := range fields(cfg) {
+		if hasPath(values, f.Path) {
+			sources[f.Path] = source
+		}
+	}
+	return nil
+}
+
+// hasPath 字段路径是否在配置中出现
+func hasPath(m map[string]interface{}, path string) bool {
+	node := interface{}(m)
+	for _, key := range strings.Split(path, ".") {
+		next, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if node, ok = next[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// findFile 从 dir 逐级向上查找 name，直到遇到 go.mod 所在目录或文件系统根目录；
+// 在子目录中运行（如 go test）时可找到项目根目录的文件
+func findFile(dir, name string) string {
+	for dir != "" {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProject 在临时目录中写入 go.mod 与给定文件，避免向上查找到仓库自身的 .env
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module test\n"
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// lookupMap 以 map 模拟系统环境变量
+func lookupMap(env map[string]string) LookupFunc {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"config.yaml": "limits:\n  max_page_size: 200\n  min_page_size: 5\n  default_page_size: 15\n",
+		".env":        "MAX_PAGE_SIZE=300\nMIN_PAGE_SIZE=8\nLOCAL_DB_NAME=local_db\nDB_NAME=shared_db\n",
+	})
+	cfg, info, err := Load(lookupMap(map[string]string{"MAX_PAGE_SIZE": "400", "TRACING_SAMPLE_RATIO": "0.25"}), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Limits.MaxPageSize != 400 || info.Sources["limits.max_page_size"] != SourceEnv+" MAX_PAGE_SIZE" {
+		t.Errorf("环境变量应覆盖 .env 与配置文件，实际 %d (%s)", cfg.Limits.MaxPageSize, info.Sources["limits.max_page_size"])
+	}
+	if cfg.Limits.MinPageSize != 8 {
+		t.Errorf(".env 应覆盖配置文件，实际 %d", cfg.Limits.MinPageSize)
+	}
+	if cfg.Limits.DefaultPageSize != 15 || info.Sources["limits.default_page_size"] != SourceFile {
+		t.Errorf("配置文件应覆盖默认值，实际 %d", cfg.Limits.DefaultPageSize)
+	}
+	if cfg.Limits.MaxExportSize != Defaults().Limits.MaxExportSize {
+		t.Errorf("未配置的字段应取默认值，实际 %d", cfg.Limits.MaxExportSize)
+	}
+	if cfg.Mongo.DBName != "local_db" {
+		t.Errorf("带环境前缀的变量应优先，实际 %q", cfg.Mongo.DBName)
+	}
+	if cfg.Tracing.SampleRatio != 0.25 {
+		t.Errorf("应读取小数配置，实际 %v", cfg.Tracing.SampleRatio)
+	}
+	if info.File == "" || info.DotEnv == "" {
+		t.Errorf("应记录使用的配置文件与 .env: %+v", info)
+	}
+}
+
+// 来源优先级高于环境前缀：系统环境变量中的 X 应覆盖 .env 中的 PROD_X
+func TestLoadEnvBeatsDotEnvProfileKey(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		".env": "PROD_DB_NAME=dotenv_prod_db\nPROD_DB_URL=mongodb://dotenv:27017\n",
+	})
+	env := map[string]string{"ENV": PROD, "DB_NAME": "process_db"}
+	cfg, info, err := Load(lookupMap(env), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mongo.DBName != "process_db" || info.Sources["mongo.db_name"] != SourceEnv+" DB_NAME" {
+		t.Errorf("系统环境变量应优先于 .env 中的前缀变量，实际 %q (%s)", cfg.Mongo.DBName, info.Sources["mongo.db_name"])
+	}
+	if cfg.Mongo.URL != "mongodb://dotenv:27017" || info.Sources["mongo.url"] != SourceDotEnv+" PROD_DB_URL" {
+		t.Errorf("同一来源内带环境前缀的变量应优先，实际 %q (%s)", cfg.Mongo.URL, info.Sources["mongo.url"])
+	}
+}
+
+func TestLoadTOMLAndProfileFallback(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"settings.toml": "[account]\nroot_admins = [\"alice\", \"bob\"]\n",
+	})
+	env := map[string]string{
+		"CONFIG_FILE": filepath.Join(dir, "settings.toml"),
+		"ENV":         PROD,
+		"DB_NAME":     "shared_db",
+		"PROD_DB_URL": "mongodb://prod:27017",
+	}
+	cfg, _, err := Load(lookupMap(env), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Account.RootAdmins) != 2 || cfg.Account.RootAdmins[1] != "bob" {
+		t.Errorf("应读取 TOML 配置文件，实际 %v", cfg.Account.RootAdmins)
+	}
+	if cfg.Mongo.DBName != "shared_db" || cfg.Mongo.URL != "mongodb://prod:27017" {
+		t.Errorf("前缀变量缺失时应回退到无前缀变量: %+v", cfg.Mongo)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"config.yaml": "limits:\n  max_page_size: 50\n",
+		".env":        "PAGE_MIN_SIZE=10\nLESS_LEN_PWD=six\n",
+	})
+	_, _, err := Load(lookupMap(map[string]string{"INVITE_CODE_CHECKSUM": "maybe", "TRACING_SAMPLE_RATIO": "half"}), dir)
+	if err == nil {
+		t.Fatal("应返回错误")
+	}
+	for _, want := range []string{"PAGE_MIN_SIZE", "LESS_LEN_PWD", "INVITE_CODE_CHECKSUM", "TRACING_SAMPLE_RATIO"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息应包含 %s:\n%v", want, err)
+		}
+	}
+
+	bad := writeProject(t, map[string]string{"config.yaml": "limits:\n  max_page_sise: 50\n"})
+	if _, _, err := Load(lookupMap(nil), bad); err == nil || !strings.Contains(err.Error(), "max_page_sise") {
+		t.Errorf("配置文件中的未知键应报错，实际 %v", err)
+	}
+}
+
+func TestValidateCollectsErrors(t *testing.T) {
+	cfg := Defaults()
+	cfg.Limits.MinPageSize = 150
+	cfg.Session.Store = "etcd"
+	cfg.Timeouts.OperationSeconds = 0
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("应返回校验错误")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"jwt.admin_secret (ADMIN_SECRET)",
+		"mongo.url (DB_URL)",
+		"session.store (SESSION_STORE)",
+		"limits.max_page_size",
+		"limits.default_page_size",
+		"timeouts.operation_seconds (OPERATION_TIMEOUT_SECONDS)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("校验错误应包含 %s:\n%s", want, msg)
+		}
+	}
+}
+
+func TestFormatValueRedactsSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.JWT.AdminSecret = "top-secret"
+	cfg.Mongo.URL = "mongodb://root:hunter2@db:27017/app"
+	for _, f := range fields(&cfg) {
+		got := formatValue(f)
+		switch f.Path {
+		case "jwt.admin_secret":
+			if got != redactedMask {
+				t.Errorf("密钥应脱敏，实际 %q", got)
+			}
+		case "mongo.url":
+			if strings.Contains(got, "hunter2") || !strings.Contains(got, "root") {
+				t.Errorf("URL 只应隐藏密码，实际 %q", got)
+			}
+		}
+	}
+}
+
+func TestLoadProfileInheritance(t *testing.T) {
+	dir := writeProject(t, map[string]string{})
+	env := map[string]string{
+		"ENV":                STAGING,
+		"PROD_DB_URL":        "mongodb://prod:27017",
+		"STAGING_DB_NAME":    "staging_db",
+		"PROD_DB_NAME":       "prod_db",
+		"DEBUG_PRINT_ROUTES": "false",
+	}
+	cfg, info, err := Load(lookupMap(env), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(info.Profiles, ","); got != PROD+","+STAGING {
+		t.Errorf("继承链应为 production,staging，实际 %s", got)
+	}
+	if cfg.Mongo.URL != "mongodb://prod:27017" || cfg.Mongo.DBName != "staging_db" {
+		t.Errorf("应按继承链从子到父读取前缀变量: %+v", cfg.Mongo)
+	}
+	if !cfg.Debug.Swagger || cfg.Debug.GinDebug || cfg.Debug.PrintRoutes {
+		t.Errorf("staging 应开启 Swagger、继承 production 的 Gin release 模式，打印路由被环境变量关闭: %+v", cfg.Debug)
+	}
+	if info.Sources["debug.swagger"] != "环境 "+STAGING {
+		t.Errorf("内置环境的设置应记录来源，实际 %q", info.Sources["debug.swagger"])
+	}
+}
+
+func TestLoadFileProfiles(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"config.yaml": `env: perf
+limits:
+  max_page_size: 200
+profiles:
+  perf:
+    extends: staging
+    limits:
+      max_page_size: 500
+    debug:
+      swagger: false
+  test:
+    mongo:
+      db_name: ci_db
+`,
+	})
+	cfg, info, err := Load(lookupMap(map[string]string{"PERF_DB_URL": "mongodb://perf:27017"}), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(info.Profiles, ","); got != "production,staging,perf" {
+		t.Errorf("继承链应为 production,staging,perf，实际 %s", got)
+	}
+	if cfg.Limits.MaxPageSize != 500 || info.Sources["limits.max_page_size"] != SourceFile+" profiles.perf" {
+		t.Errorf("环境中的配置应覆盖顶层配置，实际 %d (%s)", cfg.Limits.MaxPageSize, info.Sources["limits.max_page_size"])
+	}
+	if cfg.Debug.Swagger || !cfg.Debug.PrintRoutes {
+		t.Errorf("自定义环境应可覆盖继承的调试开关: %+v", cfg.Debug)
+	}
+	if cfg.Mongo.URL != "mongodb://perf:27017" || cfg.Mongo.DBName != "" {
+		t.Errorf("未在继承链中的环境不应生效: %+v", cfg.Mongo)
+	}
+
+	// 覆盖内置环境时沿用其继承关系
+	cfg, info, err = Load(lookupMap(map[string]string{"ENV": TEST}), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(info.Profiles, ","); got != "local,test" || cfg.Mongo.DBName != "ci_db" || !cfg.Debug.Swagger {
+		t.Errorf("test 应继承 local 并应用配置文件中的设置: %v %+v", info.Profiles, cfg)
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	cases := map[string]struct {
+		file string
+		want string
+	}{
+		"未定义":       {"env: qa\n", `"qa"`},
+		"循环继承":      {"env: a\nprofiles:\n  a:\n    extends: b\n  b:\n    extends: a\n", "循环继承"},
+		"父环境未定义":    {"env: a\nprofiles:\n  a:\n    extends: nope\n", `"nope"`},
+		"环境中设置 env": {"env: a\nprofiles:\n  a:\n    env: local\n", "profiles.a"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := writeProject(t, map[string]string{"config.yaml": tc.file})
+			if _, _, err := Load(lookupMap(nil), dir); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("错误信息应包含 %s，实际 %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"regexp"
+	"strings"
+)
+
+// profile 内置环境，子环境先应用父环境的设置，再应用自身的设置
+type profile struct {
+	Extends string        // 父环境，空为不继承
+	apply   func(*Config) // 对默认值的覆盖，可为空
+}
+
+// builtinProfiles 内置环境；配置文件的 profiles 中可覆盖同名环境的设置或定义新环境
+var builtinProfiles = map[string]profile{
+	LOCAL: {apply: func(c *Config) {
+		c.Debug = DebugConfig{Swagger: true, GinDebug: true}
+		c.Log.Format = logging.FormatText
+	}},
+	TEST: {Extends: LOCAL},
+	PROD: {apply: func(c *Config) {
+		c.Debug = DebugConfig{PrintRoutes: true}
+	}},
+	STAGING: {Extends: PROD, apply: func(c *Config) {
+		c.Debug.Swagger = true
+	}},
+}
+
+// profileNamePattern 环境名，同时用作环境变量前缀
+var profileNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// profileExtendsKey 配置文件 profiles 中指定父环境的键
+const profileExtendsKey = "extends"
+
+// resolveProfiles 解析 name 的继承链，按从父到子排列；fileProfiles 为配置文件中的 profiles
+func resolveProfiles(name string, fileProfiles map[string]map[string]interface{}) ([]string, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for n := name; n != ""; {
+		if !profileNamePattern.MatchString(n) {
+			return nil, fmt.Errorf("环境名 %q 无效，只能包含小写字母、数字与下划线", n)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("环境 %s 存在循环继承: %s", name, strings.Join(append(chain, n), " <- "))
+		}
+		seen[n] = true
+		builtin, isBuiltin := builtinProfiles[n]
+		overlay, inFile := fileProfiles[n]
+		if !isBuiltin && !inFile {
+			return nil, fmt.Errorf("未定义的环境 %q，可选内置环境 %s / %s / %s / %s，或在配置文件 profiles 中定义",
+				n, LOCAL, TEST, STAGING, PROD)
+		}
+		chain = append([]string{n}, chain...)
+		next := builtin.Extends
+		if v, ok := overlay[profileExtendsKey]; ok {
+			s, isString := v.(string)
+			if !isString {
+				return nil, fmt.Errorf("profiles.%s.%s 应为环境名", n, profileExtendsKey)
+			}
+			next = s
+		}
+		n = next
+	}
+	return chain, nil
+}
+
+// profilePrefix 环境变量前缀，production 沿用 PROD_，其余为环境名大写
+func profilePrefix(name string) string {
+	if name == PROD {
+		return "PROD_"
+	}
+	return strings.ToUpper(name) + "_"
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Change 热更新时一项配置的变化，取值已脱敏
+type Change struct {
+	Path    string
+	Env     string
+	Old     string
+	New     string
+	Applied bool // false 表示该字段不可热更新，需重启生效
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+	if !c.Applied {
+		s += "（需重启生效）"
+	}
+	return s
+}
+
+// reloadMu 保证同一时刻只有一次热更新
+var reloadMu sync.Mutex
+
+// Reload 重新读取配置文件、.env 与环境变量，可热更新的字段（reloadable）原子地整体生效，
+// 其余字段的变化只记录不生效；新配置加载或校验失败时保持当前配置不变
+func Reload() ([]Change, error) {
+	return reload(os.LookupEnv, loadDir)
+}
+
+func reload(lookup LookupFunc, dir string) ([]Change, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, nextInfo, err := Load(lookup, dir)
+	if err == nil {
+		err = validate(next)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	old := loaded.Load()
+	merged := old.cfg
+	sources := make(map[string]string, len(old.info.Sources))
+	for k, v := range old.info.Sources {
+		sources[k] = v
+	}
+	var changes []Change
+	oldFields, nextFields, mergedFields := fields(&old.cfg), fields(&next), fields(&merged)
+	for i, f := range oldFields {
+		n := nextFields[i]
+		if reflect.DeepEqual(f.Value.Interface(), n.Value.Interface()) {
+			continue
+		}
+		changes = append(changes, Change{Path: f.Path, Env: f.Env, Old: formatValue(f), New: formatValue(n), Applied: f.Reload})
+		if f.Reload {
+			mergedFields[i].Value.Set(n.Value)
+			sources[f.Path] = nextInfo.Sources[f.Path]
+		}
+	}
+
+	// 组合后的配置仍需整体有效，如 min_page_size 可热更新而相关字段未生效时
+	if err := validate(merged); err != nil {
+		return changes, fmt.Errorf("热更新后的配置无效，保持当前配置: %w", err)
+	}
+	info := old.info
+	info.Sources = sources
+	loaded.Store(&snapshot{cfg: merged, info: info})
+	_ = logging.SetLevels(merged.Log.Level, merged.Log.Levels)
+	return changes, nil
+}
+
+// Watch 收到 SIGHUP 或配置文件、.env 的修改时间变化时热更新配置并记录变化，直到 ctx 取消；
+// interval 不大于 0 时只响应 SIGHUP
+func Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	stamp := fileStamp()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			stamp = fileStamp()
+			reloadAndLog("SIGHUP")
+		case <-tick:
+			if s := fileStamp(); s != stamp {
+				stamp = s
+				reloadAndLog("文件修改")
+			}
+		}
+	}
+}
+
+// fileStamp 当前使用的配置文件与 .env 的修改时间与大小，用于判断是否修改
+func fileStamp() string {
+	info := Info()
+	var stamp string
+	for _, path := range []string{info.File, info.DotEnv} {
+		if path == "" {
+			continue
+		}
+		if st, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%s:%d:%d;", path, st.ModTime().UnixNano(), st.Size())
+		}
+	}
+	return stamp
+}
+
+func reloadAndLog(trigger string) {
+	changes, err := Reload()
+	if err != nil {
+		logger.Error("配置热更新失败，保持当前配置", "trigger", trigger, logging.Err(err))
+		return
+	}
+	if len(changes) == 0 {
+		logger.Info("配置热更新: 无变化", "trigger", trigger)
+		return
+	}
+	for _, c := range changes {
+		logger.Info("配置热更新", "trigger", trigger, "path", c.Path, "old", c.Old, "new", c.New, "applied", c.Applied)
+	}
+}
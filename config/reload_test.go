@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useConfig 以 cfg 作为当前配置，测试结束后恢复
+func useConfig(t *testing.T, cfg Config, info LoadInfo) {
+	t.Helper()
+	prev := loaded.Load()
+	loaded.Store(&snapshot{cfg: cfg, info: info})
+	t.Cleanup(func() { loaded.Store(prev) })
+}
+
+func TestReloadAppliesOnlyReloadableFields(t *testing.T) {
+	env := map[string]string{"DB_URL": "mongodb://a:27017", "DB_NAME": "app",
+		"ADMIN_SECRET": "a", "USER_SECRET": "u", "ADMIN_R_SECRET": "r", "SESSION_STORE": SessionMemory}
+	dir := writeProject(t, map[string]string{"config.yaml": "limits:\n  max_page_size: 100\n"})
+	cfg, info, err := Load(lookupMap(env), dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	useConfig(t, cfg, info)
+
+	yaml := "limits:\n  max_page_size: 300\nlockout:\n  steps: 2:10s\n  freeze_after: 4\nredis:\n  max_retries: 9\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := reload(lookupMap(env), dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	applied := map[string]bool{}
+	for _, c := range changes {
+		applied[c.Path] = c.Applied
+	}
+	if !applied["limits.max_page_size"] || !applied["lockout.steps"] {
+		t.Errorf("可热更新的字段应生效: %v", changes)
+	}
+	if a, ok := applied["redis.max_retries"]; !ok || a {
+		t.Errorf("不可热更新的字段应记录变化但不生效: %v", changes)
+	}
+	got := Get()
+	if got.Limits.MaxPageSize != 300 || got.Redis.MaxRetries != Defaults().Redis.MaxRetries {
+		t.Errorf("热更新后配置错误: limits=%+v redis=%+v", got.Limits, got.Redis)
+	}
+	if d, ok := Lockout().Duration(2); !ok || d.Seconds() != 10 {
+		t.Errorf("锁定策略应热更新，实际 %v %v", d, ok)
+	}
+	if Info().Sources["limits.max_page_size"] != SourceFile {
+		t.Errorf("来源应随热更新更新，实际 %q", Info().Sources["limits.max_page_size"])
+	}
+}
+
+func TestReloadKeepsCurrentOnError(t *testing.T) {
+	cfg := Defaults()
+	useConfig(t, cfg, LoadInfo{Sources: map[string]string{}})
+	dir := writeProject(t, map[string]string{"config.yaml": "lockout:\n  steps: three:1m\n"})
+	if _, err := reload(lookupMap(nil), dir); err == nil || !strings.Contains(err.Error(), "lockout.steps") {
+		t.Errorf("新配置无效时应返回错误，实际 %v", err)
+	}
+	if Get().Lockout != cfg.Lockout {
+		t.Errorf("新配置无效时应保持当前配置，实际 %+v", Get().Lockout)
+	}
+}
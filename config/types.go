@@ -0,0 +1,325 @@
+package config
+
+import (
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 全部配置项
+//
+// 加载优先级（后者覆盖前者）：默认值 < 内置环境 < 配置文件 < 配置文件中的环境（profiles）< .env < 环境变量，
+// 环境按继承链从父到子依次应用，见 profiles.go。
+// 字段标签说明：
+//   - yaml: 配置文件中的键名（TOML 使用相同键名）
+//   - env: 对应的环境变量名（.env 与系统环境变量共用）
+//   - profile: 为 true 时优先读取带环境前缀的变量，按继承链从子到父，
+//     如 ENV=staging 时依次读取 STAGING_DB_URL、PROD_DB_URL、DB_URL
+//   - secret: 敏感字段，config check 输出时脱敏；值为 url 时只隐藏 URL 中的密码
+//   - reloadable: 为 true 时可热更新，见 Reload；其余字段修改后需重启
+type Config struct {
+	Env       string          `yaml:"env" env:"ENV"` // 运行环境（profile）名称，见 profiles.go
+	Server    ServerConfig    `yaml:"server"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Debug     DebugConfig     `yaml:"debug"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Mongo     MongoConfig     `yaml:"mongo"`
+	Session   SessionConfig   `yaml:"session"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Invite    InviteConfig    `yaml:"invite"`
+	Retention RetentionConfig `yaml:"retention"`
+	Account   AccountConfig   `yaml:"account"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	Reload    ReloadConfig    `yaml:"reload"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Timeouts  TimeoutConfig   `yaml:"timeouts"`
+	Crash     CrashConfig     `yaml:"crash"`
+}
+
+// ServerConfig 服务监听
+type ServerConfig struct {
+	Addr string `yaml:"addr" env:"ADDR"` // 监听地址 ip:port，设置后忽略 IP 与 PORT
+	IP   string `yaml:"ip" env:"IP"`     // 监听 IP
+	Port int    `yaml:"port" env:"PORT"` // 监听端口
+
+	ReadHeaderTimeoutSeconds int `yaml:"read_header_timeout_seconds" env:"SERVER_READ_HEADER_TIMEOUT_SECONDS"` // 读取请求头超时秒数
+	ReadTimeoutSeconds       int `yaml:"read_timeout_seconds" env:"SERVER_READ_TIMEOUT_SECONDS"`               // 读取整个请求超时秒数，0 不限制
+	WriteTimeoutSeconds      int `yaml:"write_timeout_seconds" env:"SERVER_WRITE_TIMEOUT_SECONDS"`             // 写响应超时秒数，需覆盖邀请码导出等流式响应，0 不限制
+	IdleTimeoutSeconds       int `yaml:"idle_timeout_seconds" env:"SERVER_IDLE_TIMEOUT_SECONDS"`               // keep-alive 空闲连接超时秒数
+	ShutdownTimeoutSeconds   int `yaml:"shutdown_timeout_seconds" env:"SERVER_SHUTDOWN_TIMEOUT_SECONDS"`       // 收到 SIGINT/SIGTERM 后等待进行中请求与定时任务结束的最长秒数
+	DrainDelaySeconds        int `yaml:"drain_delay_seconds" env:"SERVER_DRAIN_DELAY_SECONDS"`                 // 收到信号后 /readyz 先返回未就绪并继续接收请求的秒数，便于负载均衡摘除实例
+
+	H2C bool `yaml:"h2c" env:"SERVER_H2C"` // 未启用 TLS 时允许明文 HTTP/2（h2c），用于反向代理以 HTTP/2 转发到本服务
+}
+
+// ListenAddr 实际监听地址
+func (s ServerConfig) ListenAddr() string {
+	if s.Addr != "" {
+		return s.Addr
+	}
+	return net.JoinHostPort(s.IP, strconv.Itoa(s.Port))
+}
+
+// Timeout 将秒数转换为超时时长
+func (s ServerConfig) Timeout(seconds int) time.Duration {
+	return time.Duration(seconds) * time.Second
+}
+
+// ListenPort 实际监听端口
+func (s ServerConfig) ListenPort() string {
+	if _, port, err := net.SplitHostPort(s.ListenAddr()); err == nil {
+		return port
+	}
+	return strconv.Itoa(s.Port)
+}
+
+// TLSConfig HTTPS 证书，证书与私钥文件修改后按 reload.watch_seconds 自动重新加载
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" env:"TLS_CERT_FILE" profile:"true"`           // 证书文件（PEM，可含中间证书链）
+	KeyFile      string `yaml:"key_file" env:"TLS_KEY_FILE" profile:"true"`             // 私钥文件（PEM）
+	SelfSigned   bool   `yaml:"self_signed" env:"TLS_SELF_SIGNED" profile:"true"`       // 未配置证书时启动时生成自签名证书，仅用于本地开发
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" profile:"true"` // 管理端路由要求客户端证书（mTLS）时用于校验的 CA 证书，修改后需重启
+}
+
+// Enabled 是否以 HTTPS 提供服务
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// Scheme 服务对外的 URL 协议
+func (t TLSConfig) Scheme() string {
+	if t.Enabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// DebugConfig 调试功能，默认值由环境决定
+type DebugConfig struct {
+	Swagger     bool `yaml:"swagger" env:"DEBUG_SWAGGER" profile:"true"`           // 注册 Swagger 文档路由
+	PrintRoutes bool `yaml:"print_routes" env:"DEBUG_PRINT_ROUTES" profile:"true"` // 启动时打印已注册路由
+	GinDebug    bool `yaml:"gin_debug" env:"DEBUG_GIN" profile:"true"`             // Gin 使用 debug 模式
+}
+
+// JWTConfig 令牌签名与有效期
+type JWTConfig struct {
+	AdminSecret          string `yaml:"admin_secret" env:"ADMIN_SECRET" secret:"true"`     // 管理员访问令牌密钥
+	UserSecret           string `yaml:"user_secret" env:"USER_SECRET" secret:"true"`       // 普通用户访问令牌密钥
+	AdminRSecret         string `yaml:"admin_r_secret" env:"ADMIN_R_SECRET" secret:"true"` // 管理员刷新令牌密钥
+	AdminExpirationDays  int    `yaml:"admin_expiration_days" env:"ADMIN_EXPIRATION_DAY" reloadable:"true"`
+	AdminRExpirationDays int    `yaml:"admin_r_expiration_days" env:"ADMIN_R_EXPIRATION_DAY" reloadable:"true"`
+	UserExpirationDays   int    `yaml:"user_expiration_days" env:"USER_EXPIRATION_DAY" reloadable:"true"`
+}
+
+// MongoConfig MongoDB 连接
+type MongoConfig struct {
+	URL    string `yaml:"url" env:"DB_URL" profile:"true" secret:"url"`
+	DBName string `yaml:"db_name" env:"DB_NAME" profile:"true"`
+}
+
+// SessionConfig 访问令牌与登录失败记录存储
+type SessionConfig struct {
+	Store string `yaml:"store" env:"SESSION_STORE"` // redis / mongo / memory
+}
+
+// RedisConfig Redis 连接
+type RedisConfig struct {
+	Addr         string `yaml:"addr" env:"REDIS_ADDR" profile:"true"`
+	Password     string `yaml:"password" env:"REDIS_PASSWORD" profile:"true" secret:"true"`
+	DB           int    `yaml:"db" env:"REDIS_DB" profile:"true"`
+	Mode         string `yaml:"mode" env:"REDIS_MODE"` // redis / memory
+	MaxRetries   int    `yaml:"max_retries" env:"REDIS_MAX_RETRIES"`
+	MinBackoffMs int    `yaml:"min_backoff_ms" env:"REDIS_MIN_BACKOFF_MS"`
+	MaxBackoffMs int    `yaml:"max_backoff_ms" env:"REDIS_MAX_BACKOFF_MS"`
+}
+
+// LimitsConfig 分页与批量操作上限
+type LimitsConfig struct {
+	MaxCreateBatchSize int `yaml:"max_create_batch_size" env:"MAX_CREATE_BATCH_SIZE" reloadable:"true"`
+	DefaultPageSize    int `yaml:"default_page_size" env:"DEFAULT_PAGE_SIZE" reloadable:"true"`
+	MinPageSize        int `yaml:"min_page_size" env:"MIN_PAGE_SIZE" reloadable:"true"`
+	MaxPageSize        int `yaml:"max_page_size" env:"MAX_PAGE_SIZE" reloadable:"true"`
+	MaxExportSize      int `yaml:"max_export_size" env:"MAX_EXPORT_SIZE" reloadable:"true"`
+	MaxQRSheetSize     int `yaml:"max_qr_sheet_size" env:"MAX_QR_SHEET_SIZE" reloadable:"true"`
+}
+
+// InviteConfig 邀请码格式
+type InviteConfig struct {
+	CodeLength   int    `yaml:"code_length" env:"INVITE_CODE_LENGTH"`
+	Alphabet     string `yaml:"alphabet" env:"INVITE_CODE_ALPHABET"` // crockford / alnum / 自定义字符
+	Prefix       string `yaml:"prefix" env:"INVITE_CODE_PREFIX"`
+	Group        int    `yaml:"group" env:"INVITE_CODE_GROUP"`
+	Checksum     bool   `yaml:"checksum" env:"INVITE_CODE_CHECKSUM"`
+	AcceptLegacy bool   `yaml:"accept_legacy" env:"INVITE_CODE_ACCEPT_LEGACY"`
+	RegisterURL  string `yaml:"register_url" env:"INVITE_REGISTER_URL" reloadable:"true"`
+}
+
+// RetentionConfig TTL 索引保留天数
+type RetentionConfig struct {
+	BlockIpTTLDays     int `yaml:"block_ip_ttl_days" env:"BLOCK_IP_TTL_DAYS"`
+	FailedLoginTTLDays int `yaml:"failed_login_ttl_days" env:"FAILED_LOGIN_TTL_DAYS"`
+}
+
+// AccountConfig 初始管理员与账号字段长度限制
+//
+// 尚未创建的根管理员按 RootPassword > RootPasswordFile > RootPasswordHash > 终端交互输入 > 一次性初始化链接
+// 的顺序取得密码，见 initRootAdmin.InitRootAdmins
+type AccountConfig struct {
+	RootAdmins       []string `yaml:"root_admins" env:"ROOT_ADMINS" profile:"true"` // 环境变量中以逗号分隔
+	RootPassword     string   `yaml:"root_password" env:"ROOT_ADMIN_PASSWORD" profile:"true" secret:"true"`
+	RootPasswordFile string   `yaml:"root_password_file" env:"ROOT_ADMIN_PASSWORD_FILE" profile:"true"`               // 挂载的密钥文件；为目录时读取与用户名同名的文件
+	RootPasswordHash string   `yaml:"root_password_hash" env:"ROOT_ADMIN_PASSWORD_HASH" profile:"true" secret:"true"` // bcrypt 哈希
+	RootBootstrap    string   `yaml:"root_bootstrap" env:"ROOT_ADMIN_BOOTSTRAP"`                                      // auto / setup_token
+	MinAdminNameLen  int      `yaml:"min_admin_name_len" env:"LESS_LEN_ADMIN" reloadable:"true"`
+	MinUserNameLen   int      `yaml:"min_user_name_len" env:"LESS_LEN_USER" reloadable:"true"`
+	MinPasswordLen   int      `yaml:"min_password_len" env:"LESS_LEN_PWD" reloadable:"true"`
+}
+
+// LockoutConfig 普通用户登录失败锁定策略
+type LockoutConfig struct {
+	// 失败次数:锁定时长，逗号分隔，如 3:1m,5:5m 表示失败 3 次锁定 1 分钟、失败 5 次锁定 5 分钟
+	Steps string `yaml:"steps" env:"LOGIN_LOCKOUT_STEPS" reloadable:"true"`
+	// 失败达到该次数后冻结登录，直到重置密码；0 不冻结
+	FreezeAfter int `yaml:"freeze_after" env:"LOGIN_FREEZE_AFTER" reloadable:"true"`
+}
+
+// Duration 失败 count 次时的锁定时长，ok 为 false 表示该次数不锁定；Steps 格式错误时不锁定，由 Validate 报告
+func (l LockoutConfig) Duration(count int) (d time.Duration, ok bool) {
+	steps, _ := l.steps()
+	d, ok = steps[count]
+	return d, ok
+}
+
+// MaxStep 锁定策略中最大的失败次数，未配置锁定时为 0
+func (l LockoutConfig) MaxStep() int {
+	steps, _ := l.steps()
+	var max int
+	for count := range steps {
+		if count > max {
+			max = count
+		}
+	}
+	return max
+}
+
+// steps 解析 Steps 为 失败次数 -> 锁定时长
+func (l LockoutConfig) steps() (map[int]time.Duration, error) {
+	out := make(map[int]time.Duration)
+	for _, item := range strings.Split(l.Steps, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		count, duration, found := strings.Cut(item, ":")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !found || err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q 应为 失败次数:锁定时长，如 3:1m", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q 的锁定时长无效，如 30s / 5m / 24h", item)
+		}
+		out[n] = d
+	}
+	return out, nil
+}
+
+// ReloadConfig 配置热更新
+type ReloadConfig struct {
+	WatchSeconds int `yaml:"watch_seconds" env:"CONFIG_WATCH_SECONDS"` // 检查配置文件与 .env 是否修改的间隔秒数，0 只响应 SIGHUP
+}
+
+// MetricsConfig Prometheus 指标
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" env:"METRICS_ENABLED"` // 记录 HTTP 请求指标并暴露 /metrics
+}
+
+// LogConfig 日志输出，级别可热更新
+type LogConfig struct {
+	Format string `yaml:"format" env:"LOG_FORMAT" profile:"true"`                 // 输出格式: json / text
+	Level  string `yaml:"level" env:"LOG_LEVEL" profile:"true" reloadable:"true"` // 默认级别: debug / info / warn / error
+	Levels string `yaml:"levels" env:"LOG_LEVELS" reloadable:"true"`              // 按组件覆盖级别，如 db=debug,task=warn
+}
+
+// TracingConfig OpenTelemetry 链路追踪，通过 OTLP/HTTP 导出
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" env:"TRACING_ENABLED" profile:"true"`              // 启用链路追踪
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" profile:"true"` // OTLP/HTTP 地址，如 http://localhost:4318
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`                      // 上报的服务名
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" profile:"true"`    // 采样比例 0-1，上游已采样的请求始终采样
+}
+
+// TimeoutConfig 单次操作的超时时间，在请求 context 的基础上附加，客户端断开时同样提前结束
+type TimeoutConfig struct {
+	OperationSeconds int `yaml:"operation_seconds" env:"OPERATION_TIMEOUT_SECONDS" reloadable:"true"` // 单次业务操作（数据库与令牌存储读写）
+	ExportSeconds    int `yaml:"export_seconds" env:"EXPORT_TIMEOUT_SECONDS" reloadable:"true"`       // 导出邀请码等流式输出的操作
+	JobChunkSeconds  int `yaml:"job_chunk_seconds" env:"JOB_CHUNK_TIMEOUT_SECONDS" reloadable:"true"` // 后台批量生成任务中单块插入
+}
+
+// CrashConfig panic 崩溃报告，每次 panic 写入一个文件，同时输出到日志
+type CrashConfig struct {
+	Dir      string `yaml:"dir" env:"CRASH_REPORT_DIR" reloadable:"true"`             // 崩溃报告目录，为空时只输出到日志
+	MaxFiles int    `yaml:"max_files" env:"CRASH_REPORT_MAX_FILES" reloadable:"true"` // 保留的报告数量，超出时删除最旧的
+}
+
+// Defaults 默认配置，未在任何来源中出现的字段取此值
+func Defaults() Config {
+	return Config{
+		Env: LOCAL,
+		Server: ServerConfig{
+			IP:                       "0.0.0.0",
+			Port:                     8080,
+			ReadHeaderTimeoutSeconds: 10,
+			ReadTimeoutSeconds:       30,
+			WriteTimeoutSeconds:      360,
+			IdleTimeoutSeconds:       120,
+			ShutdownTimeoutSeconds:   30,
+		},
+		JWT: JWTConfig{
+			AdminExpirationDays:  5,
+			AdminRExpirationDays: 30,
+			UserExpirationDays:   7,
+		},
+		Session: SessionConfig{Store: SessionRedis},
+		Redis: RedisConfig{
+			Mode:         "redis",
+			MaxRetries:   3,
+			MinBackoffMs: 200,
+			MaxBackoffMs: 5000,
+		},
+		Limits: LimitsConfig{
+			MaxCreateBatchSize: 50000,
+			DefaultPageSize:    20,
+			MinPageSize:        10,
+			MaxPageSize:        100,
+			MaxExportSize:      100000,
+			MaxQRSheetSize:     200,
+		},
+		Invite: InviteConfig{
+			CodeLength:   8,
+			Alphabet:     "crockford",
+			Group:        4,
+			Checksum:     true,
+			AcceptLegacy: true,
+			RegisterURL:  "http://localhost:8080/register",
+		},
+		Account: AccountConfig{
+			MinAdminNameLen: 3,
+			MinUserNameLen:  3,
+			MinPasswordLen:  6,
+			RootBootstrap:   BootstrapAuto,
+		},
+		Lockout:  LockoutConfig{Steps: "3:1m,5:5m,7:30m,10:24h", FreezeAfter: 11},
+		Reload:   ReloadConfig{WatchSeconds: 5},
+		Metrics:  MetricsConfig{Enabled: true},
+		Log:      LogConfig{Format: logging.FormatJSON, Level: "info"},
+		Tracing:  TracingConfig{Endpoint: "http://localhost:4318", ServiceName: "go-movies", SampleRatio: 1},
+		Timeouts: TimeoutConfig{OperationSeconds: 10, ExportSeconds: 300, JobChunkSeconds: 30},
+		Crash:    CrashConfig{Dir: "crash_reports", MaxFiles: 50},
+	}
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// redactedMask 脱敏后的显示值
+const redactedMask = "******"
+
+// checks 其他包注册的校验，见 RegisterCheck
+var checks []func(Config) error
+
+// RegisterCheck 注册额外的配置校验，供 config 包无法引用的模块使用（如邀请码格式由 InviteCodeService 校验），
+// 须在 init 中调用
+func RegisterCheck(check func(Config) error) {
+	checks = append(checks, check)
+}
+
+// Validate 校验当前配置：加载错误、字段校验与已注册的校验一次性全部返回，无错误时返回 nil
+func Validate() error {
+	return errors.Join(loadErr, validate(Get()))
+}
+
+// validate 字段校验与已注册的校验
+func validate(c Config) error {
+	errs := []error{c.Validate()}
+	for _, check := range checks {
+		errs = append(errs, check(c))
+	}
+	return errors.Join(errs...)
+}
+
+// validator 收集校验错误，错误信息带配置路径与环境变量名
+type validator struct {
+	env  map[string]string
+	errs []error
+}
+
+func (v *validator) require(ok bool, path, format string, args ...interface{}) {
+	if ok {
+		return
+	}
+	name := path
+	if key := v.env[path]; key != "" {
+		name += " (" + key + ")"
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+}
+
+// Validate 校验字段取值，返回全部错误
+func (c Config) Validate() error {
+	v := &validator{env: make(map[string]string)}
+	for _, f := range fields(&c) {
+		v.env[f.Path] = f.Env
+	}
+
+	// 环境是否已定义由 Load 解析继承链时检查
+	v.require(c.Env != "", "env", "不能为空")
+
+	if c.Server.Addr != "" {
+		_, port, err := net.SplitHostPort(c.Server.Addr)
+		v.require(err == nil && validPort(port), "server.addr", "应为 ip:port 格式，当前为 %q", c.Server.Addr)
+	} else {
+		v.require(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port", "应在 1-65535 之间，当前为 %d", c.Server.Port)
+	}
+	v.require(c.Server.ReadHeaderTimeoutSeconds > 0, "server.read_header_timeout_seconds", "应大于 0")
+	v.require(c.Server.ReadTimeoutSeconds >= 0, "server.read_timeout_seconds", "不能为负数")
+	v.require(c.Server.WriteTimeoutSeconds >= 0, "server.write_timeout_seconds", "不能为负数")
+	v.require(c.Server.IdleTimeoutSeconds >= 0, "server.idle_timeout_seconds", "不能为负数")
+	v.require(c.Server.ShutdownTimeoutSeconds > 0, "server.shutdown_timeout_seconds", "应大于 0")
+	v.require(c.Server.DrainDelaySeconds >= 0, "server.drain_delay_seconds", "不能为负数")
+	v.require(!c.Server.H2C || !c.TLS.Enabled(), "server.h2c", "启用 TLS 时已支持 HTTP/2，无需 h2c")
+
+	v.require((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.key_file", "tls.cert_file 与 tls.key_file 需同时配置")
+	v.require(c.TLS.CertFile == "" || !c.TLS.SelfSigned, "tls.self_signed", "已配置证书文件时不能同时使用自签名证书")
+	v.require(c.TLS.ClientCAFile == "" || c.TLS.Enabled(), "tls.client_ca_file", "需要先启用 TLS")
+	for _, f := range []struct{ path, file string }{
+		{"tls.cert_file", c.TLS.CertFile},
+		{"tls.key_file", c.TLS.KeyFile},
+		{"tls.client_ca_file", c.TLS.ClientCAFile},
+	} {
+		if f.file != "" {
+			_, err := os.Stat(f.file)
+			v.require(err == nil, f.path, "%v", err)
+		}
+	}
+
+	v.require(c.JWT.AdminSecret != "", "jwt.admin_secret", "不能为空")
+	v.require(c.JWT.UserSecret != "", "jwt.user_secret", "不能为空")
+	v.require(c.JWT.AdminRSecret != "", "jwt.admin_r_secret", "不能为空")
+	v.require(c.JWT.AdminExpirationDays > 0, "jwt.admin_expiration_days", "应大于 0")
+	v.require(c.JWT.AdminRExpirationDays > 0, "jwt.admin_r_expiration_days", "应大于 0")
+	v.require(c.JWT.UserExpirationDays > 0, "jwt.user_expiration_days", "应大于 0")
+
+	v.require(c.Mongo.URL != "", "mongo.url", "不能为空")
+	if c.Mongo.URL != "" {
+		v.require(strings.HasPrefix(c.Mongo.URL, "mongodb://") || strings.HasPrefix(c.Mongo.URL, "mongodb+srv://"),
+			"mongo.url", "应以 mongodb:// 或 mongodb+srv:// 开头")
+	}
+	v.require(c.Mongo.DBName != "", "mongo.db_name", "不能为空")
+
+	switch c.Session.Store {
+	case SessionRedis:
+		v.require(c.Redis.Mode == "redis" || c.Redis.Mode == "memory", "redis.mode", "可选 redis / memory，当前为 %q", c.Redis.Mode)
+		v.require(c.Redis.Mode != "redis" || c.Redis.Addr != "", "redis.addr", "SESSION_STORE=redis 时不能为空")
+	case SessionMongo, SessionMemory:
+	default:
+		v.require(false, "session.store", "可选 %s / %s / %s，当前为 %q", SessionRedis, SessionMongo, SessionMemory, c.Session.Store)
+	}
+	v.require(c.Redis.DB >= 0, "redis.db", "不能为负数")
+	v.require(c.Redis.MaxRetries >= 0, "redis.max_retries", "不能为负数")
+	v.require(c.Redis.MinBackoffMs > 0, "redis.min_backoff_ms", "应大于 0")
+	v.require(c.Redis.MaxBackoffMs >= c.Redis.MinBackoffMs, "redis.max_backoff_ms", "不能小于 redis.min_backoff_ms")
+
+	l := c.Limits
+	v.require(l.MaxCreateBatchSize > 0, "limits.max_create_batch_size", "应大于 0")
+	v.require(l.MinPageSize > 0, "limits.min_page_size", "应大于 0")
+	v.require(l.MaxPageSize >= l.MinPageSize, "limits.max_page_size", "不能小于 limits.min_page_size")
+	v.require(l.DefaultPageSize >= l.MinPageSize && l.DefaultPageSize <= l.MaxPageSize,
+		"limits.default_page_size", "应在 %d-%d 之间", l.MinPageSize, l.MaxPageSize)
+	v.require(l.MaxExportSize > 0, "limits.max_export_size", "应大于 0")
+	v.require(l.MaxQRSheetSize > 0, "limits.max_qr_sheet_size", "应大于 0")
+
+	u, err := url.Parse(c.Invite.RegisterURL)
+	v.require(err == nil && u.Scheme != "" && u.Host != "", "invite.register_url", "应为完整的 http(s) 地址")
+
+	v.require(c.Retention.BlockIpTTLDays >= 0, "retention.block_ip_ttl_days", "不能为负数")
+	v.require(c.Retention.FailedLoginTTLDays >= 0, "retention.failed_login_ttl_days", "不能为负数")
+
+	v.require(c.Account.MinAdminNameLen > 0, "account.min_admin_name_len", "应大于 0")
+	v.require(c.Account.MinUserNameLen > 0, "account.min_user_name_len", "应大于 0")
+	v.require(c.Account.MinPasswordLen > 0, "account.min_password_len", "应大于 0")
+	v.require(c.Account.RootPassword == "" || len(c.Account.RootPassword) >= c.Account.MinPasswordLen,
+		"account.root_password", "至少%d个字符", c.Account.MinPasswordLen)
+	if c.Account.RootPasswordHash != "" {
+		_, err := bcrypt.Cost([]byte(c.Account.RootPasswordHash))
+		v.require(err == nil, "account.root_password_hash", "应为 bcrypt 哈希: %v", err)
+	}
+	if c.Account.RootPasswordFile != "" {
+		_, err := os.Stat(c.Account.RootPasswordFile)
+		v.require(err == nil, "account.root_password_file", "%v", err)
+	}
+	v.require(c.Account.RootBootstrap == BootstrapAuto || c.Account.RootBootstrap == BootstrapSetupToken,
+		"account.root_bootstrap", "可选 %s / %s，当前为 %q", BootstrapAuto, BootstrapSetupToken, c.Account.RootBootstrap)
+
+	_, err = c.Lockout.steps()
+	v.require(err == nil, "lockout.steps", "%v", err)
+	v.require(c.Lockout.FreezeAfter == 0 || c.Lockout.FreezeAfter > c.Lockout.MaxStep(),
+		"lockout.freeze_after", "应为 0 或大于 lockout.steps 中最大的失败次数 %d", c.Lockout.MaxStep())
+
+	v.require(c.Reload.WatchSeconds >= 0, "reload.watch_seconds", "不能为负数")
+
+	v.require(c.Log.Format == logging.FormatJSON || c.Log.Format == logging.FormatText,
+		"log.format", "可选 %s / %s，当前为 %q", logging.FormatJSON, logging.FormatText, c.Log.Format)
+	err = logging.ValidateLevels(c.Log.Level, "")
+	v.require(err == nil, "log.level", "%v", err)
+	if err == nil {
+		err = logging.ValidateLevels(c.Log.Level, c.Log.Levels)
+		v.require(err == nil, "log.levels", "%v", err)
+	}
+
+	if c.Tracing.Enabled {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		v.require(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.endpoint", "应为完整的 http(s) 地址，当前为 %q", c.Tracing.Endpoint)
+		v.require(c.Tracing.ServiceName != "", "tracing.service_name", "不能为空")
+	}
+	v.require(c.Timeouts.OperationSeconds > 0, "timeouts.operation_seconds", "应大于 0")
+	v.require(c.Timeouts.ExportSeconds > 0, "timeouts.export_seconds", "应大于 0")
+	v.require(c.Timeouts.JobChunkSeconds > 0, "timeouts.job_chunk_seconds", "应大于 0")
+	v.require(c.Crash.MaxFiles > 0, "crash.max_files", "应大于 0")
+	v.require(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "应在 0-1 之间，当前为 %v", c.Tracing.SampleRatio)
+
+	return errors.Join(v.errs...)
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
+
+// Entry config check 输出的一项配置
+type Entry struct {
+	Path   string // 配置文件中的路径
+	Env    string // 环境变量名
+	Value  string // 脱敏后的取值
+	Source string // 来源
+}
+
+// Entries 当前生效的全部配置，敏感字段已脱敏
+func Entries() []Entry {
+	s := loaded.Load()
+	c := s.cfg
+	var out []Entry
+	for _, f := range fields(&c) {
+		source := s.info.Sources[f.Path]
+		if source == "" {
+			source = SourceDefault
+		}
+		out = append(out, Entry{Path: f.Path, Env: f.Env, Value: formatValue(f), Source: source})
+	}
+	return out
+}
+
+// Info 当前配置的加载记录
+func Info() LoadInfo {
+	return loaded.Load().info
+}
+
+// formatValue 格式化字段取值，敏感字段脱敏
+func formatValue(f field) string {
+	switch f.Value.Kind() {
+	case reflect.String:
+		s := f.Value.String()
+		switch {
+		case s == "":
+			return `""`
+		case f.Secret == "url":
+			return redactURL(s)
+		case f.Secret != "":
+			return redactedMask
+		}
+		return s
+	case reflect.Slice:
+		return "[" + strings.Join(f.Value.Interface().([]string), ", ") + "]"
+	}
+	return fmt.Sprint(f.Value.Interface())
+}
+
+// redactURL 隐藏 URL 中的密码，无法解析时整体隐藏
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedMask
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedMask)
+	}
+	return u.String()
+}
@@ -21,6 +21,7 @@ type AdminController interface {
 	ChangeAdminPassword(c *gin.Context)
 	Remember(c *gin.Context)
 	ActiveAdminUser(c *gin.Context)
+	SetupRootAdmin(c *gin.Context)
 }
 
 // AdminHandler 实现 AdminController
@@ -66,13 +67,40 @@ func (*AdminHandler) CreateAdmin(c *gin.Context) {
 	if !bindAndValidate(c, &req) {
 		return
 	}
-	if err := adminService.CreateAdmin(req.Username, req.Password, req.Role); err != nil {
+	if err := adminService.CreateAdmin(c.Request.Context(), req.Username, req.Password, req.Role); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse(err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, controller.SuccessResponse(fmt.Sprintf("管理员 %s 已创建", req.Username)))
 }
 
+// SetupRootAdmin 使用一次性初始化令牌创建根管理员
+// @Summary 根管理员初始化
+// @Description 使用启动日志中打印的一次性初始化链接中的令牌设置根管理员密码，无需登录
+// @Tags 管理员管理
+// @Accept json
+// @Produce json
+// @Param body body AdminService.SetupRootAdminRequest true "初始化令牌与密码"
+// @Success 200 {object} adminSwaggerResponse.SetupRootAdminSuccessResponse
+// @Failure 422 {object} adminSwaggerResponse.SetupRootAdminErrorResponse
+// @Router /admin/setup [post]
+// 说明：
+//   - 功能：未配置根管理员密码（如容器部署）时，由首位管理员通过初始化链接设置密码
+//   - 输入：JSON 或表单，包含 token, password
+//   - 输出：成功返回 200，令牌无效、已使用、过期或密码不符合要求返回 422；令牌校验后即失效
+func (*AdminHandler) SetupRootAdmin(c *gin.Context) {
+	var req AdminService2.SetupRootAdminRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	username, err := adminService.SetupRootAdmin(c.Request.Context(), req.Token, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(fmt.Sprintf("根管理员 %s 已创建，请登录", username)))
+}
+
 // Remember 管理员登录并记住会话
 // @Summary 管理员记住登录
 // @Description 登录后返回访问令牌和刷新令牌
@@ -93,7 +121,7 @@ func (*AdminHandler) Remember(c *gin.Context) {
 		return
 	}
 	req.IP = c.ClientIP()
-	data, err := adminService.LoginAndRemember(req)
+	data, err := adminService.LoginAndRemember(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, controller.ErrorResponse(err.Error()))
 		return
@@ -120,7 +148,7 @@ func (*AdminHandler) AdminLogin(c *gin.Context) {
 	if !bindAndValidate(c, &req) {
 		return
 	}
-	token, err := adminService.AdminLogin(req.Username, req.Password, c.ClientIP(), req.DeviceId)
+	token, err := adminService.AdminLogin(c.Request.Context(), req.Username, req.Password, c.ClientIP(), req.DeviceId)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, controller.ErrorResponse(err.Error()))
 		return
@@ -144,7 +172,7 @@ func (*AdminHandler) AdminLogin(c *gin.Context) {
 //   - 输出：成功返回 200 + 成功提示，失败返回 401 + 错误信息
 func (*AdminHandler) LogOut(c *gin.Context) {
 	adminInfo := getAdminInfo(c)
-	if err := adminService.LogOut(adminInfo.AdminID); err != nil {
+	if err := adminService.LogOut(c.Request.Context(), adminInfo.AdminID); err != nil {
 		c.JSON(http.StatusUnauthorized, controller.ErrorResponse(err.Error()))
 		return
 	}
@@ -172,7 +200,7 @@ func (*AdminHandler) ActiveAdminUser(c *gin.Context) {
 	if !bindAndValidate(c, &req) {
 		return
 	}
-	if err := adminService.ActiveAdminUser(req.BannedUsername); err != nil {
+	if err := adminService.ActiveAdminUser(c.Request.Context(), req.BannedUsername); err != nil {
 		c.JSON(http.StatusUnauthorized, controller.ErrorResponse(err.Error()))
 		return
 	}
@@ -199,7 +227,7 @@ func (*AdminHandler) BannedAdminUser(c *gin.Context) {
 	if !bindAndValidate(c, &req) {
 		return
 	}
-	if err := adminService.BannedAdminUser(req.BannedUsername); err != nil {
+	if err := adminService.BannedAdminUser(c.Request.Context(), req.BannedUsername); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse(err.Error()))
 		return
 	}
@@ -228,7 +256,7 @@ func (*AdminHandler) AdminList(c *gin.Context) {
 		return
 	}
 	req.Page, req.PageSize = controller.PageSet(req.Page, req.PageSize)
-	adminList, err := adminService.AdminList(req)
+	adminList, err := adminService.AdminList(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
 		return
@@ -257,7 +285,7 @@ func (*AdminHandler) DeleteAdmin(c *gin.Context) {
 		return
 	}
 	adminInfo := getAdminInfo(c)
-	if err := adminService.DeleteAdmin(adminInfo.AdminUsername, req.DelUsername); err != nil {
+	if err := adminService.DeleteAdmin(c.Request.Context(), adminInfo.AdminUsername, req.DelUsername); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse(err.Error()))
 		return
 	}
@@ -284,7 +312,7 @@ func (*AdminHandler) ChangeAdminPassword(c *gin.Context) {
 	if !bindAndValidate(c, &req) {
 		return
 	}
-	if err := adminService.ChangeAdminPassword(req.Username, req.NewPassword); err != nil {
+	if err := adminService.ChangeAdminPassword(c.Request.Context(), req.Username, req.NewPassword); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse(err.Error()))
 		return
 	}
@@ -0,0 +1,48 @@
+package HealthController
+
+import (
+	"github.com/StephenChristianW/go-movies-open/controller"
+	"github.com/StephenChristianW/go-movies-open/services"
+	HealthService "github.com/StephenChristianW/go-movies-open/services/System/Health"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// HealthController 存活与就绪检查接口定义
+type HealthController interface {
+	Healthz(c *gin.Context)
+	Readyz(c *gin.Context)
+}
+
+// HealthHandler 实现 HealthController
+type HealthHandler struct{}
+
+var healthService = HealthService.HealthService{}
+
+// Healthz 存活检查
+// @Summary 存活检查
+// @Description 进程存活即返回 200，不检查依赖；关闭过程中仍返回 200
+// @Tags 健康检查
+// @Produce json
+// @Success 200 {object} services.Response
+// @Router /healthz [get]
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, controller.SuccessResponse(gin.H{"status": HealthService.StatusOK}))
+}
+
+// Readyz 就绪检查
+// @Summary 就绪检查
+// @Description 检查 MongoDB、Redis、数据库迁移与定时任务，返回各项状态与耗时；任一项失败或服务正在关闭时返回 503
+// @Tags 健康检查
+// @Produce json
+// @Success 200 {object} services.Response
+// @Failure 503 {object} services.Response
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := healthService.Ready(c.Request.Context())
+	if report.Status != HealthService.Ready {
+		c.JSON(http.StatusServiceUnavailable, services.Response{Code: -1, Msg: report.Status, Data: report})
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(report))
+}
@@ -1,12 +1,22 @@
 package InviteController
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
 	"github.com/StephenChristianW/go-movies-open/controller"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/routers/Middlewares"
 	InviteCodeService2 "github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
+	"github.com/StephenChristianW/go-movies-open/utils/Jwt"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsExport"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsQRCode"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // InviteCodeController 邀请码控制器接口
@@ -18,23 +28,56 @@ type InviteCodeController interface {
 	DeleteInviteCode(c *gin.Context)
 	UpdateInviteCode(c *gin.Context)
 	GetInviteCode(c *gin.Context)
+	CreateCampaign(c *gin.Context)
+	ListCampaigns(c *gin.Context)
+	GetCampaignStats(c *gin.Context)
+	ListRedemptions(c *gin.Context)
+	ExportInviteCodes(c *gin.Context)
+	ExportQRSheet(c *gin.Context)
+	GetGenerateJob(c *gin.Context)
+	ListGenerateJobs(c *gin.Context)
+	CancelGenerateJob(c *gin.Context)
 }
 
 // InviteCodeHandler 实现
 type InviteCodeHandler struct{}
 
+// getAdminInfo 从 Context 获取当前管理员信息
+func getAdminInfo(c *gin.Context) *Jwt.AdminClaims {
+	return c.MustGet(Middlewares.AdminInfo).(*Jwt.AdminClaims)
+}
+
 var inviteCodeService InviteCodeService2.InviteCodeInterface = &InviteCodeService2.CreateInviteCode{}
 
+var logger = logging.For("invite")
+
 // -------------------- 请求体定义 --------------------
 
 type GenerateCodesRequest struct {
-	Num int `json:"num" binding:"required"`
+	Num        int    `json:"num" binding:"required"`
+	CampaignID string `json:"campaign_id"` // 可选，所属活动ID
+	MaxUses    int    `json:"max_uses"`    // 可选，每个邀请码最大使用次数，-1 不限，默认单次
+}
+
+type GenerateCodeRequest struct {
+	CampaignID string `form:"campaign_id"` // 可选，所属活动ID
+	MaxUses    int    `form:"max_uses"`    // 可选，最大使用次数，-1 不限，默认单次
 }
 
 type InviteCodesRequest struct {
 	CodeIds []string `json:"codeIds" binding:"required"`
 }
 
+type ExportInviteCodesRequest struct {
+	InviteCodeService2.CreateInviteFilter
+	Format string `json:"format"` // 导出格式 csv / xlsx，默认 csv
+}
+
+type QRSheetRequest struct {
+	InviteCodeService2.CreateInviteFilter
+	Columns int `json:"columns"` // 每行二维码数量，默认 4
+}
+
 // -------------------- 控制器方法 --------------------
 
 // GenerateAndInsertCode 生成单个邀请码
@@ -43,12 +86,19 @@ type InviteCodesRequest struct {
 // @Tags 邀请码管理
 // @Accept json
 // @Produce json
+// @Param campaign_id query string false "所属活动ID"
+// @Param max_uses query int false "最大使用次数，-1 不限，默认单次"
 // @Param Authorization header string true "Bearer token"
 // @Success 200 {object} inviteCodeSwaggerResponse.GenerateAndInsertCodeSuccessResponse
 // @Failure 500 {object} inviteCodeSwaggerResponse.GenerateAndInsertCodeErrorResponse
 // @Router /invite/create_one [post]
 func (InviteCodeHandler) GenerateAndInsertCode(c *gin.Context) {
-	data, err := inviteCodeService.GenerateAndInsertCode()
+	var req GenerateCodeRequest
+	_ = c.ShouldBindQuery(&req)
+	data, err := inviteCodeService.GenerateAndInsertCode(c.Request.Context(), InviteCodeService2.GenerateOptions{
+		CampaignID: req.CampaignID,
+		MaxUses:    req.MaxUses,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
 		return
@@ -56,9 +106,9 @@ func (InviteCodeHandler) GenerateAndInsertCode(c *gin.Context) {
 	c.JSON(http.StatusOK, controller.SuccessResponse(data))
 }
 
-// GenerateAndInsertCodes 2批量生成邀请码
+// GenerateAndInsertCodes 批量生成邀请码
 // @Summary 批量生成邀请码
-// @Description 批量生成指定数量的邀请码
+// @Description 创建后台批量生成任务并立即返回任务信息，通过 /invite/job/get 轮询进度
 // @Tags 邀请码管理
 // @Accept json
 // @Produce json
@@ -74,7 +124,10 @@ func (InviteCodeHandler) GenerateAndInsertCodes(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
 		return
 	}
-	data, err := inviteCodeService.GenerateAndInsertCodes(req.Num)
+	data, err := inviteCodeService.StartGenerateJob(c.Request.Context(), req.Num, InviteCodeService2.GenerateOptions{
+		CampaignID: req.CampaignID,
+		MaxUses:    req.MaxUses,
+	}, getAdminInfo(c).AdminUsername)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
 		return
@@ -100,7 +153,7 @@ func (InviteCodeHandler) ReBackInviteCodes(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
 		return
 	}
-	data, err := inviteCodeService.ReBackInviteCodes(req.CodeIds)
+	data, err := inviteCodeService.ReBackInviteCodes(c.Request.Context(), req.CodeIds)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -127,7 +180,7 @@ func (InviteCodeHandler) ListInviteCodes(c *gin.Context) {
 		return
 	}
 	req.Page, req.PageSize = controller.PageSet(req.Page, req.PageSize)
-	data, err := inviteCodeService.ListInviteCodes(req)
+	data, err := inviteCodeService.ListInviteCodes(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
 		return
@@ -153,7 +206,7 @@ func (InviteCodeHandler) DeleteInviteCode(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("未提供有效的 ID 列表"))
 		return
 	}
-	data, err := inviteCodeService.DeleteInviteCode(req.IDs)
+	data, err := inviteCodeService.DeleteInviteCode(c.Request.Context(), req.IDs)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -179,7 +232,7 @@ func (InviteCodeHandler) UpdateInviteCode(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
 		return
 	}
-	data, err := inviteCodeService.UpdateInviteCodes(req)
+	data, err := inviteCodeService.UpdateInviteCodes(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -206,15 +259,15 @@ func (InviteCodeHandler) GetInviteCode(c *gin.Context) {
 		return
 	}
 
-	data, err := inviteCodeService.GetInviteCode(req.Code)
+	data, err := inviteCodeService.GetInviteCode(c.Request.Context(), req.Code)
 	if err != nil {
-		var errStr string
+		if errors.Is(err, InviteCodeService2.ErrInvalidCodeFormat) {
+			c.JSON(http.StatusBadRequest, controller.ErrorResponse("邀请码格式错误: "+req.Code))
+			return
+		}
+		errStr := ": " + req.Code
 		if len(req.Code) == 24 {
 			errStr = " id: " + req.Code
-		} else if len(req.Code) == 8 {
-			errStr = ": " + req.Code
-		} else {
-			errStr = "-验证码长度错误: " + req.Code
 		}
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			c.JSON(http.StatusBadRequest, controller.ErrorResponse("未找到验证码"+errStr))
@@ -226,3 +279,311 @@ func (InviteCodeHandler) GetInviteCode(c *gin.Context) {
 
 	c.JSON(http.StatusOK, controller.SuccessResponse(data))
 }
+
+// CreateCampaign 创建邀请活动
+// @Summary 创建邀请活动
+// @Description 创建带名称、渠道、标签、有效期和兑换配额的邀请活动，邀请码可在活动下生成
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param body body InviteCodeService.CreateCampaign true "活动信息"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.CreateCampaignSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} inviteCodeSwaggerResponse.CreateCampaignErrorResponse
+// @Router /invite/campaign/create [post]
+func (InviteCodeHandler) CreateCampaign(c *gin.Context) {
+	var req InviteCodeService2.CreateCampaign
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	adminInfo := getAdminInfo(c)
+	data, err := inviteCodeService.CreateCampaign(c.Request.Context(), req, adminInfo.AdminID, adminInfo.AdminUsername)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// ListCampaigns 分页获取邀请活动列表
+// @Summary 分页获取邀请活动列表
+// @Description 根据名称、渠道、标签筛选邀请活动
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param body body InviteCodeService.CampaignFilter true "分页参数和过滤条件"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.ListCampaignsSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 500 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/campaign/list [post]
+func (InviteCodeHandler) ListCampaigns(c *gin.Context) {
+	var req InviteCodeService2.CampaignFilter
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	req.Page, req.PageSize = controller.PageSet(req.Page, req.PageSize)
+	data, err := inviteCodeService.ListCampaigns(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// GetCampaignStats 获取邀请活动统计
+// @Summary 获取邀请活动统计
+// @Description 返回活动下邀请码的生成、兑换、过期数量及剩余兑换配额
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param id query string true "活动ID"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.CampaignStatsSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/campaign/stats [get]
+func (InviteCodeHandler) GetCampaignStats(c *gin.Context) {
+	var req InviteCodeService2.CampaignStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	data, err := inviteCodeService.GetCampaignStats(c.Request.Context(), req.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// ListRedemptions 分页获取邀请码兑换记录
+// @Summary 分页获取邀请码兑换记录
+// @Description 根据邀请码或用户ID查询每一次注册兑换记录
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param body body InviteCodeService.RedemptionFilter true "分页参数和过滤条件"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.ListRedemptionsSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 500 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/redemptions [post]
+func (InviteCodeHandler) ListRedemptions(c *gin.Context) {
+	var req InviteCodeService2.RedemptionFilter
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	req.Page, req.PageSize = controller.PageSet(req.Page, req.PageSize)
+	data, err := inviteCodeService.ListRedemptions(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// GetGenerateJob 查询批量生成任务进度
+// @Summary 查询批量生成任务进度
+// @Description 返回任务状态、已生成数量与进度百分比
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param id query string true "任务ID"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.GenerateJobSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/job/get [get]
+func (InviteCodeHandler) GetGenerateJob(c *gin.Context) {
+	var req InviteCodeService2.GenerateJobRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	data, err := inviteCodeService.GetGenerateJob(c.Request.Context(), req.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// ListGenerateJobs 分页获取批量生成任务
+// @Summary 分页获取批量生成任务
+// @Description 根据状态筛选批量生成任务
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param body body InviteCodeService.GenerateJobFilter true "分页参数和过滤条件"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.ListGenerateJobsSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 500 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/job/list [post]
+func (InviteCodeHandler) ListGenerateJobs(c *gin.Context) {
+	var req InviteCodeService2.GenerateJobFilter
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	req.Page, req.PageSize = controller.PageSet(req.Page, req.PageSize)
+	data, err := inviteCodeService.ListGenerateJobs(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// CancelGenerateJob 取消批量生成任务
+// @Summary 取消批量生成任务
+// @Description 取消未完成的批量生成任务，已生成的邀请码保留
+// @Tags 邀请码管理
+// @Accept json
+// @Produce json
+// @Param id query string true "任务ID"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} inviteCodeSwaggerResponse.GenerateJobSuccessResponse
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/job/cancel [put]
+func (InviteCodeHandler) CancelGenerateJob(c *gin.Context) {
+	var req InviteCodeService2.GenerateJobRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	data, err := inviteCodeService.CancelGenerateJob(c.Request.Context(), req.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, controller.SuccessResponse(data))
+}
+
+// ExportInviteCodes 导出邀请码
+// @Summary 导出邀请码
+// @Description 按筛选条件以 CSV 或 XLSX 流式导出邀请码及注册链接，不分页
+// @Tags 邀请码管理
+// @Accept json
+// @Produce octet-stream
+// @Param body body ExportInviteCodesRequest true "过滤条件与导出格式"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {file} file "CSV 或 XLSX 文件"
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/export [post]
+func (InviteCodeHandler) ExportInviteCodes(c *gin.Context) {
+	var req ExportInviteCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = UtilsExport.FormatCSV
+	}
+	if format != UtilsExport.FormatCSV && format != UtilsExport.FormatXLSX {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("不支持的导出格式: "+req.Format))
+		return
+	}
+	if !checkExportSize(c, req.CreateInviteFilter, config.Limits().MaxExportSize) {
+		return
+	}
+
+	// 开始写出后状态码已发送，之后的错误只能记录日志并中断连接
+	c.Header("Content-Type", UtilsExport.ContentType(format))
+	c.Header("Content-Disposition", attachment("invite_codes", format))
+	c.Status(http.StatusOK)
+	writer, err := UtilsExport.NewRowWriter(c.Writer, format)
+	if err == nil {
+		err = writer.WriteRow(InviteCodeService2.ExportHeader)
+	}
+	if err == nil {
+		err = inviteCodeService.ExportInviteCodes(c.Request.Context(), req.CreateInviteFilter, func(code InviteCodeService2.InviteCode) error {
+			return writer.WriteRow(InviteCodeService2.ExportRow(code))
+		})
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "导出邀请码失败", logging.Err(err))
+		c.Abort()
+	}
+}
+
+// ExportQRSheet 导出邀请码二维码打印页
+// @Summary 导出邀请码二维码打印页
+// @Description 按筛选条件生成 PNG 打印页，每个二维码编码注册链接，下方标注邀请码
+// @Tags 邀请码管理
+// @Accept json
+// @Produce png
+// @Param body body QRSheetRequest true "过滤条件与排版"
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {file} file "PNG 图片"
+// @Failure 422 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 400 {object} swaggerResponse.ErrorResponseDoc
+// @Failure 500 {object} swaggerResponse.ErrorResponseDoc
+// @Router /invite/qrsheet [post]
+func (InviteCodeHandler) ExportQRSheet(c *gin.Context) {
+	var req QRSheetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, controller.ErrorResponse("参数错误"))
+		return
+	}
+	if !checkExportSize(c, req.CreateInviteFilter, config.Limits().MaxQRSheetSize) {
+		return
+	}
+
+	var items []UtilsQRCode.SheetItem
+	err := inviteCodeService.ExportInviteCodes(c.Request.Context(), req.CreateInviteFilter, func(code InviteCodeService2.InviteCode) error {
+		items = append(items, UtilsQRCode.SheetItem{
+			Content: InviteCodeService2.RegisterLink(code.Code),
+			Label:   code.Code,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
+		return
+	}
+
+	// 先在内存中完整渲染，编码失败时仍可返回错误响应
+	var buf bytes.Buffer
+	if err := UtilsQRCode.WriteSheetPNG(&buf, items, req.Columns); err != nil {
+		logger.ErrorContext(c.Request.Context(), "生成二维码打印页失败", logging.Err(err))
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse("生成二维码打印页失败: "+err.Error()))
+		return
+	}
+	c.Header("Content-Disposition", attachment("invite_qrcodes", "png"))
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// checkExportSize 校验筛选结果数量在 1 到 limit 之间，不满足时写入错误响应并返回 false
+func checkExportSize(c *gin.Context, filter InviteCodeService2.CreateInviteFilter, limit int) bool {
+	total, err := inviteCodeService.CountInviteCodes(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, controller.ErrorResponse(err.Error()))
+		return false
+	}
+	if total == 0 {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse("没有符合条件的邀请码"))
+		return false
+	}
+	if total > limit {
+		c.JSON(http.StatusBadRequest, controller.ErrorResponse(fmt.Sprintf("符合条件的邀请码共 %d 个，超过单次上限 %d，请缩小筛选范围", total, limit)))
+		return false
+	}
+	return true
+}
+
+// attachment 生成带时间戳的下载文件名
+func attachment(name, ext string) string {
+	return fmt.Sprintf(`attachment; filename="%s_%s.%s"`, name, time.Now().Format("20060102150405"), ext)
+}
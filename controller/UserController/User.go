@@ -32,12 +32,12 @@ type UserHandler struct {
 
 // RegisterUser 注册新用户
 // @Summary 注册新用户
-// @Description 使用用户名、密码和邀请码注册用户
+// @Description 使用用户名、密码和邀请码注册用户，成功后返回仅下发一次的找回密码恢复码
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Param request body UserService.CreateUser true "用户注册信息"
-// @Success 201 {object} swaggerResponse.SuccessResponseDoc "注册成功"
+// @Success 201 {object} userSwaggerResponse.RegisterUserSuccessResponse "注册成功"
 // @Failure 400 {object} userSwaggerResponse.RegisterUserErrorResponse "参数错误或注册失败"
 // @Router /user/register [post]
 func (UserHandler) RegisterUser(c *gin.Context) {
@@ -48,13 +48,13 @@ func (UserHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	err = userService.RegisterUser(req)
+	recoveryCode, err := userService.RegisterUser(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusCreated, controller.SuccessResponse(nil))
+	c.JSON(http.StatusCreated, controller.SuccessResponse(UserService.RegisterResponse{RecoveryCode: recoveryCode}))
 }
 
 // Login 用户登录
@@ -78,7 +78,7 @@ func (UserHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse("参数错误"))
 		return
 	}
-	data, err := userService.UserLogin(req.Username, req.Password)
+	data, err := userService.UserLogin(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -86,8 +86,8 @@ func (UserHandler) Login(c *gin.Context) {
 	var failureResp UserService.LoginFailureResponse
 	failureResp.FailedAttempts = data.FailedAttempts
 	failureResp.LockSeconds = data.LockSeconds
-	countMap := map[int]struct{}{3: {}, 5: {}, 7: {}, 10: {}}
-	_, ok := countMap[failureResp.FailedAttempts]
+	lockout := config.Lockout()
+	_, ok := lockout.Duration(failureResp.FailedAttempts)
 	if !ok && failureResp.LockSeconds == 0 && data.FailedAttempts > 0 {
 		c.JSON(http.StatusBadRequest, services.Response{
 			Code: -1,
@@ -106,9 +106,9 @@ func (UserHandler) Login(c *gin.Context) {
 		return
 	}
 	if data.Token == "" {
-		if data.FailedAttempts == 10 && !config.ProdEnv() {
+		if data.FailedAttempts == lockout.MaxStep() && !config.ProdEnv() {
 			ip := c.ClientIP()
-			IPManage.AddSuspiciousIp(req.Username, ip, "异常请求登录次数过多，涉嫌机器人刷密码")
+			IPManage.AddSuspiciousIp(c.Request.Context(), req.Username, ip, "异常请求登录次数过多，涉嫌机器人刷密码")
 		}
 		failureResp.LockSeconds = 1
 		c.JSON(http.StatusBadRequest, services.Response{
@@ -125,7 +125,7 @@ func (UserHandler) Login(c *gin.Context) {
 
 // Forget 忘记密码重置
 // @Summary 忘记密码
-// @Description 用户忘记密码时，通过用户名和邀请码重置密码
+// @Description 用户忘记密码时，通过用户名和注册时下发的恢复码重置密码
 // @Tags 用户
 // @Accept json
 // @Produce json
@@ -140,7 +140,7 @@ func (UserHandler) Forget(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse("参数错误"))
 		return
 	}
-	err = userService.ForgetPassword(req.Username, req.Code, req.Password)
+	err = userService.ForgetPassword(c.Request.Context(), req.Username, req.Code, req.Password)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -167,7 +167,7 @@ func (UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 	userInfo := getUserInfo(c)
-	err = userService.ChangePassword(userInfo.UserID, req.OldPassword, req.NewPassword)
+	err = userService.ChangePassword(c.Request.Context(), userInfo.UserID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
@@ -194,7 +194,7 @@ func (UserHandler) RenameUser(c *gin.Context) {
 		return
 	}
 	userInfo := getUserInfo(c)
-	err = userService.RenameUser(userInfo.UserID, req.Username)
+	err = userService.RenameUser(c.Request.Context(), userInfo.UserID, req.Username)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, controller.ErrorResponse(err.Error()))
 		return
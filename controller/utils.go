@@ -9,14 +9,15 @@ func PageSet(page, pageSize int) (int, int) {
 	if page <= 0 {
 		page = 1
 	}
+	limits := config.Limits()
 	if pageSize <= 0 {
-		pageSize = config.DefaultPageSize
+		pageSize = limits.DefaultPageSize
 	}
-	if pageSize < config.MinPageSize {
-		pageSize = config.MinPageSize
+	if pageSize < limits.MinPageSize {
+		pageSize = limits.MinPageSize
 	}
-	if pageSize > config.MaxPageSize {
-		pageSize = config.MaxPageSize
+	if pageSize > limits.MaxPageSize {
+		pageSize = limits.MaxPageSize
 	}
 	return page, pageSize
 }
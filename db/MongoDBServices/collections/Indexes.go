@@ -0,0 +1,362 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var logger = logging.For("index")
+
+// Index 声明的索引，按 Name 与数据库中的索引对应
+type Index struct {
+	Name    string        // 索引名，修改键或选项时必须同时修改名称
+	Keys    bson.D        // 索引键，1 升序 / -1 降序
+	Unique  bool          // 唯一索引
+	Partial bson.D        // 部分索引条件（partialFilterExpression），为空表示全部文档
+	TTL     time.Duration // 大于 0 时为 TTL 索引，文档在 Keys 首个时间字段之后 TTL 被删除
+}
+
+// collectionIndexes 单个集合的索引声明
+type collectionIndexes struct {
+	Collection string
+	Indexes    []Index
+}
+
+// declaredIndexes 各集合的索引声明，启动时由 ReconcileIndexes 同步到数据库
+func declaredIndexes() []collectionIndexes {
+	notDeleted := bson.D{{Key: "deleted", Value: 1}}
+
+	var blockIps []Index
+	blockIps = append(blockIps, Index{Name: "ip", Keys: bson.D{{Key: "ip", Value: 1}}})
+	if config.BlockIpTTLDays > 0 {
+		blockIps = append(blockIps, Index{
+			Name: "blocked_at_ttl",
+			Keys: bson.D{{Key: "blocked_at", Value: 1}},
+			TTL:  time.Duration(config.BlockIpTTLDays) * 24 * time.Hour,
+		})
+	} else {
+		blockIps = append(blockIps, Index{Name: "blocked_at", Keys: bson.D{{Key: "blocked_at", Value: -1}}})
+	}
+
+	indexes := []collectionIndexes{
+		{config.UserColl, []Index{
+			// 同名用户只能存在一个未删除的，已删除的用户不参与唯一约束
+			{Name: "username_deleted_unique", Keys: bson.D{{Key: "username", Value: 1}, {Key: "deleted", Value: 1}}, Unique: true, Partial: notDeleted},
+			{Name: "code", Keys: bson.D{{Key: "code", Value: 1}}},
+			{Name: "campaign_id_create_at", Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "create_at", Value: -1}}},
+		}},
+		{config.AdminColl, []Index{
+			{Name: "username_deleted_unique", Keys: bson.D{{Key: "username", Value: 1}, {Key: "deleted", Value: 1}}, Unique: true, Partial: notDeleted},
+			{Name: "role_status", Keys: bson.D{{Key: "role", Value: 1}, {Key: "status", Value: 1}}},
+		}},
+		{config.AdminRemember, []Index{
+			{Name: "user_id_unique", Keys: bson.D{{Key: "user_id", Value: 1}}, Unique: true},
+		}},
+		{config.InviteCodeColl, []Index{
+			// 批量生成依赖该索引的重复键错误重新生成冲突的邀请码
+			{Name: "code_unique", Keys: bson.D{{Key: "code", Value: 1}}, Unique: true},
+			{Name: "expired_time", Keys: bson.D{{Key: "expired_time", Value: -1}}},
+			{Name: "status_expired_time", Keys: bson.D{{Key: "status", Value: 1}, {Key: "expired_time", Value: -1}}},
+			{Name: "username_deleted_expired_time", Keys: bson.D{{Key: "username", Value: 1}, {Key: "deleted", Value: 1}, {Key: "expired_time", Value: -1}}},
+			{Name: "campaign_id_expired_time", Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "expired_time", Value: -1}}},
+			{Name: "job_id", Keys: bson.D{{Key: "job_id", Value: 1}, {Key: "_id", Value: 1}}},
+		}},
+		{config.InviteCampaign, []Index{
+			{Name: "deleted_create_at", Keys: bson.D{{Key: "deleted", Value: 1}, {Key: "create_at", Value: -1}}},
+			{Name: "channel_create_at", Keys: bson.D{{Key: "channel", Value: 1}, {Key: "create_at", Value: -1}}},
+			{Name: "tags", Keys: bson.D{{Key: "tags", Value: 1}}},
+		}},
+		{config.InviteRedeem, []Index{
+			{Name: "code_redeemed_at", Keys: bson.D{{Key: "code", Value: 1}, {Key: "redeemed_at", Value: -1}}},
+			{Name: "user_id_redeemed_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "redeemed_at", Value: -1}}},
+			{Name: "code_id_user_id", Keys: bson.D{{Key: "code_id", Value: 1}, {Key: "user_id", Value: 1}}},
+		}},
+		{config.InviteGenJob, []Index{
+			{Name: "status_create_at", Keys: bson.D{{Key: "status", Value: 1}, {Key: "create_at", Value: -1}}},
+		}},
+		{config.BlockIps, blockIps},
+	}
+	if config.SessionStore == config.SessionMongo {
+		indexes = append(indexes, sessionIndexes()...)
+	}
+	return indexes
+}
+
+// sessionIndexes 令牌与登录失败记录集合的索引，仅在 SESSION_STORE=mongo 时声明，避免创建用不到的集合
+func sessionIndexes() []collectionIndexes {
+	failedLogin := []Index{{Name: "user_id_unique", Keys: bson.D{{Key: "user_id", Value: 1}}, Unique: true}}
+	if config.FailedLoginTTLDays > 0 {
+		failedLogin = append(failedLogin, Index{
+			Name: "last_ttl",
+			Keys: bson.D{{Key: "last", Value: 1}},
+			TTL:  time.Duration(config.FailedLoginTTLDays) * 24 * time.Hour,
+		})
+	}
+	return []collectionIndexes{
+		{config.SessionToken, []Index{
+			{Name: "kind_owner_id_unique", Keys: bson.D{{Key: "kind", Value: 1}, {Key: "owner_id", Value: 1}}, Unique: true},
+			// expire_at 为令牌过期时间，过期 1 秒后删除；TTL 清理有延迟，读取时另按 expire_at 过滤
+			{Name: "expire_at_ttl", Keys: bson.D{{Key: "expire_at", Value: 1}}, TTL: time.Second},
+		}},
+		{config.FailedLogin, failedLogin},
+	}
+}
+
+// IndexDrift 数据库中与声明不一致的索引
+type IndexDrift struct {
+	Collection string
+	Index      string
+	Reason     string
+}
+
+// IndexReport 索引同步结果
+type IndexReport struct {
+	Created []string     // 新建的索引，格式 集合.索引名
+	Updated []string     // 原地修改的索引（仅 TTL 时长）
+	Drift   []IndexDrift // 无法自动同步、需要人工处理的差异
+}
+
+// ReconcileIndexes 启动时同步索引：缺失的创建，TTL 时长不一致的原地修改，
+// 其余差异（键或唯一性不同、未声明的索引、创建失败）只报告不处理，避免启动时误删索引或阻塞服务
+func ReconcileIndexes(ctx context.Context) (IndexReport, error) {
+	var report IndexReport
+	for _, decl := range declaredIndexes() {
+		if err := reconcileCollection(ctx, decl, &report); err != nil {
+			return report, fmt.Errorf("同步集合 %s 索引失败: %w", decl.Collection, err)
+		}
+	}
+	return report, nil
+}
+
+// RunIndexesOnStartup 启动时同步索引并输出差异，失败只记录日志
+func RunIndexesOnStartup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	report, err := ReconcileIndexes(ctx)
+	for _, name := range report.Created {
+		logger.Info("已创建索引", "index", name)
+	}
+	for _, name := range report.Updated {
+		logger.Info("已更新索引", "index", name)
+	}
+	for _, d := range report.Drift {
+		logger.Warn("索引差异", "collection", d.Collection, "index", d.Index, "reason", d.Reason)
+	}
+	if err != nil {
+		logger.Error("同步索引失败", logging.Err(err))
+	}
+}
+
+// existingIndex listIndexes 返回的索引信息
+type existingIndex struct {
+	Name               string      `bson:"name"`
+	Key                bson.D      `bson:"key"`
+	Unique             bool        `bson:"unique"`
+	Partial            bson.D      `bson:"partialFilterExpression"`
+	ExpireAfterSeconds interface{} `bson:"expireAfterSeconds"`
+}
+
+func reconcileCollection(ctx context.Context, decl collectionIndexes, report *IndexReport) error {
+	collection := db.GetStackBuilderCollection(decl.Collection)
+	existing, err := listIndexes(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	plan := planIndexes(decl, existing)
+	for _, idx := range plan.Create {
+		if _, err := collection.Indexes().CreateOne(ctx, idx.model()); err != nil {
+			report.Drift = append(report.Drift, IndexDrift{decl.Collection, idx.Name, "创建失败: " + err.Error()})
+			continue
+		}
+		report.Created = append(report.Created, decl.Collection+"."+idx.Name)
+	}
+	for _, idx := range plan.UpdateTTL {
+		if err := updateTTL(ctx, collection, idx); err != nil {
+			report.Drift = append(report.Drift, IndexDrift{decl.Collection, idx.Name, "修改 TTL 失败: " + err.Error()})
+			continue
+		}
+		report.Updated = append(report.Updated, decl.Collection+"."+idx.Name)
+	}
+	report.Drift = append(report.Drift, plan.Drift...)
+	return nil
+}
+
+// indexPlan 单个集合的索引声明与数据库现状的比较结果
+type indexPlan struct {
+	Create    []Index      // 缺失、需要创建的索引
+	UpdateTTL []Index      // 仅 TTL 时长不同、可原地修改的索引
+	Drift     []IndexDrift // 需要人工处理的差异
+}
+
+// planIndexes 比较声明与数据库中已有的索引，不访问数据库
+func planIndexes(decl collectionIndexes, existing map[string]existingIndex) indexPlan {
+	var plan indexPlan
+	declared := make(map[string]bool, len(decl.Indexes))
+	for _, idx := range decl.Indexes {
+		declared[idx.Name] = true
+
+		current, ok := existing[idx.Name]
+		if !ok {
+			if other := findByKeys(existing, idx.Keys); other != "" {
+				plan.Drift = append(plan.Drift, IndexDrift{decl.Collection, idx.Name, fmt.Sprintf("相同的键已存在于索引 %s，请删除后重启", other)})
+				continue
+			}
+			plan.Create = append(plan.Create, idx)
+			continue
+		}
+
+		if reason := idx.diff(current); reason != "" {
+			plan.Drift = append(plan.Drift, IndexDrift{decl.Collection, idx.Name, reason + "，请删除后重启"})
+			continue
+		}
+		if idx.TTL > 0 && toSeconds(current.ExpireAfterSeconds) != int64(idx.TTL.Seconds()) {
+			plan.UpdateTTL = append(plan.UpdateTTL, idx)
+		}
+	}
+
+	var extra []string
+	for name := range existing {
+		if name != "_id_" && !declared[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		plan.Drift = append(plan.Drift, IndexDrift{decl.Collection, name, "未声明的索引"})
+	}
+	return plan
+}
+
+func listIndexes(ctx context.Context, collection *mongo.Collection) (map[string]existingIndex, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		// 集合尚不存在时没有索引
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+			return map[string]existingIndex{}, nil
+		}
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	result := make(map[string]existingIndex)
+	for cursor.Next(ctx) {
+		var idx existingIndex
+		if err := cursor.Decode(&idx); err != nil {
+			return nil, err
+		}
+		result[idx.Name] = idx
+	}
+	return result, cursor.Err()
+}
+
+// model 转换为驱动的索引模型
+func (idx Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(idx.Name)
+	if idx.Unique {
+		opts.SetUnique(true)
+	}
+	if len(idx.Partial) > 0 {
+		opts.SetPartialFilterExpression(idx.Partial)
+	}
+	if idx.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(idx.TTL.Seconds()))
+	}
+	return mongo.IndexModel{Keys: idx.Keys, Options: opts}
+}
+
+// diff 比较声明与数据库中的同名索引，返回无法原地修改的差异，一致时返回空字符串
+func (idx Index) diff(current existingIndex) string {
+	if canonical(idx.Keys) != canonical(current.Key) {
+		return fmt.Sprintf("键不一致：声明 %s，实际 %s", canonical(idx.Keys), canonical(current.Key))
+	}
+	if idx.Unique != current.Unique {
+		return fmt.Sprintf("唯一性不一致：声明 %v，实际 %v", idx.Unique, current.Unique)
+	}
+	if canonical(idx.Partial) != canonical(current.Partial) {
+		return fmt.Sprintf("部分索引条件不一致：声明 %s，实际 %s", canonical(idx.Partial), canonical(current.Partial))
+	}
+	if (idx.TTL > 0) != (current.ExpireAfterSeconds != nil) {
+		return "TTL 设置不一致"
+	}
+	return ""
+}
+
+// findByKeys 查找键相同的已有索引名（名称不同时无法再创建）
+func findByKeys(existing map[string]existingIndex, keys bson.D) string {
+	want := canonical(keys)
+	for name, idx := range existing {
+		if canonical(idx.Key) == want {
+			return name
+		}
+	}
+	return ""
+}
+
+// canonical 将文档转为可比较的字符串，忽略 int32/int64/double 的数值类型差异
+func canonical(d bson.D) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, e := range d {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(e.Key + ": ")
+		switch v := e.Value.(type) {
+		case bson.D:
+			b.WriteString(canonical(v))
+		case int, int32, int64, float64:
+			b.WriteString(strconv.FormatFloat(toFloat(v), 'f', -1, 64))
+		default:
+			b.WriteString(fmt.Sprint(v))
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// updateTTL 通过 collMod 原地修改 TTL 时长
+func updateTTL(ctx context.Context, collection *mongo.Collection, idx Index) error {
+	return collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: idx.Name},
+			{Key: "expireAfterSeconds", Value: int64(idx.TTL.Seconds())},
+		}},
+	}).Err()
+}
+
+func toSeconds(v interface{}) int64 {
+	if v == nil {
+		return -1
+	}
+	return int64(toFloat(v))
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return -1
+}
@@ -0,0 +1,158 @@
+package collections
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func names(list []Index) []string {
+	out := []string{}
+	for _, idx := range list {
+		out = append(out, idx.Name)
+	}
+	return out
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b bson.D
+		same bool
+	}{
+		{"数值类型不同视为相同", bson.D{{Key: "a", Value: int32(1)}}, bson.D{{Key: "a", Value: 1.0}}, true},
+		{"int64 与 int", bson.D{{Key: "a", Value: int64(-1)}}, bson.D{{Key: "a", Value: -1}}, true},
+		{"方向不同", bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "a", Value: -1}}, false},
+		{"键顺序敏感", bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}, bson.D{{Key: "b", Value: 1}, {Key: "a", Value: 1}}, false},
+		{"嵌套文档", bson.D{{Key: "deleted", Value: bson.D{{Key: "$eq", Value: int32(1)}}}}, bson.D{{Key: "deleted", Value: bson.D{{Key: "$eq", Value: 1}}}}, true},
+		{"空文档", nil, bson.D{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonical(tt.a) == canonical(tt.b); got != tt.same {
+				t.Errorf("%s 与 %s 应%v相同", canonical(tt.a), canonical(tt.b), map[bool]string{true: "", false: "不"}[tt.same])
+			}
+		})
+	}
+}
+
+func TestIndexDiff(t *testing.T) {
+	keys := bson.D{{Key: "username", Value: 1}, {Key: "deleted", Value: 1}}
+	stored := bson.D{{Key: "username", Value: int32(1)}, {Key: "deleted", Value: int32(1)}}
+	partial := bson.D{{Key: "deleted", Value: 1}}
+	tests := []struct {
+		name    string
+		decl    Index
+		current existingIndex
+		want    string // 差异原因应包含的内容，为空表示一致
+	}{
+		{"一致", Index{Keys: keys, Unique: true, Partial: partial},
+			existingIndex{Key: stored, Unique: true, Partial: bson.D{{Key: "deleted", Value: int32(1)}}}, ""},
+		{"键顺序不同", Index{Keys: keys},
+			existingIndex{Key: bson.D{{Key: "deleted", Value: int32(1)}, {Key: "username", Value: int32(1)}}}, "键不一致"},
+		{"唯一性变化", Index{Keys: keys, Unique: true}, existingIndex{Key: stored}, "唯一性不一致"},
+		{"部分索引条件变化", Index{Keys: keys, Partial: partial},
+			existingIndex{Key: stored, Partial: bson.D{{Key: "deleted", Value: int32(0)}}}, "部分索引条件不一致"},
+		{"缺少部分索引条件", Index{Keys: keys, Partial: partial}, existingIndex{Key: stored}, "部分索引条件不一致"},
+		{"新增 TTL", Index{Keys: keys, TTL: time.Hour}, existingIndex{Key: stored}, "TTL 设置不一致"},
+		{"去掉 TTL", Index{Keys: keys}, existingIndex{Key: stored, ExpireAfterSeconds: int32(60)}, "TTL 设置不一致"},
+		{"仅 TTL 时长不同不算差异", Index{Keys: keys, TTL: time.Hour}, existingIndex{Key: stored, ExpireAfterSeconds: int32(60)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.decl.diff(tt.current)
+			if tt.want == "" && got != "" || tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("diff = %q，应包含 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindByKeys(t *testing.T) {
+	existing := map[string]existingIndex{
+		"_id_":     {Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+		"manual_1": {Name: "manual_1", Key: bson.D{{Key: "code", Value: int32(1)}, {Key: "status", Value: int32(1)}}},
+	}
+	tests := []struct {
+		name string
+		keys bson.D
+		want string
+	}{
+		{"相同的键", bson.D{{Key: "code", Value: 1}, {Key: "status", Value: 1}}, "manual_1"},
+		{"键顺序不同", bson.D{{Key: "status", Value: 1}, {Key: "code", Value: 1}}, ""},
+		{"方向不同", bson.D{{Key: "code", Value: 1}, {Key: "status", Value: -1}}, ""},
+		{"不存在", bson.D{{Key: "ip", Value: 1}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findByKeys(existing, tt.keys); got != tt.want {
+				t.Errorf("findByKeys = %q，应为 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanIndexes(t *testing.T) {
+	decl := collectionIndexes{Collection: "c", Indexes: []Index{
+		{Name: "code_unique", Keys: bson.D{{Key: "code", Value: 1}}, Unique: true},
+		{Name: "blocked_at_ttl", Keys: bson.D{{Key: "blocked_at", Value: 1}}, TTL: 30 * 24 * time.Hour},
+		{Name: "a_b", Keys: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}},
+	}}
+	idIndex := existingIndex{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}}
+	codeUnique := existingIndex{Name: "code_unique", Key: bson.D{{Key: "code", Value: int32(1)}}, Unique: true}
+	ttl := func(seconds int32) existingIndex {
+		return existingIndex{Name: "blocked_at_ttl", Key: bson.D{{Key: "blocked_at", Value: int32(1)}}, ExpireAfterSeconds: seconds}
+	}
+	ab := existingIndex{Name: "a_b", Key: bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(1)}}}
+
+	tests := []struct {
+		name       string
+		existing   []existingIndex
+		wantCreate []string
+		wantUpdate []string
+		wantDrift  []string // 格式 索引名: 原因片段
+	}{
+		{"集合为空时全部创建", nil,
+			[]string{"code_unique", "blocked_at_ttl", "a_b"}, []string{}, nil},
+		{"全部一致", []existingIndex{idIndex, codeUnique, ttl(30 * 24 * 3600), ab},
+			[]string{}, []string{}, nil},
+		{"缺少一个索引", []existingIndex{idIndex, codeUnique, ab},
+			[]string{"blocked_at_ttl"}, []string{}, nil},
+		{"TTL 时长变化原地修改", []existingIndex{codeUnique, ttl(3600), ab},
+			[]string{}, []string{"blocked_at_ttl"}, nil},
+		{"唯一性变化报告差异", []existingIndex{{Name: "code_unique", Key: bson.D{{Key: "code", Value: int32(1)}}}, ttl(30 * 24 * 3600), ab},
+			[]string{}, []string{}, []string{"code_unique: 唯一性不一致"}},
+		{"未声明的索引", []existingIndex{idIndex, codeUnique, ttl(30 * 24 * 3600), ab, {Name: "z_manual", Key: bson.D{{Key: "z", Value: int32(1)}}}},
+			[]string{}, []string{}, []string{"z_manual: 未声明的索引"}},
+		{"同键不同名的索引不重复创建", []existingIndex{codeUnique, ttl(30 * 24 * 3600), {Name: "a_1_b_1", Key: ab.Key}},
+			[]string{}, []string{}, []string{"a_b: 相同的键已存在于索引 a_1_b_1", "a_1_b_1: 未声明的索引"}},
+		{"同名索引键顺序不同", []existingIndex{codeUnique, ttl(30 * 24 * 3600), {Name: "a_b", Key: bson.D{{Key: "b", Value: int32(1)}, {Key: "a", Value: int32(1)}}}},
+			[]string{}, []string{}, []string{"a_b: 键不一致"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := make(map[string]existingIndex)
+			for _, idx := range tt.existing {
+				existing[idx.Name] = idx
+			}
+			plan := planIndexes(decl, existing)
+			if got := names(plan.Create); !reflect.DeepEqual(got, tt.wantCreate) {
+				t.Errorf("应创建 %v，实际 %v", tt.wantCreate, got)
+			}
+			if got := names(plan.UpdateTTL); !reflect.DeepEqual(got, tt.wantUpdate) {
+				t.Errorf("应修改 TTL %v，实际 %v", tt.wantUpdate, got)
+			}
+			if len(plan.Drift) != len(tt.wantDrift) {
+				t.Fatalf("应报告 %d 项差异，实际 %+v", len(tt.wantDrift), plan.Drift)
+			}
+			for i, d := range plan.Drift {
+				name, reason, _ := strings.Cut(tt.wantDrift[i], ": ")
+				if d.Collection != "c" || d.Index != name || !strings.Contains(d.Reason, reason) {
+					t.Errorf("第 %d 项差异应为 %s，实际 %+v", i, tt.wantDrift[i], d)
+				}
+			}
+		})
+	}
+}
@@ -21,3 +21,24 @@ func GetInviteCodeCollection() *mongo.Collection {
 func GetBlockIps() *mongo.Collection {
 	return db.GetStackBuilderCollection(config.BlockIps)
 }
+func GetInviteCampaignCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.InviteCampaign)
+}
+func GetInviteRedemptionCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.InviteRedeem)
+}
+func GetInviteGenerateJobCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.InviteGenJob)
+}
+func GetSchemaMigrationCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.SchemaMigrate)
+}
+func GetMigrationLockCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.MigrateLock)
+}
+func GetSessionTokenCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.SessionToken)
+}
+func GetFailedLoginCollection() *mongo.Collection {
+	return db.GetStackBuilderCollection(config.FailedLogin)
+}
@@ -2,50 +2,56 @@ package db
 
 import (
 	"context"
+	"errors"
 	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/tracing"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
 	"sync"
 	"time"
 )
 
-const (
-	dbLog = "[DB] "
-)
+var logger = logging.For("db")
 
 var (
 	clientInstance *mongo.Client
 	clientOnce     sync.Once
 )
 
-// DefaultTimeout 默认超时时间
-const DefaultTimeout = 10 * time.Second
-
-// GetCtx 返回一个带超时的 context
-func GetCtx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), DefaultTimeout)
+// WithTimeout 在 parent 的基础上附加单次操作超时（timeouts.operation_seconds），
+// 保留其中的请求 ID 与链路信息；parent 取消（如客户端断开）时同样提前结束
+func WithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, config.OperationTimeout())
 }
 
 // MongoClient 获取全局 MongoDB 客户端
 func MongoClient() func() {
 	clientOnce.Do(func() {
-		clientOptions := options.Client().ApplyURI(config.DBUrl)
+		clientOptions := options.Client().ApplyURI(config.DBUrl).SetMonitor(commandMonitor())
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		client, err := mongo.Connect(ctx, clientOptions)
 		if err != nil {
-			log.Fatalf(dbLog+"MongoDB 连接失败: %v", err)
+			logger.Error("MongoDB 连接失败", logging.Err(err))
+			os.Exit(1)
 		}
 
 		// 测试连通性
 		if err := client.Ping(ctx, nil); err != nil {
-			log.Fatalf(dbLog+"MongoDB Ping 失败: %v", err)
+			logger.Error("MongoDB Ping 失败", logging.Err(err))
+			os.Exit(1)
 		}
 
 		clientInstance = client
-		log.Println(dbLog + "MongoDB 连接成功")
+		logger.Info("MongoDB 连接成功")
 	})
 	return func() {
 		if clientInstance == nil {
@@ -55,11 +61,63 @@ func MongoClient() func() {
 		defer cancel()
 
 		if err := clientInstance.Disconnect(ctx); err != nil {
-			log.Printf(dbLog+"关闭 MongoDB 失败: %v\n", err)
+			logger.Error("关闭 MongoDB 失败", logging.Err(err))
 		} else {
-			log.Println(dbLog + "MongoDB 已关闭")
+			logger.Info("MongoDB 已关闭")
+		}
+	}
+}
+
+// commandMonitor 记录每条命令的耗时到 mongo_command_duration_seconds，并在命令的 context 下创建 span
+func commandMonitor() *event.CommandMonitor {
+	var spans sync.Map // commandKey -> trace.Span，命令开始时创建，结束时取出
+	end := func(connID string, requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(commandKey{connID, requestID}); ok {
+			tracing.End(span.(trace.Span), err)
 		}
 	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemNameMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+			}
+			// 命令文档的第一个元素为 命令名: 集合名，如 {find: "user"}
+			if first, err := e.Command.IndexErr(0); err == nil {
+				if coll, ok := first.Value().StringValueOK(); ok {
+					attrs = append(attrs, semconv.DBCollectionName(coll))
+				}
+			}
+			_, span := tracing.Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			if span.IsRecording() {
+				spans.Store(commandKey{e.ConnectionID, e.RequestID}, span)
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			metrics.ObserveMongo(e.CommandName, e.Duration, true)
+			end(e.ConnectionID, e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			metrics.ObserveMongo(e.CommandName, e.Duration, false)
+			end(e.ConnectionID, e.RequestID, errors.New(e.Failure))
+		},
+	}
+}
+
+// commandKey 标识一条命令，请求 ID 在连接内唯一
+type commandKey struct {
+	connID    string
+	requestID int64
+}
+
+// Ping 检查 MongoDB 连通性，客户端未初始化时返回错误
+func Ping(ctx context.Context) error {
+	if clientInstance == nil {
+		return errors.New("MongoDB 客户端未初始化")
+	}
+	return clientInstance.Ping(ctx, nil)
 }
 
 // GetStackBuilderCollection 获取StackBuilder的集合
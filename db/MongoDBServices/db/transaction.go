@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrTransactionsUnsupported 当前 MongoDB 部署（单机模式）不支持多文档事务
+var ErrTransactionsUnsupported = errors.New("当前 MongoDB 部署不支持事务")
+
+// transactionsUnsupported 首次探测到不支持事务后记录，后续调用直接返回，避免重复尝试
+var transactionsUnsupported atomic.Bool
+
+// WithTransaction 在多文档事务中执行 fn，fn 返回错误时整个事务回滚
+// 事务冲突等临时错误由驱动自动重试，因此 fn 必须可重复执行；
+// 单机部署不支持事务或客户端未初始化（如单元测试、使用内存存储）时返回 ErrTransactionsUnsupported，由调用方自行降级
+func WithTransaction(ctx context.Context, fn func(sessCtx context.Context) error) error {
+	if transactionsUnsupported.Load() {
+		return ErrTransactionsUnsupported
+	}
+	// 客户端未初始化时不记录探测结果，连接建立后仍可使用事务
+	if clientInstance == nil {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := clientInstance.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	if err != nil && isTransactionUnsupported(err) {
+		transactionsUnsupported.Store(true)
+		logger.WarnContext(ctx, "MongoDB 未部署为副本集，事务不可用，降级为补偿回滚")
+		return ErrTransactionsUnsupported
+	}
+	return err
+}
+
+// isTransactionUnsupported 判断错误是否由单机部署不支持事务引起
+func isTransactionUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 { // IllegalOperation
+		return true
+	}
+	return strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTransactionWithoutClient(t *testing.T) {
+	if clientInstance != nil {
+		t.Skip("MongoDB 客户端已初始化")
+	}
+	called := false
+	err := WithTransaction(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrTransactionsUnsupported) {
+		t.Errorf("客户端未初始化时应返回 ErrTransactionsUnsupported 以便调用方降级，实际 %v", err)
+	}
+	if called {
+		t.Error("客户端未初始化时不应执行 fn")
+	}
+	if transactionsUnsupported.Load() {
+		t.Error("客户端未初始化不应记录为部署不支持事务")
+	}
+}
@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsRandom"
+	"os"
+	"time"
+)
+
+const (
+	lockID = "migrations"
+	// lockTTL 锁租约时长，持有期间定期续约；进程崩溃后租约到期即可被其他实例获取
+	lockTTL = 30 * time.Second
+	// lockRetry 锁被占用时的重试间隔
+	lockRetry = time.Second
+)
+
+// ErrLockTimeout 等待迁移锁超时
+var ErrLockTimeout = errors.New("等待迁移锁超时")
+
+// withLock 获取迁移锁后执行 fn，执行完毕释放锁
+func withLock(ctx context.Context, store Store, fn func() error) error {
+	owner, err := lockOwner()
+	if err != nil {
+		return err
+	}
+	if err := acquireLock(ctx, store, owner); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	go renewLock(store, owner, stop)
+	defer func() {
+		close(stop)
+		releaseLock(store, owner)
+	}()
+	return fn()
+}
+
+// acquireLock 获取锁：锁不存在、已过期或本身持有时成功，否则重试直到 ctx 结束
+func acquireLock(ctx context.Context, store Store, owner string) error {
+	for {
+		now := time.Now()
+		ok, err := store.AcquireLock(ctx, owner, now, now.Add(lockTTL))
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		logger.InfoContext(ctx, "迁移锁被其他实例持有，等待中")
+		select {
+		case <-ctx.Done():
+			return ErrLockTimeout
+		case <-time.After(lockRetry):
+		}
+	}
+}
+
+// renewLock 定期续约，直到 stop 关闭
+func renewLock(store Store, owner string, stop <-chan struct{}) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), lockTTL/3)
+			err := store.RenewLock(ctx, owner, time.Now().Add(lockTTL))
+			cancel()
+			if err != nil {
+				logger.Warn("迁移锁续约失败", logging.Err(err))
+			}
+		}
+	}
+}
+
+// releaseLock 释放本实例持有的锁
+func releaseLock(store Store, owner string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := store.ReleaseLock(ctx, owner); err != nil {
+		logger.Warn("释放迁移锁失败", logging.Err(err))
+	}
+}
+
+// lockOwner 生成本次持锁标识：主机名-进程号-随机串
+func lockOwner() (string, error) {
+	host, _ := os.Hostname()
+	suffix, err := UtilsRandom.RandomAlphaNumString(8)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), suffix), nil
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 进程内迁移记录与迁移锁存储，用于测试；锁的语义与 MongoDB 实现一致
+type MemoryStore struct {
+	mu        sync.Mutex
+	applied   map[int]Record
+	owner     string
+	expiresAt time.Time
+}
+
+// NewMemoryStore 创建内存迁移存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{applied: make(map[int]Record)}
+}
+
+func (s *MemoryStore) Applied(context.Context) (map[int]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	applied := make(map[int]Record, len(s.applied))
+	for v, r := range s.applied {
+		applied[v] = r
+	}
+	return applied, nil
+}
+
+func (s *MemoryStore) MarkApplied(_ context.Context, m Migration, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied[m.Version] = Record{Version: m.Version, Name: m.Name, AppliedAt: at}
+	return nil
+}
+
+func (s *MemoryStore) MarkReverted(_ context.Context, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.applied, version)
+	return nil
+}
+
+func (s *MemoryStore) AcquireLock(_ context.Context, owner string, now, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != "" && s.owner != owner && s.expiresAt.After(now) {
+		return false, nil
+	}
+	s.owner, s.expiresAt = owner, until
+	return true, nil
+}
+
+func (s *MemoryStore) RenewLock(_ context.Context, owner string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == owner {
+		s.expiresAt = until
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReleaseLock(_ context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == owner {
+		s.owner, s.expiresAt = "", time.Time{}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package migrations MongoDB 集合结构迁移
+// 迁移按版本号顺序执行，执行记录保存在 schema_migrations 集合中；
+// 每个迁移必须可重复执行（幂等），执行前获取分布式锁，避免多实例同时迁移
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"os"
+	"time"
+)
+
+var logger = logging.For("migration")
+
+// Migration 一次结构迁移
+type Migration struct {
+	Version int                             // 版本号，严格递增
+	Name    string                          // 迁移名称
+	Up      func(ctx context.Context) error // 升级，必须幂等
+	Down    func(ctx context.Context) error // 回滚，为 nil 表示不可回滚
+}
+
+// Record schema_migrations 中的执行记录
+type Record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// State 迁移状态
+type State struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// startupTimeout 启动时迁移（含等待锁）的最长时间
+const startupTimeout = 10 * time.Minute
+
+// RunOnStartup 服务启动时执行全部未执行的迁移，失败时终止进程，避免新代码运行在旧结构上
+func RunOnStartup() {
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	done, err := Up(ctx)
+	if err != nil {
+		logger.Error("数据库迁移失败", logging.Err(err))
+		os.Exit(1)
+	}
+	if len(done) > 0 {
+		logger.Info("迁移执行完成", "count", len(done))
+	}
+}
+
+// Migrator 按迁移列表与执行记录执行迁移，依赖字段为 nil 时使用 registry 与 MongoDB 实现
+type Migrator struct {
+	Migrations []Migration // 全部迁移，按版本号递增排列
+	Store      Store       // 执行记录与迁移锁
+}
+
+func (mg Migrator) migrations() []Migration {
+	if mg.Migrations != nil {
+		return mg.Migrations
+	}
+	return registry
+}
+
+func (mg Migrator) store() Store {
+	if mg.Store != nil {
+		return mg.Store
+	}
+	return mongoStore{}
+}
+
+// Up 执行全部未执行的迁移，返回本次执行的迁移
+func Up(ctx context.Context) ([]Migration, error) {
+	return Migrator{}.Up(ctx)
+}
+
+// Up 执行全部未执行的迁移，返回本次执行的迁移；某个迁移失败时停止，之前执行的迁移保留执行记录
+func (mg Migrator) Up(ctx context.Context) ([]Migration, error) {
+	list := mg.migrations()
+	if err := validate(list); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err := withLock(ctx, mg.store(), func() error {
+		applied, err := mg.store().Applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending(list, applied) {
+			logger.InfoContext(ctx, "执行迁移", "version", m.Version, "name", m.Name)
+			if err := m.Up(ctx); err != nil {
+				return fmt.Errorf("迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+			}
+			if err := mg.store().MarkApplied(ctx, m, time.Now()); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本号倒序回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+func Down(ctx context.Context, steps int) ([]Migration, error) {
+	return Migrator{}.Down(ctx, steps)
+}
+
+// Down 按版本号倒序回滚最近执行的 steps 个迁移，返回本次回滚的迁移；
+// 其中有不可回滚的迁移时不做任何回滚
+func (mg Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	list := mg.migrations()
+	if err := validate(list); err != nil {
+		return nil, err
+	}
+	if steps <= 0 {
+		return nil, errors.New("回滚步数必须大于 0")
+	}
+	var done []Migration
+	err := withLock(ctx, mg.store(), func() error {
+		applied, err := mg.store().Applied(ctx)
+		if err != nil {
+			return err
+		}
+		plan, err := rollbackPlan(list, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, m := range plan {
+			logger.InfoContext(ctx, "回滚迁移", "version", m.Version, "name", m.Name)
+			if err := m.Down(ctx); err != nil {
+				return fmt.Errorf("回滚 %04d_%s 失败: %w", m.Version, m.Name, err)
+			}
+			if err := mg.store().MarkReverted(ctx, m.Version); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回所有迁移的执行状态
+func Status(ctx context.Context) ([]State, error) {
+	return Migrator{}.Status(ctx)
+}
+
+// Status 返回所有迁移的执行状态
+func (mg Migrator) Status(ctx context.Context) ([]State, error) {
+	applied, err := mg.store().Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := mg.migrations()
+	states := make([]State, 0, len(list))
+	for _, m := range list {
+		state := State{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = &r.AppliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// pending 按版本号顺序返回尚未执行的迁移
+func pending(list []Migration, applied map[int]Record) []Migration {
+	var out []Migration
+	for _, m := range list {
+		if _, ok := applied[m.Version]; !ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// rollbackPlan 按版本号倒序返回最近执行的 steps 个迁移，其中有不可回滚的迁移时返回错误
+func rollbackPlan(list []Migration, applied map[int]Record, steps int) ([]Migration, error) {
+	var plan []Migration
+	for i := len(list) - 1; i >= 0 && len(plan) < steps; i-- {
+		m := list[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return nil, fmt.Errorf("迁移 %04d_%s 不可回滚", m.Version, m.Name)
+		}
+		plan = append(plan, m)
+	}
+	return plan, nil
+}
+
+// validate 校验迁移版本号严格递增且 Up 不为空
+func validate(list []Migration) error {
+	for i, m := range list {
+		if m.Up == nil {
+			return fmt.Errorf("迁移 %04d_%s 缺少 Up", m.Version, m.Name)
+		}
+		if i > 0 && m.Version <= list[i-1].Version {
+			return fmt.Errorf("迁移版本号必须严格递增: %d 之后为 %d", list[i-1].Version, m.Version)
+		}
+	}
+	return nil
+}
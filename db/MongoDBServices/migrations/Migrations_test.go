@@ -0,0 +1,269 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder 记录迁移的执行顺序
+type recorder struct {
+	calls []string
+}
+
+// migration 创建记录执行顺序的迁移，failUp 为 true 时 Up 返回错误
+func (r *recorder) migration(version int, reversible, failUp bool) Migration {
+	m := Migration{Version: version, Name: "m" + string(rune('0'+version))}
+	m.Up = func(context.Context) error {
+		if failUp {
+			return errors.New("boom")
+		}
+		r.calls = append(r.calls, "up"+string(rune('0'+version)))
+		return nil
+	}
+	if reversible {
+		m.Down = func(context.Context) error {
+			r.calls = append(r.calls, "down"+string(rune('0'+version)))
+			return nil
+		}
+	}
+	return m
+}
+
+func versions(list []Migration) []int {
+	out := []int{}
+	for _, m := range list {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func appliedSet(vs ...int) map[int]Record {
+	applied := make(map[int]Record)
+	for _, v := range vs {
+		applied[v] = Record{Version: v}
+	}
+	return applied
+}
+
+func TestValidate(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	tests := []struct {
+		name    string
+		list    []Migration
+		wantErr string
+	}{
+		{"空列表", nil, ""},
+		{"严格递增", []Migration{{Version: 1, Up: up}, {Version: 2, Up: up}, {Version: 5, Up: up}}, ""},
+		{"缺少 Up", []Migration{{Version: 1, Name: "a"}}, "缺少 Up"},
+		{"版本号重复", []Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}, "严格递增"},
+		{"版本号递减", []Migration{{Version: 2, Up: up}, {Version: 1, Up: up}}, "严格递增"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.list)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("不应返回错误，实际 %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("应返回包含 %q 的错误，实际 %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	var r recorder
+	list := []Migration{r.migration(1, true, false), r.migration(2, true, false), r.migration(3, true, false)}
+	tests := []struct {
+		name    string
+		applied map[int]Record
+		want    []int
+	}{
+		{"全部未执行", appliedSet(), []int{1, 2, 3}},
+		{"部分已执行", appliedSet(1), []int{2, 3}},
+		{"中间有缺口", appliedSet(1, 3), []int{2}},
+		{"全部已执行", appliedSet(1, 2, 3), []int{}},
+		{"记录中有未知版本", appliedSet(1, 9), []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versions(pending(list, tt.applied)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("应执行 %v，实际 %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRollbackPlan(t *testing.T) {
+	var r recorder
+	list := []Migration{r.migration(1, false, false), r.migration(2, true, false), r.migration(3, true, false)}
+	tests := []struct {
+		name    string
+		applied map[int]Record
+		steps   int
+		want    []int
+		wantErr bool
+	}{
+		{"回滚最近一个", appliedSet(1, 2, 3), 1, []int{3}, false},
+		{"按版本号倒序", appliedSet(1, 2, 3), 2, []int{3, 2}, false},
+		{"跳过未执行的版本", appliedSet(1, 2), 1, []int{2}, false},
+		{"步数超过已执行数量", appliedSet(2, 3), 5, []int{3, 2}, false},
+		{"没有已执行的迁移", appliedSet(), 1, []int{}, false},
+		{"包含不可回滚的迁移", appliedSet(1, 2, 3), 3, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := rollbackPlan(list, tt.applied, tt.steps)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("应返回错误，实际回滚 %v", versions(plan))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rollbackPlan: %v", err)
+			}
+			if got := versions(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("应回滚 %v，实际 %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	var r recorder
+	store := NewMemoryStore()
+	mg := Migrator{
+		Migrations: []Migration{r.migration(1, true, false), r.migration(2, true, false), r.migration(3, true, false)},
+		Store:      store,
+	}
+
+	done, err := mg.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("应执行 [1 2 3]，实际 %v", got)
+	}
+	if done, _ := mg.Up(ctx); len(done) != 0 {
+		t.Errorf("重复执行 Up 不应再执行迁移，实际 %v", versions(done))
+	}
+
+	if done, err = mg.Down(ctx, 2); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int{3, 2}) {
+		t.Errorf("应回滚 [3 2]，实际 %v", got)
+	}
+	want := []string{"up1", "up2", "up3", "down3", "down2"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("执行顺序应为 %v，实际 %v", want, r.calls)
+	}
+
+	states, err := mg.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !states[0].Applied || states[1].Applied || states[2].Applied {
+		t.Errorf("回滚后只有版本 1 已执行: %+v", states)
+	}
+	if _, err := mg.Down(ctx, 0); err == nil {
+		t.Error("回滚步数为 0 应返回错误")
+	}
+}
+
+func TestMigratorUpStopsOnFailure(t *testing.T) {
+	ctx := context.Background()
+	var r recorder
+	store := NewMemoryStore()
+	mg := Migrator{
+		Migrations: []Migration{r.migration(1, true, false), r.migration(2, true, true), r.migration(3, true, false)},
+		Store:      store,
+	}
+	done, err := mg.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "0002_m2") {
+		t.Errorf("应返回迁移 2 的错误，实际 %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("失败前只应执行 [1]，实际 %v", got)
+	}
+	applied, _ := store.Applied(ctx)
+	if !reflect.DeepEqual(applied, map[int]Record{1: applied[1]}) {
+		t.Errorf("只应记录版本 1，实际 %v", applied)
+	}
+
+	// 不可回滚的迁移在计划阶段即报错，不会回滚任何迁移
+	mg.Migrations[0].Down = nil
+	if _, err := mg.Down(ctx, 1); err == nil {
+		t.Error("不可回滚的迁移应返回错误")
+	}
+	if applied, _ := store.Applied(ctx); len(applied) != 1 {
+		t.Errorf("回滚失败不应删除执行记录，实际 %v", applied)
+	}
+}
+
+func TestLockExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+	tests := []struct {
+		name  string
+		owner string
+		now   time.Time
+		want  bool
+	}{
+		{"锁不存在时取得", "a", now, true},
+		{"租约有效期间其他实例不能取得", "b", now.Add(lockTTL / 2), false},
+		{"持有者可以重复取得（续约）", "a", now.Add(lockTTL / 2), true},
+		{"续约后原到期时间不再有效", "b", now.Add(lockTTL), false},
+		{"租约到期后其他实例可以取得", "b", now.Add(lockTTL/2 + lockTTL), true},
+		{"被取得后原持有者不能取得", "a", now.Add(lockTTL/2 + lockTTL), false},
+	}
+	for _, tt := range tests {
+		ok, err := store.AcquireLock(ctx, tt.owner, tt.now, tt.now.Add(lockTTL))
+		if err != nil || ok != tt.want {
+			t.Errorf("%s: 应为 %v，实际 %v（%v）", tt.name, tt.want, ok, err)
+		}
+	}
+
+	// 只有持有者能释放锁
+	if err := store.ReleaseLock(ctx, "a"); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	if ok, _ := store.AcquireLock(ctx, "c", now.Add(lockTTL*2), now.Add(lockTTL*3)); ok {
+		t.Error("非持有者释放后锁仍应被 b 持有")
+	}
+	_ = store.ReleaseLock(ctx, "b")
+	if ok, _ := store.AcquireLock(ctx, "c", now.Add(lockTTL*2), now.Add(lockTTL*3)); !ok {
+		t.Error("持有者释放后应可立即取得")
+	}
+}
+
+func TestWithLockTimesOut(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	if ok, _ := store.AcquireLock(context.Background(), "other", now, now.Add(time.Hour)); !ok {
+		t.Fatal("预先占用锁失败")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := withLock(ctx, store, func() error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, ErrLockTimeout) || ran {
+		t.Errorf("锁被其他实例持有时应返回 ErrLockTimeout 且不执行，实际 %v, ran=%v", err, ran)
+	}
+}
+
+func TestRegistryIsValid(t *testing.T) {
+	if err := validate(registry); err != nil {
+		t.Fatalf("registry 无效: %v", err)
+	}
+}
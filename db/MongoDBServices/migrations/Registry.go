@@ -0,0 +1,123 @@
+package migrations
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	InviteCodeService "github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// timeFormat 旧版字符串时间格式（对应 UtilsTime 的 "2006-01-02 15:04:05"）
+const timeFormat = "%Y-%m-%d %H:%M:%S"
+
+// registry 全部迁移，按版本号递增排列；新增迁移追加到末尾，已发布的迁移不可修改
+var registry = []Migration{
+	{
+		Version: 1,
+		Name:    "invite_code_expiry_time",
+		Up: func(ctx context.Context) error {
+			_, err := InviteCodeService.MigrateExpirySchema(ctx)
+			return err
+		},
+		Down: InviteCodeService.RevertExpirySchema,
+	},
+	{
+		Version: 2,
+		Name:    "block_ips_blocked_at_time",
+		Up: func(ctx context.Context) error {
+			return stringToDate(ctx, "blocked_at")
+		},
+		Down: func(ctx context.Context) error {
+			return dateToString(ctx, "blocked_at")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "user_drop_time_strings",
+		Up: func(ctx context.Context) error {
+			_, err := collections.GetUserCollection().UpdateMany(ctx,
+				bson.M{"$or": bson.A{
+					bson.M{"create_at_str": bson.M{"$exists": true}},
+					bson.M{"update_at_str": bson.M{"$exists": true}},
+				}},
+				bson.M{"$unset": bson.M{"create_at_str": "", "update_at_str": ""}},
+			)
+			return err
+		},
+		Down: func(ctx context.Context) error {
+			_, err := collections.GetUserCollection().UpdateMany(ctx,
+				bson.M{"create_at_str": bson.M{"$exists": false}},
+				bson.A{bson.M{"$set": bson.M{
+					"create_at_str": formatDate("$create_at"),
+					"update_at_str": formatDate("$update_at"),
+				}}},
+			)
+			return err
+		},
+	},
+}
+
+// maxReportedIDs 无法解析的时间最多在日志中列出的文档数
+const maxReportedIDs = 20
+
+// stringToDate 将 block_ips 中字符串格式的时间字段转换为日期类型（服务器本地时区）
+// 无法解析的值保持原样不做修改，并在日志中列出对应文档的 _id，由人工处理
+func stringToDate(ctx context.Context, field string) error {
+	filter := bson.M{field: bson.M{"$type": "string"}}
+	_, err := collections.GetBlockIps().UpdateMany(ctx, filter,
+		bson.A{bson.M{"$set": bson.M{field: bson.M{"$dateFromString": bson.M{
+			"dateString": "$" + field,
+			"format":     timeFormat,
+			"timezone":   localOffset(),
+			"onError":    "$" + field,
+		}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	left, err := collections.GetBlockIps().CountDocuments(ctx, filter)
+	if err != nil || left == 0 {
+		return err
+	}
+	cursor, err := collections.GetBlockIps().Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(maxReportedIDs))
+	if err != nil {
+		return err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID.Hex())
+	}
+	logger.WarnContext(ctx, "部分时间无法解析，已保持原值", "collection", "block_ips", "field", field,
+		"count", left, "ids", ids)
+	return nil
+}
+
+// dateToString 将 block_ips 中日期类型的时间字段还原为字符串
+func dateToString(ctx context.Context, field string) error {
+	_, err := collections.GetBlockIps().UpdateMany(ctx,
+		bson.M{field: bson.M{"$type": "date"}},
+		bson.A{bson.M{"$set": bson.M{field: formatDate("$" + field)}}},
+	)
+	return err
+}
+
+// formatDate 聚合表达式：按旧版格式将日期字段格式化为字符串
+func formatDate(expr string) bson.M {
+	return bson.M{"$dateToString": bson.M{"date": expr, "format": timeFormat, "timezone": localOffset()}}
+}
+
+// localOffset 服务器本地时区偏移，如 +08:00；旧数据的字符串时间均按本地时区写入
+func localOffset() string {
+	return time.Now().Format("-07:00")
+}
@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Store 迁移执行记录与迁移锁的存储
+type Store interface {
+	// Applied 已执行的迁移记录，键为版本号
+	Applied(ctx context.Context) (map[int]Record, error)
+	// MarkApplied 记录迁移已执行
+	MarkApplied(ctx context.Context, m Migration, at time.Time) error
+	// MarkReverted 删除迁移的执行记录
+	MarkReverted(ctx context.Context, version int) error
+	// AcquireLock 锁不存在、已过期（到期时间不晚于 now）或已由 owner 持有时，将锁设为 owner 持有至 until，返回是否取得
+	AcquireLock(ctx context.Context, owner string, now, until time.Time) (bool, error)
+	// RenewLock 将 owner 持有的锁延长至 until
+	RenewLock(ctx context.Context, owner string, until time.Time) error
+	// ReleaseLock 释放 owner 持有的锁
+	ReleaseLock(ctx context.Context, owner string) error
+}
+
+// =======================================
+//          MongoDB 实现
+// =======================================
+
+type mongoStore struct{}
+
+func (mongoStore) Applied(ctx context.Context) (map[int]Record, error) {
+	cursor, err := collections.GetSchemaMigrationCollection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	applied := make(map[int]Record)
+	for cursor.Next(ctx) {
+		var r Record
+		if err := cursor.Decode(&r); err != nil {
+			return nil, err
+		}
+		applied[r.Version] = r
+	}
+	return applied, cursor.Err()
+}
+
+func (mongoStore) MarkApplied(ctx context.Context, m Migration, at time.Time) error {
+	_, err := collections.GetSchemaMigrationCollection().UpdateOne(ctx,
+		bson.M{"_id": m.Version},
+		bson.M{"$set": bson.M{"name": m.Name, "applied_at": at}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (mongoStore) MarkReverted(ctx context.Context, version int) error {
+	_, err := collections.GetSchemaMigrationCollection().DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
+
+// AcquireLock 锁被其他实例持有时，upsert 的过滤条件不匹配，插入相同 _id 触发重复键错误
+func (mongoStore) AcquireLock(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	_, err := collections.GetMigrationLockCollection().UpdateOne(ctx,
+		bson.M{"_id": lockID, "$or": bson.A{
+			bson.M{"expires_at": bson.M{"$lte": now}},
+			bson.M{"owner": owner},
+		}},
+		bson.M{"$set": bson.M{"owner": owner, "acquired_at": now, "expires_at": until}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (mongoStore) RenewLock(ctx context.Context, owner string, until time.Time) error {
+	_, err := collections.GetMigrationLockCollection().UpdateOne(ctx,
+		bson.M{"_id": lockID, "owner": owner},
+		bson.M{"$set": bson.M{"expires_at": until}},
+	)
+	return err
+}
+
+func (mongoStore) ReleaseLock(ctx context.Context, owner string) error {
+	_, err := collections.GetMigrationLockCollection().DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner})
+	return err
+}
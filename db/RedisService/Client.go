@@ -2,21 +2,251 @@ package RedisService
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
 )
 
-var Ctx = context.Background()
-var RedisClient *redis.Client
+var logger = logging.For("redis")
 
-func init() {
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     config.RedisAddr,
-		Password: config.RedisPassword, // 没有密码就 ""
-		DB:       config.RedisDB,       // 默认 0
+// 存储模式
+const (
+	ModeRedis  = "redis"  // 使用 Redis，多实例共享令牌与登录失败记录
+	ModeMemory = "memory" // 使用进程内存储，仅适用于单实例部署
+)
+
+// ErrNil 键不存在
+var ErrNil = redis.Nil
+
+// Store 令牌与登录失败记录使用的键值存储
+type Store interface {
+	// Get 获取字符串值，键不存在时返回 ErrNil
+	Get(ctx context.Context, key string) (string, error)
+	// Set 写入字符串值，ttl 为 0 时不过期
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	// HSet 写入哈希字段
+	HSet(ctx context.Context, key string, values map[string]interface{}) error
+	// HMGet 读取哈希字段，字段不存在的位置为 nil，存在的为字符串
+	HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error)
+	// Ping 健康检查
+	Ping(ctx context.Context) error
+	// Close 释放连接
+	Close() error
+}
+
+// =======================================
+//          Redis 实现
+// =======================================
+
+// Options Redis 连接参数
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	// MaxRetries 单条命令失败后的重试次数
+	MaxRetries int
+	// MinBackoff / MaxBackoff 重试退避时间范围，也用于启动时的连接重试
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client 基于 go-redis 的存储，创建时不建立连接，首次执行命令时才连接
+type Client struct {
+	rdb *redis.Client
+}
+
+// NewClient 创建 Redis 存储，命令耗时记录到 redis_command_duration_seconds，并在命令的 context 下创建 span
+func NewClient(opts Options) *Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:            opts.Addr,
+		Password:        opts.Password, // 没有密码就 ""
+		DB:              opts.DB,       // 默认 0
+		MaxRetries:      opts.MaxRetries,
+		MinRetryBackoff: opts.MinBackoff,
+		MaxRetryBackoff: opts.MaxBackoff,
 	})
-	_, err := RedisClient.Ping(Ctx).Result()
-	if err != nil {
-		panic("Redis 连接失败: " + err.Error())
+	rdb.AddHook(observeHook{})
+	return &Client{rdb: rdb}
+}
+
+// observeHook 记录每条命令的耗时与 span，键不存在（redis.Nil）不算失败
+type observeHook struct{}
+
+func (observeHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (observeHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startSpan(ctx, cmd.Name())
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.ObserveRedis(cmd.Name(), time.Since(start), commandOK(err))
+		endSpan(span, err)
+		return err
+	}
+}
+
+func (observeHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startSpan(ctx, "pipeline", attribute.Int("db.operation.batch.size", len(cmds)))
+		start := time.Now()
+		err := next(ctx, cmds)
+		metrics.ObserveRedis("pipeline", time.Since(start), commandOK(err))
+		endSpan(span, err)
+		return err
+	}
+}
+
+func commandOK(err error) bool {
+	return err == nil || errors.Is(err, redis.Nil)
+}
+
+func startSpan(ctx context.Context, command string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemNameRedis, semconv.DBOperationName(command))
+	return tracing.Start(ctx, "redis."+command, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if commandOK(err) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	return c.rdb.Get(ctx, key).Result()
+}
+
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) error {
+	return c.rdb.Del(ctx, keys...).Err()
+}
+
+func (c *Client) HSet(ctx context.Context, key string, values map[string]interface{}) error {
+	return c.rdb.HSet(ctx, key, values).Err()
+}
+
+func (c *Client) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return c.rdb.HMGet(ctx, key, fields...).Result()
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
+
+// =======================================
+//          全局存储与生命周期
+// =======================================
+
+var (
+	mu      sync.Mutex
+	current Store
+)
+
+// Default 返回全局存储，未初始化时按配置创建（不建立连接），
+// 因此仅导入本包或 OperateToken 等包不需要 Redis 可用
+func Default() Store {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = newFromConfig()
+	}
+	return current
+}
+
+// SetDefault 替换全局存储，返回原存储；用于测试或单机模式注入
+func SetDefault(s Store) Store {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := current
+	current = s
+	return prev
+}
+
+// newFromConfig 按 REDIS_MODE 创建存储
+func newFromConfig() Store {
+	if config.RedisMode == ModeMemory {
+		logger.Warn("单机模式: 令牌与登录失败记录保存在进程内存中，重启后失效")
+		return NewMemoryStore()
+	}
+	return NewClient(Options{
+		Addr:       config.RedisAddr,
+		Password:   config.RedisPassword,
+		DB:         config.RedisDB,
+		MaxRetries: config.RedisMaxRetries,
+		MinBackoff: config.RedisMinBackoff,
+		MaxBackoff: config.RedisMaxBackoff,
+	})
+}
+
+// Init 服务启动时调用：创建全局存储并做健康检查，连接失败按指数退避重试
+func Init(ctx context.Context) error {
+	if config.RedisMode != ModeRedis && config.RedisMode != ModeMemory {
+		return fmt.Errorf("未知的 REDIS_MODE: %q，可选 %s / %s", config.RedisMode, ModeRedis, ModeMemory)
+	}
+	return Connect(ctx, Default(), config.RedisMaxRetries, config.RedisMinBackoff, config.RedisMaxBackoff)
+}
+
+// Connect 检查存储连通性，失败后按 minBackoff 起翻倍（不超过 maxBackoff）等待重试，共 retries 次
+func Connect(ctx context.Context, s Store, retries int, minBackoff, maxBackoff time.Duration) error {
+	backoff := minBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = s.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			break
+		}
+		logger.WarnContext(ctx, "连接失败，稍后重试", "attempt", attempt+1, "backoff", backoff.String(), logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	return fmt.Errorf("重试 %d 次后仍无法连接: %w", retries, err)
+}
+
+// Ping 检查全局存储连通性
+func Ping(ctx context.Context) error {
+	return Default().Ping(ctx)
+}
+
+// Close 关闭全局存储，服务退出时调用；之后再次使用会重新创建
+func Close() error {
+	mu.Lock()
+	s := current
+	current = nil
+	mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	if err := s.Close(); err != nil {
+		return err
 	}
+	logger.Info("已关闭")
+	return nil
 }
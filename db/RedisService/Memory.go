@@ -0,0 +1,151 @@
+package RedisService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errWrongType 对字符串键执行哈希操作或反之，与 Redis WRONGTYPE 错误对应
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// sweepInterval 过期键的后台清理间隔
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	value    string
+	hash     map[string]string
+	expireAt time.Time // 零值表示不过期
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// MemoryStore 嵌入式内存存储，语义与所用的 Redis 命令一致，用于单机模式与测试；
+// 过期键在读取时清除，并由后台定时清理
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryStore 创建内存存储并启动过期清理，使用完毕需调用 Close
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{entries: make(map[string]memoryEntry), stop: make(chan struct{})}
+	go s.sweepLoop()
+	return s
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.load(key)
+	if !ok {
+		return "", ErrNil
+	}
+	if e.hash != nil {
+		return "", errWrongType
+	}
+	return e.value, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryStore) Del(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) HSet(_ context.Context, key string, values map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.load(key)
+	if ok && e.hash == nil {
+		return errWrongType
+	}
+	if !ok {
+		e = memoryEntry{hash: make(map[string]string, len(values))}
+	}
+	for field, v := range values {
+		e.hash[field] = fmt.Sprint(v)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryStore) HMGet(_ context.Context, key string, fields ...string) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vals := make([]interface{}, len(fields))
+	e, ok := s.load(key)
+	if !ok {
+		return vals, nil
+	}
+	if e.hash == nil {
+		return nil, errWrongType
+	}
+	for i, field := range fields {
+		if v, ok := e.hash[field]; ok {
+			vals[i] = v
+		}
+	}
+	return vals, nil
+}
+
+func (s *MemoryStore) Ping(context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+// load 读取未过期的键，已过期的键顺带删除；调用方需持有锁
+func (s *MemoryStore) load(key string) (memoryEntry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return e, false
+	}
+	if e.expired(time.Now()) {
+		delete(s.entries, key)
+		return e, false
+	}
+	return e, true
+}
+
+func (s *MemoryStore) sweepLoop() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, e := range s.entries {
+				if e.expired(now) {
+					delete(s.entries, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -1,49 +1,53 @@
 package OperateToken
 
 import (
+	"context"
+	"errors"
 	"github.com/StephenChristianW/go-movies-open/db/RedisService"
 	"time"
 )
 
-var redisClient = RedisService.RedisClient
-var ctx = RedisService.Ctx
+// store 每次调用时获取全局存储，导入本包不会建立连接
+func store() RedisService.Store {
+	return RedisService.Default()
+}
 
-func StoreAdminToken(adminID, token string, expire time.Duration) error {
+func StoreAdminToken(ctx context.Context, adminID, token string, expire time.Duration) error {
 	key := "admin_token:" + adminID
-	return redisClient.Set(ctx, key, token, expire).Err()
+	return store().Set(ctx, key, token, expire)
 }
-func GetAdminToken(adminID string) (string, error) {
+func GetAdminToken(ctx context.Context, adminID string) (string, error) {
 	key := "admin_token:" + adminID
-	token, err := redisClient.Get(ctx, key).Result()
+	token, err := store().Get(ctx, key)
 	if err != nil {
-		if err.Error() != "redis: nil" {
+		if !errors.Is(err, RedisService.ErrNil) {
 			return "", err
 		}
 		return "", nil
 	}
 	return token, nil
 }
-func DeleteAdminToken(adminID string) error {
+func DeleteAdminToken(ctx context.Context, adminID string) error {
 	key := "admin_token:" + adminID
-	return redisClient.Del(ctx, key).Err()
+	return store().Del(ctx, key)
 }
 
-func StoreUserToken(userID, token string, expire time.Duration) error {
+func StoreUserToken(ctx context.Context, userID, token string, expire time.Duration) error {
 	key := "user_token:" + userID
-	return redisClient.Set(ctx, key, token, expire).Err()
+	return store().Set(ctx, key, token, expire)
 }
-func GetUserToken(userID string) (string, error) {
+func GetUserToken(ctx context.Context, userID string) (string, error) {
 	key := "user_token:" + userID
-	token, err := redisClient.Get(ctx, key).Result()
+	token, err := store().Get(ctx, key)
 	if err != nil {
-		if err.Error() != "redis: nil" {
+		if !errors.Is(err, RedisService.ErrNil) {
 			return "", err
 		}
 		return "", nil
 	}
 	return token, nil
 }
-func DeleteUserToken(userID string) error {
+func DeleteUserToken(ctx context.Context, userID string) error {
 	key := "user_token:" + userID
-	return redisClient.Del(ctx, key).Err()
+	return store().Del(ctx, key)
 }
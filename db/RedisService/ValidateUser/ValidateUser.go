@@ -1,33 +1,36 @@
 package ValidateUser
 
 import (
+	"context"
 	"errors"
 	"github.com/StephenChristianW/go-movies-open/db/RedisService"
 	"strconv"
 	"time"
 )
 
-var redisClient = RedisService.RedisClient
-var ctx = RedisService.Ctx
+// store 每次调用时获取全局存储，导入本包不会建立连接
+func store() RedisService.Store {
+	return RedisService.Default()
+}
 
 type FailedLoginInfo struct {
 	Count int       `json:"count"`
 	Last  time.Time `json:"last"`
 }
 
-func SetFailedLoginInfo(userID string, info FailedLoginInfo) error {
+func SetFailedLoginInfo(ctx context.Context, userID string, info FailedLoginInfo) error {
 	key := "login:failed:" + userID
 	data := map[string]interface{}{
 		"count": info.Count,
 		"last":  info.Last.Unix(),
 	}
-	return redisClient.HSet(ctx, key, data).Err()
+	return store().HSet(ctx, key, data)
 }
 
-func getFailedLoginInfo(userID string) (FailedLoginInfo, error) {
+func getFailedLoginInfo(ctx context.Context, userID string) (FailedLoginInfo, error) {
 	key := "login:failed:" + userID
 	var info FailedLoginInfo
-	vals, err := redisClient.HMGet(ctx, key, "count", "last").Result()
+	vals, err := store().HMGet(ctx, key, "count", "last")
 	if err != nil {
 		return info, err
 	}
@@ -55,8 +58,8 @@ func getFailedLoginInfo(userID string) (FailedLoginInfo, error) {
 	return info, nil
 }
 
-func HasFailedLoginRecord(userID string) (bool, FailedLoginInfo, error) {
-	info, err := getFailedLoginInfo(userID)
+func HasFailedLoginRecord(ctx context.Context, userID string) (bool, FailedLoginInfo, error) {
+	info, err := getFailedLoginInfo(ctx, userID)
 	if err != nil {
 		// Redis 异常或数据错误，可以当作没有记录
 		return false, info, err
@@ -69,7 +72,7 @@ func HasFailedLoginRecord(userID string) (bool, FailedLoginInfo, error) {
 
 	return true, info, nil
 }
-func DeleteFailedLoginRecord(userID string) error {
+func DeleteFailedLoginRecord(ctx context.Context, userID string) error {
 	key := "login:failed:" + userID
-	return redisClient.Del(ctx, key).Err()
+	return store().Del(ctx, key)
 }
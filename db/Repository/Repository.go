@@ -0,0 +1,102 @@
+// Package Repository 存储层公共定义
+// 各服务在自己的包内声明仓储接口，并提供 MongoDB 与内存两种实现：
+// 服务结构体中的仓储字段为 nil 时使用 MongoDB 实现，测试时注入内存实现即可脱离数据库运行。
+// 内存实现与 MongoDB 实现保持相同的错误语义：查询不到返回 mongo.ErrNoDocuments，
+// 违反唯一约束返回可被 mongo.IsDuplicateKeyError 识别的错误
+package Repository
+
+import (
+	"bytes"
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound 查询不到数据，与 MongoDB 驱动保持一致
+var ErrNotFound = mongo.ErrNoDocuments
+
+// DuplicateKeyError 构造唯一约束冲突错误，供内存实现模拟唯一索引
+func DuplicateKeyError(index string) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: "E11000 duplicate key error index: " + index,
+	}}}
+}
+
+// =======================================
+//          事务
+// =======================================
+
+// Transactor 多文档事务执行器
+type Transactor interface {
+	// WithTransaction 在事务中执行 fn；不支持事务时返回 db.ErrTransactionsUnsupported，由调用方降级
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// MongoTransactor 使用 MongoDB 会话事务
+type MongoTransactor struct{}
+
+func (MongoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.WithTransaction(ctx, fn)
+}
+
+// NoTransactor 不支持事务，调用方走补偿回滚逻辑；用于内存实现
+type NoTransactor struct{}
+
+func (NoTransactor) WithTransaction(context.Context, func(ctx context.Context) error) error {
+	return db.ErrTransactionsUnsupported
+}
+
+// =======================================
+//          内存实现工具函数
+// =======================================
+
+// Paginate 按页截取，页码与条数的处理与 db.CalculatePagination 一致
+func Paginate[T any](items []T, page, pageSize int) []T {
+	if pageSize == 0 {
+		pageSize = 10
+	}
+	skip := max((page-1)*pageSize, 0)
+	if skip >= len(items) {
+		return nil
+	}
+	return items[skip:min(skip+pageSize, len(items))]
+}
+
+// ApplySet 按 bson 字段名将 set 写入 doc（doc 必须为结构体指针），模拟 $set
+// 返回文档是否发生变化，对应 MongoDB 的 ModifiedCount
+func ApplySet(doc interface{}, set bson.M) (bool, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return false, err
+	}
+	for k, v := range set {
+		fields[k] = v
+	}
+	updated, err := bson.Marshal(fields)
+	if err != nil {
+		return false, err
+	}
+	if err := bson.Unmarshal(updated, doc); err != nil {
+		return false, err
+	}
+	after, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(raw, after), nil
+}
+
+// Convert 按 bson 字段名将 src 复制到 dst（结构体指针），用于写入结构与数据库结构之间的转换
+func Convert(src, dst interface{}) error {
+	raw, err := bson.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, dst)
+}
@@ -0,0 +1,302 @@
+package Repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService/OperateToken"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService/ValidateUser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// TokenKind 令牌归属
+type TokenKind string
+
+const (
+	AdminToken TokenKind = "admin" // 管理员访问令牌
+	UserToken  TokenKind = "user"  // 普通用户访问令牌
+)
+
+// FailedLoginInfo 登录失败记录
+type FailedLoginInfo = ValidateUser.FailedLoginInfo
+
+// TokenStore 访问令牌存储，每个账号同一时间只有一个有效令牌
+type TokenStore interface {
+	// Get 获取令牌，不存在时返回空字符串
+	Get(ctx context.Context, kind TokenKind, id string) (string, error)
+	Set(ctx context.Context, kind TokenKind, id, token string, ttl time.Duration) error
+	Delete(ctx context.Context, kind TokenKind, id string) error
+}
+
+// FailedLoginStore 登录失败次数存储
+type FailedLoginStore interface {
+	// Get 获取失败记录，没有记录时 ok 为 false
+	Get(ctx context.Context, userID string) (info FailedLoginInfo, ok bool, err error)
+	Set(ctx context.Context, userID string, info FailedLoginInfo) error
+	Delete(ctx context.Context, userID string) error
+}
+
+// =======================================
+//          按配置选择实现
+// =======================================
+
+var (
+	sessionOnce  sync.Once
+	tokenStore   TokenStore
+	failedLogins FailedLoginStore
+)
+
+// DefaultTokenStore 按 SESSION_STORE 返回全局令牌存储，服务未注入存储时使用
+func DefaultTokenStore() TokenStore {
+	sessionOnce.Do(initSessionStores)
+	return tokenStore
+}
+
+// DefaultFailedLoginStore 按 SESSION_STORE 返回全局登录失败记录存储
+func DefaultFailedLoginStore() FailedLoginStore {
+	sessionOnce.Do(initSessionStores)
+	return failedLogins
+}
+
+// initSessionStores 创建全局存储；内存实现必须全局唯一，登录写入的令牌才能被鉴权中间件读到
+func initSessionStores() {
+	switch config.SessionStore {
+	case config.SessionMongo:
+		tokenStore, failedLogins = MongoTokenStore{}, MongoFailedLoginStore{}
+	case config.SessionMemory:
+		tokenStore, failedLogins = NewMemoryTokenStore(), NewMemoryFailedLoginStore()
+	default:
+		tokenStore, failedLogins = RedisTokenStore{}, RedisFailedLoginStore{}
+	}
+}
+
+// InitSessionStores 服务启动时调用：校验 SESSION_STORE，使用 Redis 时检查连通性
+func InitSessionStores(ctx context.Context) error {
+	switch config.SessionStore {
+	case config.SessionRedis:
+		return RedisService.Init(ctx)
+	case config.SessionMongo, config.SessionMemory:
+		return nil
+	default:
+		return fmt.Errorf("未知的 SESSION_STORE: %q，可选 %s / %s / %s",
+			config.SessionStore, config.SessionRedis, config.SessionMongo, config.SessionMemory)
+	}
+}
+
+// =======================================
+//          Redis 实现
+// =======================================
+
+// RedisTokenStore 基于 Redis 的令牌存储
+type RedisTokenStore struct{}
+
+func (RedisTokenStore) Get(ctx context.Context, kind TokenKind, id string) (string, error) {
+	if kind == AdminToken {
+		return OperateToken.GetAdminToken(ctx, id)
+	}
+	return OperateToken.GetUserToken(ctx, id)
+}
+
+func (RedisTokenStore) Set(ctx context.Context, kind TokenKind, id, token string, ttl time.Duration) error {
+	if kind == AdminToken {
+		return OperateToken.StoreAdminToken(ctx, id, token, ttl)
+	}
+	return OperateToken.StoreUserToken(ctx, id, token, ttl)
+}
+
+func (RedisTokenStore) Delete(ctx context.Context, kind TokenKind, id string) error {
+	if kind == AdminToken {
+		return OperateToken.DeleteAdminToken(ctx, id)
+	}
+	return OperateToken.DeleteUserToken(ctx, id)
+}
+
+// RedisFailedLoginStore 基于 Redis 的登录失败记录存储
+type RedisFailedLoginStore struct{}
+
+func (RedisFailedLoginStore) Get(ctx context.Context, userID string) (FailedLoginInfo, bool, error) {
+	ok, info, err := ValidateUser.HasFailedLoginRecord(ctx, userID)
+	return info, ok, err
+}
+
+func (RedisFailedLoginStore) Set(ctx context.Context, userID string, info FailedLoginInfo) error {
+	return ValidateUser.SetFailedLoginInfo(ctx, userID, info)
+}
+
+func (RedisFailedLoginStore) Delete(ctx context.Context, userID string) error {
+	return ValidateUser.DeleteFailedLoginRecord(ctx, userID)
+}
+
+// =======================================
+//          MongoDB 实现
+// =======================================
+
+type mongoToken struct {
+	Kind     TokenKind  `bson:"kind"`
+	OwnerID  string     `bson:"owner_id"`
+	Token    string     `bson:"token"`
+	ExpireAt *time.Time `bson:"expire_at,omitempty"` // 为空表示不过期，TTL 索引不处理
+}
+
+// MongoTokenStore 基于 MongoDB 的令牌存储，过期文档由 expire_at 上的 TTL 索引删除；
+// TTL 清理约每分钟执行一次，读取时另按 expire_at 过滤
+type MongoTokenStore struct{}
+
+func (MongoTokenStore) Get(ctx context.Context, kind TokenKind, id string) (string, error) {
+	filter := bson.M{
+		"kind":     kind,
+		"owner_id": id,
+		"$or": bson.A{
+			bson.M{"expire_at": bson.M{"$exists": false}},
+			bson.M{"expire_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+	var doc mongoToken
+	err := collections.GetSessionTokenCollection().FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", err
+	}
+	return doc.Token, nil
+}
+
+func (MongoTokenStore) Set(ctx context.Context, kind TokenKind, id, token string, ttl time.Duration) error {
+	doc := mongoToken{Kind: kind, OwnerID: id, Token: token}
+	if ttl > 0 {
+		expireAt := time.Now().Add(ttl)
+		doc.ExpireAt = &expireAt
+	}
+	_, err := collections.GetSessionTokenCollection().ReplaceOne(ctx,
+		bson.M{"kind": kind, "owner_id": id}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (MongoTokenStore) Delete(ctx context.Context, kind TokenKind, id string) error {
+	_, err := collections.GetSessionTokenCollection().DeleteOne(ctx, bson.M{"kind": kind, "owner_id": id})
+	return err
+}
+
+type mongoFailedLogin struct {
+	UserID string    `bson:"user_id"`
+	Count  int       `bson:"count"`
+	Last   time.Time `bson:"last"` // FAILED_LOGIN_TTL_DAYS 大于 0 时由 TTL 索引按该字段清理
+}
+
+// MongoFailedLoginStore 基于 MongoDB 的登录失败记录存储
+type MongoFailedLoginStore struct{}
+
+func (MongoFailedLoginStore) Get(ctx context.Context, userID string) (FailedLoginInfo, bool, error) {
+	var doc mongoFailedLogin
+	err := collections.GetFailedLoginCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return FailedLoginInfo{}, false, nil
+		}
+		return FailedLoginInfo{}, false, err
+	}
+	return FailedLoginInfo{Count: doc.Count, Last: doc.Last}, true, nil
+}
+
+func (MongoFailedLoginStore) Set(ctx context.Context, userID string, info FailedLoginInfo) error {
+	doc := mongoFailedLogin{UserID: userID, Count: info.Count, Last: info.Last}
+	_, err := collections.GetFailedLoginCollection().ReplaceOne(ctx,
+		bson.M{"user_id": userID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (MongoFailedLoginStore) Delete(ctx context.Context, userID string) error {
+	_, err := collections.GetFailedLoginCollection().DeleteOne(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// =======================================
+//          内存实现
+// =======================================
+
+type memoryToken struct {
+	token    string
+	expireAt time.Time
+}
+
+// MemoryTokenStore 进程内令牌存储，过期令牌在读取时清除
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]memoryToken)}
+}
+
+func (s *MemoryTokenStore) Get(_ context.Context, kind TokenKind, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := string(kind) + ":" + id
+	t, ok := s.tokens[key]
+	if !ok {
+		return "", nil
+	}
+	if !t.expireAt.IsZero() && !time.Now().Before(t.expireAt) {
+		delete(s.tokens, key)
+		return "", nil
+	}
+	return t.token, nil
+}
+
+func (s *MemoryTokenStore) Set(_ context.Context, kind TokenKind, id, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := memoryToken{token: token}
+	if ttl > 0 {
+		t.expireAt = time.Now().Add(ttl)
+	}
+	s.tokens[string(kind)+":"+id] = t
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(_ context.Context, kind TokenKind, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, string(kind)+":"+id)
+	return nil
+}
+
+// MemoryFailedLoginStore 进程内登录失败记录存储
+type MemoryFailedLoginStore struct {
+	mu      sync.Mutex
+	records map[string]FailedLoginInfo
+}
+
+func NewMemoryFailedLoginStore() *MemoryFailedLoginStore {
+	return &MemoryFailedLoginStore{records: make(map[string]FailedLoginInfo)}
+}
+
+func (s *MemoryFailedLoginStore) Get(_ context.Context, userID string) (FailedLoginInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.records[userID]
+	return info, ok, nil
+}
+
+func (s *MemoryFailedLoginStore) Set(_ context.Context, userID string, info FailedLoginInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[userID] = info
+	return nil
+}
+
+func (s *MemoryFailedLoginStore) Delete(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, userID)
+	return nil
+}
@@ -0,0 +1,195 @@
+package Repository
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 各实现的令牌与登录失败记录存储必须通过同一套一致性测试。
+// Redis 实现默认运行在内嵌内存存储上；设置 TEST_REDIS_ADDR / TEST_MONGO_URL 后
+// 额外针对真实的 Redis / MongoDB 运行
+
+// sessionBackend 一组待测的存储实现
+type sessionBackend struct {
+	name  string
+	setup func(t *testing.T) (TokenStore, FailedLoginStore)
+	skip  func() string // 返回非空时跳过，内容为跳过原因
+}
+
+func sessionBackends() []sessionBackend {
+	return []sessionBackend{
+		{name: "memory", setup: func(t *testing.T) (TokenStore, FailedLoginStore) {
+			return NewMemoryTokenStore(), NewMemoryFailedLoginStore()
+		}},
+		{name: "redis-embedded", setup: func(t *testing.T) (TokenStore, FailedLoginStore) {
+			useRedisStore(t, RedisService.NewMemoryStore())
+			return RedisTokenStore{}, RedisFailedLoginStore{}
+		}},
+		{name: "redis", skip: envRequired("TEST_REDIS_ADDR"), setup: func(t *testing.T) (TokenStore, FailedLoginStore) {
+			client := RedisService.NewClient(RedisService.Options{Addr: os.Getenv("TEST_REDIS_ADDR")})
+			if err := client.Ping(context.Background()); err != nil {
+				t.Fatalf("连接 Redis 失败: %v", err)
+			}
+			useRedisStore(t, client)
+			return RedisTokenStore{}, RedisFailedLoginStore{}
+		}},
+		{name: "mongo", skip: envRequired("TEST_MONGO_URL"), setup: func(t *testing.T) (TokenStore, FailedLoginStore) {
+			config.DBUrl = os.Getenv("TEST_MONGO_URL")
+			config.DBName = "go_movies_session_test"
+			db.MongoClient()
+			t.Cleanup(func() {
+				_ = collections.GetSessionTokenCollection().Drop(context.Background())
+				_ = collections.GetFailedLoginCollection().Drop(context.Background())
+			})
+			return MongoTokenStore{}, MongoFailedLoginStore{}
+		}},
+	}
+}
+
+func envRequired(key string) func() string {
+	return func() string {
+		if os.Getenv(key) == "" {
+			return "未设置 " + key
+		}
+		return ""
+	}
+}
+
+// useRedisStore 测试期间替换 RedisService 的全局存储
+func useRedisStore(t *testing.T, s RedisService.Store) {
+	prev := RedisService.SetDefault(s)
+	t.Cleanup(func() {
+		_ = s.Close()
+		RedisService.SetDefault(prev)
+	})
+}
+
+// uniqueID 避免真实存储中残留数据互相影响
+func uniqueID(name string) string {
+	return name + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func TestSessionStoreConformance(t *testing.T) {
+	for _, b := range sessionBackends() {
+		t.Run(b.name, func(t *testing.T) {
+			if b.skip != nil {
+				if reason := b.skip(); reason != "" {
+					t.Skip(reason)
+				}
+			}
+			tokens, failed := b.setup(t)
+			t.Run("TokenStore", func(t *testing.T) { testTokenStore(t, tokens) })
+			t.Run("FailedLoginStore", func(t *testing.T) { testFailedLoginStore(t, failed) })
+		})
+	}
+}
+
+func testTokenStore(t *testing.T, s TokenStore) {
+	ctx := context.Background()
+	id := uniqueID("owner")
+
+	if got, err := s.Get(ctx, AdminToken, id); err != nil || got != "" {
+		t.Fatalf("不存在的令牌应返回空字符串，实际 %q, %v", got, err)
+	}
+
+	if err := s.Set(ctx, AdminToken, id, "admin-1", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := s.Get(ctx, AdminToken, id); err != nil || got != "admin-1" {
+		t.Fatalf("Get 应返回写入的令牌，实际 %q, %v", got, err)
+	}
+	if got, _ := s.Get(ctx, UserToken, id); got != "" {
+		t.Errorf("管理员与用户令牌应相互独立，实际 %q", got)
+	}
+
+	// 覆盖写入
+	if err := s.Set(ctx, AdminToken, id, "admin-2", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _ := s.Get(ctx, AdminToken, id); got != "admin-2" {
+		t.Errorf("重复写入应覆盖旧令牌，实际 %q", got)
+	}
+
+	// ttl 为 0 不过期
+	if err := s.Set(ctx, UserToken, id, "user-1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _ := s.Get(ctx, UserToken, id); got != "user-1" {
+		t.Errorf("不过期的令牌应可读取，实际 %q", got)
+	}
+
+	if err := s.Delete(ctx, AdminToken, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, _ := s.Get(ctx, AdminToken, id); got != "" {
+		t.Errorf("删除后应返回空字符串，实际 %q", got)
+	}
+	if got, _ := s.Get(ctx, UserToken, id); got != "user-1" {
+		t.Errorf("删除管理员令牌不应影响用户令牌，实际 %q", got)
+	}
+	if err := s.Delete(ctx, AdminToken, id); err != nil {
+		t.Errorf("删除不存在的令牌不应报错: %v", err)
+	}
+	_ = s.Delete(ctx, UserToken, id)
+
+	// 过期
+	expiring := uniqueID("expiring")
+	if err := s.Set(ctx, UserToken, expiring, "short", 100*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got, err := s.Get(ctx, UserToken, expiring); err != nil || got != "" {
+		t.Errorf("过期令牌应返回空字符串，实际 %q, %v", got, err)
+	}
+}
+
+func testFailedLoginStore(t *testing.T, s FailedLoginStore) {
+	ctx := context.Background()
+	userID := uniqueID("user")
+	other := uniqueID("other")
+
+	if _, ok, err := s.Get(ctx, userID); err != nil || ok {
+		t.Fatalf("没有记录时 ok 应为 false，实际 %v, %v", ok, err)
+	}
+
+	// 各实现的时间精度不同，统一截断到秒
+	last := time.Now().Truncate(time.Second)
+	if err := s.Set(ctx, userID, FailedLoginInfo{Count: 3, Last: last}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	info, ok, err := s.Get(ctx, userID)
+	if err != nil || !ok {
+		t.Fatalf("Get 应返回记录，实际 %v, %v", ok, err)
+	}
+	if info.Count != 3 || !info.Last.Equal(last) {
+		t.Errorf("记录内容不一致: %+v", info)
+	}
+	if _, ok, _ := s.Get(ctx, other); ok {
+		t.Error("不同用户的记录应相互独立")
+	}
+
+	later := last.Add(time.Minute)
+	if err := s.Set(ctx, userID, FailedLoginInfo{Count: 4, Last: later}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if info, _, _ := s.Get(ctx, userID); info.Count != 4 || !info.Last.Equal(later) {
+		t.Errorf("重复写入应覆盖旧记录，实际 %+v", info)
+	}
+
+	if err := s.Delete(ctx, userID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, userID); ok {
+		t.Error("删除后不应再有记录")
+	}
+	if err := s.Delete(ctx, userID); err != nil {
+		t.Errorf("删除不存在的记录不应报错: %v", err)
+	}
+}
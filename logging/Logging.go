@@ -0,0 +1,294 @@
+// Package logging 基于 log/slog 的结构化日志
+//
+// 各包通过 For 取得带 component 字段的 logger，级别可按组件配置（LOG_LEVELS，如 db=debug,task=warn）；
+// 请求 ID、用户 ID 与管理员 ID 通过 context 传递，使用 InfoContext 等带 ctx 的方法时自动输出。
+// 本包不依赖项目内其他包，由 config 在加载与热更新时调用 Configure / SetLevels
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 输出格式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// 通用字段名，各包记录同类信息时使用相同的键
+const (
+	KeyComponent = "component"
+	KeyRequestID = "request_id"
+	KeyUserID    = "user_id"
+	KeyAdminID   = "admin_id"
+	KeyRoute     = "route"
+	KeyError     = "err"
+)
+
+// Options 日志配置
+type Options struct {
+	Format string // json / text
+	Level  string // 默认级别 debug / info / warn / error
+	Levels string // 按组件覆盖级别，逗号分隔，如 db=debug,task=warn
+}
+
+// levels 默认级别与各组件级别
+type levels struct {
+	def         slog.Level
+	byComponent map[string]slog.Level
+}
+
+func (l *levels) of(component string) slog.Level {
+	if lv, ok := l.byComponent[component]; ok {
+		return lv
+	}
+	return l.def
+}
+
+var (
+	// output 输出目标与格式，各 logger 的级别过滤在此之前完成，因此其自身级别为最低
+	output atomic.Pointer[slog.Handler]
+	// current 当前级别配置
+	current atomic.Pointer[levels]
+)
+
+func init() {
+	setOutput(newOutput(os.Stderr, FormatJSON))
+	current.Store(&levels{def: slog.LevelInfo})
+	slog.SetDefault(For(""))
+}
+
+func newOutput(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if format == FormatText {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+func setOutput(h slog.Handler) {
+	output.Store(&h)
+}
+
+// Configure 设置输出格式与级别，并将标准库 log 与 slog 默认 logger 的输出接入；
+// 配置无效时返回错误且保持原配置
+func Configure(w io.Writer, opts Options) error {
+	lv, err := parseLevels(opts.Level, opts.Levels)
+	if err != nil {
+		return err
+	}
+	if opts.Format != FormatJSON && opts.Format != FormatText {
+		return fmt.Errorf("日志格式可选 %s / %s，当前为 %q", FormatJSON, FormatText, opts.Format)
+	}
+	setOutput(newOutput(w, opts.Format))
+	current.Store(lv)
+	slog.SetDefault(For(""))
+	return nil
+}
+
+// SetLevels 热更新日志级别，配置无效时返回错误且保持原级别
+func SetLevels(level, byComponent string) error {
+	lv, err := parseLevels(level, byComponent)
+	if err != nil {
+		return err
+	}
+	current.Store(lv)
+	return nil
+}
+
+// ValidateLevels 校验级别配置，供配置校验使用
+func ValidateLevels(level, byComponent string) error {
+	_, err := parseLevels(level, byComponent)
+	return err
+}
+
+func parseLevels(level, byComponent string) (*levels, error) {
+	def, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	lv := &levels{def: def, byComponent: make(map[string]slog.Level)}
+	for _, item := range strings.Split(byComponent, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		component, level, found := strings.Cut(item, "=")
+		component = strings.TrimSpace(component)
+		if !found || component == "" {
+			return nil, fmt.Errorf("%q 应为 组件=级别，如 db=debug", item)
+		}
+		l, err := parseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		lv.byComponent[component] = l
+	}
+	return lv, nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return l, fmt.Errorf("日志级别可选 debug / info / warn / error，当前为 %q", s)
+	}
+	return l, nil
+}
+
+// =======================================
+//          按组件的 logger
+// =======================================
+
+// For 返回组件 component 的 logger，级别由 LOG_LEVEL 与 LOG_LEVELS 中的同名项决定；
+// 可在包级变量中创建，之后 Configure 修改的格式与级别同样生效
+func For(component string) *slog.Logger {
+	return slog.New(&handler{component: component, cache: new(atomic.Pointer[derived])})
+}
+
+// derived 基于某个输出派生出的带固定字段的 handler，输出被替换后重新派生
+type derived struct {
+	base slog.Handler
+	h    slog.Handler
+}
+
+// handler 按组件过滤级别，并附加 context 中的请求 ID、用户 ID 与管理员 ID
+type handler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler // WithAttrs / WithGroup 的调用，按顺序应用到输出上
+	cache     *atomic.Pointer[derived]
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().of(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		for _, f := range contextFields {
+			if v, ok := ctx.Value(f.key).(string); ok && v != "" {
+				r.AddAttrs(slog.String(f.name, v))
+			}
+		}
+	}
+	return h.target().Handle(ctx, r)
+}
+
+// target 当前输出派生出的 handler
+func (h *handler) target() slog.Handler {
+	base := *output.Load()
+	if d := h.cache.Load(); d != nil && d.base == base {
+		return d.h
+	}
+	out := base
+	if h.component != "" {
+		out = out.WithAttrs([]slog.Attr{slog.String(KeyComponent, h.component)})
+	}
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	h.cache.Store(&derived{base: base, h: out})
+	return out
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{component: h.component, ops: append(ops, op), cache: new(atomic.Pointer[derived])}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
+
+// Err 错误字段，统一使用 KeyError 作为键
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String(KeyError, err.Error())
+}
+
+// =======================================
+//          context 中的请求信息
+// =======================================
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	adminIDKey
+	routeKey
+)
+
+// contextFields 从 context 中读取并输出的字段
+var contextFields = []struct {
+	key  ctxKey
+	name string
+}{
+	{requestIDKey, KeyRequestID},
+	{userIDKey, KeyUserID},
+	{adminIDKey, KeyAdminID},
+}
+
+// WithRequestID 在 ctx 中记录请求 ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时为空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUserID 在 ctx 中记录当前普通用户 ID
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID 返回 ctx 中的普通用户 ID，没有时为空
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// WithAdminID 在 ctx 中记录当前管理员 ID
+func WithAdminID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, adminIDKey, id)
+}
+
+// AdminID 返回 ctx 中的管理员 ID，没有时为空
+func AdminID(ctx context.Context) string {
+	id, _ := ctx.Value(adminIDKey).(string)
+	return id
+}
+
+// WithRoute 在 ctx 中记录请求的路由模板；访问日志已单独输出路由，因此不自动添加到日志，供崩溃报告等使用
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+// Route 返回 ctx 中的路由模板，没有时为空
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey).(string)
+	return route
+}
+
+// NewRequestID 生成 32 位十六进制的请求 ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
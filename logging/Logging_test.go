@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// capture 将输出重定向到缓冲区，测试结束后恢复默认配置
+func capture(t *testing.T, opts Options) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Configure(&buf, opts); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() { _ = Configure(os.Stderr, Options{Format: FormatJSON, Level: "info"}) })
+	return &buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("输出不是 JSON: %q", line)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestLevelsPerComponent(t *testing.T) {
+	// 在 Configure 之前创建，验证包级 logger 同样使用之后的配置
+	db, task := For("db"), For("task")
+	buf := capture(t, Options{Format: FormatJSON, Level: "info", Levels: "db=debug, task=warn"})
+
+	db.Debug("db debug")
+	task.Info("task info")
+	task.Warn("task warn")
+	For("http").Debug("http debug")
+
+	got := lines(t, buf)
+	if len(got) != 2 {
+		t.Fatalf("应输出 2 条日志，实际 %d: %s", len(got), buf)
+	}
+	if got[0]["msg"] != "db debug" || got[0][KeyComponent] != "db" {
+		t.Errorf("db 的 debug 日志不正确: %v", got[0])
+	}
+	if got[1]["msg"] != "task warn" || got[1][KeyComponent] != "task" {
+		t.Errorf("task 的 warn 日志不正确: %v", got[1])
+	}
+
+	if err := SetLevels("warn", ""); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	db.Debug("db debug")
+	if buf.Len() != 0 {
+		t.Errorf("热更新级别后不应输出 debug 日志: %s", buf)
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	buf := capture(t, Options{Format: FormatJSON, Level: "info"})
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithUserID(ctx, "u1")
+
+	For("user").With("attempt", 2).InfoContext(ctx, "登录", Err(nil))
+
+	got := lines(t, buf)
+	if len(got) != 1 {
+		t.Fatalf("应输出 1 条日志，实际 %d", len(got))
+	}
+	want := map[string]any{KeyComponent: "user", KeyRequestID: "req-1", KeyUserID: "u1", "attempt": float64(2)}
+	for k, v := range want {
+		if got[0][k] != v {
+			t.Errorf("%s 应为 %v，实际 %v", k, v, got[0][k])
+		}
+	}
+	if _, ok := got[0][KeyAdminID]; ok {
+		t.Errorf("ctx 中没有管理员 ID 时不应输出 %s", KeyAdminID)
+	}
+	if RequestID(ctx) != "req-1" {
+		t.Errorf("RequestID 应为 req-1，实际 %q", RequestID(ctx))
+	}
+	if UserID(ctx) != "u1" || AdminID(ctx) != "" {
+		t.Errorf("UserID 应为 u1、AdminID 应为空，实际 %q / %q", UserID(ctx), AdminID(ctx))
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Format: "xml", Level: "info"},
+		{Format: FormatJSON, Level: "verbose"},
+		{Format: FormatJSON, Level: "info", Levels: "db"},
+		{Format: FormatJSON, Level: "info", Levels: "db=loud"},
+	} {
+		if err := Configure(os.Stderr, opts); err == nil {
+			t.Errorf("%+v 应返回错误", opts)
+		}
+	}
+	if len(NewRequestID()) != 32 {
+		t.Errorf("请求 ID 应为 32 位十六进制")
+	}
+}
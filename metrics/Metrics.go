@@ -0,0 +1,158 @@
+// Package metrics Prometheus 指标，由 /metrics 暴露
+//
+// 本包只依赖 Prometheus 客户端，由 HTTP 中间件、数据库客户端、定时任务与业务服务调用 Observe* 记录
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "go_movies"
+
+// 登录账号类型
+const (
+	LoginUser  = "user"
+	LoginAdmin = "admin"
+)
+
+// 登录结果
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginLocked  = "locked" // 失败次数过多被锁定
+)
+
+// 调用结果
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// UnmatchedRoute 未匹配任何路由（404）的请求使用的 route 标签，避免按原始路径产生大量标签
+const UnmatchedRoute = "unmatched"
+
+// registry 独立的注册表，不使用全局 DefaultRegisterer，避免依赖库注册的指标混入
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "http_requests_total",
+		Help: "HTTP 请求数，route 为路由模板",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "http_request_duration_seconds",
+		Help:    "HTTP 请求耗时，route 为路由模板",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "logins_total",
+		Help: "登录次数，kind 为 user / admin，result 为 success / failure / locked",
+	}, []string{"kind", "result"})
+
+	inviteGenerated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "invite_codes_generated_total",
+		Help: "已生成的邀请码数量",
+	})
+
+	inviteRedeemed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "invite_codes_redeemed_total",
+		Help: "注册成功兑换的邀请码次数",
+	})
+
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "mongo_command_duration_seconds",
+		Help:    "MongoDB 命令耗时",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"command", "result"})
+
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "redis_command_duration_seconds",
+		Help:    "Redis 命令耗时",
+		Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+
+	taskRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "task_runs_total",
+		Help: "定时任务执行次数，result 为 ok / error（含 panic）",
+	}, []string{"task", "result"})
+
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "task_duration_seconds",
+		Help:    "定时任务执行耗时",
+		Buckets: []float64{.1, .5, 1, 5, 15, 30, 60, 300, 900, 3600},
+	}, []string{"task"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		logins,
+		inviteGenerated, inviteRedeemed,
+		mongoDuration, redisDuration,
+		taskRuns, taskDuration,
+	)
+}
+
+// Handler 输出 Prometheus 文本格式的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveHTTP 记录一次 HTTP 请求，route 为空时记为 UnmatchedRoute
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveLogin 记录一次登录
+func ObserveLogin(kind, result string) {
+	logins.WithLabelValues(kind, result).Inc()
+}
+
+// AddInviteGenerated 记录生成的邀请码数量
+func AddInviteGenerated(n int) {
+	if n > 0 {
+		inviteGenerated.Add(float64(n))
+	}
+}
+
+// IncInviteRedeemed 记录一次邀请码兑换
+func IncInviteRedeemed() {
+	inviteRedeemed.Inc()
+}
+
+// ObserveMongo 记录一次 MongoDB 命令
+func ObserveMongo(command string, d time.Duration, ok bool) {
+	mongoDuration.WithLabelValues(command, result(ok)).Observe(d.Seconds())
+}
+
+// ObserveRedis 记录一次 Redis 命令
+func ObserveRedis(command string, d time.Duration, ok bool) {
+	redisDuration.WithLabelValues(command, result(ok)).Observe(d.Seconds())
+}
+
+// ObserveTask 记录一次定时任务执行
+func ObserveTask(task string, d time.Duration, ok bool) {
+	taskRuns.WithLabelValues(task, result(ok)).Inc()
+	taskDuration.WithLabelValues(task).Observe(d.Seconds())
+}
+
+func result(ok bool) string {
+	if ok {
+		return ResultOK
+	}
+	return ResultError
+}
@@ -2,15 +2,26 @@ package Middlewares
 
 import (
 	"errors"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
-	redis "github.com/StephenChristianW/go-movies-open/db/RedisService/OperateToken"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/security/IPManage"
+	"github.com/StephenChristianW/go-movies-open/services"
+	"github.com/StephenChristianW/go-movies-open/task/SafeGo"
+	"github.com/StephenChristianW/go-movies-open/tracing"
 	"github.com/StephenChristianW/go-movies-open/utils/Jwt"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // ==================================== 中间件 ====================================
@@ -19,10 +30,13 @@ const (
 	UserInfo  = "userInfo"
 )
 
+// ipService 用户鉴权时查询封禁 IP
+var ipService = IPManage.IPService{}
+
 // AdminAuthMiddleware 管理员鉴权中间件
 // - 排除 /admin/login 接口
 // - 校验请求头中的 Authorization token
-// - 解析 JWT 并校验 Redis 中 token 是否有效
+// - 解析 JWT 并校验令牌存储中 token 是否有效
 // - 将解析出的管理员信息存入 context
 func AdminAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -56,39 +70,27 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 校验 Redis 中是否存在该 token
-		redisToken, err := redis.GetAdminToken(adminClaims.AdminID)
-		if err != nil || redisToken != token {
+		// 校验令牌存储中是否存在该 token
+		storedToken, err := Repository.DefaultTokenStore().Get(c.Request.Context(), Repository.AdminToken, adminClaims.AdminID)
+		if err != nil || storedToken != token {
 			c.JSON(401, gin.H{"code": 401, "msg": "token已失效"})
 			c.Abort()
 			return
 		}
 
-		// 将管理员信息存入 Gin 上下文
+		// 将管理员信息存入 Gin 上下文，管理员 ID 同时写入请求 context 供日志使用
 		c.Set(AdminInfo, adminClaims)
+		c.Request = c.Request.WithContext(logging.WithAdminID(c.Request.Context(), adminClaims.AdminID))
 		c.Next()
 	}
 }
 
-func isIPBlocked(ip string) (bool, error) {
-	ctx, cancel := db.GetCtx()
-	defer cancel()
-	result := collections.GetBlockIps().FindOne(ctx, bson.M{"ip": ip})
-	if result.Err() != nil {
-		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
-			return false, nil
-		}
-		return true, result.Err()
-	}
-	return true, nil
-}
-
 // UserAuthMiddleware 用户鉴权中间件
-// 用于检查请求中携带的 JWT token 是否有效，并校验 Redis 中的 token
+// 用于检查请求中携带的 JWT token 是否有效，并校验令牌存储中的 token
 func UserAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
-		blocked, err := isIPBlocked(ip) // 查询数据库
+		blocked, err := ipService.IsIPBlocked(c.Request.Context(), ip) // 查询数据库
 		if err != nil {
 			// 查询异常，可选择放行或者阻止
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -104,7 +106,6 @@ func UserAuthMiddleware() gin.HandlerFunc {
 			})
 			return
 		}
-		c.Next() // 继续处理
 		// 以下接口无需鉴权
 		// 登录接口无需鉴权 (支持版本号或前缀)
 		if strings.HasSuffix(c.FullPath(), "/login") ||
@@ -132,17 +133,186 @@ func UserAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 校验 Redis 中存储的 token 是否与请求 token 匹配
+		// 校验令牌存储中的 token 是否与请求 token 匹配
 		// 防止 token 被非法使用或已被手动清理
-		redisToken, err := redis.GetUserToken(userClaims.UserID)
-		if err != nil || redisToken != token {
+		storedToken, err := Repository.DefaultTokenStore().Get(c.Request.Context(), Repository.UserToken, userClaims.UserID)
+		if err != nil || storedToken != token {
 			c.JSON(401, gin.H{"code": 401, "msg": "token已失效"})
 			c.Abort()
 			return
 		}
 
-		// 将解析出的用户信息存入 Gin 上下文，供后续 handler 使用
+		// 将解析出的用户信息存入 Gin 上下文，供后续 handler 使用；用户 ID 同时写入请求 context 供日志使用
 		c.Set(UserInfo, userClaims)
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), userClaims.UserID))
+		c.Next()
+	}
+}
+
+// RequireClientCert 管理端 mTLS 中间件
+// 配置了 tls.client_ca_file 时要求请求携带该 CA 签发的客户端证书，未配置时直接放行
+// 证书链已在 TLS 握手时校验（见 TLSManage.NewServerConfig），此处只检查是否存在已校验的证书
+func RequireClientCert() gin.HandlerFunc {
+	required := config.Get().TLS.ClientCAFile != ""
+	return func(c *gin.Context) {
+		if !required {
+			c.Next()
+			return
+		}
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 403, "msg": "需要有效的客户端证书"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Metrics HTTP 指标中间件，按路由模板（c.FullPath()）、方法与状态码记录请求数与耗时；
+// 需挂载在 Recovery 之前，handler panic 时记录 Recovery 写入的 500
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		metrics.ObserveHTTP(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
+
+// RequestIDHeader 请求 ID 请求头与响应头
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern 接受的外部请求 ID，其余情况重新生成，避免日志注入
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID 请求 ID 中间件
+// 沿用请求头 X-Request-ID（如由网关生成），缺失或格式不合法时生成新的 ID；
+// ID 写入响应头与请求 context，之后经 c.Request.Context() 传入的服务调用记录日志时自动带上 request_id；
+// 路由模板同时写入请求 context，供崩溃报告使用
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = logging.NewRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		c.Request = c.Request.WithContext(logging.WithRoute(ctx, c.FullPath()))
+		c.Next()
+	}
+}
+
+// Recovery panic 恢复中间件，替代 gin.Recovery；需挂载在 RequestID 与 Tracing 之后
+// 崩溃报告（调用栈、请求 ID、路由与用户）由 SafeGo.Report 写入日志与崩溃报告目录，
+// 响应为统一的 {"code","msg","data"} 格式，data 中带请求 ID 便于排查；客户端已断开时只记录警告
+func Recovery() gin.HandlerFunc {
+	logger := logging.For("http")
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r) // net/http 约定的中止请求，交由 net/http 处理
+			}
+			// 鉴权中间件会替换 c.Request，此时读取才能带上用户 ID
+			ctx := c.Request.Context()
+			if err, ok := r.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				logger.WarnContext(ctx, "客户端已断开", logging.Err(err))
+				c.Abort()
+				return
+			}
+			report := SafeGo.Report(ctx, "http", r)
+			if c.Writer.Written() {
+				c.Abort() // 响应已开始写出，无法再返回错误信息
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, services.Response{
+				Code: -1,
+				Msg:  "服务器内部错误",
+				Data: gin.H{logging.KeyRequestID: report.RequestID},
+			})
+		}()
+		c.Next()
+	}
+}
+
+// Tracing 链路追踪中间件，为每个请求创建 span 并写入请求 context，需挂载在 RequestID 之后
+// 请求头中带有 traceparent 时作为上游链路的子 span；span 名为 方法 + 路由模板，避免按原始路径产生大量名称；
+// 探针与指标采集请求不记录
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if probePaths[route] {
+			c.Next()
+			return
+		}
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				attribute.String(logging.KeyRequestID, logging.RequestID(ctx)),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			span.RecordError(errors.New(errs))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// probePaths 探针与指标采集请求，访问日志以 debug 级别记录且不创建 span，避免刷屏
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// AccessLog 访问日志中间件，替代 Gin 默认的文本日志；需挂载在 RequestID 之后
+func AccessLog() gin.HandlerFunc {
+	logger := logging.For("http")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case probePaths[c.FullPath()]:
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String(logging.KeyError, errs))
+		}
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			attrs = append(attrs, slog.String("trace_id", traceID))
+		}
+		// 使用 c.Request.Context()，以便带上鉴权中间件写入的用户或管理员 ID
+		logger.LogAttrs(c.Request.Context(), level, "请求", attrs...)
 	}
 }
@@ -0,0 +1,98 @@
+package Middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/security/IPManage"
+	"github.com/StephenChristianW/go-movies-open/utils/Jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserAuthSetsUserBeforeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.SessionStore = config.SessionMemory
+	config.UserSecret = "test-user-secret"
+	ipService = IPManage.IPService{Blocks: IPManage.NewMemoryBlockListRepository()}
+	t.Cleanup(func() { ipService = IPManage.IPService{} })
+
+	var buf bytes.Buffer
+	if err := logging.Configure(&buf, logging.Options{Format: logging.FormatJSON, Level: "info"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() { _ = logging.Configure(os.Stderr, logging.Options{Format: logging.FormatJSON, Level: "info"}) })
+
+	token, err := Jwt.GenerateUserToken("u1", "alice", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUserToken: %v", err)
+	}
+	if err := Repository.DefaultTokenStore().Set(t.Context(), Repository.UserToken, "u1", token, time.Hour); err != nil {
+		t.Fatalf("保存令牌: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(RequestID(), UserAuthMiddleware())
+	var seen any
+	r.GET("/user/info", func(c *gin.Context) {
+		seen, _ = c.Get(UserInfo)
+		logging.For("test").InfoContext(c.Request.Context(), "handled")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/info", nil)
+	req.Header.Set("Authorization", token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", w.Code, w.Body.String())
+	}
+	if claims, ok := seen.(*Jwt.UserClaims); !ok || claims.UserID != "u1" {
+		t.Errorf("handler 执行时应已设置用户信息，实际 %v", seen)
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &line); err != nil {
+		t.Fatalf("应只输出一行 JSON 日志: %q", buf.String())
+	}
+	if line["msg"] != "handled" || line[logging.KeyUserID] != "u1" || line[logging.KeyRequestID] == nil {
+		t.Errorf("handler 日志应带上 user_id 与 request_id: %v", line)
+	}
+
+	// 未携带 token 时 handler 不应执行
+	buf.Reset()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/info", nil))
+	if w.Code != http.StatusUnauthorized || buf.Len() != 0 {
+		t.Errorf("缺少 token 应返回 401 且不执行 handler，实际 %d，日志 %q", w.Code, buf.String())
+	}
+}
+
+func TestMetricsCountsRecoveredPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir(t.TempDir()) // 崩溃报告写入临时目录
+	r := gin.New()
+	r.Use(RequestID(), Metrics(), Recovery())
+	r.GET("/metrics-test/panic", func(c *gin.Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics-test/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic 应返回 500，实际 %d", w.Code)
+	}
+
+	scrape := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `http_requests_total{method="GET",route="/metrics-test/panic",status="500"} 1`
+	if !strings.Contains(scrape.Body.String(), want) {
+		t.Errorf("指标中应包含 %s", want)
+	}
+}
@@ -1,6 +1,9 @@
 package routers
 
 import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/routers/Middlewares"
 	"github.com/gin-gonic/gin"
 )
 
@@ -8,17 +11,26 @@ var router *gin.Engine // 全局 Gin 引擎实例，可供其他地方引用
 
 // RunServer 启动 HTTP 服务
 // 1. 根据当前环境设置 Gin 模式（Debug/Release）
-// 2. 初始化 Gin 引擎
+// 2. 初始化 Gin 引擎，挂载请求 ID、链路追踪、访问日志与 panic 恢复中间件（开启 metrics.enabled 时挂载请求指标中间件）
 // 3. 注册路由分组及模块路由
-// 4. 配置 swaggerResponse（仅非生产环境）
-// 5. 打印已注册路由信息
-// 6. 启动 HTTP 服务监听端口
-func RunServer() {
-	setGinMode()           // 设置 Gin 模式
-	router = gin.Default() // 初始化 Gin 引擎
-	registerRoutes()       // 注册路由分组及模块路由
-	setSwagger()           // 配置 swaggerResponse，仅非生产环境
+// 4. 配置 swaggerResponse（环境开启 debug.swagger 时）
+// 5. 打印已注册路由信息（环境开启 debug.print_routes 时）
+// 6. 启动 HTTP 服务监听端口，ctx 取消后停止接收新连接并等待进行中的请求结束
+//
+// 正常关闭时返回 nil，监听失败或等待超时时返回错误
+func RunServer(ctx context.Context) error {
+	setGinMode()       // 设置 Gin 模式
+	router = gin.New() // 初始化 Gin 引擎，以结构化访问日志替代 gin.Default 的文本日志
+	router.Use(Middlewares.RequestID(), Middlewares.Tracing(), Middlewares.AccessLog())
+	if config.Get().Metrics.Enabled {
+		// 记录 HTTP 请求指标，需在注册路由前、Recovery 之前挂载，panic 的请求才能以 500 计入
+		router.Use(Middlewares.Metrics())
+	}
+	router.Use(Middlewares.Recovery())
+	registerRoutes() // 注册路由分组及模块路由
+	setSwagger()     // 配置 swaggerResponse，由环境决定
 	htmlController()
 	setIcon()
-	runServer() // 启动 HTTP 服务
+	printRoutes()         // 打印已注册路由信息，由环境决定
+	return runServer(ctx) // 启动 HTTP 服务
 }
@@ -2,7 +2,9 @@ package routers
 
 import (
 	"fmt"
+	"github.com/StephenChristianW/go-movies-open/routers/Middlewares"
 	"github.com/StephenChristianW/go-movies-open/services"
+	AdminService "github.com/StephenChristianW/go-movies-open/services/System/Admin"
 	"github.com/StephenChristianW/go-movies-open/utils"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -12,6 +14,38 @@ import (
 
 var basePath string
 
+// setupPage 根管理员初始化页面，不依赖 front 目录，从链接中读取令牌后提交到同一路径
+const setupPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head><meta charset="utf-8"><title>初始化根管理员</title></head>
+<body style="font-family: sans-serif; max-width: 360px; margin: 80px auto">
+<h2>初始化根管理员</h2>
+<form id="f">
+  <p><input type="password" name="password" placeholder="密码" required style="width: 100%"></p>
+  <p><input type="password" name="confirm" placeholder="确认密码" required style="width: 100%"></p>
+  <p><button type="submit">设置密码</button></p>
+</form>
+<p id="msg"></p>
+<script>
+document.getElementById("f").onsubmit = async function (e) {
+  e.preventDefault();
+  const msg = document.getElementById("msg");
+  if (this.password.value !== this.confirm.value) { msg.textContent = "两次输入的密码不一致"; return; }
+  const token = new URLSearchParams(location.search).get("token") || "";
+  const resp = await fetch(location.pathname, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({token: token, password: this.password.value})
+  });
+  const body = await resp.json();
+  msg.textContent = body.code === 1 ? body.data : body.msg;
+  if (body.code === 1) { this.remove(); }
+};
+</script>
+</body>
+</html>
+`
+
 func init() {
 	var err error
 	basePath, err = os.Getwd()
@@ -31,6 +65,14 @@ func htmlController() {
 	router.GET("/admin/login", func(c *gin.Context) {
 		c.File(basePath + "login.html")
 	})
+	// 根管理员一次性初始化页面，仅在有未使用的初始化链接时可访问
+	router.GET(AdminService.SetupPath, Middlewares.RequireClientCert(), func(c *gin.Context) {
+		if !AdminService.SetupPending() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(setupPage))
+	})
 	router.GET("/admin/main", func(c *gin.Context) {
 		c.File(basePath + "index.html")
 	})
@@ -1,10 +1,14 @@
 package routers
 
 import (
+	"github.com/StephenChristianW/go-movies-open/config"
 	"github.com/StephenChristianW/go-movies-open/controller/AdminController"
+	"github.com/StephenChristianW/go-movies-open/controller/HealthController"
 	"github.com/StephenChristianW/go-movies-open/controller/InviteController"
 	"github.com/StephenChristianW/go-movies-open/controller/UserController"
+	"github.com/StephenChristianW/go-movies-open/metrics"
 	"github.com/StephenChristianW/go-movies-open/routers/Middlewares"
+	AdminService "github.com/StephenChristianW/go-movies-open/services/System/Admin"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,12 +20,23 @@ func registerInviteRoutes(rg *gin.RouterGroup) {
 	var handler InviteController.InviteCodeController = &InviteController.InviteCodeHandler{}
 
 	rg.POST("/create_one", handler.GenerateAndInsertCode)    // 生成单个邀请码
-	rg.POST("/create_batch", handler.GenerateAndInsertCodes) // 批量生成邀请码
+	rg.POST("/create_batch", handler.GenerateAndInsertCodes) // 创建批量生成邀请码任务
 	rg.POST("/reback", handler.ReBackInviteCodes)            // 根据ID回查邀请码
 	rg.POST("/list", handler.ListInviteCodes)                // 分页获取邀请码列表
 	rg.DELETE("/delete", handler.DeleteInviteCode)           // 删除邀请码
 	rg.PUT("/update", handler.UpdateInviteCode)              // 更新邀请码状态
 	rg.GET("/get", handler.GetInviteCode)                    // GET获取邀请码
+	rg.POST("/redemptions", handler.ListRedemptions)         // 分页获取兑换记录
+	rg.POST("/export", handler.ExportInviteCodes)            // 导出邀请码 CSV / XLSX
+	rg.POST("/qrsheet", handler.ExportQRSheet)               // 导出邀请码二维码打印页 PNG
+
+	rg.POST("/campaign/create", handler.CreateCampaign) // 创建邀请活动
+	rg.POST("/campaign/list", handler.ListCampaigns)    // 分页获取邀请活动列表
+	rg.GET("/campaign/stats", handler.GetCampaignStats) // 获取邀请活动统计
+
+	rg.GET("/job/get", handler.GetGenerateJob)       // 查询批量生成任务进度
+	rg.POST("/job/list", handler.ListGenerateJobs)   // 分页获取批量生成任务
+	rg.PUT("/job/cancel", handler.CancelGenerateJob) // 取消批量生成任务
 }
 
 // registerAdminRoutes 注册 Admin 模块的路由
@@ -51,20 +66,34 @@ func registerUserRoutes(rg *gin.RouterGroup) {
 
 // registerRoutes 注册所有模块路由
 // - 创建路由分组（/invite, /admin）
-// - 挂载鉴权中间件（AdminAuthMiddleware）
+// - 挂载鉴权中间件（AdminAuthMiddleware）与管理端 mTLS 中间件（RequireClientCert）
 // - 调用各模块路由注册函数
 func registerRoutes() {
 	// 路由分组
 	inviteGroup := router.Group("/invite")
 	adminGroup := router.Group("/admin")
 	userGroup := router.Group("/user")
-	// 挂载中间件
-	adminGroup.Use(Middlewares.AdminAuthMiddleware())
-	inviteGroup.Use(Middlewares.AdminAuthMiddleware())
+	// 挂载中间件，管理端路由在配置 tls.client_ca_file 时还要求客户端证书
+	adminGroup.Use(Middlewares.RequireClientCert(), Middlewares.AdminAuthMiddleware())
+	inviteGroup.Use(Middlewares.RequireClientCert(), Middlewares.AdminAuthMiddleware())
 	userGroup.Use(Middlewares.UserAuthMiddleware())
 
 	// 注册模块路由
 	registerInviteRoutes(inviteGroup)
 	registerAdminRoutes(adminGroup)
 	registerUserRoutes(userGroup)
+
+	// 根管理员一次性初始化，无需登录，由启动日志中打印的一次性令牌鉴权
+	var adminHandler AdminController.AdminController = &AdminController.AdminHandler{}
+	router.POST(AdminService.SetupPath, Middlewares.RequireClientCert(), adminHandler.SetupRootAdmin)
+
+	// 存活与就绪检查，供负载均衡与容器编排探测，无需鉴权
+	var healthHandler HealthController.HealthController = &HealthController.HealthHandler{}
+	router.GET("/healthz", healthHandler.Healthz)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	// Prometheus 指标
+	if config.Get().Metrics.Enabled {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
 }
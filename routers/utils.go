@@ -1,56 +1,118 @@
 package routers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/security/TLSManage"
+	HealthService "github.com/StephenChristianW/go-movies-open/services/System/Health"
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
-	"log"
+	"net/http"
+	"strings"
+	"time"
 )
 
-const (
-	routesLog = "[ROUTES] "
-	serverLog = "[SERVER] "
-)
+var logger = logging.For("server")
+
+// runServer 启动 HTTP 服务直到 ctx 取消，然后在 server.shutdown_timeout_seconds 内等待进行中的请求结束
+// 启用 TLS 时以 HTTPS 提供服务并自动协商 HTTP/2；未启用 TLS 且开启 server.h2c 时同时接受明文 HTTP/2
+func runServer(ctx context.Context) error {
+	cfg := config.Get()
+	server := cfg.Server
+	tlsConfig, err := TLSManage.NewServerConfig(ctx, cfg.TLS, config.Addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              config.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: server.Timeout(server.ReadHeaderTimeoutSeconds),
+		ReadTimeout:       server.Timeout(server.ReadTimeoutSeconds),
+		WriteTimeout:      server.Timeout(server.WriteTimeoutSeconds),
+		IdleTimeout:       server.Timeout(server.IdleTimeoutSeconds),
+		TLSConfig:         tlsConfig,
+	}
+	if server.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("开始监听", "scheme", cfg.TLS.Scheme(), "addr", srv.Addr)
+		if tlsConfig != nil {
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
 
-func runServer() {
-	// 启动服务
-	port := config.Addr
-	if err := router.Run(port); err != nil {
-		log.Fatalf(serverLog+"启动失败: %v\n", err)
+	// 先让 /readyz 返回未就绪，等待负载均衡摘除实例后再停止接收新连接
+	HealthService.SetDraining()
+	if delay := server.Timeout(server.DrainDelaySeconds); delay > 0 {
+		logger.Info("/readyz 已返回未就绪，稍后开始关闭", "delay", delay.String())
+		time.Sleep(delay)
+	}
+	logger.Info("正在关闭，等待进行中的请求结束")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.Timeout(server.ShutdownTimeoutSeconds))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		_ = srv.Close()
+		return err
 	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Info("HTTP 服务已关闭")
+	return nil
 }
 
-// setSwagger 配置 swaggerResponse 路由，仅在非生产环境
+// setSwagger 配置 swaggerResponse 路由，由当前环境的 debug.swagger 控制
 func setSwagger() {
-	if config.ProdEnv() {
+	if !config.DebugSwagger {
 		return
 	}
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	log.Println(serverLog + "Server running at http://localhost:" + config.Port)
-	log.Println(serverLog + "swaggerResponse UI: http://localhost:" + config.Port + "/swagger/index.html")
+	base := config.Get().TLS.Scheme() + "://localhost:" + config.Port
+	logger.Info("Swagger 文档已启用", "url", base+"/swagger/index.html")
 }
 
-// printRoutes 打印已注册路由信息
+// printRoutes 打印已注册路由信息，由当前环境的 debug.print_routes 控制
 func printRoutes() {
-	log.Println()
-	log.Println(routesLog + "已注册路由列表:")
+	if !config.DebugPrintRoutes {
+		return
+	}
 	for _, ri := range router.Routes() {
-		log.Printf(" - %s %s -> %s\n", ri.Method, ri.Path, ri.Handler)
+		logger.Info("已注册路由", "method", ri.Method, "path", ri.Path, "handler", ri.Handler)
 	}
 }
 
-// setGinMode 根据环境设置 Gin 运行模式
+// setGinMode 设置 Gin 运行模式，由当前环境的 debug.gin_debug 控制
 func setGinMode() {
-	mode := gin.DebugMode
-	envDesc := "本地环境"
-	if config.ProdEnv() {
-		mode = gin.ReleaseMode
-		envDesc = "生产环境"
-		printRoutes() // 打印已注册路由信息
+	mode := gin.ReleaseMode
+	if config.DebugGin {
+		mode = gin.DebugMode
 	}
 	gin.SetMode(mode)
-	log.Printf("[ENV] %s, Gin 设置为 %s\n", envDesc, mode)
-	log.Printf("[ENV] 当前环境: %s\n", config.Env)
+	// Gin 调试输出（路由注册等）同样以结构化日志输出
+	ginLogger := logging.For("gin")
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		ginLogger.Debug(strings.TrimSpace(fmt.Sprintf(strings.TrimPrefix(format, "[WARNING] "), values...)))
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		ginLogger.Debug("注册路由", "method", method, "path", path, "handler", handler, "handlers", handlers)
+	}
+	logger.Info("Gin 运行模式", "env", config.Env, "mode", mode)
 }
@@ -1,22 +1,25 @@
 package IPManage
 
 import (
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"context"
+	"fmt"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
 	"github.com/StephenChristianW/go-movies-open/services"
 	UserService "github.com/StephenChristianW/go-movies-open/services/User"
 	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net"
+	"time"
 )
 
 type BlockedIPs struct {
-	UserID    string `json:"user_id" bson:"user_id"`
-	UserName  string `json:"username" bson:"username"`
-	IP        string `json:"ip" bson:"ip"`
-	Reason    string `json:"reason" bson:"reason"`
-	Active    int    `json:"active" bson:"active"`
-	BlockedAt string `json:"blocked_at" bson:"blocked_at"`
+	UserID    string    `json:"user_id" bson:"user_id"`
+	UserName  string    `json:"username" bson:"username"`
+	IP        string    `json:"ip" bson:"ip"`
+	Reason    string    `json:"reason" bson:"reason"`
+	Active    int       `json:"active" bson:"active"`
+	BlockedAt time.Time `json:"blocked_at" bson:"blocked_at"`
 }
 type BlockedIPsInDB struct {
 	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
@@ -24,21 +27,80 @@ type BlockedIPsInDB struct {
 	IP        string             `json:"ip" bson:"ip"`
 	Reason    string             `json:"reason" bson:"reason"`
 	Active    int                `json:"active" bson:"active"`
-	BlockedAt string             `json:"blocked_at" bson:"blocked_at"`
+	BlockedAt time.Time          `json:"blocked_at" bson:"blocked_at"`
 }
 
-func AddSuspiciousIp(username, ip, reason string) {
-	ctx, cancel := db.GetCtx()
+// IPService 封禁 IP 管理，依赖字段为 nil 时使用 MongoDB 实现
+type IPService struct {
+	Blocks BlockListRepository        // 封禁 IP 存储
+	Users  UserService.UserRepository // 用户存储，用于记录封禁关联的用户
+}
+
+func (s IPService) blocks() BlockListRepository {
+	if s.Blocks != nil {
+		return s.Blocks
+	}
+	return mongoBlockListRepository{}
+}
+
+func (s IPService) users() UserService.UserRepository {
+	if s.Users != nil {
+		return s.Users
+	}
+	return UserService.NewMongoUserRepository()
+}
+
+func AddSuspiciousIp(ctx context.Context, username, ip, reason string) {
+	IPService{}.AddSuspiciousIp(ctx, username, ip, reason)
+}
+
+func (s IPService) AddSuspiciousIp(ctx context.Context, username, ip, reason string) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 	var data BlockedIPs
-	var userInDB UserService.UserInDB
-	_ = collections.GetUserCollection().FindOne(ctx, bson.M{"username": username, "deleted": 1}).Decode(&userInDB)
+	userInDB, _ := s.users().FindActiveByUsername(ctx, username)
 	data.UserID = userInDB.ID.Hex()
 	data.IP = ip
 	data.Reason = reason
 	data.Active = 1
-	data.BlockedAt = UtilsTime.NowTime()
-	_, _ = collections.GetBlockIps().InsertOne(ctx, data)
+	data.BlockedAt = time.Now()
+	_ = s.blocks().Insert(ctx, data)
+}
+
+// BlockIP 手动封禁 IP，username 为关联的用户，可为空
+func (s IPService) BlockIP(ctx context.Context, username, ip, reason string) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("IP 地址无效: %q", ip)
+	}
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	data := BlockedIPs{UserName: username, IP: ip, Reason: reason, Active: 1, BlockedAt: time.Now()}
+	if username != "" {
+		userInDB, err := s.users().FindActiveByUsername(ctx, username)
+		if err != nil {
+			return fmt.Errorf("用户 %s 不存在", username)
+		}
+		data.UserID = userInDB.ID.Hex()
+	}
+	return s.blocks().Insert(ctx, data)
+}
+
+// UnblockIP 解封 IP，删除其全部封禁记录，返回删除数量
+func (s IPService) UnblockIP(ctx context.Context, ip string) (int, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	return s.blocks().Delete(ctx, ip)
+}
+
+// IsIPBlocked IP 是否已被封禁
+func IsIPBlocked(ctx context.Context, ip string) (bool, error) {
+	return IPService{}.IsIPBlocked(ctx, ip)
+}
+
+func (s IPService) IsIPBlocked(ctx context.Context, ip string) (bool, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	return s.blocks().Exists(ctx, ip)
 }
 
 type CreateIPFilter struct {
@@ -69,31 +131,34 @@ func buildFilter(find CreateIPFilter) bson.M {
 		filter["active"] = find.Active
 	}
 
-	if find.BeginTime != "" {
-		filter["blocked_at"] = bson.M{
-			"$gte": find.BeginTime,
-			"$lte": find.EndTime,
-		}
+	// 时间区间：blocked_at 以时间类型存储，字符串条件需先解析
+	blockedAt := bson.M{}
+	if t, err := UtilsTime.ParseFlexibleTime(find.BeginTime); err == nil && !t.IsZero() {
+		blockedAt["$gte"] = t
+	}
+	if t, err := UtilsTime.ParseFlexibleTime(find.EndTime); err == nil && !t.IsZero() {
+		blockedAt["$lte"] = t
+	}
+	if len(blockedAt) > 0 {
+		filter["blocked_at"] = blockedAt
 	}
 	return filter
 
 }
 
-func BlockIpList(ip CreateIPFilter) (services.Pagination, error) {
-	ctx, cancel := db.GetCtx()
+func BlockIpList(ctx context.Context, ip CreateIPFilter) (services.Pagination, error) {
+	return IPService{}.BlockIpList(ctx, ip)
+}
+
+func (s IPService) BlockIpList(ctx context.Context, ip CreateIPFilter) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 	var ips []BlockedIPs
-	conditions := buildFilter(ip)
-	opts := db.CalculatePagination(ip.Page, ip.PageSize, "blocked_at", -1)
-	cursor, err := collections.GetBlockIps().Find(ctx, conditions, opts)
+	records, total, err := s.blocks().List(ctx, ip)
 	if err != nil {
 		return services.Pagination{}, err
 	}
-	for cursor.Next(ctx) {
-		var dbData BlockedIPsInDB
-		if err := cursor.Decode(&dbData); err != nil {
-			return services.Pagination{}, err
-		}
+	for _, dbData := range records {
 		ips = append(ips, BlockedIPs{
 			UserID:    dbData.UserID.Hex(),
 			UserName:  dbData.UserName,
@@ -103,17 +168,13 @@ func BlockIpList(ip CreateIPFilter) (services.Pagination, error) {
 			BlockedAt: dbData.BlockedAt,
 		})
 	}
-	total, err := collections.GetBlockIps().CountDocuments(ctx, conditions)
-	if err != nil {
-		return services.Pagination{}, err
-	}
 
-	totalPages := (int(total) + ip.PageSize - 1) / ip.PageSize
+	totalPages := (total + ip.PageSize - 1) / ip.PageSize
 	return services.Pagination{
 		Page:       ip.Page,
 		PageSize:   ip.PageSize,
-		Total:      int(total),
+		Total:      total,
 		TotalPages: totalPages,
-		Data:       nil,
+		Data:       ips,
 	}, nil
 }
@@ -0,0 +1,87 @@
+package IPManage
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+// MemoryBlockListRepository 进程内封禁 IP 存储，用于测试
+type MemoryBlockListRepository struct {
+	mu  sync.Mutex
+	ips []BlockedIPsInDB
+}
+
+// NewMemoryBlockListRepository 创建内存封禁 IP 存储
+func NewMemoryBlockListRepository() *MemoryBlockListRepository {
+	return &MemoryBlockListRepository{}
+}
+
+func (r *MemoryBlockListRepository) Insert(_ context.Context, data BlockedIPs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userID, _ := primitive.ObjectIDFromHex(data.UserID)
+	r.ips = append(r.ips, BlockedIPsInDB{
+		UserID:    userID,
+		UserName:  data.UserName,
+		IP:        data.IP,
+		Reason:    data.Reason,
+		Active:    data.Active,
+		BlockedAt: data.BlockedAt,
+	})
+	return nil
+}
+
+func (r *MemoryBlockListRepository) List(_ context.Context, filter CreateIPFilter) ([]BlockedIPsInDB, int, error) {
+	begin, _ := UtilsTime.ParseFlexibleTime(filter.BeginTime)
+	end, _ := UtilsTime.ParseFlexibleTime(filter.EndTime)
+
+	r.mu.Lock()
+	var matched []BlockedIPsInDB
+	for _, b := range r.ips {
+		switch {
+		case filter.UserName != "" && b.UserName != filter.UserName,
+			filter.IP != "" && b.IP != filter.IP,
+			filter.Reason != "" && b.Reason != filter.Reason,
+			filter.Active != 0 && b.Active != filter.Active,
+			!begin.IsZero() && b.BlockedAt.Before(begin),
+			!end.IsZero() && b.BlockedAt.After(end):
+			continue
+		}
+		matched = append(matched, b)
+	}
+	r.mu.Unlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].BlockedAt.After(matched[j].BlockedAt)
+	})
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+func (r *MemoryBlockListRepository) Exists(_ context.Context, ip string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.ips {
+		if b.IP == ip {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryBlockListRepository) Delete(_ context.Context, ip string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.ips[:0]
+	for _, b := range r.ips {
+		if b.IP != ip {
+			kept = append(kept, b)
+		}
+	}
+	deleted := len(r.ips) - len(kept)
+	r.ips = kept
+	return deleted, nil
+}
@@ -0,0 +1,62 @@
+package IPManage
+
+import (
+	"context"
+	"errors"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BlockListRepository 封禁 IP 存储
+type BlockListRepository interface {
+	Insert(ctx context.Context, data BlockedIPs) error
+	// List 按封禁时间倒序分页查询，返回当前页与总数
+	List(ctx context.Context, filter CreateIPFilter) ([]BlockedIPsInDB, int, error)
+	// Exists IP 是否存在封禁记录
+	Exists(ctx context.Context, ip string) (bool, error)
+	// Delete 删除 IP 的全部封禁记录，返回删除数量
+	Delete(ctx context.Context, ip string) (int, error)
+}
+
+type mongoBlockListRepository struct{}
+
+func (mongoBlockListRepository) Insert(ctx context.Context, data BlockedIPs) error {
+	_, err := collections.GetBlockIps().InsertOne(ctx, data)
+	return err
+}
+
+func (mongoBlockListRepository) List(ctx context.Context, filter CreateIPFilter) ([]BlockedIPsInDB, int, error) {
+	conditions := buildFilter(filter)
+	opts := db.CalculatePagination(filter.Page, filter.PageSize, "blocked_at", -1)
+	cursor, err := collections.GetBlockIps().Find(ctx, conditions, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ips []BlockedIPsInDB
+	if err := cursor.All(ctx, &ips); err != nil {
+		return nil, 0, err
+	}
+	total, err := collections.GetBlockIps().CountDocuments(ctx, conditions)
+	return ips, int(total), err
+}
+
+func (mongoBlockListRepository) Delete(ctx context.Context, ip string) (int, error) {
+	result, err := collections.GetBlockIps().DeleteMany(ctx, bson.M{"ip": ip})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+func (mongoBlockListRepository) Exists(ctx context.Context, ip string) (bool, error) {
+	err := collections.GetBlockIps().FindOne(ctx, bson.M{"ip": ip}).Err()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return true, err
+	}
+	return true, nil
+}
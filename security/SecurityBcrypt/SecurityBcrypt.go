@@ -1,25 +1,39 @@
 package SecurityBcrypt
 
 import (
+	"context"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
-	"log"
 )
 
 // GenerateHashPwd 对明文密码生成 bcrypt 哈希
-func GenerateHashPwd(password string) (string, error) {
+func GenerateHashPwd(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.generate", attributeCost(bcrypt.DefaultCost))
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	tracing.End(span, err)
 	if err != nil {
-		log.Fatal("生成密码哈希失败:", err)
+		return "", fmt.Errorf("生成密码哈希失败: %w", err)
 	}
-	return string(hash), err
+	return string(hash), nil
 }
 
 // CompareHashPwd 验证明文密码是否与哈希匹配
-func CompareHashPwd(hash, password string) bool {
+func CompareHashPwd(ctx context.Context, hash, password string) bool {
+	cost, _ := bcrypt.Cost([]byte(hash))
+	_, span := tracing.Start(ctx, "bcrypt.compare", attributeCost(cost))
+	defer span.End()
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// attributeCost bcrypt 计算成本，耗时随其指数增长
+func attributeCost(cost int) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.Int("bcrypt.cost", cost))
+}
+
 //secret, err := Jwt.GenerateSecret(32) // 32字节 ≈ 43字符
 //if err != nil {
 //	panic(err)
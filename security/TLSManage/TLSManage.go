@@ -0,0 +1,199 @@
+package TLSManage
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"math/big"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var logger = logging.For("tls")
+
+// selfSignedValidity 自签名证书有效期，每次启动重新生成
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// CertReloader 持有当前证书，证书或私钥文件修改后由 Watch 重新加载，新连接立即使用新证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	stamp    string
+}
+
+// NewCertReloader 加载证书与私钥文件
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) load() error {
+	stamp := r.fileStamp()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.stamp = stamp
+	logger.Info("已加载证书", "file", r.certFile, "cert", describe(&cert))
+	return nil
+}
+
+// fileStamp 证书与私钥文件的修改时间与大小，用于判断是否修改
+func (r *CertReloader) fileStamp() string {
+	var stamp string
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if st, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%s:%d:%d;", path, st.ModTime().UnixNano(), st.Size())
+		}
+	}
+	return stamp
+}
+
+// GetCertificate 供 tls.Config.GetCertificate 使用
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Watch 每隔 interval 检查证书与私钥文件，修改后重新加载，直到 ctx 取消；
+// 加载失败（如证书与私钥只更新了一个）时保留当前证书，下次检查时重试
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if r.fileStamp() == r.stamp {
+				continue
+			}
+			if err := r.load(); err != nil {
+				logger.Error("证书重新加载失败，继续使用当前证书", logging.Err(err))
+			}
+		}
+	}
+}
+
+// SelfSigned 生成覆盖 hosts（域名或 IP）的自签名证书，仅用于本地开发
+func SelfSigned(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-movies 本地开发"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// selfSignedHosts 自签名证书覆盖的地址：localhost 与监听 IP
+func selfSignedHosts(listenAddr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(listenAddr); err == nil {
+		if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// NewServerConfig 按 tls 配置创建 HTTPS 服务使用的 tls.Config，TLS 未启用时返回 nil；
+// 使用证书文件时在后台监听文件修改，直到 ctx 取消
+//
+// 配置了 client_ca_file 时请求客户端证书但不强制，是否必须由 RequireClientCert 中间件按路由决定
+func NewServerConfig(ctx context.Context, c config.TLSConfig, listenAddr string) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CertFile != "" {
+		reloader, err := NewCertReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = reloader.GetCertificate
+		go reloader.Watch(ctx, time.Duration(config.Get().Reload.WatchSeconds)*time.Second)
+	} else {
+		cert, err := SelfSigned(selfSignedHosts(listenAddr))
+		if err != nil {
+			return nil, fmt.Errorf("生成自签名证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		logger.Warn("已生成自签名证书，浏览器会提示不受信任，仅用于本地开发", "cert", describe(&cert))
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("客户端 CA 文件中没有有效的 PEM 证书: " + c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// describe 证书摘要：主题、有效期与 SHA-256 指纹
+func describe(cert *tls.Certificate) string {
+	leaf := cert.Leaf
+	if leaf == nil && len(cert.Certificate) > 0 {
+		leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if leaf == nil {
+		return "无法解析证书"
+	}
+	sum := sha256.Sum256(leaf.Raw)
+	return fmt.Sprintf("%s，有效期至 %s，SHA-256 %s",
+		leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02 15:04:05"), hex.EncodeToString(sum[:]))
+}
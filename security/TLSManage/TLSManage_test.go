@@ -0,0 +1,148 @@
+package TLSManage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair 生成覆盖 host 的自签名证书并以 PEM 写入 certFile 与 keyFile，返回证书 DER
+func writeKeyPair(t *testing.T, certFile, keyFile, host string) []byte {
+	t.Helper()
+	cert, err := SelfSigned([]string{host})
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert.Certificate[0]
+}
+
+// served 当前对新连接提供的证书 DER
+func served(t *testing.T, r *CertReloader) []byte {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil || cert == nil || len(cert.Certificate) == 0 {
+		t.Fatalf("GetCertificate: %v, %v", cert, err)
+	}
+	return cert.Certificate[0]
+}
+
+func TestCertReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeKeyPair(t, certFile, keyFile, "old.example.com")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader: %v", err)
+	}
+	if !bytes.Equal(served(t, r), first) {
+		t.Fatal("应提供启动时加载的证书")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	second := writeKeyPair(t, certFile, keyFile, "new.example.com")
+	// 确保修改时间变化，不依赖文件系统的时间精度
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !bytes.Equal(served(t, r), second) {
+		if time.Now().After(deadline) {
+			t.Fatal("替换证书文件后应提供新证书")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCertReloaderKeepsCertOnBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeKeyPair(t, certFile, keyFile, "old.example.com")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader: %v", err)
+	}
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.load(); err == nil {
+		t.Error("无效的证书文件应加载失败")
+	}
+	if !bytes.Equal(served(t, r), first) {
+		t.Error("加载失败时应继续提供当前证书")
+	}
+}
+
+func TestSelfSignedCoversHosts(t *testing.T) {
+	hosts := selfSignedHosts("10.0.0.5:8443")
+	tlsConfig, err := NewServerConfig(context.Background(), config.TLSConfig{SelfSigned: true}, "10.0.0.5:8443")
+	if err != nil {
+		t.Fatalf("NewServerConfig: %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("应生成一张自签名证书，实际 %d", len(tlsConfig.Certificates))
+	}
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("自签名证书无法解析: %v", err)
+	}
+	for _, host := range append(hosts, "10.0.0.5") {
+		if err := leaf.VerifyHostname(host); err != nil {
+			t.Errorf("自签名证书应覆盖 %s: %v", host, err)
+		}
+	}
+	if err := leaf.VerifyHostname("example.com"); err == nil {
+		t.Error("自签名证书不应覆盖未配置的域名")
+	}
+	if !time.Now().Before(leaf.NotAfter) || time.Now().Before(leaf.NotBefore) {
+		t.Errorf("自签名证书应在有效期内: %v ~ %v", leaf.NotBefore, leaf.NotAfter)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: pool}); err != nil {
+		t.Errorf("自签名证书应能以自身为根校验通过: %v", err)
+	}
+}
+
+func TestSelfSignedHosts(t *testing.T) {
+	cases := map[string]int{
+		"10.0.0.5:8443":  4, // 额外包含监听 IP
+		"0.0.0.0:8443":   3, // 未指定地址不加入
+		"127.0.0.1:8443": 3, // 回环地址已包含
+		":8443":          3,
+	}
+	for addr, want := range cases {
+		if got := selfSignedHosts(addr); len(got) != want {
+			t.Errorf("selfSignedHosts(%q) = %v，应有 %d 项", addr, got, want)
+		}
+	}
+}
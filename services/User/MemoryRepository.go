@@ -0,0 +1,95 @@
+package UserService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+)
+
+// MemoryUserRepository 进程内用户存储，用于测试
+type MemoryUserRepository struct {
+	mu    sync.Mutex
+	users []UserInDB
+}
+
+// NewMemoryUserRepository 创建内存用户存储
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{}
+}
+
+func (r *MemoryUserRepository) FindActiveByUsername(_ context.Context, username string) (UserInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username && u.Deleted == 1 {
+			return u, nil
+		}
+	}
+	return UserInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindActiveByID(_ context.Context, id primitive.ObjectID) (UserInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(id); i >= 0 && r.users[i].Deleted == 1 {
+		return r.users[i], nil
+	}
+	return UserInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryUserRepository) UsernameTaken(_ context.Context, username string, exclude primitive.ObjectID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.taken(username, exclude), nil
+}
+
+func (r *MemoryUserRepository) Insert(_ context.Context, user *UserInDB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.Deleted == 1 && r.taken(user.Username, primitive.NilObjectID) {
+		return Repository.DuplicateKeyError("username_deleted_unique")
+	}
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *MemoryUserRepository) Update(_ context.Context, id primitive.ObjectID, set bson.M) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return false, nil
+	}
+	_, err := Repository.ApplySet(&r.users[i], set)
+	return true, err
+}
+
+func (r *MemoryUserRepository) UpdateActive(_ context.Context, id primitive.ObjectID, set bson.M) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(id); i >= 0 && r.users[i].Deleted == 1 {
+		_, err := Repository.ApplySet(&r.users[i], set)
+		return err
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) index(id primitive.ObjectID) int {
+	for i, u := range r.users {
+		if u.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemoryUserRepository) taken(username string, exclude primitive.ObjectID) bool {
+	for _, u := range r.users {
+		if u.Username == username && u.Deleted == 1 && u.ID != exclude {
+			return true
+		}
+	}
+	return false
+}
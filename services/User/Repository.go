@@ -0,0 +1,71 @@
+package UserService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepository 用户存储
+type UserRepository interface {
+	// FindActiveByUsername 按用户名查询未删除的用户，不存在返回 mongo.ErrNoDocuments
+	FindActiveByUsername(ctx context.Context, username string) (UserInDB, error)
+	// FindActiveByID 按ID查询未删除的用户，不存在返回 mongo.ErrNoDocuments
+	FindActiveByID(ctx context.Context, id primitive.ObjectID) (UserInDB, error)
+	// UsernameTaken 用户名是否已被其他未删除用户占用，exclude 为排除的用户ID（可为零值）
+	UsernameTaken(ctx context.Context, username string, exclude primitive.ObjectID) (bool, error)
+	// Insert 插入用户，未删除用户名重复时返回重复键错误
+	Insert(ctx context.Context, user *UserInDB) error
+	// Update 按ID更新字段，返回是否匹配到用户
+	Update(ctx context.Context, id primitive.ObjectID, set bson.M) (bool, error)
+	// UpdateActive 按ID更新未删除用户的字段
+	UpdateActive(ctx context.Context, id primitive.ObjectID, set bson.M) error
+}
+
+// mongoUserRepository 基于 MongoDB user 集合的实现
+type mongoUserRepository struct{}
+
+// NewMongoUserRepository 创建 MongoDB 用户存储
+func NewMongoUserRepository() UserRepository {
+	return mongoUserRepository{}
+}
+
+func (mongoUserRepository) FindActiveByUsername(ctx context.Context, username string) (UserInDB, error) {
+	var user UserInDB
+	err := collections.GetUserCollection().FindOne(ctx, bson.M{"username": username, "deleted": 1}).Decode(&user)
+	return user, err
+}
+
+func (mongoUserRepository) FindActiveByID(ctx context.Context, id primitive.ObjectID) (UserInDB, error) {
+	var user UserInDB
+	err := collections.GetUserCollection().FindOne(ctx, bson.M{"_id": id, "deleted": 1}).Decode(&user)
+	return user, err
+}
+
+func (mongoUserRepository) UsernameTaken(ctx context.Context, username string, exclude primitive.ObjectID) (bool, error) {
+	filter := bson.M{"username": username, "deleted": 1}
+	if !exclude.IsZero() {
+		filter["_id"] = bson.M{"$ne": exclude}
+	}
+	count, err := collections.GetUserCollection().CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+func (mongoUserRepository) Insert(ctx context.Context, user *UserInDB) error {
+	_, err := collections.GetUserCollection().InsertOne(ctx, user)
+	return err
+}
+
+func (mongoUserRepository) Update(ctx context.Context, id primitive.ObjectID, set bson.M) (bool, error) {
+	res, err := collections.GetUserCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (mongoUserRepository) UpdateActive(ctx context.Context, id primitive.ObjectID, set bson.M) error {
+	_, err := collections.GetUserCollection().UpdateOne(ctx, bson.M{"_id": id, "deleted": 1}, bson.M{"$set": set})
+	return err
+}
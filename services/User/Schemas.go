@@ -16,32 +16,34 @@ type UserFilter struct {
 	EndTime     string   `json:"end_time" bson:"end_time"`
 }
 type UserSchemas struct {
-	ID          string    `json:"id" bson:"_id"`
-	Username    string    `json:"username" bson:"username"`
-	Password    string    `json:"password" bson:"password"`
-	Code        string    `json:"code" bson:"code"`           // 邀请码
-	CreateAt    time.Time `json:"create_at" bson:"create_at"` // 创建时间
-	CreateAtStr string    `json:"create_at_str" bson:"create_at_str"`
-	UpdateAt    time.Time `json:"update_at" bson:"update_at"` // 更新时间
-	UpdateAtStr string    `json:"update_at_str" bson:"update_at_str"`
-	Status      int       `json:"status" bson:"status"`             // 是否禁用 1 正常 2 已禁用
-	Deleted     int       `json:"deleted" bson:"deleted"`           // 是否删除 1 未删除 2 已删除
-	ContactType string    `json:"contact_type" bson:"contact_type"` // 联系渠道-用于找回密码
-	ContactInfo string    `json:"contact_info" bson:"contact_info"` // 联系信息-用于找回密码
+	ID           string    `json:"id" bson:"_id"`
+	Username     string    `json:"username" bson:"username"`
+	Password     string    `json:"password" bson:"password"`
+	Code         string    `json:"code" bson:"code"`                 // 邀请码
+	CreateAt     time.Time `json:"create_at" bson:"create_at"`       // 创建时间
+	UpdateAt     time.Time `json:"update_at" bson:"update_at"`       // 更新时间
+	Status       int       `json:"status" bson:"status"`             // 是否禁用 1 正常 2 已禁用
+	Deleted      int       `json:"deleted" bson:"deleted"`           // 是否删除 1 未删除 2 已删除
+	ContactType  string    `json:"contact_type" bson:"contact_type"` // 联系渠道-用于找回密码
+	ContactInfo  string    `json:"contact_info" bson:"contact_info"` // 联系信息-用于找回密码
+	CampaignID   string    `json:"campaign_id" bson:"campaign_id"`   // 注册所用邀请码所属活动ID
+	Channel      string    `json:"channel" bson:"channel"`           // 注册来源渠道
+	RecoveryCode string    `json:"-" bson:"recovery_code"`           // 找回密码恢复码（bcrypt 哈希）
 }
 type UserInDB struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	Username    string             `json:"username" bson:"username"`
-	Password    string             `json:"password" bson:"password"`
-	Code        string             `json:"code" bson:"code"`           // 邀请码
-	CreateAt    time.Time          `json:"create_at" bson:"create_at"` // 创建时间
-	CreateAtStr string             `json:"create_at_str" bson:"create_at_str"`
-	UpdateAt    time.Time          `json:"update_at" bson:"update_at"` // 更新时间
-	UpdateAtStr string             `json:"update_at_str" bson:"update_at_str"`
-	Status      int                `json:"status" bson:"status"`             // 是否禁用 1 正常 2 已禁用
-	Deleted     int                `json:"deleted" bson:"deleted"`           // 是否删除 1 未删除 2 已删除
-	ContactType string             `json:"contact_type" bson:"contact_type"` // 联系渠道-用于找回密码
-	ContactInfo string             `json:"contact_info" bson:"contact_info"` // 联系信息-用于找回密码
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Username     string             `json:"username" bson:"username"`
+	Password     string             `json:"password" bson:"password"`
+	Code         string             `json:"code" bson:"code"`                 // 邀请码
+	CreateAt     time.Time          `json:"create_at" bson:"create_at"`       // 创建时间
+	UpdateAt     time.Time          `json:"update_at" bson:"update_at"`       // 更新时间
+	Status       int                `json:"status" bson:"status"`             // 是否禁用 1 正常 2 已禁用
+	Deleted      int                `json:"deleted" bson:"deleted"`           // 是否删除 1 未删除 2 已删除
+	ContactType  string             `json:"contact_type" bson:"contact_type"` // 联系渠道-用于找回密码
+	ContactInfo  string             `json:"contact_info" bson:"contact_info"` // 联系信息-用于找回密码
+	CampaignID   string             `json:"campaign_id" bson:"campaign_id"`   // 注册所用邀请码所属活动ID
+	Channel      string             `json:"channel" bson:"channel"`           // 注册来源渠道
+	RecoveryCode string             `json:"-" bson:"recovery_code"`           // 找回密码恢复码（bcrypt 哈希）
 }
 type CreateUser struct {
 	Username    string `json:"username" binding:"required" bson:"username"`
@@ -55,19 +57,19 @@ type NewUserSchemas struct {
 	Password    string    `json:"password" binding:"required" bson:"password"`
 	Code        string    `json:"code" binding:"required" bson:"code"` // 邀请码
 	CreateAt    time.Time `json:"create_at" bson:"create_at"`          // 创建时间
-	CreateAtStr string    `json:"create_at_str" bson:"create_at_str"`
-	UpdateAt    time.Time `json:"update_at" bson:"update_at"` // 更新时间
-	UpdateAtStr string    `json:"update_at_str" bson:"update_at_str"`
-	Status      int       `json:"status" bson:"status"`             // 是否禁用 1 正常 2 已禁用禁止一切操作 3 已冻结 可更改密码解锁登录
-	Deleted     int       `json:"deleted" bson:"deleted"`           // 是否删除 1 未删除 2 已删除
-	ContactType string    `json:"contact_type" bson:"contact_type"` // 联系渠道-用于找回密码
-	ContactInfo string    `json:"contact_info" bson:"contact_info"` // 联系信息-用于找回密码
+	UpdateAt    time.Time `json:"update_at" bson:"update_at"`          // 更新时间
+	Status      int       `json:"status" bson:"status"`                // 是否禁用 1 正常 2 已禁用禁止一切操作 3 已冻结 可更改密码解锁登录
+	Deleted     int       `json:"deleted" bson:"deleted"`              // 是否删除 1 未删除 2 已删除
+	ContactType string    `json:"contact_type" bson:"contact_type"`    // 联系渠道-用于找回密码
+	ContactInfo string    `json:"contact_info" bson:"contact_info"`    // 联系信息-用于找回密码
 }
 type UserLogin struct {
 	Username string `json:"username" binding:"required" bson:"username"`
 	Password string `json:"password" binding:"required" bson:"password"`
 }
 
+// ForgetUser 忘记密码请求体
+// Code 为注册时下发的恢复码；注册早于恢复码功能的旧用户仍可使用其单次邀请码
 type ForgetUser struct {
 	Username string `json:"username" binding:"required" bson:"username"`
 	Code     string `json:"code" binding:"required" bson:"code"`
@@ -79,6 +81,25 @@ type ChangePasswordSchema struct {
 	NewPassword string `json:"new_password" binding:"required" bson:"new_password"`
 }
 
+// RegisterResponse 注册成功返回的恢复码，仅下发一次，用于忘记密码时重置
+// UserInfo 对外展示的用户信息，不含密码与恢复码
+type UserInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Code        string    `json:"code"`   // 注册所用邀请码
+	Status      int       `json:"status"` // 1 正常 2 已禁用 3 安全冻结
+	CreateAt    time.Time `json:"create_at"`
+	UpdateAt    time.Time `json:"update_at"`
+	ContactType string    `json:"contact_type"`
+	ContactInfo string    `json:"contact_info"`
+	CampaignID  string    `json:"campaign_id"`
+	Channel     string    `json:"channel"`
+}
+
+type RegisterResponse struct {
+	RecoveryCode string `json:"recovery_code"`
+}
+
 type LoginFailureResponse struct {
 	FailedAttempts int `json:"num"`
 	LockSeconds    int `json:"seconds"`
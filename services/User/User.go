@@ -1,18 +1,17 @@
 package UserService
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
-	"github.com/StephenChristianW/go-movies-open/db/RedisService/OperateToken"
-	"github.com/StephenChristianW/go-movies-open/db/RedisService/ValidateUser"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/metrics"
 	"github.com/StephenChristianW/go-movies-open/security/SecurityBcrypt"
 	"github.com/StephenChristianW/go-movies-open/services"
 	"github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
 	"github.com/StephenChristianW/go-movies-open/utils/Jwt"
-	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,78 +19,97 @@ import (
 )
 
 type UserInterface interface {
-	RegisterUser(user CreateUser) error
-	UserLogin(username, password string) (LoginResponse, error)
-	ForgetPassword(username, code, password string) error
-	ChangePassword(userID, oldPassword, newPassword string) error
-	RenameUser(userID, newUserName string) error
-	DeleteUser(username string) error
-	BannedUser(username string) error
-	UserList(filter UserFilter) (services.Pagination, error)
+	RegisterUser(ctx context.Context, user CreateUser) (string, error)
+	UserLogin(ctx context.Context, username, password string) (LoginResponse, error)
+	ForgetPassword(ctx context.Context, username, code, password string) error
+	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
+	RenameUser(ctx context.Context, userID, newUserName string) error
+	DeleteUser(ctx context.Context, username string) error
+	BannedUser(ctx context.Context, username string) error
+	GetUser(ctx context.Context, username string) (UserInfo, error)
+	RevokeToken(ctx context.Context, username string) error
+	UserList(ctx context.Context, filter UserFilter) (services.Pagination, error)
 }
+
+// UserSchema 用户服务，依赖字段为 nil 时使用 MongoDB 与 SESSION_STORE 所选的默认实现
 type UserSchema struct {
+	Users        UserRepository                     // 用户存储
+	Tokens       Repository.TokenStore              // 访问令牌存储
+	FailedLogins Repository.FailedLoginStore        // 登录失败记录存储
+	Invites      InviteCodeService.CreateInviteCode // 邀请码服务，Invites.Repos 为 nil 时使用 MongoDB
+	Tx           Repository.Transactor              // 事务执行器
+}
+
+func (u *UserSchema) users() UserRepository {
+	if u.Users != nil {
+		return u.Users
+	}
+	return NewMongoUserRepository()
+}
+
+func (u *UserSchema) tokens() Repository.TokenStore {
+	if u.Tokens != nil {
+		return u.Tokens
+	}
+	return Repository.DefaultTokenStore()
+}
+
+func (u *UserSchema) failedLogins() Repository.FailedLoginStore {
+	if u.FailedLogins != nil {
+		return u.FailedLogins
+	}
+	return Repository.DefaultFailedLoginStore()
+}
+
+func (u *UserSchema) tx() Repository.Transactor {
+	if u.Tx != nil {
+		return u.Tx
+	}
+	return Repository.MongoTransactor{}
 }
 
 // RegisterUser 注册新用户
 // 功能：
-// 1. 检查用户名是否已存在
-// 2. 检查邀请码是否已被使用
-// 3. 创建新的用户数据
-// 4. 将新用户插入 MongoDB
-// 5. 更新邀请码状态为已绑定
-func (*UserSchema) RegisterUser(user CreateUser) error {
-	ctx, cancel := db.GetCtx() // 获取 MongoDB 上下文
+// 1. 校验用户名与密码长度，生成新用户数据（用户ID、密码哈希与恢复码）
+// 2. 在一个事务中完成检查用户名、兑换邀请码（存在、未过期、未删除且有剩余次数）、占用活动名额、插入用户，任一步失败整体回滚
+// 3. MongoDB 为单机部署不支持事务时，降级为逐步执行并在失败时补偿回滚
+// 返回值：恢复码明文，仅在注册时下发一次
+func (u *UserSchema) RegisterUser(ctx context.Context, user CreateUser) (string, error) {
+	ctx, cancel := db.WithTimeout(ctx) // 获取 MongoDB 上下文
 	defer cancel()
-	if len(user.Username) < config.LessLenUserName {
-		return errors.New("用户名至少3个字符")
-	}
-	if len(user.Password) < config.LessLenPwd {
-		return errors.New("密码至少6位")
-	}
-	// 检查用户是否存在
-	var userInDB UserInDB
-	err := collections.GetUserCollection().FindOne(ctx, bson.M{
-		"username": user.Username,
-		"deleted":  1, // 只检查未删除的用户
-	}).Decode(&userInDB)
-	if err == nil {
-		return errors.New("用户已存在") // 用户名已存在
-	} else if !errors.Is(err, mongo.ErrNoDocuments) {
-		return err // 查询出错
-	}
-
-	// 检查邀请码是否已被使用
-	ok, err := codeIsBind(user.Code)
-	if err != nil {
-		return err // 查询邀请码出错
+	account := config.Account()
+	if len(user.Username) < account.MinUserNameLen {
+		return "", fmt.Errorf("用户名至少%d个字符", account.MinUserNameLen)
 	}
-	if ok {
-		return fmt.Errorf("邀请码 %s 已被使用", user.Code)
+	if len(user.Password) < account.MinPasswordLen {
+		return "", fmt.Errorf("密码至少%d位", account.MinPasswordLen)
 	}
 
-	// 创建新用户数据（newUser 会生成初始状态、密码哈希等）
-	newU, err := newUser(user)
+	// 规范化邀请码，格式或校验位错误直接拒绝，无需查库
+	code, err := InviteCodeService.NormalizeCode(user.Code)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("%w: %s", err, user.Code)
 	}
+	user.Code = code
 
-	// 插入新用户到 MongoDB
-	_, err = collections.GetUserCollection().InsertOne(ctx, newU)
+	// 创建新用户数据（newUser 会生成用户ID、初始状态、密码哈希与恢复码）
+	newU, recoveryCode, err := newUser(ctx, user)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// 更新邀请码状态为已绑定，并记录绑定的用户名
-	_, err = collections.GetInviteCodeCollection().UpdateOne(
-		ctx,
-		bson.M{"code": user.Code},
-		bson.M{"$set": bson.M{"username": user.Username, "status": 2}},
-	)
-	if err != nil {
+	err = u.tx().WithTransaction(ctx, func(sessCtx context.Context) error {
+		_, err := u.registerSteps(sessCtx, newU)
 		return err
+	})
+	if errors.Is(err, db.ErrTransactionsUnsupported) {
+		err = u.registerWithCompensation(ctx, newU)
 	}
-
-	return nil
+	if err != nil {
+		return "", registerError(user.Code, err)
+	}
+	metrics.IncInviteRedeemed()
+	return recoveryCode, nil
 }
 
 // UserLogin 用户登录
@@ -99,16 +117,29 @@ func (*UserSchema) RegisterUser(user CreateUser) error {
 // 1. 查询用户信息
 // 2. 检查用户状态（正常、禁用、冻结）
 // 3. 根据失败次数判断是否锁定
-// 4. 验证密码，如果失败 记录失败次数并更新 Redis
+// 4. 验证密码，如果失败 更新登录失败记录
 // 5. 登录成功，清理失败记录
 // 6. 获取已有 token 或生成新的 JWT token 并存储
-func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
-	ctx, cancel := db.GetCtx() // 获取 MongoDB 上下文
+func (u *UserSchema) UserLogin(ctx context.Context, username, password string) (LoginResponse, error) {
+	resp, err := u.userLogin(ctx, username, password)
+	result := metrics.LoginFailure
+	switch {
+	case resp.LockSeconds > 0:
+		result = metrics.LoginLocked
+	case err == nil && resp.Token != "":
+		result = metrics.LoginSuccess
+	}
+	metrics.ObserveLogin(metrics.LoginUser, result)
+	return resp, err
+}
+
+// userLogin 执行登录；密码错误时不返回错误，而是返回失败次数与锁定秒数
+func (u *UserSchema) userLogin(ctx context.Context, username, password string) (LoginResponse, error) {
+	ctx, cancel := db.WithTimeout(ctx) // 获取 MongoDB 上下文
 	defer cancel()
 	var resp = LoginResponse{}
 	// 1. 查询用户
-	var userInDB UserInDB
-	err := collections.GetUserCollection().FindOne(ctx, bson.M{"username": username, "deleted": 1}).Decode(&userInDB)
+	userInDB, err := u.users().FindActiveByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return resp, errors.New("用户不存在")
@@ -124,11 +155,11 @@ func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
 	case 3:
 		return resp, errors.New("账户已被安全冻结,请重置密码后重试")
 	}
-	if !SecurityBcrypt.CompareHashPwd(userInDB.Password, password) {
-		_ = OperateToken.DeleteUserToken(userID)
+	if !SecurityBcrypt.CompareHashPwd(ctx, userInDB.Password, password) {
+		_ = u.tokens().Delete(ctx, Repository.UserToken, userID)
 		// 3. 检查是否锁定
-		// validateUser 根据 Redis 失败次数和上次失败时间判断是否锁定
-		lockSeconds, count, err := validateUser(userID)
+		// validateUser 根据失败次数和上次失败时间判断是否锁定
+		lockSeconds, count, err := u.validateUser(ctx, userID)
 		if err != nil {
 			return resp, err
 		}
@@ -141,8 +172,8 @@ func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
 			return resp, nil
 		}
 		count += 1
-		err = ValidateUser.SetFailedLoginInfo(userID,
-			ValidateUser.FailedLoginInfo{
+		err = u.failedLogins().Set(ctx, userID,
+			Repository.FailedLoginInfo{
 				Count: count,
 				Last:  time.Now(),
 			})
@@ -151,13 +182,13 @@ func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
 		}
 		return resp, nil
 	}
-	err = ValidateUser.DeleteFailedLoginRecord(userID)
+	err = u.failedLogins().Delete(ctx, userID)
 	if err != nil {
 		return resp, err
 	}
 	// 生成token
 	var token string
-	token, err = OperateToken.GetUserToken(userID)
+	token, err = u.tokens().Get(ctx, Repository.UserToken, userID)
 	if err != nil {
 		return resp, err
 	}
@@ -165,11 +196,11 @@ func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
 		resp.Token = token
 		return resp, nil
 	}
-	token, err = Jwt.GenerateUserToken(userID, username, userExpireDuration)
+	token, err = Jwt.GenerateUserToken(userID, username, config.UserTokenTTL())
 	if err != nil {
 		return resp, err
 	}
-	err = OperateToken.StoreUserToken(userID, token, userExpireDuration)
+	err = u.tokens().Set(ctx, Repository.UserToken, userID, token, config.UserTokenTTL())
 	if err != nil {
 		return resp, err
 	}
@@ -178,44 +209,96 @@ func (*UserSchema) UserLogin(username, password string) (LoginResponse, error) {
 	return resp, nil
 }
 
-func (*UserSchema) RenameUser(userID, newUserName string) error {
-	ctx, cancel := db.GetCtx()
+func (u *UserSchema) RenameUser(ctx context.Context, userID, newUserName string) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 	objId, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return err
 	}
 	// 检查新用户名是否已被占用（排除自己）
-	count, err := collections.GetUserCollection().CountDocuments(ctx, bson.M{
-		"username": newUserName,
-		"deleted":  1,
-		"_id":      bson.M{"$ne": objId},
-	})
+	taken, err := u.users().UsernameTaken(ctx, newUserName, objId)
 	if err != nil {
 		return err
 	}
-	if count > 0 {
+	if taken {
 		return errors.New("用户名已存在")
 	}
 	// 更新用户
-	res, err := collections.GetUserCollection().UpdateOne(ctx,
-		bson.M{"_id": objId},
-		bson.M{"$set": bson.M{"username": newUserName}},
-	)
+	matched, err := u.users().Update(ctx, objId, bson.M{"username": newUserName})
 	if err != nil {
 		return err
 	}
-	if res.MatchedCount == 0 {
+	if !matched {
 		return errors.New("用户不存在")
 	}
 	return nil
 }
 
-func (*UserSchema) DeleteUser(username string) error {
+func (*UserSchema) DeleteUser(ctx context.Context, username string) error {
 	return nil
 }
-func (*UserSchema) BannedUser(username string) error {
-	return nil
+
+// BannedUser 禁用用户并吊销其访问令牌
+func (u *UserSchema) BannedUser(ctx context.Context, username string) error {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	userInDB, err := u.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if userInDB.Status == 2 {
+		return fmt.Errorf("用户 %s 已被禁用", username)
+	}
+	if err := u.users().UpdateActive(ctx, userInDB.ID, bson.M{"status": 2}); err != nil {
+		return err
+	}
+	return u.tokens().Delete(ctx, Repository.UserToken, userInDB.ID.Hex())
+}
+
+// GetUser 按用户名查询未删除的用户信息，不含密码与恢复码
+func (u *UserSchema) GetUser(ctx context.Context, username string) (UserInfo, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	userInDB, err := u.findUser(ctx, username)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	return UserInfo{
+		ID:          userInDB.ID.Hex(),
+		Username:    userInDB.Username,
+		Code:        userInDB.Code,
+		Status:      userInDB.Status,
+		CreateAt:    userInDB.CreateAt,
+		UpdateAt:    userInDB.UpdateAt,
+		ContactType: userInDB.ContactType,
+		ContactInfo: userInDB.ContactInfo,
+		CampaignID:  userInDB.CampaignID,
+		Channel:     userInDB.Channel,
+	}, nil
+}
+
+// RevokeToken 吊销用户当前的访问令牌，用户需重新登录
+func (u *UserSchema) RevokeToken(ctx context.Context, username string) error {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	userInDB, err := u.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	return u.tokens().Delete(ctx, Repository.UserToken, userInDB.ID.Hex())
+}
+
+// findUser 按用户名查询未删除的用户，不存在时返回可读的错误
+func (u *UserSchema) findUser(ctx context.Context, username string) (UserInDB, error) {
+	userInDB, err := u.users().FindActiveByUsername(ctx, username)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return userInDB, fmt.Errorf("用户 %s 不存在", username)
+	}
+	return userInDB, err
 }
 func filterFunc(filter UserFilter) bson.M {
 	var condition bson.M
@@ -228,7 +311,7 @@ func filterFunc(filter UserFilter) bson.M {
 	}
 	return condition
 }
-func (*UserSchema) UserList(filter UserFilter) (services.Pagination, error) {
+func (*UserSchema) UserList(ctx context.Context, filter UserFilter) (services.Pagination, error) {
 
 	return services.Pagination{}, nil
 }
@@ -243,9 +326,9 @@ func (*UserSchema) UserList(filter UserFilter) (services.Pagination, error) {
 // 返回值:
 //
 //	error - 错误信息，如果修改失败会返回具体错误
-func (*UserSchema) ChangePassword(userID, oldPassword, newPassword string) error {
+func (u *UserSchema) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
 	// 1. 获取 MongoDB 上下文
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	// 2. 将字符串 userID 转换为 MongoDB ObjectID
@@ -255,33 +338,27 @@ func (*UserSchema) ChangePassword(userID, oldPassword, newPassword string) error
 	}
 
 	// 3. 查询数据库获取用户信息
-	var userInDB UserInDB
-	err = collections.GetUserCollection().FindOne(ctx, bson.M{
-		"_id":     objId,
-		"deleted": 1, // 未删除状态
-	}).Decode(&userInDB)
+	userInDB, err := u.users().FindActiveByID(ctx, objId)
 	if err != nil {
 		return err
 	}
 
 	// 4. 校验旧密码是否正确
-	if !SecurityBcrypt.CompareHashPwd(userInDB.Password, oldPassword) {
+	if !SecurityBcrypt.CompareHashPwd(ctx, userInDB.Password, oldPassword) {
 		return errors.New("密码错误")
 	}
 
 	// 5. 生成新密码的哈希值
-	newHash, err := SecurityBcrypt.GenerateHashPwd(newPassword)
+	newHash, err := SecurityBcrypt.GenerateHashPwd(ctx, newPassword)
 	if err != nil {
 		return err
 	}
 
 	// 6. 更新数据库中的密码和更新时间
-	_, err = collections.GetUserCollection().UpdateOne(ctx, bson.M{"_id": userInDB.ID},
-		bson.M{"$set": bson.M{
-			"password":      newHash,             // 更新密码
-			"update_at":     time.Now(),          // 更新时间
-			"update_at_str": UtilsTime.NowTime(), // 更新时间字符串表示
-		}})
+	_, err = u.users().Update(ctx, userInDB.ID, bson.M{
+		"password":  newHash,    // 更新密码
+		"update_at": time.Now(), // 更新时间
+	})
 	if err != nil {
 		return err
 	}
@@ -293,20 +370,19 @@ func (*UserSchema) ChangePassword(userID, oldPassword, newPassword string) error
 // 参数:
 //
 //	username - 用户名
-//	code - 用户的邀请码
+//	code - 注册时下发的恢复码（旧用户可使用其单次邀请码）
 //	password - 新密码
 //
 // 返回值:
 //
 //	error - 错误信息，如果修改失败会返回具体错误
-func (*UserSchema) ForgetPassword(username, code, password string) error {
+func (u *UserSchema) ForgetPassword(ctx context.Context, username, code, password string) error {
 	// 1. 获取 MongoDB 上下文
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	// 2. 查询用户信息
-	var userInDB UserInDB
-	err := collections.GetUserCollection().FindOne(ctx, bson.M{"username": username, "deleted": 1}).Decode(&userInDB)
+	userInDB, err := u.users().FindActiveByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return errors.New("无用户: " + username)
@@ -316,35 +392,29 @@ func (*UserSchema) ForgetPassword(username, code, password string) error {
 	if userInDB.Status == 2 {
 		return errors.New("用户已禁用, 请联系管理员")
 	}
-	// 3. 查询用户对应的邀请码信息
-	var inviteCode InviteCodeService.InviteCodeInDB
-	err = collections.GetInviteCodeCollection().FindOne(ctx, bson.M{"code": userInDB.Code}).Decode(&inviteCode)
+
+	// 3. 校验恢复码
+	ok, err := u.verifyRecoveryCode(ctx, userInDB, code)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return errors.New("邀请码错误")
-		}
 		return err
 	}
-
-	// 4. 校验用户提供的邀请码是否匹配
-	if code != inviteCode.Code {
-		return errors.New("邀请码错误, 密码无法修改")
+	if !ok {
+		return errors.New("恢复码错误, 密码无法修改")
 	}
 
-	// 5. 生成新密码的哈希值
-	pwd, err := SecurityBcrypt.GenerateHashPwd(password)
+	// 4. 生成新密码的哈希值
+	pwd, err := SecurityBcrypt.GenerateHashPwd(ctx, password)
 	if err != nil {
 		return err
 	}
 
-	// 6. 更新用户密码及更新时间
-	_, err = collections.GetUserCollection().UpdateOne(ctx, bson.M{"_id": userInDB.ID}, bson.M{"$set": bson.M{
-		"status":        1,
-		"password":      pwd,                 // 新密码
-		"update_at":     time.Now(),          // 更新时间
-		"update_at_str": UtilsTime.NowTime(), // 更新时间字符串
-	}})
-	_ = ValidateUser.DeleteFailedLoginRecord(userInDB.ID.Hex())
+	// 5. 更新用户密码及更新时间
+	_, err = u.users().Update(ctx, userInDB.ID, bson.M{
+		"status":    1,
+		"password":  pwd,        // 新密码
+		"update_at": time.Now(), // 更新时间
+	})
+	_ = u.failedLogins().Delete(ctx, userInDB.ID.Hex())
 	if err != nil {
 		return err
 	}
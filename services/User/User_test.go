@@ -0,0 +1,231 @@
+package UserService
+
+import (
+	"context"
+	"errors"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
+	"github.com/StephenChristianW/go-movies-open/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// newTestService 使用内存存储、不支持事务的用户服务，注册走补偿回滚路径
+func newTestService(t *testing.T) *UserSchema {
+	t.Helper()
+	if config.UserSecret == "" {
+		config.UserSecret = "test-user-secret"
+	}
+	return &UserSchema{
+		Users:        NewMemoryUserRepository(),
+		Tokens:       Repository.NewMemoryTokenStore(),
+		FailedLogins: Repository.NewMemoryFailedLoginStore(),
+		Invites:      InviteCodeService.CreateInviteCode{Repos: InviteCodeService.NewMemoryRepositories()},
+		Tx:           Repository.NoTransactor{},
+	}
+}
+
+// newCode 生成邀请码并返回码值
+func newCode(t *testing.T, u *UserSchema, opts InviteCodeService.GenerateOptions) string {
+	t.Helper()
+	id, err := u.Invites.GenerateAndInsertCode(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("GenerateAndInsertCode: %v", err)
+	}
+	code, err := u.Invites.GetInviteCode(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetInviteCode: %v", err)
+	}
+	return code.Code
+}
+
+// register 注册用户并返回恢复码
+func register(t *testing.T, u *UserSchema, username, password string) string {
+	t.Helper()
+	code := newCode(t, u, InviteCodeService.GenerateOptions{})
+	recovery, err := u.RegisterUser(context.Background(), CreateUser{Username: username, Password: password, Code: code})
+	if err != nil {
+		t.Fatalf("RegisterUser(%s): %v", username, err)
+	}
+	return recovery
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	recovery := register(t, u, "alice", "secret123")
+	if recovery == "" {
+		t.Fatal("注册应下发恢复码")
+	}
+
+	resp, err := u.UserLogin(ctx, "alice", "secret123")
+	if err != nil {
+		t.Fatalf("UserLogin: %v", err)
+	}
+	if resp.Token == "" {
+		t.Fatal("登录成功应返回 token")
+	}
+	again, err := u.UserLogin(ctx, "alice", "secret123")
+	if err != nil {
+		t.Fatalf("UserLogin: %v", err)
+	}
+	if again.Token != resp.Token {
+		t.Error("令牌未过期时应复用已有 token")
+	}
+
+	if _, err := u.UserLogin(ctx, "nobody", "secret123"); err == nil {
+		t.Error("不存在的用户应登录失败")
+	}
+}
+
+func TestRegisterDuplicateReleasesInviteCode(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	register(t, u, "alice", "secret123")
+
+	code := newCode(t, u, InviteCodeService.GenerateOptions{})
+	_, err := u.RegisterUser(ctx, CreateUser{Username: "alice", Password: "secret123", Code: code})
+	if !errors.Is(err, errUserExists) {
+		t.Fatalf("重复用户名应返回 errUserExists，实际 %v", err)
+	}
+	if _, err := u.RegisterUser(ctx, CreateUser{Username: "bob", Password: "secret123", Code: code}); err != nil {
+		t.Errorf("未注册成功时邀请码不应被消耗: %v", err)
+	}
+}
+
+func TestRegisterCompensatesCampaignQuota(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	campaignID, err := u.Invites.CreateCampaign(ctx, InviteCodeService.CreateCampaign{Name: "限量活动", MaxRedemptions: 1}, "admin-id", "admin")
+	if err != nil {
+		t.Fatalf("CreateCampaign: %v", err)
+	}
+	first := newCode(t, u, InviteCodeService.GenerateOptions{CampaignID: campaignID})
+	second := newCode(t, u, InviteCodeService.GenerateOptions{CampaignID: campaignID})
+
+	if _, err := u.RegisterUser(ctx, CreateUser{Username: "alice", Password: "secret123", Code: first}); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	alice, err := u.users().FindActiveByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("FindActiveByUsername: %v", err)
+	}
+	if alice.CampaignID != campaignID {
+		t.Errorf("用户应记录注册来源活动，实际 %q", alice.CampaignID)
+	}
+
+	_, err = u.RegisterUser(ctx, CreateUser{Username: "bob", Password: "secret123", Code: second})
+	if !errors.Is(err, InviteCodeService.ErrCampaignQuotaExceeded) {
+		t.Fatalf("活动名额已满应返回 ErrCampaignQuotaExceeded，实际 %v", err)
+	}
+	got, err := u.Invites.GetInviteCode(ctx, second)
+	if err != nil {
+		t.Fatalf("GetInviteCode: %v", err)
+	}
+	if got.UsedCount != 0 || got.Username != "" || got.Status != 1 {
+		t.Errorf("名额不足时应撤销邀请码兑换，实际 %+v", got)
+	}
+	if _, err := u.users().FindActiveByUsername(context.Background(), "bob"); err == nil {
+		t.Error("注册失败不应写入用户")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	register(t, u, "alice", "secret123")
+
+	var resp LoginResponse
+	var err error
+	for i := 0; i < 3; i++ {
+		if resp, err = u.UserLogin(ctx, "alice", "wrong-password"); err != nil {
+			t.Fatalf("第 %d 次错误登录: %v", i+1, err)
+		}
+	}
+	if resp.LockSeconds <= 0 {
+		t.Errorf("连续 3 次密码错误应锁定，实际 %+v", resp)
+	}
+
+	// 失败次数写入失败记录存储
+	alice, _ := u.users().FindActiveByUsername(context.Background(), "alice")
+	if _, ok, _ := u.failedLogins().Get(context.Background(), alice.ID.Hex()); !ok {
+		t.Fatal("应记录登录失败次数")
+	}
+}
+
+func TestPasswordRecoveryAndChange(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	recovery := register(t, u, "alice", "secret123")
+	alice, _ := u.users().FindActiveByUsername(context.Background(), "alice")
+
+	if err := u.ForgetPassword(ctx, "alice", "WRONGCODE", "newpass123"); err == nil {
+		t.Error("错误的恢复码不应允许重置密码")
+	}
+	if err := u.ForgetPassword(ctx, "alice", recovery, "newpass123"); err != nil {
+		t.Fatalf("ForgetPassword: %v", err)
+	}
+	if _, ok, _ := u.failedLogins().Get(context.Background(), alice.ID.Hex()); ok {
+		t.Error("重置密码后应清除失败记录")
+	}
+
+	if err := u.ChangePassword(ctx, alice.ID.Hex(), "secret123", "other123"); err == nil {
+		t.Error("旧密码错误时不应修改密码")
+	}
+	if err := u.ChangePassword(ctx, alice.ID.Hex(), "newpass123", "other123"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if resp, err := u.UserLogin(ctx, "alice", "other123"); err != nil || resp.Token == "" {
+		t.Errorf("修改后的密码应能登录: %+v, %v", resp, err)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	ctx := context.Background()
+	u := newTestService(t)
+	register(t, u, "alice", "secret123")
+	register(t, u, "bob", "secret123")
+	alice, _ := u.users().FindActiveByUsername(context.Background(), "alice")
+
+	if err := u.RenameUser(ctx, alice.ID.Hex(), "bob"); err == nil {
+		t.Error("重名时不应允许改名")
+	}
+	if err := u.RenameUser(ctx, alice.ID.Hex(), "alice2"); err != nil {
+		t.Fatalf("RenameUser: %v", err)
+	}
+	if _, err := u.users().FindActiveByUsername(context.Background(), "alice2"); err != nil {
+		t.Errorf("改名后应能按新用户名查询: %v", err)
+	}
+}
+
+func TestLoginTracesBcrypt(t *testing.T) {
+	u := newTestService(t)
+	register(t, u, "alice", "secret123")
+
+	prev := otel.GetTracerProvider()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, request := tracing.Start(context.Background(), "POST /user/login")
+	if _, err := u.UserLogin(ctx, "alice", "secret123"); err != nil {
+		t.Fatalf("UserLogin: %v", err)
+	}
+	request.End()
+
+	var found bool
+	for _, s := range exporter.GetSpans() {
+		if s.Name == "bcrypt.compare" {
+			found = true
+			if s.Parent.SpanID() != request.SpanContext().SpanID() {
+				t.Errorf("bcrypt.compare 应为请求 span 的子 span")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("登录应记录 bcrypt.compare span，实际 %v", exporter.GetSpans())
+	}
+}
@@ -1,13 +1,14 @@
 package UserService
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
-	"github.com/StephenChristianW/go-movies-open/db/RedisService/ValidateUser"
+	"github.com/StephenChristianW/go-movies-open/logging"
 	"github.com/StephenChristianW/go-movies-open/security/SecurityBcrypt"
+	"github.com/StephenChristianW/go-movies-open/services/System/InviteCode"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsRandom"
 	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,50 +16,150 @@ import (
 	"time"
 )
 
-var userExpireDuration = UtilsTime.DaysToTimeDuration(config.UserExpiration)
-
-func codeIsBind(code string) (bool, error) {
-	ctx, cancel := db.GetCtx()
-	defer cancel()
-	// 查询是否有未删除、已使用的邀请码
-	result := collections.GetInviteCodeCollection().FindOne(ctx, bson.M{
-		"code":    code,
-		"status":  2, // 已使用
-		"deleted": 1, // 未删除
-	})
-	if result.Err() != nil {
-		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
-			return false, nil
+var logger = logging.For("user")
+
+var errUserExists = errors.New("用户已存在")
+
+// registerClaim 注册过程中已完成的兑换，用于补偿回滚
+type registerClaim struct {
+	code     *InviteCodeService.InviteCodeInDB
+	campaign *InviteCodeService.Campaign
+}
+
+// registerSteps 依次执行注册的各个步骤：检查用户名、兑换邀请码、占用活动名额、插入用户
+// 在事务中执行时任一步失败整体回滚；返回已完成的兑换供非事务模式补偿
+func (u *UserSchema) registerSteps(ctx context.Context, newU *UserInDB) (registerClaim, error) {
+	var done registerClaim
+	userID := newU.ID.Hex()
+
+	// 检查用户是否存在（只检查未删除的用户）
+	taken, err := u.users().UsernameTaken(ctx, newU.Username, primitive.NilObjectID)
+	if err != nil {
+		return done, err
+	}
+	if taken {
+		return done, errUserExists
+	}
+
+	// 兑换邀请码，并发注册不会超出邀请码的使用次数；兑换失败时已由 ClaimInviteCode 归还占用的次数
+	claimed, err := u.Invites.ClaimInviteCode(ctx, newU.Code, userID, newU.Username)
+	if err != nil {
+		return done, err
+	}
+	done.code = &claimed
+
+	// 占用活动兑换名额，记录注册来源
+	campaign, err := u.Invites.ReserveCampaignQuota(ctx, claimed.CampaignID)
+	if err != nil {
+		return done, err
+	}
+	done.campaign = campaign
+	if campaign != nil {
+		newU.CampaignID = campaign.ID
+		newU.Channel = campaign.Channel
+	}
+
+	// 插入新用户到 MongoDB
+	if err = u.users().Insert(ctx, newU); err != nil {
+		// 并发注册同名用户时由 username 唯一索引拦截
+		if mongo.IsDuplicateKeyError(err) {
+			return done, errUserExists
+		}
+		return done, err
+	}
+	return done, nil
+}
+
+// registerWithCompensation 不支持事务时逐步注册，失败后撤销已完成的兑换与名额占用
+func (u *UserSchema) registerWithCompensation(ctx context.Context, newU *UserInDB) error {
+	done, err := u.registerSteps(ctx, newU)
+	if err == nil {
+		return nil
+	}
+	if done.campaign != nil {
+		if rbErr := u.Invites.ReleaseCampaignQuota(ctx, done.campaign.ID); rbErr != nil {
+			logger.ErrorContext(ctx, "注册回滚失败: 归还活动名额出错", "campaign_id", done.campaign.ID, logging.Err(rbErr))
 		}
-		return false, result.Err()
 	}
-	return true, nil
+	if done.code != nil {
+		if rbErr := u.Invites.ReleaseInviteCode(ctx, *done.code, newU.ID.Hex()); rbErr != nil {
+			logger.ErrorContext(ctx, "注册回滚失败: 撤销邀请码兑换出错", "code", done.code.Code, logging.Err(rbErr))
+		}
+	}
+	return err
+}
+
+// registerError 将注册失败原因转换为对外提示
+func registerError(code string, err error) error {
+	switch {
+	case errors.Is(err, InviteCodeService.ErrInviteCodeNotFound),
+		errors.Is(err, InviteCodeService.ErrInviteCodeDeleted),
+		errors.Is(err, InviteCodeService.ErrInviteCodeExpired),
+		errors.Is(err, InviteCodeService.ErrInviteCodeUnavailable):
+		return fmt.Errorf("%w: %s", err, code)
+	case mongo.IsDuplicateKeyError(err):
+		return errUserExists
+	}
+	return err
 }
 
-func newUser(user CreateUser) (*UserSchemas, error) {
+// recoveryCodeLength 找回密码恢复码长度
+const recoveryCodeLength = 12
+
+// newUser 构建新用户数据，返回用户及恢复码明文（数据库仅保存其哈希）
+func newUser(ctx context.Context, user CreateUser) (*UserInDB, string, error) {
 
-	pwd, err := SecurityBcrypt.GenerateHashPwd(user.Password)
+	pwd, err := SecurityBcrypt.GenerateHashPwd(ctx, user.Password)
 	if err != nil {
-		return nil, err
-	}
-	return &UserSchemas{
-		Username:    user.Username,
-		Password:    pwd,
-		Code:        user.Code,
-		CreateAt:    time.Now(),
-		UpdateAt:    time.Now(),
-		CreateAtStr: UtilsTime.NowTime(),
-		UpdateAtStr: UtilsTime.NowTime(),
-		Status:      1,
-		Deleted:     1,
-		ContactType: user.ContactType,
-		ContactInfo: user.ContactInfo,
-	}, nil
+		return nil, "", err
+	}
+	recoveryCode, err := UtilsRandom.RandomAlphaNumString(recoveryCodeLength)
+	if err != nil {
+		return nil, "", err
+	}
+	recoveryHash, err := SecurityBcrypt.GenerateHashPwd(ctx, recoveryCode)
+	if err != nil {
+		return nil, "", err
+	}
+	return &UserInDB{
+		ID:           primitive.NewObjectID(),
+		Username:     user.Username,
+		Password:     pwd,
+		Code:         user.Code,
+		CreateAt:     time.Now(),
+		UpdateAt:     time.Now(),
+		Status:       1,
+		Deleted:      1,
+		ContactType:  user.ContactType,
+		ContactInfo:  user.ContactInfo,
+		RecoveryCode: recoveryHash,
+	}, recoveryCode, nil
 
 }
 
+// verifyRecoveryCode 校验找回密码恢复码
+// 有恢复码的用户只接受恢复码；旧用户没有恢复码时，仅当其注册邀请码为单次邀请码才允许用邀请码校验，
+// 多次邀请码被多人共享，不能作为个人凭证
+func (u *UserSchema) verifyRecoveryCode(ctx context.Context, userInDB UserInDB, code string) (bool, error) {
+	if userInDB.RecoveryCode != "" {
+		return SecurityBcrypt.CompareHashPwd(ctx, userInDB.RecoveryCode, code), nil
+	}
+
+	inviteCode, err := u.Invites.FindInviteCode(ctx, userInDB.Code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !InviteCodeService.IsSingleUse(inviteCode) {
+		return false, nil
+	}
+	return code == inviteCode.Code, nil
+}
+
 // calculateLockSeconds 计算用户锁定剩余秒数，如果已解锁自动更新状态
-func calculateLockSeconds(userID string, lockDuration time.Duration, lastTime time.Time) (int, error) {
+func (u *UserSchema) calculateLockSeconds(ctx context.Context, userID string, lockDuration time.Duration, lastTime time.Time) (int, error) {
 	unlockTime := lastTime.Add(lockDuration)
 	unlocked, remain := UtilsTime.CheckUnlockTime(unlockTime)
 	objId, err := primitive.ObjectIDFromHex(userID)
@@ -67,12 +168,7 @@ func calculateLockSeconds(userID string, lockDuration time.Duration, lastTime ti
 	}
 	if unlocked {
 		// 已解锁，更新用户状态为正常
-		ctx, cancel := db.GetCtx()
-		defer cancel()
-		_, err := collections.GetUserCollection().UpdateOne(ctx,
-			bson.M{"_id": objId, "deleted": 1},
-			bson.M{"$set": bson.M{"status": 1}})
-		if err != nil {
+		if err := u.users().UpdateActive(ctx, objId, bson.M{"status": 1}); err != nil {
 			return 0, err
 		}
 		return 0, nil
@@ -81,41 +177,31 @@ func calculateLockSeconds(userID string, lockDuration time.Duration, lastTime ti
 }
 
 // validateUser 根据失败次数和最后失败时间判断是否锁定
-func validateUser(userID string) (int, int, error) {
-	has, data, err := ValidateUser.HasFailedLoginRecord(userID)
+func (u *UserSchema) validateUser(ctx context.Context, userID string) (int, int, error) {
+	data, has, err := u.failedLogins().Get(ctx, userID)
 	if err != nil {
 		return -1, 0, err
 	}
 	if !has {
 		return 0, 0, nil // 没有失败记录，直接放行
 	}
-	var lockDuration time.Duration
-	switch data.Count {
-	case 3:
-		lockDuration = time.Minute * 1
-	case 5:
-		lockDuration = time.Minute * 5
-	case 7:
-		lockDuration = time.Minute * 30
-	case 10:
-		lockDuration = time.Hour * 24
-	case 11:
+	// 锁定策略见 config.LockoutConfig，可热更新
+	lockout := config.Lockout()
+	if lockout.FreezeAfter > 0 && data.Count == lockout.FreezeAfter {
 		// 超过阈值，永久冻结
 		objId, err := primitive.ObjectIDFromHex(userID)
 		if err != nil {
 			return 0, data.Count, err
 		}
-		ctx, cancel := db.GetCtx()
-		defer cancel()
-		_, _ = collections.GetUserCollection().UpdateOne(ctx,
-			bson.M{"_id": objId, "deleted": 1},
-			bson.M{"$set": bson.M{"status": 3}})
+		_ = u.users().UpdateActive(ctx, objId, bson.M{"status": 3})
 		return -1, data.Count, fmt.Errorf("密码错误:下次重置密码前用户已冻结登录")
-	default:
+	}
+	lockDuration, ok := lockout.Duration(data.Count)
+	if !ok {
 		return 0, data.Count, nil
 	}
 	// 普通锁定逻辑
-	seconds, err := calculateLockSeconds(userID, lockDuration, data.Last)
+	seconds, err := u.calculateLockSeconds(ctx, userID, lockDuration, data.Last)
 	if err != nil {
 		return seconds, data.Count, err
 	}
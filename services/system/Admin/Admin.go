@@ -1,38 +1,58 @@
 package AdminService
 
-import "github.com/StephenChristianW/go-movies-open/services"
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/services"
+)
+
+var logger = logging.For("admin")
 
 // ======================= AdminService 接口定义 =======================
 
-// AdminService 实现管理员业务逻辑的服务结构体
-type AdminService struct{}
+// AdminService 实现管理员业务逻辑的服务结构体，依赖字段为 nil 时使用 MongoDB 与 SESSION_STORE 所选的默认实现
+type AdminService struct {
+	Admins   AdminRepository        // 管理员存储
+	Sessions AdminSessionRepository // 设备会话存储
+	Tokens   Repository.TokenStore  // 访问令牌存储
+}
 
 // AdminInterface 定义管理员服务应提供的方法
 type AdminInterface interface {
 
 	// AdminLogin 管理员登录，返回短期访问令牌
-	AdminLogin(username, password, ip, deviceID string) (token string, err error)
+	AdminLogin(ctx context.Context, username, password, ip, deviceID string) (token string, err error)
 
 	// LoginAndRemember 登录并生成访问令牌 + 刷新令牌
-	LoginAndRemember(data LoginCredentialsRequest) (AuthTokens, error)
+	LoginAndRemember(ctx context.Context, data LoginCredentialsRequest) (AuthTokens, error)
 
 	// LogOut 管理员登出
-	LogOut(adminId string) error
+	LogOut(ctx context.Context, adminId string) error
 
 	// AdminList 获取管理员列表，支持分页和条件过滤
-	AdminList(filter AdminList) (services.Pagination, error)
+	AdminList(ctx context.Context, filter AdminList) (services.Pagination, error)
 	// CreateAdmin 创建新管理员
-	CreateAdmin(username, password, roleID string) error
+	CreateAdmin(ctx context.Context, username, password, roleID string) error
+
+	// CreateAdminWithHash 以 bcrypt 哈希创建管理员
+	CreateAdminWithHash(ctx context.Context, username, hashedPwd, roleID string) error
+
+	// SetupRootAdmin 使用一次性初始化令牌设置根管理员密码并创建账号，返回用户名
+	SetupRootAdmin(ctx context.Context, token, password string) (string, error)
 
 	// DeleteAdmin 逻辑删除管理员
-	DeleteAdmin(nowUsername, delUsername string) error
+	DeleteAdmin(ctx context.Context, nowUsername, delUsername string) error
 
 	// ChangeAdminPassword 修改管理员密码
-	ChangeAdminPassword(username, newPassword string) error
+	ChangeAdminPassword(ctx context.Context, username, newPassword string) error
 
 	// BannedAdminUser 禁用管理员
-	BannedAdminUser(bannedUsername string) error
+	BannedAdminUser(ctx context.Context, bannedUsername string) error
 
 	// ActiveAdminUser 启用管理员
-	ActiveAdminUser(bannedUsername string) error
+	ActiveAdminUser(ctx context.Context, bannedUsername string) error
+
+	// RevokeToken 吊销管理员当前的访问令牌
+	RevokeToken(ctx context.Context, username string) error
 }
@@ -1,30 +1,20 @@
 package AdminService
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
-	"github.com/StephenChristianW/go-movies-open/db/RedisService/OperateToken"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
 	"github.com/StephenChristianW/go-movies-open/security/SecurityBcrypt"
 	"github.com/StephenChristianW/go-movies-open/utils/Jwt"
-	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 	"time"
 )
 
-// =======================================
-//
-//	Token 有效期配置
-//
-// =======================================
-var adminTokenDuration = UtilsTime.DaysToTimeDuration(config.AdminExpiration)
-var adminRefreshTokenDuration = UtilsTime.DaysToTimeDuration(config.AdminRExpiration)
-
 // =======================================
 //          登录与记住密码
 // =======================================
@@ -32,48 +22,60 @@ var adminRefreshTokenDuration = UtilsTime.DaysToTimeDuration(config.AdminRExpira
 // LoginAndRemember 支持两种登录方式：
 // 1. 使用用户名+密码登录
 // 2. 使用 rToken 免密登录
-func (*AdminService) LoginAndRemember(loginData LoginCredentialsRequest) (AuthTokens, error) {
-	var tokens AuthTokens
+func (s *AdminService) LoginAndRemember(ctx context.Context, loginData LoginCredentialsRequest) (tokens AuthTokens, err error) {
+	defer func() { observeLogin(err) }()
 	// 密码登录
 	if loginData.RToken == "" || loginData.Password != "" {
-		admin, err := verifyAdminByPassword(loginData.Username, loginData.Password)
+		admin, err := s.verifyAdminByPassword(ctx, loginData.Username, loginData.Password)
 		if err != nil {
 			return tokens, err
 		}
-		return generateAuthTokens(admin.ID, loginData.DeviceId, admin.Username, loginData.IP, loginData.AuthStatus)
+		return s.generateAuthTokens(ctx, admin.ID, loginData.DeviceId, admin.Username, loginData.IP, loginData.AuthStatus)
 	}
 
 	// rToken 免密登录
 	if loginData.RToken != "" && loginData.Password == "" {
-		admin, err := verifyAdminByRToken(loginData)
+		admin, err := s.verifyAdminByRToken(ctx, loginData)
 		if err != nil {
 			return tokens, err
 		}
-		return generateAuthTokens(admin.ID, loginData.DeviceId, admin.Username, loginData.IP, loginData.AuthStatus)
+		return s.generateAuthTokens(ctx, admin.ID, loginData.DeviceId, admin.Username, loginData.IP, loginData.AuthStatus)
 	}
 
 	return tokens, errors.New("登录信息错误")
 }
 
 // AdminLogin 使用用户名+密码登录并返回 JWT
-func (*AdminService) AdminLogin(username, password, ip, deviceID string) (string, error) {
+func (s *AdminService) AdminLogin(ctx context.Context, username, password, ip, deviceID string) (token string, err error) {
+	defer func() { observeLogin(err) }()
 
-	admin, err := verifyAdminByPassword(username, password)
+	admin, err := s.verifyAdminByPassword(ctx, username, password)
 	if err != nil {
 		return "", err
 	}
 
 	// TODO 完善后台控制AuthStatus
 
-	deleteDeviceSessionSafe(admin.ID, deviceID, ip)
+	s.deleteDeviceSessionSafe(ctx, admin.ID, deviceID, ip)
 
-	return getOrCreateAdminToken(admin.ID, username)
+	return s.getOrCreateAdminToken(ctx, admin.ID, username)
 }
 
-// LogOut 管理员登出，删除 Redis 中 token
-func (*AdminService) LogOut(adminID string) error {
-	log.Printf("管理员 %s 已登出\n", adminID)
-	return deleteAdminToken(adminID)
+// observeLogin 记录管理员登录结果指标
+func observeLogin(err error) {
+	result := metrics.LoginSuccess
+	if err != nil {
+		result = metrics.LoginFailure
+	}
+	metrics.ObserveLogin(metrics.LoginAdmin, result)
+}
+
+// LogOut 管理员登出，删除令牌存储中的 token
+func (s *AdminService) LogOut(ctx context.Context, adminID string) error {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	logger.InfoContext(ctx, "管理员已登出", logging.KeyAdminID, adminID)
+	return s.deleteAdminToken(ctx, adminID)
 }
 
 // =======================================
@@ -81,23 +83,23 @@ func (*AdminService) LogOut(adminID string) error {
 // =======================================
 
 // generateAuthTokens 生成 JWT 与 rToken，并保存 rToken
-func generateAuthTokens(adminID, deviceID, username, ip string, authStatus int) (AuthTokens, error) {
+func (s *AdminService) generateAuthTokens(ctx context.Context, adminID, deviceID, username, ip string, authStatus int) (AuthTokens, error) {
 	var tokens AuthTokens
 
 	// 生成 rToken
-	rt, err := Jwt.GeneratedAdminRToken(adminID, deviceID, ip, time.Now().UTC(), adminRefreshTokenDuration)
+	rt, err := Jwt.GeneratedAdminRToken(adminID, deviceID, ip, time.Now().UTC(), config.AdminRefreshTokenTTL())
 	if err != nil {
 		return tokens, err
 	}
 
 	// 生成 JWT
-	token, err := getOrCreateAdminToken(adminID, username)
+	token, err := s.getOrCreateAdminToken(ctx, adminID, username)
 	if err != nil {
 		return tokens, err
 	}
 
 	// 更新 rToken 到 MongoDB
-	if err := saveRefreshToken(adminID, rt, ip, deviceID, authStatus); err != nil {
+	if err := s.saveRefreshToken(ctx, adminID, rt, ip, deviceID, authStatus); err != nil {
 		return tokens, err
 	}
 
@@ -107,61 +109,43 @@ func generateAuthTokens(adminID, deviceID, username, ip string, authStatus int)
 }
 
 // saveRefreshToken 更新或插入 rToken 到 MongoDB（upsert）
-func saveRefreshToken(adminID, rToken, ip, deviceID string, authStatus int) error {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) saveRefreshToken(ctx context.Context, adminID, rToken, ip, deviceID string, authStatus int) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	_, err := collections.GetAdminRememberCollection().UpdateOne(
-		ctx,
-		bson.M{"user_id": adminID},
-		bson.M{
-			"$set": bson.M{
-				"sessions." + deviceID: DeviceSession{
-					DeviceId:   deviceID,
-					AuthStatus: authStatus,
-					RToken:     rToken,
-					IP:         ip,
-					LastActive: time.Now().UTC(),
-				},
-			},
-		},
-		options.Update().SetUpsert(true),
-	)
-	return err
+	return s.sessions().SaveSession(ctx, adminID, DeviceSession{
+		DeviceId:   deviceID,
+		AuthStatus: authStatus,
+		RToken:     rToken,
+		IP:         ip,
+		LastActive: time.Now().UTC(),
+	})
 }
-func deleteDeviceSessionSafe(userID, deviceID, ip string) {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) deleteDeviceSessionSafe(ctx context.Context, userID, deviceID, ip string) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	// 直接尝试删除，不存在也没关系
-	_, _ = collections.GetAdminRememberCollection().UpdateOne(
-		ctx,
-		bson.M{
-			"user_id":                      userID,
-			"sessions." + deviceID + ".ip": ip, // IP 匹配
-		},
-		bson.M{
-			"$unset": bson.M{
-				"sessions." + deviceID: "",
-			},
-		},
-	)
+	_ = s.sessions().DeleteSession(ctx, userID, deviceID, ip)
 	// 不管有没有匹配到都不报错
 }
 
-// getOrCreateAdminToken 获取 Redis token，如果不存在则生成新的 JWT
-func getOrCreateAdminToken(adminID, username string) (string, error) {
-	token, err := OperateToken.GetAdminToken(adminID)
+// getOrCreateAdminToken 获取已存储的 token，如果不存在则生成新的 JWT
+func (s *AdminService) getOrCreateAdminToken(ctx context.Context, adminID, username string) (string, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	token, err := s.tokens().Get(ctx, Repository.AdminToken, adminID)
 	if err != nil {
 		return "", err
 	}
 
 	if token == "" {
-		token, err = Jwt.GenerateAdminToken(adminID, username, adminTokenDuration)
+		token, err = Jwt.GenerateAdminToken(adminID, username, config.AdminTokenTTL())
 		if err != nil {
 			return "", err
 		}
-		if err = OperateToken.StoreAdminToken(adminID, token, adminTokenDuration); err != nil {
+		if err = s.tokens().Set(ctx, Repository.AdminToken, adminID, token, config.AdminTokenTTL()); err != nil {
 			return "", err
 		}
 	}
@@ -169,15 +153,15 @@ func getOrCreateAdminToken(adminID, username string) (string, error) {
 	return token, nil
 }
 
-// deleteAdminToken 删除 Redis 中的管理员 token
-func deleteAdminToken(adminID string) error {
-	token, err := OperateToken.GetAdminToken(adminID)
+// deleteAdminToken 删除令牌存储中的管理员 token
+func (s *AdminService) deleteAdminToken(ctx context.Context, adminID string) error {
+	token, err := s.tokens().Get(ctx, Repository.AdminToken, adminID)
 	if err != nil {
-		return fmt.Errorf("获取管理员 Redis token 失败: %v", err)
+		return fmt.Errorf("获取管理员 token 失败: %v", err)
 	}
 	if token != "" {
-		if err := OperateToken.DeleteAdminToken(adminID); err != nil {
-			return fmt.Errorf("删除管理员 Redis token 失败: %v", err)
+		if err := s.tokens().Delete(ctx, Repository.AdminToken, adminID); err != nil {
+			return fmt.Errorf("删除管理员 token 失败: %v", err)
 		}
 	}
 	return nil
@@ -188,15 +172,11 @@ func deleteAdminToken(adminID string) error {
 // =======================================
 
 // verifyAdminByPassword 校验用户名和密码
-func verifyAdminByPassword(username, password string) (Admin, error) {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) verifyAdminByPassword(ctx context.Context, username, password string) (Admin, error) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	var admin AdminInDB
-	err := collections.GetAdminCollection().FindOne(ctx, bson.M{
-		"username": username,
-		"deleted":  1,
-	}).Decode(&admin)
+	admin, err := s.admins().FindActiveByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return Admin{}, fmt.Errorf("用户名错误或管理员 %s 已被禁用", username)
@@ -208,7 +188,7 @@ func verifyAdminByPassword(username, password string) (Admin, error) {
 		return Admin{}, errors.New("该账号已被禁用")
 	}
 
-	if !SecurityBcrypt.CompareHashPwd(admin.Password, password) {
+	if !SecurityBcrypt.CompareHashPwd(ctx, admin.Password, password) {
 		return Admin{}, errors.New("密码错误")
 	}
 
@@ -222,8 +202,8 @@ func verifyAdminByPassword(username, password string) (Admin, error) {
 }
 
 // verifyAdminByRToken 校验 rToken，返回管理员信息
-func verifyAdminByRToken(loginData LoginCredentialsRequest) (Admin, error) {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) verifyAdminByRToken(ctx context.Context, loginData LoginCredentialsRequest) (Admin, error) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	var result Admin
@@ -240,20 +220,11 @@ func verifyAdminByRToken(loginData LoginCredentialsRequest) (Admin, error) {
 		return result, errors.New("设备IP不一致")
 	}
 
-	var userAuth UserAuthProfile
-	err = collections.GetAdminRememberCollection().FindOne(
-		ctx,
-		bson.M{
-			"user_id": rtClaims.AdminID,
-			"sessions." + rtClaims.DeviceID + ".device_id": rtClaims.DeviceID,
-			"sessions." + rtClaims.DeviceID + ".ip":        rtClaims.IP,
-		},
-	).Decode(&userAuth)
+	userAuth, err := s.sessions().FindSession(ctx, rtClaims.AdminID, rtClaims.DeviceID, rtClaims.IP)
 	if err != nil {
 		return result, err
 	}
-	var admin AdminInDB
-	err = collections.GetAdminCollection().FindOne(ctx, bson.M{"username": loginData.Username}).Decode(&admin)
+	admin, err := s.admins().FindByUsername(ctx, loginData.Username)
 	if err != nil {
 		return result, err
 	}
@@ -5,15 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/StephenChristianW/go-movies-open/config"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/logging"
 	"github.com/StephenChristianW/go-movies-open/security/SecurityBcrypt"
 	"github.com/StephenChristianW/go-movies-open/services"
 	"github.com/StephenChristianW/go-movies-open/services/ServiceUtils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 )
 
 // =======================================
@@ -21,43 +19,45 @@ import (
 // =======================================
 
 // CreateAdmin 创建新的管理员账号
-func (*AdminService) CreateAdmin(username, password, roleID string) error {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) CreateAdmin(ctx context.Context, username, password, roleID string) error {
+	hashedPwd, err := SecurityBcrypt.GenerateHashPwd(ctx, password)
+	if err != nil {
+		return err
+	}
+	return s.CreateAdminWithHash(ctx, username, hashedPwd, roleID)
+}
+
+// CreateAdminWithHash 以 bcrypt 哈希创建管理员，用于根管理员初始化时直接使用部署方提供的哈希
+func (s *AdminService) CreateAdminWithHash(ctx context.Context, username, hashedPwd, roleID string) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	// 检查管理员是否已存在
-	var existing AdminInDB
-	err := collections.GetAdminCollection().FindOne(ctx, bson.M{
-		"username": username,
-		"deleted":  1,
-	}).Decode(&existing)
-
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			// 不存在则创建新管理员
-			hashedPwd, err := SecurityBcrypt.GenerateHashPwd(password)
-			if err != nil {
-				return err
-			}
-
-			newAdmin := AdminCreate{
-				Username: username,
-				Password: hashedPwd,
-				Status:   1,
-				Deleted:  1,
-				Role:     roleID,
-			}
-			_, insertErr := collections.GetAdminCollection().InsertOne(ctx, newAdmin)
-			return insertErr
-		}
+	_, err := s.admins().FindActiveByUsername(ctx, username)
+	if err == nil {
+		return fmt.Errorf("管理员 %s 已存在", username)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
 		return err
 	}
 
-	return fmt.Errorf("管理员 %s 已存在", username)
+	// 不存在则创建新管理员
+	newAdmin := AdminCreate{
+		Username: username,
+		Password: hashedPwd,
+		Status:   1,
+		Deleted:  1,
+		Role:     roleID,
+	}
+	insertErr := s.admins().Insert(ctx, newAdmin)
+	if mongo.IsDuplicateKeyError(insertErr) {
+		return fmt.Errorf("管理员 %s 已存在", username)
+	}
+	return insertErr
 }
 
 // DeleteAdmin 逻辑删除管理员（不会删除本人或根管理员）
-func (*AdminService) DeleteAdmin(currentUsername, targetUsername string) error {
+func (s *AdminService) DeleteAdmin(ctx context.Context, currentUsername, targetUsername string) error {
 	if currentUsername == targetUsername {
 		return errors.New("无法删除本人")
 	}
@@ -65,14 +65,10 @@ func (*AdminService) DeleteAdmin(currentUsername, targetUsername string) error {
 		return errors.New("该管理员无法删除")
 	}
 
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	var admin AdminInDB
-	err := collections.GetAdminCollection().FindOne(ctx, bson.M{
-		"username": targetUsername,
-		"deleted":  1,
-	}).Decode(&admin)
+	admin, err := s.admins().FindActiveByUsername(ctx, targetUsername)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return fmt.Errorf("未找到管理员: %s", targetUsername)
@@ -81,29 +77,23 @@ func (*AdminService) DeleteAdmin(currentUsername, targetUsername string) error {
 	}
 
 	// 逻辑删除
-	_, err = collections.GetAdminCollection().UpdateOne(ctx,
-		bson.M{"_id": admin.ID, "deleted": 1},
-		bson.M{"$set": bson.M{"deleted": 2}},
-	)
-	if err != nil {
+	if err = s.admins().Update(ctx, admin.ID, bson.M{"deleted": 2}); err != nil {
 		return err
 	}
 
 	// 删除 token
-	return deleteAdminToken(admin.ID.Hex())
+	return s.deleteAdminToken(ctx, admin.ID.Hex())
 }
 
 // ChangeAdminPassword 修改管理员密码，并删除其 token
-func (*AdminService) ChangeAdminPassword(username, newPassword string) error {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) ChangeAdminPassword(ctx context.Context, username, newPassword string) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	var admin AdminInDB
-	err := collections.GetAdminCollection().FindOne(ctx, bson.M{
-		"username": username,
-		"deleted":  1,
-		"status":   1,
-	}).Decode(&admin)
+	admin, err := s.admins().FindActiveByUsername(ctx, username)
+	if err == nil && admin.Status != 1 {
+		err = mongo.ErrNoDocuments
+	}
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return fmt.Errorf("用户名错误或管理员 %s 已被禁用", username)
@@ -111,38 +101,32 @@ func (*AdminService) ChangeAdminPassword(username, newPassword string) error {
 		return err
 	}
 
-	hashedPwd, err := SecurityBcrypt.GenerateHashPwd(newPassword)
+	hashedPwd, err := SecurityBcrypt.GenerateHashPwd(ctx, newPassword)
 	if err != nil {
 		return err
 	}
 
-	_, err = collections.GetAdminCollection().UpdateOne(ctx,
-		bson.M{"username": username, "deleted": 1},
-		bson.M{"$set": bson.M{"password": hashedPwd}},
-	)
-	if err != nil {
+	if err = s.admins().Update(ctx, admin.ID, bson.M{"password": hashedPwd}); err != nil {
 		return err
 	}
 
-	log.Printf("管理员 %s 已更改密码", username)
-	return deleteAdminToken(admin.ID.Hex())
+	logger.InfoContext(ctx, "管理员已更改密码", "admin", username, logging.KeyAdminID, admin.ID.Hex())
+	return s.deleteAdminToken(ctx, admin.ID.Hex())
 }
 
 // BannedAdminUser 禁用管理员账号（非根管理员）
-func (*AdminService) BannedAdminUser(username string) error {
+func (s *AdminService) BannedAdminUser(ctx context.Context, username string) error {
 	if _, ok := config.RootAdmins[username]; ok {
 		return errors.New("初始管理员无法禁用")
 	}
 
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	var admin AdminInDB
-	err := collections.GetAdminCollection().FindOne(ctx, bson.M{
-		"username": username,
-		"deleted":  1,
-		"status":   1,
-	}).Decode(&admin)
+	admin, err := s.admins().FindActiveByUsername(ctx, username)
+	if err == nil && admin.Status != 1 {
+		err = mongo.ErrNoDocuments
+	}
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return fmt.Errorf("管理员 %s 不存在或已被禁用/删除", username)
@@ -150,70 +134,57 @@ func (*AdminService) BannedAdminUser(username string) error {
 		return fmt.Errorf("查询管理员失败: %v", err)
 	}
 
-	_, err = collections.GetAdminCollection().UpdateOne(ctx,
-		bson.M{"_id": admin.ID},
-		bson.M{"$set": bson.M{"status": 2}},
-	)
-	if err != nil {
+	if err = s.admins().Update(ctx, admin.ID, bson.M{"status": 2}); err != nil {
 		return fmt.Errorf("禁用管理员失败: %v", err)
 	}
 
-	return deleteAdminToken(admin.ID.Hex())
+	return s.deleteAdminToken(ctx, admin.ID.Hex())
 }
-func (*AdminService) ActiveAdminUser(username string) error {
-	ctx, cancel := db.GetCtx()
+func (s *AdminService) ActiveAdminUser(ctx context.Context, username string) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 	// 启用条件：未删除、当前状态为禁用
-	filter := bson.M{
-		"username": username,
-		"deleted":  1,
-		"status":   2,
+	admin, err := s.admins().FindActiveByUsername(ctx, username)
+	if err == nil && admin.Status != 2 {
+		err = mongo.ErrNoDocuments
 	}
-	update := bson.M{
-		"$set": bson.M{"status": 1},
-	}
-
-	doc := collections.GetAdminCollection().FindOneAndUpdate(ctx, filter, update)
-	if doc.Err() != nil {
-		if errors.Is(doc.Err(), mongo.ErrNoDocuments) {
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
 			return errors.New("该管理员已启用或不存在")
 		}
-		return doc.Err()
+		return err
 	}
-	return nil
+	return s.admins().Update(ctx, admin.ID, bson.M{"status": 1})
 }
 
-// AdminList 获取管理员列表（支持分页与过滤）
-func (*AdminService) AdminList(filter AdminList) (services.Pagination, error) {
-	ctx, cancel := db.GetCtx()
+// RevokeToken 吊销管理员当前的访问令牌，管理员需重新登录
+func (s *AdminService) RevokeToken(ctx context.Context, username string) error {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	var result services.Pagination
-	conditions := buildAdminFilter(filter)
-
-	total, err := collections.GetAdminCollection().CountDocuments(ctx, conditions)
+	admin, err := s.admins().FindActiveByUsername(ctx, username)
 	if err != nil {
-		return result, err
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("管理员 %s 不存在", username)
+		}
+		return err
 	}
+	return s.deleteAdminToken(ctx, admin.ID.Hex())
+}
 
-	findOptions := options.Find().
-		SetSkip(int64((filter.Page - 1) * filter.PageSize)).
-		SetLimit(int64(filter.PageSize))
+// AdminList 获取管理员列表（支持分页与过滤）
+func (s *AdminService) AdminList(ctx context.Context, filter AdminList) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
 
-	cursor, err := collections.GetAdminCollection().Find(ctx, conditions, findOptions)
+	var result services.Pagination
+	dbAdmins, total, err := s.admins().List(ctx, filter)
 	if err != nil {
 		return result, err
 	}
-	defer func(cursor *mongo.Cursor, ctx context.Context) {
-		_ = cursor.Close(ctx)
-	}(cursor, ctx)
 
 	var admins []Admin
-	for cursor.Next(ctx) {
-		var a AdminInDB
-		if err := cursor.Decode(&a); err != nil {
-			return result, err
-		}
+	for _, a := range dbAdmins {
 		admins = append(admins, Admin{
 			ID:       a.ID.Hex(),
 			Username: a.Username,
@@ -223,12 +194,12 @@ func (*AdminService) AdminList(filter AdminList) (services.Pagination, error) {
 		})
 	}
 
-	result.Total = int(total)
+	result.Total = total
 	result.Data = admins
 	result.Page = filter.Page
 	result.PageSize = filter.PageSize
 	if filter.PageSize > 0 {
-		result.TotalPages = int((total + int64(filter.PageSize) - 1) / int64(filter.PageSize))
+		result.TotalPages = (total + filter.PageSize - 1) / filter.PageSize
 	} else {
 		result.TotalPages = 1
 	}
@@ -23,6 +23,12 @@ type DeletedUserRequest struct {
 	DelUsername string `json:"username" binding:"required"` // 待删除的管理员用户名
 }
 
+// SetupRootAdminRequest 根管理员一次性初始化请求结构体
+type SetupRootAdminRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`       // 启动日志中打印的一次性初始化令牌
+	Password string `json:"password" form:"password" binding:"required"` // 根管理员密码
+}
+
 // ChangeAdminPasswordRequest 修改管理员密码请求结构体
 type ChangeAdminPasswordRequest struct {
 	Username    string `json:"username" binding:"required"`     // 管理员用户名
@@ -0,0 +1,178 @@
+package AdminService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"testing"
+)
+
+// newTestService 使用内存存储的管理员服务
+func newTestService(t *testing.T) *AdminService {
+	t.Helper()
+	if config.AdminSecret == "" {
+		config.AdminSecret = "test-admin-secret"
+	}
+	if config.AdminRSecret == "" {
+		config.AdminRSecret = "test-admin-r-secret"
+	}
+	return &AdminService{
+		Admins:   NewMemoryAdminRepository(),
+		Sessions: NewMemoryAdminSessionRepository(),
+		Tokens:   Repository.NewMemoryTokenStore(),
+	}
+}
+
+func TestCreateAdminAndLogin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	if err := s.CreateAdmin(ctx, "ops", "secret123", "role-1"); err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+	if err := s.CreateAdmin(ctx, "ops", "secret123", "role-1"); err == nil {
+		t.Error("重复创建管理员应失败")
+	}
+
+	token, err := s.AdminLogin(ctx, "ops", "secret123", "127.0.0.1", "dev-1")
+	if err != nil {
+		t.Fatalf("AdminLogin: %v", err)
+	}
+	if token == "" {
+		t.Fatal("登录成功应返回 token")
+	}
+	if _, err := s.AdminLogin(ctx, "ops", "wrong", "127.0.0.1", "dev-1"); err == nil {
+		t.Error("密码错误应登录失败")
+	}
+
+	admin, _ := s.admins().FindActiveByUsername(context.Background(), "ops")
+	if err := s.LogOut(ctx, admin.ID.Hex()); err != nil {
+		t.Fatalf("LogOut: %v", err)
+	}
+	if got, _ := s.tokens().Get(context.Background(), Repository.AdminToken, admin.ID.Hex()); got != "" {
+		t.Error("登出后应删除 token")
+	}
+}
+
+func TestLoginAndRememberWithRToken(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	if err := s.CreateAdmin(ctx, "ops", "secret123", "role-1"); err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+	login := LoginCredentialsRequest{Username: "ops", Password: "secret123", DeviceId: "dev-1", IP: "10.0.0.1", AuthStatus: 1}
+	tokens, err := s.LoginAndRemember(ctx, login)
+	if err != nil {
+		t.Fatalf("LoginAndRemember: %v", err)
+	}
+	if tokens.Token == "" || tokens.RToken == "" {
+		t.Fatalf("应返回访问令牌与刷新令牌: %+v", tokens)
+	}
+
+	// 刷新令牌免密登录
+	remembered := LoginCredentialsRequest{Username: "ops", DeviceId: "dev-1", IP: "10.0.0.1", RToken: tokens.RToken, AuthStatus: 1}
+	if _, err := s.LoginAndRemember(ctx, remembered); err != nil {
+		t.Fatalf("刷新令牌登录失败: %v", err)
+	}
+
+	// IP 变化后刷新令牌不可用
+	remembered.IP = "10.0.0.2"
+	if _, err := s.LoginAndRemember(ctx, remembered); err == nil {
+		t.Error("IP 不一致时刷新令牌登录应失败")
+	}
+
+	// 密码登录会清除同设备会话，原刷新令牌失效
+	if _, err := s.AdminLogin(ctx, "ops", "secret123", "10.0.0.1", "dev-1"); err != nil {
+		t.Fatalf("AdminLogin: %v", err)
+	}
+	remembered.IP = "10.0.0.1"
+	if _, err := s.LoginAndRemember(ctx, remembered); err == nil {
+		t.Error("设备会话删除后刷新令牌登录应失败")
+	}
+}
+
+func TestBanActivateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	for _, name := range []string{"ops", "dev"} {
+		if err := s.CreateAdmin(ctx, name, "secret123", "role-1"); err != nil {
+			t.Fatalf("CreateAdmin(%s): %v", name, err)
+		}
+	}
+
+	if err := s.BannedAdminUser(ctx, "ops"); err != nil {
+		t.Fatalf("BannedAdminUser: %v", err)
+	}
+	if _, err := s.AdminLogin(ctx, "ops", "secret123", "127.0.0.1", "dev-1"); err == nil {
+		t.Error("禁用后不应能登录")
+	}
+	if err := s.BannedAdminUser(ctx, "ops"); err == nil {
+		t.Error("重复禁用应失败")
+	}
+	if err := s.ChangeAdminPassword(ctx, "ops", "newpass123"); err == nil {
+		t.Error("禁用的管理员不应能修改密码")
+	}
+	if err := s.ActiveAdminUser(ctx, "ops"); err != nil {
+		t.Fatalf("ActiveAdminUser: %v", err)
+	}
+	if err := s.ActiveAdminUser(ctx, "ops"); err == nil {
+		t.Error("已启用的管理员重复启用应失败")
+	}
+
+	if err := s.ChangeAdminPassword(ctx, "ops", "newpass123"); err != nil {
+		t.Fatalf("ChangeAdminPassword: %v", err)
+	}
+	if _, err := s.AdminLogin(ctx, "ops", "newpass123", "127.0.0.1", "dev-1"); err != nil {
+		t.Errorf("修改后的密码应能登录: %v", err)
+	}
+
+	if err := s.DeleteAdmin(ctx, "ops", "ops"); err == nil {
+		t.Error("不应允许删除本人")
+	}
+	if err := s.DeleteAdmin(ctx, "ops", "dev"); err != nil {
+		t.Fatalf("DeleteAdmin: %v", err)
+	}
+	page, err := s.AdminList(ctx, AdminList{Deleted: 1, Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("AdminList: %v", err)
+	}
+	admins := page.Data.([]Admin)
+	if page.Total != 1 || admins[0].Username != "ops" {
+		t.Errorf("删除后列表应只剩 ops，实际 %+v", admins)
+	}
+	// 删除后可重新创建同名管理员
+	if err := s.CreateAdmin(ctx, "dev", "secret123", "role-1"); err != nil {
+		t.Errorf("删除后应能重新创建同名管理员: %v", err)
+	}
+}
+
+func TestSetupRootAdminTokenIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	token, err := IssueSetupToken("root")
+	if err != nil {
+		t.Fatalf("IssueSetupToken: %v", err)
+	}
+	if !SetupPending() {
+		t.Fatal("生成令牌后应有待初始化的根管理员")
+	}
+	if _, err := s.SetupRootAdmin(ctx, "wrong", "secret123"); err == nil {
+		t.Error("错误的令牌应失败")
+	}
+	if _, err := s.SetupRootAdmin(ctx, token, "x"); err == nil {
+		t.Error("密码过短应失败")
+	}
+
+	username, err := s.SetupRootAdmin(ctx, token, "secret123")
+	if err != nil || username != "root" {
+		t.Fatalf("SetupRootAdmin: %q %v", username, err)
+	}
+	if _, err := s.AdminLogin(ctx, "root", "secret123", "127.0.0.1", "dev-1"); err != nil {
+		t.Errorf("初始化后应可登录: %v", err)
+	}
+	if _, err := s.SetupRootAdmin(ctx, token, "another123"); err == nil {
+		t.Error("令牌只能使用一次")
+	}
+	if SetupPending() {
+		t.Error("令牌使用后不应再有待初始化的根管理员")
+	}
+}
@@ -0,0 +1,163 @@
+package AdminService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/services/ServiceUtils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// MemoryAdminRepository 进程内管理员存储，用于测试
+type MemoryAdminRepository struct {
+	mu     sync.Mutex
+	admins []AdminInDB
+}
+
+// NewMemoryAdminRepository 创建内存管理员存储
+func NewMemoryAdminRepository() *MemoryAdminRepository {
+	return &MemoryAdminRepository{}
+}
+
+func (r *MemoryAdminRepository) FindActiveByUsername(_ context.Context, username string) (AdminInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.admins {
+		if a.Username == username && a.Deleted == 1 {
+			return a, nil
+		}
+	}
+	return AdminInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryAdminRepository) FindByUsername(_ context.Context, username string) (AdminInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.admins {
+		if a.Username == username {
+			return a, nil
+		}
+	}
+	return AdminInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryAdminRepository) Insert(_ context.Context, admin AdminCreate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if admin.Deleted == 1 {
+		for _, a := range r.admins {
+			if a.Username == admin.Username && a.Deleted == 1 {
+				return Repository.DuplicateKeyError("username_deleted_unique")
+			}
+		}
+	}
+	r.admins = append(r.admins, AdminInDB{
+		ID:       primitive.NewObjectID(),
+		Username: admin.Username,
+		Password: admin.Password,
+		Status:   admin.Status,
+		Deleted:  admin.Deleted,
+		Role:     admin.Role,
+	})
+	return nil
+}
+
+func (r *MemoryAdminRepository) Update(_ context.Context, id primitive.ObjectID, set bson.M) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.admins {
+		if r.admins[i].ID == id {
+			_, err := Repository.ApplySet(&r.admins[i], set)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAdminRepository) List(_ context.Context, filter AdminList) ([]AdminInDB, int, error) {
+	var ids []primitive.ObjectID
+	if len(filter.IDs) > 0 {
+		ids = ServiceUtils.StringsToObjectIds(filter.IDs)
+	}
+	var name *regexp.Regexp
+	if len(ids) == 0 && filter.Username != "" {
+		var err error
+		if name, err = regexp.Compile("(?i)" + filter.Username); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []AdminInDB
+	for _, a := range r.admins {
+		if len(ids) > 0 {
+			if slices.Contains(ids, a.ID) {
+				matched = append(matched, a)
+			}
+			continue
+		}
+		switch {
+		case name != nil && !name.MatchString(a.Username),
+			filter.Status != 0 && a.Status != filter.Status,
+			filter.Deleted != 0 && a.Deleted != filter.Deleted,
+			filter.Role != "" && a.Role != filter.Role:
+			continue
+		}
+		matched = append(matched, a)
+	}
+	if filter.PageSize <= 0 {
+		return matched, len(matched), nil
+	}
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+// MemoryAdminSessionRepository 进程内管理员设备会话存储，用于测试
+type MemoryAdminSessionRepository struct {
+	mu       sync.Mutex
+	profiles map[string]UserAuthProfile
+}
+
+// NewMemoryAdminSessionRepository 创建内存设备会话存储
+func NewMemoryAdminSessionRepository() *MemoryAdminSessionRepository {
+	return &MemoryAdminSessionRepository{profiles: make(map[string]UserAuthProfile)}
+}
+
+func (r *MemoryAdminSessionRepository) SaveSession(_ context.Context, adminID string, session DeviceSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	profile, ok := r.profiles[adminID]
+	if !ok {
+		profile = UserAuthProfile{UserID: adminID, Sessions: make(map[string]DeviceSession)}
+	}
+	profile.Sessions[session.DeviceId] = session
+	r.profiles[adminID] = profile
+	return nil
+}
+
+func (r *MemoryAdminSessionRepository) DeleteSession(_ context.Context, adminID, deviceID, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if profile, ok := r.profiles[adminID]; ok {
+		if s, ok := profile.Sessions[deviceID]; ok && s.IP == ip {
+			delete(profile.Sessions, deviceID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAdminSessionRepository) FindSession(_ context.Context, adminID, deviceID, ip string) (UserAuthProfile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	profile, ok := r.profiles[adminID]
+	if !ok {
+		return UserAuthProfile{}, Repository.ErrNotFound
+	}
+	if s, ok := profile.Sessions[deviceID]; !ok || s.DeviceId != deviceID || s.IP != ip {
+		return UserAuthProfile{}, Repository.ErrNotFound
+	}
+	return profile, nil
+}
@@ -0,0 +1,141 @@
+package AdminService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AdminRepository 管理员存储
+type AdminRepository interface {
+	// FindActiveByUsername 按用户名查询未删除的管理员，不存在返回 mongo.ErrNoDocuments
+	FindActiveByUsername(ctx context.Context, username string) (AdminInDB, error)
+	// FindByUsername 按用户名查询管理员（包含已删除），不存在返回 mongo.ErrNoDocuments
+	FindByUsername(ctx context.Context, username string) (AdminInDB, error)
+	// Insert 插入管理员，未删除用户名重复时返回重复键错误
+	Insert(ctx context.Context, admin AdminCreate) error
+	// Update 按ID更新字段
+	Update(ctx context.Context, id primitive.ObjectID, set bson.M) error
+	// List 按条件分页查询，返回当前页与总数
+	List(ctx context.Context, filter AdminList) ([]AdminInDB, int, error)
+}
+
+// AdminSessionRepository 管理员设备会话（记住登录）存储
+type AdminSessionRepository interface {
+	// SaveSession 保存设备会话，已存在时覆盖
+	SaveSession(ctx context.Context, adminID string, session DeviceSession) error
+	// DeleteSession 删除 IP 匹配的设备会话，不存在时不报错
+	DeleteSession(ctx context.Context, adminID, deviceID, ip string) error
+	// FindSession 查询包含指定设备与 IP 会话的认证档案，不存在返回 mongo.ErrNoDocuments
+	FindSession(ctx context.Context, adminID, deviceID, ip string) (UserAuthProfile, error)
+}
+
+func (s *AdminService) admins() AdminRepository {
+	if s.Admins != nil {
+		return s.Admins
+	}
+	return mongoAdminRepository{}
+}
+
+func (s *AdminService) sessions() AdminSessionRepository {
+	if s.Sessions != nil {
+		return s.Sessions
+	}
+	return mongoAdminSessionRepository{}
+}
+
+func (s *AdminService) tokens() Repository.TokenStore {
+	if s.Tokens != nil {
+		return s.Tokens
+	}
+	return Repository.DefaultTokenStore()
+}
+
+// =======================================
+//          MongoDB 实现
+// =======================================
+
+type mongoAdminRepository struct{}
+
+func (mongoAdminRepository) FindActiveByUsername(ctx context.Context, username string) (AdminInDB, error) {
+	var admin AdminInDB
+	err := collections.GetAdminCollection().FindOne(ctx, bson.M{"username": username, "deleted": 1}).Decode(&admin)
+	return admin, err
+}
+
+func (mongoAdminRepository) FindByUsername(ctx context.Context, username string) (AdminInDB, error) {
+	var admin AdminInDB
+	err := collections.GetAdminCollection().FindOne(ctx, bson.M{"username": username}).Decode(&admin)
+	return admin, err
+}
+
+func (mongoAdminRepository) Insert(ctx context.Context, admin AdminCreate) error {
+	_, err := collections.GetAdminCollection().InsertOne(ctx, admin)
+	return err
+}
+
+func (mongoAdminRepository) Update(ctx context.Context, id primitive.ObjectID, set bson.M) error {
+	_, err := collections.GetAdminCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+func (mongoAdminRepository) List(ctx context.Context, filter AdminList) ([]AdminInDB, int, error) {
+	collection := collections.GetAdminCollection()
+	conditions := buildAdminFilter(filter)
+
+	total, err := collection.CountDocuments(ctx, conditions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSkip(int64((filter.Page - 1) * filter.PageSize)).
+		SetLimit(int64(filter.PageSize))
+	cursor, err := collection.Find(ctx, conditions, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	var admins []AdminInDB
+	err = cursor.All(ctx, &admins)
+	return admins, int(total), err
+}
+
+type mongoAdminSessionRepository struct{}
+
+func (mongoAdminSessionRepository) SaveSession(ctx context.Context, adminID string, session DeviceSession) error {
+	_, err := collections.GetAdminRememberCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": adminID},
+		bson.M{"$set": bson.M{"sessions." + session.DeviceId: session}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (mongoAdminSessionRepository) DeleteSession(ctx context.Context, adminID, deviceID, ip string) error {
+	_, err := collections.GetAdminRememberCollection().UpdateOne(
+		ctx,
+		bson.M{
+			"user_id":                      adminID,
+			"sessions." + deviceID + ".ip": ip, // IP 匹配
+		},
+		bson.M{"$unset": bson.M{"sessions." + deviceID: ""}},
+	)
+	return err
+}
+
+func (mongoAdminSessionRepository) FindSession(ctx context.Context, adminID, deviceID, ip string) (UserAuthProfile, error) {
+	var userAuth UserAuthProfile
+	err := collections.GetAdminRememberCollection().FindOne(
+		ctx,
+		bson.M{
+			"user_id":                             adminID,
+			"sessions." + deviceID + ".device_id": deviceID,
+			"sessions." + deviceID + ".ip":        ip,
+		},
+	).Decode(&userAuth)
+	return userAuth, err
+}
@@ -0,0 +1,97 @@
+package AdminService
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"sync"
+	"time"
+)
+
+// =======================================
+//          根管理员一次性初始化
+// =======================================
+
+// SetupPath 一次性初始化页面路径，令牌通过 token 参数传递
+const SetupPath = "/admin/setup"
+
+// SetupTokenTTL 初始化令牌有效期，过期后需重启服务重新生成
+const SetupTokenTTL = 24 * time.Hour
+
+var errSetupToken = errors.New("初始化链接无效、已使用或已过期")
+
+// setupToken 待设置密码的根管理员
+type setupToken struct {
+	username string
+	expire   time.Time
+}
+
+// setupTokens 令牌哈希 -> 待设置密码的根管理员；只保存在当前进程内，多实例部署时需访问打印链接的实例
+var (
+	setupMu     sync.Mutex
+	setupTokens = make(map[string]setupToken)
+)
+
+func hashSetupToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueSetupToken 为尚未创建的根管理员生成一次性初始化令牌，同一用户名再次生成时旧令牌失效
+func IssueSetupToken(username string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	for k, v := range setupTokens {
+		if v.username == username {
+			delete(setupTokens, k)
+		}
+	}
+	setupTokens[hashSetupToken(token)] = setupToken{username: username, expire: time.Now().Add(SetupTokenTTL)}
+	return token, nil
+}
+
+// SetupPending 是否还有未使用且未过期的初始化令牌
+func SetupPending() bool {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	for _, v := range setupTokens {
+		if time.Now().Before(v.expire) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupRootAdmin 使用一次性初始化令牌设置根管理员密码并创建账号，令牌校验通过即失效，不可重复使用
+func (s *AdminService) SetupRootAdmin(ctx context.Context, token, password string) (string, error) {
+	if minLen := config.Account().MinPasswordLen; len(password) < minLen {
+		return "", fmt.Errorf("密码至少%d个字符", minLen)
+	}
+
+	setupMu.Lock()
+	key := hashSetupToken(token)
+	pending, ok := setupTokens[key]
+	if ok {
+		delete(setupTokens, key)
+	}
+	setupMu.Unlock()
+	if !ok || time.Now().After(pending.expire) {
+		return "", errSetupToken
+	}
+
+	if err := s.CreateAdmin(ctx, pending.username, password, ""); err != nil {
+		return "", err
+	}
+	return pending.username, nil
+}
@@ -0,0 +1,186 @@
+package HealthService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/migrations"
+	"github.com/StephenChristianW/go-movies-open/db/RedisService"
+	"github.com/StephenChristianW/go-movies-open/task"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout 单项检查的超时时间，检查并发执行，/readyz 最长耗时约为该值
+const checkTimeout = 2 * time.Second
+
+// 检查结果状态
+const (
+	StatusOK      = "ok"
+	StatusFail    = "fail"
+	StatusSkipped = "skipped" // 当前配置下不依赖该项，不影响就绪状态
+)
+
+// 整体就绪状态
+const (
+	Ready    = "ready"
+	Unready  = "unready"
+	Draining = "draining" // 正在关闭，等待进行中的请求结束
+)
+
+// ErrSkipped 检查函数返回该错误表示当前配置下不依赖该项
+var ErrSkipped = errors.New("skipped")
+
+// Check 一项依赖检查，返回的 detail 原样输出
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) (detail any, err error)
+}
+
+// CheckResult 单项检查结果
+type CheckResult struct {
+	Status    string  `json:"status"`           // ok / fail / skipped
+	LatencyMs float64 `json:"latency_ms"`       // 检查耗时（毫秒）
+	Error     string  `json:"error,omitempty"`  // 失败原因
+	Detail    any     `json:"detail,omitempty"` // 检查详情，如迁移版本
+}
+
+// Report 就绪检查结果
+type Report struct {
+	Status string                 `json:"status"` // ready / unready / draining
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// draining 收到关闭信号后置为 true，之后 /readyz 始终返回未就绪
+var draining atomic.Bool
+
+// SetDraining 标记服务正在关闭
+func SetDraining() {
+	draining.Store(true)
+}
+
+// IsDraining 服务是否正在关闭
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// HealthService 就绪检查，Checks 为 nil 时使用 DefaultChecks
+type HealthService struct {
+	Checks []Check
+}
+
+func (s HealthService) checks() []Check {
+	if s.Checks != nil {
+		return s.Checks
+	}
+	return DefaultChecks()
+}
+
+// Ready 并发执行全部检查；任一项失败或服务正在关闭时为未就绪
+func (s HealthService) Ready(ctx context.Context) Report {
+	checks := s.checks()
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: Ready, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Status == StatusFail {
+			report.Status = Unready
+		}
+	}
+	if IsDraining() {
+		report.Status = Draining
+	}
+	return report
+}
+
+// run 执行单项检查，超时或 panic 均记为失败
+func run(ctx context.Context, c Check) (res CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			res = CheckResult{Status: StatusFail, Error: fmt.Sprintf("panic: %v", r)}
+		}
+		res.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	detail, err := c.Fn(ctx)
+	switch {
+	case errors.Is(err, ErrSkipped):
+		return CheckResult{Status: StatusSkipped, Detail: detail}
+	case err != nil:
+		return CheckResult{Status: StatusFail, Error: err.Error(), Detail: detail}
+	default:
+		return CheckResult{Status: StatusOK, Detail: detail}
+	}
+}
+
+// =======================================
+//          默认检查项
+// =======================================
+
+// DefaultChecks MongoDB、Redis、数据库迁移与定时任务
+func DefaultChecks() []Check {
+	return []Check{
+		{Name: "mongo", Fn: checkMongo},
+		{Name: "redis", Fn: checkRedis},
+		{Name: "migrations", Fn: checkMigrations},
+		{Name: "scheduler", Fn: checkScheduler},
+	}
+}
+
+func checkMongo(ctx context.Context) (any, error) {
+	return nil, db.Ping(ctx)
+}
+
+// checkRedis 仅在 SESSION_STORE=redis 时检查
+func checkRedis(ctx context.Context) (any, error) {
+	if config.SessionStore != config.SessionRedis {
+		return map[string]string{"session_store": config.SessionStore}, ErrSkipped
+	}
+	return map[string]string{"mode": config.RedisMode}, RedisService.Default().Ping(ctx)
+}
+
+// checkMigrations 全部迁移已执行时就绪
+func checkMigrations(ctx context.Context) (any, error) {
+	states, err := migrations.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	detail := map[string]int{"version": 0, "pending": 0}
+	for _, s := range states {
+		if s.Applied {
+			detail["version"] = max(detail["version"], s.Version)
+		} else {
+			detail["pending"]++
+		}
+	}
+	if detail["pending"] > 0 {
+		return detail, fmt.Errorf("有 %d 个数据库迁移未执行", detail["pending"])
+	}
+	return detail, nil
+}
+
+// checkScheduler 定时任务未停止时就绪
+func checkScheduler(context.Context) (any, error) {
+	detail := map[string]int{"scheduled": task.Scheduled()}
+	if task.Stopped() {
+		return detail, fmt.Errorf("定时任务已停止")
+	}
+	return detail, nil
+}
@@ -0,0 +1,50 @@
+package HealthService
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func fixed(detail any, err error) func(context.Context) (any, error) {
+	return func(context.Context) (any, error) { return detail, err }
+}
+
+func TestReadyAggregatesChecks(t *testing.T) {
+	s := HealthService{Checks: []Check{
+		{Name: "ok", Fn: fixed(map[string]int{"version": 3}, nil)},
+		{Name: "skipped", Fn: fixed(nil, ErrSkipped)},
+	}}
+	report := s.Ready(context.Background())
+	if report.Status != Ready {
+		t.Fatalf("全部通过或跳过时应就绪，实际 %s: %+v", report.Status, report.Checks)
+	}
+	if report.Checks["skipped"].Status != StatusSkipped {
+		t.Errorf("skipped 状态应为 %s，实际 %s", StatusSkipped, report.Checks["skipped"].Status)
+	}
+
+	s.Checks = append(s.Checks,
+		Check{Name: "down", Fn: fixed(nil, errors.New("connection refused"))},
+		Check{Name: "panic", Fn: func(context.Context) (any, error) { panic("boom") }},
+	)
+	report = s.Ready(context.Background())
+	if report.Status != Unready {
+		t.Fatalf("有检查失败时应未就绪，实际 %s", report.Status)
+	}
+	if r := report.Checks["down"]; r.Status != StatusFail || r.Error != "connection refused" {
+		t.Errorf("down 检查结果不正确: %+v", r)
+	}
+	if r := report.Checks["panic"]; r.Status != StatusFail {
+		t.Errorf("panic 的检查应记为失败: %+v", r)
+	}
+}
+
+func TestReadyReportsDraining(t *testing.T) {
+	s := HealthService{Checks: []Check{{Name: "ok", Fn: fixed(nil, nil)}}}
+	SetDraining()
+	defer draining.Store(false)
+
+	if report := s.Ready(context.Background()); report.Status != Draining {
+		t.Fatalf("关闭过程中应返回 %s，实际 %s", Draining, report.Status)
+	}
+}
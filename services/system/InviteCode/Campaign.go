@@ -0,0 +1,190 @@
+package InviteCodeService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+// defaultExpireDays 未指定活动或活动未配置有效期时，邀请码默认有效天数
+const defaultExpireDays = 3
+
+// ErrCampaignQuotaExceeded 活动兑换次数已达上限
+var ErrCampaignQuotaExceeded = errors.New("活动兑换次数已达上限")
+
+// =======================================
+//          邀请活动管理
+// =======================================
+
+// CreateCampaign 创建邀请活动，返回活动ID
+func (ci CreateInviteCode) CreateCampaign(ctx context.Context, req CreateCampaign, adminID, adminUsername string) (string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", errors.New("活动名称不能为空")
+	}
+	if req.ExpireDays < 0 || req.MaxRedemptions < 0 {
+		return "", errors.New("有效天数与最大兑换次数不能为负数")
+	}
+	if req.ExpireDays == 0 {
+		req.ExpireDays = defaultExpireDays
+	}
+	if req.Tags == nil {
+		req.Tags = []string{}
+	}
+
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	id, err := ci.campaigns().Insert(ctx, CampaignInDB{
+		Name:           name,
+		Channel:        strings.TrimSpace(req.Channel),
+		Tags:           req.Tags,
+		CreatedBy:      adminUsername,
+		CreatedByID:    adminID,
+		ExpireDays:     req.ExpireDays,
+		MaxRedemptions: req.MaxRedemptions,
+		Redeemed:       0,
+		CreateAt:       time.Now(),
+		Deleted:        1,
+	})
+	if err != nil {
+		return "", err
+	}
+	return id.Hex(), nil
+}
+
+// ListCampaigns 分页获取邀请活动列表
+func (ci CreateInviteCode) ListCampaigns(ctx context.Context, filter CampaignFilter) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	dbCampaigns, total, err := ci.campaigns().List(ctx, filter)
+	if err != nil {
+		return services.Pagination{}, err
+	}
+
+	var campaigns []Campaign
+	for _, dbCampaign := range dbCampaigns {
+		campaigns = append(campaigns, campaignToDTO(dbCampaign))
+	}
+
+	totalPages := (total + filter.PageSize - 1) / filter.PageSize
+	return services.Pagination{
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		Data:       campaigns,
+	}, nil
+}
+
+// GetCampaignStats 统计活动下邀请码的生成、兑换与过期数量
+func (ci CreateInviteCode) GetCampaignStats(ctx context.Context, campaignID string) (CampaignStats, error) {
+	var stats CampaignStats
+	campaign, err := ci.findCampaign(ctx, campaignID)
+	if err != nil {
+		return stats, err
+	}
+
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	counts, err := ci.codes().Stats(ctx, campaignID, time.Now())
+	if err != nil {
+		return stats, err
+	}
+
+	stats = CampaignStats{
+		CampaignID:     campaignID,
+		Name:           campaign.Name,
+		Channel:        campaign.Channel,
+		Generated:      counts.Generated,
+		Redeemed:       counts.Redeemed,
+		Expired:        counts.Expired,
+		MaxRedemptions: campaign.MaxRedemptions,
+		Remaining:      -1,
+	}
+	if campaign.MaxRedemptions > 0 {
+		stats.Remaining = max(campaign.MaxRedemptions-campaign.Redeemed, 0)
+	}
+	return stats, nil
+}
+
+// =======================================
+//          活动兑换配额
+// =======================================
+
+// ReserveCampaignQuota 为活动占用一个兑换名额
+// campaignID 为空（邀请码不属于任何活动）时返回 nil；名额已满返回 ErrCampaignQuotaExceeded
+// ctx 可以是事务会话上下文
+func (ci CreateInviteCode) ReserveCampaignQuota(ctx context.Context, campaignID string) (*Campaign, error) {
+	if campaignID == "" {
+		return nil, nil
+	}
+	objID, err := primitive.ObjectIDFromHex(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign, err := ci.campaigns().Reserve(ctx, objID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrCampaignQuotaExceeded
+		}
+		return nil, err
+	}
+	dto := campaignToDTO(campaign)
+	return &dto, nil
+}
+
+// ReleaseCampaignQuota 归还活动兑换名额，用于不支持事务时注册失败的补偿回滚
+func (ci CreateInviteCode) ReleaseCampaignQuota(ctx context.Context, campaignID string) error {
+	objID, err := primitive.ObjectIDFromHex(campaignID)
+	if err != nil {
+		return err
+	}
+	return ci.campaigns().Release(ctx, objID)
+}
+
+// =======================================
+//          工具函数
+// =======================================
+
+// findCampaign 根据ID查询未删除的活动
+func (ci CreateInviteCode) findCampaign(ctx context.Context, campaignID string) (CampaignInDB, error) {
+	var campaign CampaignInDB
+	objID, err := primitive.ObjectIDFromHex(campaignID)
+	if err != nil {
+		return campaign, fmt.Errorf("无效的活动ID: %s", campaignID)
+	}
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	campaign, err = ci.campaigns().FindActive(ctx, objID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return campaign, fmt.Errorf("活动不存在: %s", campaignID)
+		}
+		return campaign, err
+	}
+	return campaign, nil
+}
+
+func campaignToDTO(campaign CampaignInDB) Campaign {
+	return Campaign{
+		ID:             campaign.ID.Hex(),
+		Name:           campaign.Name,
+		Channel:        campaign.Channel,
+		Tags:           campaign.Tags,
+		CreatedBy:      campaign.CreatedBy,
+		ExpireDays:     campaign.ExpireDays,
+		MaxRedemptions: campaign.MaxRedemptions,
+		Redeemed:       campaign.Redeemed,
+		CreateAt:       campaign.CreateAt,
+	}
+}
@@ -0,0 +1,215 @@
+package InviteCodeService
+
+import (
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsRandom"
+	"regexp"
+	"strings"
+)
+
+// 预置字符集
+const (
+	// CrockfordAlphabet Crockford Base32，去除了易混淆的 I、L、O、U
+	CrockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// AlnumAlphabet 旧版大小写字母+数字
+	AlnumAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// codeSeparator 分组与前缀之间的分隔符
+const codeSeparator = "-"
+
+// ErrInvalidCodeFormat 邀请码格式或校验位错误，无需查询数据库
+var ErrInvalidCodeFormat = errors.New("邀请码格式错误")
+
+// legacyCodePattern 旧版邀请码：8 位大小写字母数字
+var legacyCodePattern = regexp.MustCompile(`^[A-Za-z0-9]{8}$`)
+
+// codeFormat 全局邀请码格式，由配置生成
+var codeFormat = configuredCodeFormat()
+
+func init() {
+	config.RegisterCheck(func(c config.Config) error {
+		i := c.Invite
+		if _, err := NewCodeFormat(i.CodeLength, i.Alphabet, i.Prefix, i.Group, i.Checksum, i.AcceptLegacy); err != nil {
+			return fmt.Errorf("invite: %w", err)
+		}
+		return nil
+	})
+}
+
+// CodeFormat 邀请码格式规范
+// 生成的邀请码形如 PREFIX-XXXX-XXXX-C：前缀 + 随机字符（按组以 - 分隔）+ 校验位
+type CodeFormat struct {
+	Length       int    // 随机部分长度
+	Alphabet     string // 字符集
+	Prefix       string // 前缀，可为空
+	Group        int    // 每组字符数，0 不分组
+	Checksum     bool   // 是否追加校验位（Luhn mod N）
+	AcceptLegacy bool   // 是否接受旧版 8 位字母数字邀请码
+}
+
+// NewCodeFormat 校验并构建邀请码格式，alphabet 支持 crockford、alnum 或自定义字符
+func NewCodeFormat(length int, alphabet, prefix string, group int, checksum, acceptLegacy bool) (CodeFormat, error) {
+	switch strings.ToLower(alphabet) {
+	case "crockford", "":
+		alphabet = CrockfordAlphabet
+	case "alnum":
+		alphabet = AlnumAlphabet
+	}
+	f := CodeFormat{
+		Length:       length,
+		Alphabet:     alphabet,
+		Prefix:       strings.TrimSpace(prefix),
+		Group:        group,
+		Checksum:     checksum,
+		AcceptLegacy: acceptLegacy,
+	}
+	if f.Length < 4 {
+		return f, fmt.Errorf("邀请码长度至少为 4，当前为 %d", f.Length)
+	}
+	if f.Group < 0 {
+		return f, errors.New("邀请码分组长度不能为负数")
+	}
+	if len(f.Alphabet) < 2 {
+		return f, errors.New("邀请码字符集至少包含 2 个字符")
+	}
+	seen := make(map[rune]struct{}, len(f.Alphabet))
+	for _, r := range f.Alphabet {
+		if r > 127 || r == '-' || r == ' ' {
+			return f, fmt.Errorf("邀请码字符集包含非法字符: %q", r)
+		}
+		if _, ok := seen[r]; ok {
+			return f, fmt.Errorf("邀请码字符集包含重复字符: %q", r)
+		}
+		seen[r] = struct{}{}
+	}
+	if strings.Contains(f.Prefix, codeSeparator) {
+		return f, errors.New("邀请码前缀不能包含 -")
+	}
+	return f, nil
+}
+
+// configuredCodeFormat 根据配置生成全局邀请码格式；配置错误时使用默认格式，
+// 错误由 config.Validate 在启动时统一报告
+func configuredCodeFormat() CodeFormat {
+	f, err := NewCodeFormat(config.InviteCodeLength, config.InviteCodeAlphabet, config.InviteCodePrefix,
+		config.InviteCodeGroup, config.InviteCodeChecksum, config.InviteCodeAcceptLegacy)
+	if err != nil {
+		d := config.Defaults().Invite
+		f, _ = NewCodeFormat(d.CodeLength, d.Alphabet, d.Prefix, d.Group, d.Checksum, d.AcceptLegacy)
+	}
+	return f
+}
+
+// Generate 按格式生成一个新的邀请码（规范形式）
+func (f CodeFormat) Generate() (string, error) {
+	body, err := UtilsRandom.RandomString(f.Alphabet, f.Length)
+	if err != nil {
+		return "", err
+	}
+	if f.Checksum {
+		body += string(f.Alphabet[f.checkIndex(body)])
+	}
+	return f.render(body), nil
+}
+
+// Normalize 将用户输入转换为规范形式并校验，格式或校验位错误返回 ErrInvalidCodeFormat
+// 忽略大小写（字符集不区分大小写时）、空白与分隔符，Crockford 字符集下 O→0、I/L→1
+// 启用 AcceptLegacy 时，旧版 8 位字母数字邀请码原样返回
+func (f CodeFormat) Normalize(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	body, err := f.parse(input)
+	if err == nil && f.render(body) == input {
+		return input, nil // 已是规范形式
+	}
+	if f.AcceptLegacy && legacyCodePattern.MatchString(input) {
+		return input, nil // 旧版邀请码区分大小写，按原样查询
+	}
+	if err != nil {
+		return "", err
+	}
+	return f.render(body), nil
+}
+
+// parse 去除前缀与分隔符，返回随机部分（含校验位）
+func (f CodeFormat) parse(input string) (string, error) {
+	s := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' {
+			return -1
+		}
+		return r
+	}, input)
+	if f.caseInsensitive() {
+		s = strings.ToUpper(s)
+	}
+	if f.Prefix != "" {
+		prefix := f.Prefix
+		if f.caseInsensitive() {
+			prefix = strings.ToUpper(prefix)
+		}
+		if !strings.HasPrefix(s, prefix) {
+			return "", ErrInvalidCodeFormat
+		}
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if f.Alphabet == CrockfordAlphabet {
+		s = strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(s)
+	}
+
+	want := f.Length
+	if f.Checksum {
+		want++
+	}
+	if len(s) != want {
+		return "", ErrInvalidCodeFormat
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(f.Alphabet, s[i]) < 0 {
+			return "", ErrInvalidCodeFormat
+		}
+	}
+	if f.Checksum && f.checkIndex(s[:f.Length]) != strings.IndexByte(f.Alphabet, s[f.Length]) {
+		return "", ErrInvalidCodeFormat
+	}
+	return s, nil
+}
+
+// render 将随机部分按前缀与分组渲染为规范形式
+func (f CodeFormat) render(body string) string {
+	var parts []string
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	if f.Group <= 0 {
+		parts = append(parts, body)
+	} else {
+		for i := 0; i < len(body); i += f.Group {
+			parts = append(parts, body[i:min(i+f.Group, len(body))])
+		}
+	}
+	return strings.Join(parts, codeSeparator)
+}
+
+// checkIndex 计算 Luhn mod N 校验位在字符集中的下标，可检测单字符错误与相邻字符交换
+func (f CodeFormat) checkIndex(body string) int {
+	n := len(f.Alphabet)
+	factor, sum := 2, 0
+	for i := len(body) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(f.Alphabet, body[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+	return (n - sum%n) % n
+}
+
+// caseInsensitive 字符集不含小写字母时，输入不区分大小写
+func (f CodeFormat) caseInsensitive() bool {
+	return strings.ToUpper(f.Alphabet) == f.Alphabet
+}
+
+// NormalizeCode 按全局邀请码格式规范化用户输入
+func NormalizeCode(input string) (string, error) {
+	return codeFormat.Normalize(input)
+}
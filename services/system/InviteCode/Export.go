@@ -0,0 +1,74 @@
+package InviteCodeService
+
+import (
+	"context"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsExport"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ExportHeader 导出表头，与 ExportRow 的列一一对应
+var ExportHeader = []string{"ID", "邀请码", "注册链接", "绑定用户", "状态", "已使用次数", "最大使用次数", "过期时间", "活动ID"}
+
+// =======================================
+//          邀请码导出
+// =======================================
+
+// CountInviteCodes 统计符合筛选条件的邀请码数量，导出前用于校验数量上限
+func (ci CreateInviteCode) CountInviteCodes(ctx context.Context, filter CreateInviteFilter) (int, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	return ci.codes().Count(ctx, filter)
+}
+
+// ExportInviteCodes 按创建顺序遍历符合筛选条件的邀请码，逐条回调 each，不做分页
+// each 返回错误时立即停止遍历并返回该错误；导出为流式写出，耗时与数据量相关，超时为 EXPORT_TIMEOUT_SECONDS 而非单次操作超时
+func (ci CreateInviteCode) ExportInviteCodes(ctx context.Context, filter CreateInviteFilter, each func(code InviteCode) error) error {
+	ctx, cancel := context.WithTimeout(ctx, config.ExportTimeout())
+	defer cancel()
+
+	return ci.codes().Each(ctx, filter, func(dbcode InviteCodeInDB) error {
+		return each(ci.toDTO(dbcode))
+	})
+}
+
+// ExportRow 将邀请码转换为导出行；用户名、活动等可由用户控制的内容已做公式转义
+func ExportRow(code InviteCode) []string {
+	status := "可用"
+	if code.Status == 2 {
+		status = "已用完"
+	}
+	maxUses := strconv.Itoa(code.MaxUses)
+	if code.MaxUses == UnlimitedUses {
+		maxUses = "不限"
+	}
+	row := []string{
+		code.ID,
+		code.Code,
+		RegisterLink(code.Code),
+		code.Username,
+		status,
+		strconv.Itoa(code.UsedCount),
+		maxUses,
+		UtilsTime.Format(code.ExpiredTime),
+		code.CampaignID,
+	}
+	for i, cell := range row {
+		row[i] = UtilsExport.EscapeFormula(cell)
+	}
+	return row
+}
+
+// RegisterLink 生成携带邀请码的注册深链接，用于导出与二维码
+func RegisterLink(code string) string {
+	registerURL := config.Get().Invite.RegisterURL
+	sep := "?"
+	if strings.Contains(registerURL, "?") {
+		sep = "&"
+	}
+	return registerURL + sep + "code=" + url.QueryEscape(code)
+}
@@ -0,0 +1,435 @@
+package InviteCodeService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/services"
+	"github.com/StephenChristianW/go-movies-open/task/SafeGo"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsRandom"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"sync"
+	"time"
+)
+
+var logger = logging.For("invite")
+
+const (
+	// generateChunkSize 每次 InsertMany 的邀请码数量，进度按块持久化
+	generateChunkSize = 1000
+	// maxDuplicateRetries 单个或单块邀请码因重复码重新生成的最大次数，防止字符集耗尽时死循环
+	maxDuplicateRetries = 10
+)
+
+// ErrJobNotFound 批量生成任务不存在
+var ErrJobNotFound = errors.New("批量生成任务不存在")
+
+// ErrTooManyDuplicates 连续生成的邀请码重复次数过多，通常是邀请码长度过短或字符集过小
+var ErrTooManyDuplicates = errors.New("重复邀请码过多，请增大邀请码长度或更换字符集")
+
+// errLeaseLost 任务租约由其他实例持有或任务已结束，本实例不再执行
+var errLeaseLost = errors.New("任务由其他实例执行或已结束")
+
+// runningJobs 本进程中执行中的任务，jobID -> 取消函数；多实例之间由任务租约（owner / lease_until）互斥
+var runningJobs sync.Map
+
+// jobsWG 本进程中执行中的任务，关闭时由 StopGenerateJobs 等待其结束
+var jobsWG sync.WaitGroup
+
+// jobOwner 本实例的租约持有者标识：主机名-进程号-随机串
+var jobOwner = newJobOwner()
+
+func newJobOwner() string {
+	host, _ := os.Hostname()
+	suffix, _ := UtilsRandom.RandomAlphaNumString(8)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), suffix)
+}
+
+// jobLease 任务租约时长，每块完成后续约；须大于单块插入耗时，取单块超时的 4 倍
+func jobLease() time.Duration {
+	return 4 * config.JobChunkTimeout()
+}
+
+// =======================================
+//          批量生成任务
+// =======================================
+
+// StartGenerateJob 创建批量生成任务并立即在后台执行，返回任务信息
+// 邀请码按块写入，每块完成后持久化进度；服务重启后由 ResumeGenerateJobs 续跑
+func (ci CreateInviteCode) StartGenerateJob(ctx context.Context, num int, opts GenerateOptions, adminUsername string) (GenerateJob, error) {
+	job, err := ci.createJob(ctx, num, opts, adminUsername)
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	ci.startJob(ctx, job)
+	return jobToDTO(job), nil
+}
+
+// RunGenerateJob 创建批量生成任务并在当前 goroutine 中执行至完成，供命令行使用；
+// ctx 取消时停止生成，已生成的邀请码保留，任务可由服务启动时续跑
+func (ci CreateInviteCode) RunGenerateJob(ctx context.Context, num int, opts GenerateOptions, createdBy string) (GenerateJob, error) {
+	job, err := ci.createJob(ctx, num, opts, createdBy)
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	defer ci.releaseJob(ctx, job.ID)
+	if err := ci.runJob(ctx, job); err != nil {
+		if ctx.Err() == nil && !errors.Is(err, errLeaseLost) {
+			ci.finishJob(ctx, job.ID, JobFailed, err.Error())
+		}
+		return GenerateJob{}, err
+	}
+	return ci.GetGenerateJob(ctx, job.ID.Hex())
+}
+
+// createJob 校验参数并保存待执行的批量生成任务
+func (ci CreateInviteCode) createJob(ctx context.Context, num int, opts GenerateOptions, adminUsername string) (GenerateJobInDB, error) {
+	if err := validateMaxUses(opts.MaxUses); err != nil {
+		return GenerateJobInDB{}, err
+	}
+	if _, err := ci.loadCampaign(ctx, opts.CampaignID); err != nil {
+		return GenerateJobInDB{}, err
+	}
+	if batchSize := config.Limits().MaxCreateBatchSize; num > batchSize {
+		logger.Warn("数据创建量超过单个任务上限，按上限生成", "requested", num, "max", batchSize)
+		num = batchSize
+	}
+
+	now := time.Now()
+	job := GenerateJobInDB{
+		Total:      num,
+		CampaignID: opts.CampaignID,
+		MaxUses:    effectiveMaxUses(opts.MaxUses),
+		Status:     JobPending,
+		CreatedBy:  adminUsername,
+		CreateAt:   now,
+		UpdateAt:   now,
+		// 创建时即持有租约，避免其他实例在本实例开始执行前续跑
+		Owner:      jobOwner,
+		LeaseUntil: now.Add(jobLease()),
+	}
+
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	id, err := ci.jobs().Insert(ctx, job)
+	if err != nil {
+		return GenerateJobInDB{}, err
+	}
+	job.ID = id
+	return job, nil
+}
+
+// GetGenerateJob 查询批量生成任务进度
+func (ci CreateInviteCode) GetGenerateJob(ctx context.Context, jobID string) (GenerateJob, error) {
+	job, err := ci.findJob(ctx, jobID)
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	return jobToDTO(job), nil
+}
+
+// ListGenerateJobs 分页获取批量生成任务
+func (ci CreateInviteCode) ListGenerateJobs(ctx context.Context, filter GenerateJobFilter) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	dbJobs, total, err := ci.jobs().List(ctx, filter)
+	if err != nil {
+		return services.Pagination{}, err
+	}
+
+	var jobs []GenerateJob
+	for _, job := range dbJobs {
+		jobs = append(jobs, jobToDTO(job))
+	}
+
+	totalPages := (total + filter.PageSize - 1) / filter.PageSize
+	return services.Pagination{
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		Data:       jobs,
+	}, nil
+}
+
+// CancelGenerateJob 取消未完成的批量生成任务，已生成的邀请码保留
+func (ci CreateInviteCode) CancelGenerateJob(ctx context.Context, jobID string) (GenerateJob, error) {
+	job, err := ci.findJob(ctx, jobID)
+	if err != nil {
+		return GenerateJob{}, err
+	}
+
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	ok, err := ci.jobs().UpdateUnfinished(ctx, job.ID, bson.M{"status": JobCancelled, "update_at": time.Now()})
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	if !ok {
+		return GenerateJob{}, fmt.Errorf("任务已结束，无法取消: %s", job.Status)
+	}
+	if cancelRun, ok := runningJobs.Load(jobID); ok {
+		cancelRun.(context.CancelFunc)()
+	}
+
+	job, err = ci.findJob(ctx, jobID)
+	if err != nil {
+		return GenerateJob{}, err
+	}
+	return jobToDTO(job), nil
+}
+
+// ResumeGenerateJobs 服务启动时续跑未完成（pending / running）的批量生成任务
+func ResumeGenerateJobs() error {
+	return CreateInviteCode{}.ResumeGenerateJobs(context.Background())
+}
+
+// ResumeGenerateJobs 续跑未完成（pending / running）的批量生成任务
+func (ci CreateInviteCode) ResumeGenerateJobs(ctx context.Context) error {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	jobs, err := ci.jobs().ListUnfinished(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if job.Owner != jobOwner && job.LeaseUntil.After(now) {
+			logger.InfoContext(ctx, "批量生成任务由其他实例执行，跳过", "job_id", job.ID.Hex(), "owner", job.Owner)
+			continue
+		}
+		logger.InfoContext(ctx, "续跑批量生成任务", "job_id", job.ID.Hex(), "total", job.Total, "inserted", job.Inserted)
+		ci.startJob(ctx, job)
+	}
+	return nil
+}
+
+// StopGenerateJobs 取消本进程中执行中的任务并等待其结束，须在关闭数据库连接前调用；
+// 任务状态保持 running 并释放租约，由下次启动或其他实例续跑。ctx 到期时返回 ctx.Err()
+func StopGenerateJobs(ctx context.Context) error {
+	runningJobs.Range(func(_, cancel any) bool {
+		cancel.(context.CancelFunc)()
+		return true
+	})
+	done := make(chan struct{})
+	go func() {
+		jobsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info("批量生成任务已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// =======================================
+//          任务执行
+// =======================================
+
+// startJob 在后台 goroutine 中执行任务；任务不随发起请求结束而取消，仅保留 parent 中的请求 ID 与链路信息
+func (ci CreateInviteCode) startJob(parent context.Context, job GenerateJobInDB) {
+	jobID := job.ID.Hex()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	if _, loaded := runningJobs.LoadOrStore(jobID, cancel); loaded {
+		cancel()
+		return // 已在执行
+	}
+	jobsWG.Add(1)
+	SafeGo.SafeGo(ctx, "invite.generate_job", func() {
+		defer jobsWG.Done()
+		defer func() {
+			ci.releaseJob(ctx, job.ID)
+			runningJobs.Delete(jobID)
+			cancel()
+		}()
+		if err := ci.runJob(ctx, job); err != nil {
+			if ctx.Err() != nil {
+				logger.InfoContext(ctx, "批量生成任务已取消", "job_id", jobID)
+				return
+			}
+			if errors.Is(err, errLeaseLost) {
+				logger.InfoContext(ctx, "批量生成任务由其他实例执行，停止", "job_id", jobID)
+				return
+			}
+			logger.ErrorContext(ctx, "批量生成任务执行失败", "job_id", jobID, logging.Err(err))
+			ci.finishJob(ctx, job.ID, JobFailed, err.Error())
+		}
+	})
+}
+
+// runJob 取得任务租约后逐块生成邀请码直到达到目标数量，每块完成后续约，租约被其他实例取得时返回 errLeaseLost
+// 已生成数量以带 job_id 的邀请码实际数量为准，崩溃或重启后续跑不会多生成
+func (ci CreateInviteCode) runJob(ctx context.Context, job GenerateJobInDB) error {
+	if err := ci.claimJob(ctx, job.ID); err != nil {
+		return err
+	}
+	campaign, err := ci.loadCampaign(ctx, job.CampaignID)
+	if err != nil {
+		return err
+	}
+	inserted, err := ci.countJobCodes(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if ok, err := ci.updateJobProgress(ctx, job.ID, inserted, JobRunning); err != nil || !ok {
+		return err
+	}
+
+	for inserted < job.Total {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := ci.insertChunk(ctx, min(generateChunkSize, job.Total-inserted), campaign, job)
+		inserted += n
+		if err != nil {
+			return err
+		}
+		ok, err := ci.updateJobProgress(ctx, job.ID, inserted, JobRunning)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil // 任务已在其他地方被取消
+		}
+		if err := ci.claimJob(ctx, job.ID); err != nil {
+			return err
+		}
+	}
+	ci.finishJob(ctx, job.ID, JobCompleted, "")
+	logger.InfoContext(ctx, "批量生成任务完成", "job_id", job.ID.Hex(), "inserted", inserted)
+	return nil
+}
+
+// insertChunk 插入一块邀请码，重复码在本块内循环补齐，返回成功插入数量
+func (ci CreateInviteCode) insertChunk(ctx context.Context, size int, campaign *CampaignInDB, job GenerateJobInDB) (int, error) {
+	inserted := 0
+	for attempt := 0; size > 0; attempt++ {
+		if attempt > maxDuplicateRetries {
+			return inserted, ErrTooManyDuplicates
+		}
+
+		docs := make([]CreateInviteCode, 0, size)
+		for i := 0; i < size; i++ {
+			newCode, err := ci.newInviteCode(campaign, job.MaxUses)
+			if err != nil {
+				return inserted, err
+			}
+			newCode.JobID = job.ID.Hex()
+			docs = append(docs, newCode)
+		}
+
+		chunkCtx, cancel := context.WithTimeout(ctx, config.JobChunkTimeout())
+		dupCount, err := ci.codes().InsertMany(chunkCtx, docs)
+		cancel()
+		if err != nil {
+			return inserted, fmt.Errorf("批量插入失败: %w", err)
+		}
+		inserted += size - dupCount
+		metrics.AddInviteGenerated(size - dupCount)
+		size = dupCount
+	}
+	return inserted, nil
+}
+
+// =======================================
+//          工具函数
+// =======================================
+
+// countJobCodes 统计任务已实际写入的邀请码数量
+func (ci CreateInviteCode) countJobCodes(ctx context.Context, jobID primitive.ObjectID) (int, error) {
+	countCtx, cancel := context.WithTimeout(ctx, config.JobChunkTimeout())
+	defer cancel()
+	return ci.codes().Count(countCtx, CreateInviteFilter{JobID: jobID.Hex()})
+}
+
+// updateJobProgress 更新任务进度，仅在任务未被取消时生效；返回 false 表示任务已取消或结束
+func (ci CreateInviteCode) updateJobProgress(ctx context.Context, jobID primitive.ObjectID, inserted int, status string) (bool, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, config.JobChunkTimeout())
+	defer cancel()
+	return ci.jobs().UpdateUnfinished(updateCtx, jobID, bson.M{"inserted": inserted, "status": status, "update_at": time.Now()})
+}
+
+// claimJob 取得或续约任务租约，租约由其他实例持有或任务已结束时返回 errLeaseLost
+func (ci CreateInviteCode) claimJob(ctx context.Context, jobID primitive.ObjectID) error {
+	claimCtx, cancel := context.WithTimeout(ctx, config.JobChunkTimeout())
+	defer cancel()
+	now := time.Now()
+	ok, err := ci.jobs().ClaimLease(claimCtx, jobID, jobOwner, now, now.Add(jobLease()))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// releaseJob 释放本实例持有的任务租约，未完成的任务可立即由其他实例续跑；ctx 已取消时同样执行
+func (ci CreateInviteCode) releaseJob(ctx context.Context, jobID primitive.ObjectID) {
+	ctx, cancel := db.WithTimeout(context.WithoutCancel(ctx))
+	defer cancel()
+	if err := ci.jobs().ReleaseLease(ctx, jobID, jobOwner); err != nil {
+		logger.WarnContext(ctx, "释放批量生成任务租约失败", "job_id", jobID.Hex(), logging.Err(err))
+	}
+}
+
+// finishJob 将任务置为结束状态；任务已被取消时不覆盖
+func (ci CreateInviteCode) finishJob(ctx context.Context, jobID primitive.ObjectID, status, errMsg string) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	_, err := ci.jobs().UpdateUnfinished(ctx, jobID, bson.M{"status": status, "error": errMsg, "update_at": time.Now()})
+	if err != nil {
+		logger.Error("更新批量生成任务状态失败", "job_id", jobID.Hex(), logging.Err(err))
+	}
+}
+
+// findJob 根据ID查询任务
+func (ci CreateInviteCode) findJob(ctx context.Context, jobID string) (GenerateJobInDB, error) {
+	var job GenerateJobInDB
+	objID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return job, fmt.Errorf("无效的任务ID: %s", jobID)
+	}
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+	job, err = ci.jobs().FindByID(ctx, objID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return job, ErrJobNotFound
+		}
+		return job, err
+	}
+	return job, nil
+}
+
+func jobToDTO(job GenerateJobInDB) GenerateJob {
+	progress := 100.0
+	if job.Total > 0 {
+		progress = float64(job.Inserted) * 100 / float64(job.Total)
+	}
+	return GenerateJob{
+		ID:         job.ID.Hex(),
+		Total:      job.Total,
+		Inserted:   job.Inserted,
+		Progress:   progress,
+		CampaignID: job.CampaignID,
+		MaxUses:    job.MaxUses,
+		Status:     job.Status,
+		Error:      job.Error,
+		CreatedBy:  job.CreatedBy,
+		CreateAt:   job.CreateAt,
+		UpdateAt:   job.UpdateAt,
+	}
+}
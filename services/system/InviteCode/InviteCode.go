@@ -3,204 +3,162 @@ package InviteCodeService
 import (
 	"context"
 	"errors"
-	"fmt"
-	"github.com/StephenChristianW/go-movies-open/config"
-	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
 	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
 	"github.com/StephenChristianW/go-movies-open/services"
 	"github.com/StephenChristianW/go-movies-open/services/ServiceUtils"
-	"github.com/StephenChristianW/go-movies-open/utils/UtilsRandom"
 	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"log"
-	"strings"
 	"time"
 )
 
-var batchSize = config.MaxCreateBatchSize
-
 // InviteCodeInterface 邀请码服务接口
 type InviteCodeInterface interface {
-	GenerateAndInsertCode() (string, error)
-	ListInviteCodes(filter CreateInviteFilter) (services.Pagination, error)
-	GenerateAndInsertCodes(num int) (int, error)
-	ReBackInviteCodes(ids []string) ([]InviteCode, error)
-	DeleteInviteCode(ids []string) (int, error)
-	UpdateInviteCodes(updateCode UpdateInviteCode) (int, error)
-	GetInviteCode(code string) (InviteCode, error)
+	GenerateAndInsertCode(ctx context.Context, opts GenerateOptions) (string, error)
+	ListInviteCodes(ctx context.Context, filter CreateInviteFilter) (services.Pagination, error)
+	StartGenerateJob(ctx context.Context, num int, opts GenerateOptions, adminUsername string) (GenerateJob, error)
+	GetGenerateJob(ctx context.Context, jobID string) (GenerateJob, error)
+	ListGenerateJobs(ctx context.Context, filter GenerateJobFilter) (services.Pagination, error)
+	CancelGenerateJob(ctx context.Context, jobID string) (GenerateJob, error)
+	ReBackInviteCodes(ctx context.Context, ids []string) ([]InviteCode, error)
+	DeleteInviteCode(ctx context.Context, ids []string) (int, error)
+	UpdateInviteCodes(ctx context.Context, updateCode UpdateInviteCode) (int, error)
+	GetInviteCode(ctx context.Context, code string) (InviteCode, error)
+	CountInviteCodes(ctx context.Context, filter CreateInviteFilter) (int, error)
+	ExportInviteCodes(ctx context.Context, filter CreateInviteFilter, each func(code InviteCode) error) error
+
+	CreateCampaign(ctx context.Context, req CreateCampaign, adminID, adminUsername string) (string, error)
+	ListCampaigns(ctx context.Context, filter CampaignFilter) (services.Pagination, error)
+	GetCampaignStats(ctx context.Context, campaignID string) (CampaignStats, error)
+	ListRedemptions(ctx context.Context, filter RedemptionFilter) (services.Pagination, error)
 }
 
 // 邀请码服务功能-具体实现
 
-// GenerateAndInsertCode 生成邀请码并插入数据库
-func (ci CreateInviteCode) GenerateAndInsertCode() (string, error) {
-	collection := collections.GetInviteCodeCollection()
-	ctx, cancel := db.GetCtx()
-	defer cancel()
-
-	newCode, err := ci.newInviteCode()
-	if err != nil {
+// GenerateAndInsertCode 生成邀请码并插入数据库，opts.CampaignID 为空时不归属任何活动
+func (ci CreateInviteCode) GenerateAndInsertCode(ctx context.Context, opts GenerateOptions) (string, error) {
+	if err := validateMaxUses(opts.MaxUses); err != nil {
 		return "", err
 	}
-
-	doc, err := collection.InsertOne(ctx, newCode)
+	campaign, err := ci.loadCampaign(ctx, opts.CampaignID)
 	if err != nil {
-		if mongo.IsDuplicateKeyError(err) {
-			// 若生成重复码，则递归生成新的
-			return ci.GenerateAndInsertCode()
-		}
 		return "", err
 	}
 
-	if oid, ok := doc.InsertedID.(primitive.ObjectID); ok {
-		return oid.Hex(), nil
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	// 生成重复码时重新生成，最多重试 maxDuplicateRetries 次
+	for attempt := 0; attempt <= maxDuplicateRetries; attempt++ {
+		newCode, err := ci.newInviteCode(campaign, opts.MaxUses)
+		if err != nil {
+			return "", err
+		}
+		id, err := ci.codes().Insert(ctx, newCode)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		metrics.AddInviteGenerated(1)
+		return id.Hex(), nil
 	}
-	return "", fmt.Errorf("unexpected InsertedID type: %T", doc.InsertedID)
+	return "", ErrTooManyDuplicates
 }
 
 // ListInviteCodes 分页获取邀请码列表
-func (ci CreateInviteCode) ListInviteCodes(filter CreateInviteFilter) (services.Pagination, error) {
-	collection := collections.GetInviteCodeCollection()
-	ctx, cancel := db.GetCtx()
+func (ci CreateInviteCode) ListInviteCodes(ctx context.Context, filter CreateInviteFilter) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
-	conditions := ci.buildFilter(filter)
-	opts := db.CalculatePagination(filter.Page, filter.PageSize, "expiredTime", -1)
-
-	var inviteCodes []InviteCode
-	cursor, err := collection.Find(ctx, conditions, opts)
-	defer func(cursor *mongo.Cursor, ctx context.Context) {
-		_ = cursor.Close(ctx)
-	}(cursor, ctx)
+	dbcodes, total, err := ci.codes().List(ctx, filter)
 	if err != nil {
 		return services.Pagination{}, err
 	}
 
-	for cursor.Next(ctx) {
-		var dbcode InviteCodeInDB
-		if err := cursor.Decode(&dbcode); err != nil {
-			return services.Pagination{}, err
-		}
+	var inviteCodes []InviteCode
+	for _, dbcode := range dbcodes {
 		inviteCodes = append(inviteCodes, ci.toDTO(dbcode))
 	}
 
-	total, err := collection.CountDocuments(ctx, conditions)
-	if err != nil {
-		return services.Pagination{}, err
-	}
-
-	totalPages := (int(total) + filter.PageSize - 1) / filter.PageSize
+	totalPages := (total + filter.PageSize - 1) / filter.PageSize
 	return services.Pagination{
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
-		Total:      int(total),
+		Total:      total,
 		TotalPages: totalPages,
 		Data:       inviteCodes,
 	}, nil
 }
 
-// GenerateAndInsertCodes 批量生成邀请码
-func (ci CreateInviteCode) GenerateAndInsertCodes(num int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if num > batchSize {
-		num = batchSize
-		log.Printf("数据创建量大于 %d 条, 最多一次请求只插入 %d 条数据", batchSize, batchSize)
-	}
-	totalInserted, err := ci.insertBatch(ctx, num)
-	if err != nil {
-		return totalInserted, err
-	}
-	return totalInserted, nil
-}
-
 // ReBackInviteCodes 根据ID获取邀请码
-func (ci CreateInviteCode) ReBackInviteCodes(ids []string) ([]InviteCode, error) {
+func (ci CreateInviteCode) ReBackInviteCodes(ctx context.Context, ids []string) ([]InviteCode, error) {
 	var codes []InviteCode
-	collection := collections.GetInviteCodeCollection()
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	var objectIDs []primitive.ObjectID
 	for _, id := range ids {
 		objID, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
-			log.Println("无效的 ObjectID:", id, err)
+			logger.WarnContext(ctx, "无效的 ObjectID", "id", id, logging.Err(err))
 			continue
 		}
 		objectIDs = append(objectIDs, objID)
 	}
 
-	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}})
+	dbcodes, err := ci.codes().FindByIDs(ctx, objectIDs)
 	if err != nil {
 		return codes, err
 	}
-	defer func(cursor *mongo.Cursor, ctx context.Context) {
-		_ = cursor.Close(ctx)
-	}(cursor, ctx)
-
-	for cursor.Next(ctx) {
-		var dbcode InviteCodeInDB
-		if err = cursor.Decode(&dbcode); err != nil {
-			log.Println("解码失败:", err)
-			continue
-		}
+	for _, dbcode := range dbcodes {
 		codes = append(codes, ci.toDTO(dbcode))
 	}
-
-	if err = cursor.Err(); err != nil {
-		return codes, err
-	}
-
 	return codes, nil
 }
 
 // DeleteInviteCode 删除邀请码
-func (ci CreateInviteCode) DeleteInviteCode(ids []string) (int, error) {
+func (ci CreateInviteCode) DeleteInviteCode(ctx context.Context, ids []string) (int, error) {
 	if len(ids) == 0 {
 		return 0, nil
 	}
 	objectIds := ServiceUtils.StringsToObjectIds(ids)
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
-	updateResult, err := collections.GetInviteCodeCollection().UpdateMany(ctx, bson.M{"_id": bson.M{"$in": objectIds}},
-		bson.M{"$set": bson.M{"deleted": 2}})
+	matched, modified, err := ci.codes().UpdateMany(ctx, objectIds, bson.M{"deleted": 2})
 	if err != nil {
 		return 0, err
 	}
-	if updateResult.MatchedCount == 0 {
+	if matched == 0 {
 		return 0, errors.New("无数据匹配")
 	}
-	if updateResult.ModifiedCount == 0 {
+	if modified == 0 {
 		return 0, errors.New("无数据被删除")
 	}
-	return int(updateResult.ModifiedCount), nil
+	return modified, nil
 }
 
-func (ci CreateInviteCode) UpdateInviteCodes(updateCode UpdateInviteCode) (int, error) {
+func (ci CreateInviteCode) UpdateInviteCodes(ctx context.Context, updateCode UpdateInviteCode) (int, error) {
 	if len(updateCode.Ids) == 0 {
 		return 0, nil
 	}
 
 	objectIds := ServiceUtils.StringsToObjectIds(updateCode.Ids)
-	ctx, cancel := db.GetCtx()
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	// 查询要更新的邀请码
-	cursor, err := collections.GetInviteCodeCollection().Find(ctx, bson.M{"_id": bson.M{"$in": objectIds}})
+	dbcodes, err := ci.codes().FindByIDs(ctx, objectIds)
 	if err != nil {
 		return 0, err
 	}
 
 	var noUserIDs []primitive.ObjectID
-	for cursor.Next(ctx) {
-		var dbcode InviteCodeInDB
-		if err = cursor.Decode(&dbcode); err != nil {
-			log.Println("Decode error:", err)
-			continue
-		}
+	for _, dbcode := range dbcodes {
 		// 只允许未绑定用户且状态为正常的记录修改
 		if dbcode.Username == "" && dbcode.Status == 1 {
 			noUserIDs = append(noUserIDs, dbcode.ID)
@@ -217,69 +175,69 @@ func (ci CreateInviteCode) UpdateInviteCodes(updateCode UpdateInviteCode) (int,
 		updateFields["status"] = 1
 	}
 	if updateCode.Days != 0 {
-		updateFields["expiredTime"] = UtilsTime.AfterNDays000(updateCode.Days)
+		updateFields[fieldExpiredTime] = UtilsTime.DayStart(updateCode.Days)
+		updateFields[fieldIsExpired] = 1
 	}
 	if len(updateFields) == 0 {
 		return 0, errors.New("没有要更新的字段")
 	}
 	updateFields["deleted"] = 1
 
-	update := bson.M{"$set": updateFields}
-	updateResult, err := collections.GetInviteCodeCollection().UpdateMany(
-		ctx,
-		bson.M{"_id": bson.M{"$in": noUserIDs}},
-		update,
-	)
+	_, modified, err := ci.codes().UpdateMany(ctx, noUserIDs, updateFields)
 	if err != nil {
 		return 0, err
 	}
 
-	if updateResult.ModifiedCount == 0 {
+	if modified == 0 {
 		return 0, errors.New("无数据更改")
 	}
 
-	return int(updateResult.ModifiedCount), nil
+	return modified, nil
 }
 
 // GetInviteCode 根据codeID 或 code 获取验证码
-func (ci CreateInviteCode) GetInviteCode(code string) (InviteCode, error) {
-	ctx, cancel := db.GetCtx()
+func (ci CreateInviteCode) GetInviteCode(ctx context.Context, code string) (InviteCode, error) {
+	ctx, cancel := db.WithTimeout(ctx)
 	defer cancel()
 
 	var inviteCodeDB InviteCodeInDB
 	var inviteCode InviteCode
-	condition := bson.M{}
 
 	// 尝试把 code 转为 ObjectID
 	objectId, err := primitive.ObjectIDFromHex(code)
 	if err != nil {
-		if errors.Is(err, primitive.ErrInvalidHex) {
-			// code 不是 ObjectID，则用 code 字段查询
-			condition["code"] = code
-		} else {
-			// 其他错误直接返回
+		// code 不是 ObjectID，则规范化后用 code 字段查询，格式错误无需查库
+		normalized, err := NormalizeCode(code)
+		if err != nil {
+			return inviteCode, err
+		}
+		inviteCodeDB, err = ci.codes().FindByCode(ctx, normalized)
+		if err != nil {
 			return inviteCode, err
 		}
 	} else {
 		// code 是 ObjectID，查 _id
-		condition["_id"] = objectId
+		inviteCodeDB, err = ci.codes().FindByID(ctx, objectId)
+		if err != nil {
+			return inviteCode, err
+		}
 	}
 
-	// 查询数据库
-	err = collections.GetInviteCodeCollection().FindOne(ctx, condition).Decode(&inviteCodeDB)
-	if err != nil {
-		return inviteCode, err
-	}
 	// 转 DTO 返回
 	inviteCode = ci.toDTO(inviteCodeDB)
 	return inviteCode, nil
 }
 
+// FindInviteCode 按规范化后的邀请码查询数据库记录，不存在返回 mongo.ErrNoDocuments
+func (ci CreateInviteCode) FindInviteCode(ctx context.Context, code string) (InviteCodeInDB, error) {
+	return ci.codes().FindByCode(ctx, code)
+}
+
 // 邀请码服务功能-工具函数
 
-// 生成随机邀请码（8位字母数字组合）
+// 按全局邀请码格式生成随机邀请码
 func generateRandomCode() (string, error) {
-	return UtilsRandom.RandomAlphaNumString(8)
+	return codeFormat.Generate()
 }
 
 // 将数据库结构转换为前端结构
@@ -289,8 +247,12 @@ func (ci CreateInviteCode) toDTO(dbcode InviteCodeInDB) InviteCode {
 		Code:        dbcode.Code,
 		Username:    dbcode.Username,
 		Status:      dbcode.Status,
-		IsExpired:   dbcode.IsExpired,
+		IsExpired:   expiredFlag(dbcode.ExpiredTime, time.Now()),
 		ExpiredTime: dbcode.ExpiredTime,
+		CampaignID:  dbcode.CampaignID,
+		MaxUses:     effectiveMaxUses(dbcode.MaxUses),
+		UsedCount:   dbcode.UsedCount,
+		JobID:       dbcode.JobID,
 	}
 }
 
@@ -305,7 +267,11 @@ func (ci CreateInviteCode) buildFilter(find CreateInviteFilter) bson.M {
 	filter := bson.M{}
 
 	if find.Code != "" {
-		filter["code"] = find.Code
+		if normalized, err := NormalizeCode(find.Code); err == nil {
+			filter["code"] = normalized
+		} else {
+			filter["code"] = find.Code
+		}
 	}
 	if find.Username != "" {
 		filter["username"] = find.Username
@@ -313,102 +279,126 @@ func (ci CreateInviteCode) buildFilter(find CreateInviteFilter) bson.M {
 	if find.Status != 0 {
 		filter["status"] = find.Status
 	}
-	if find.BeginTime != "" {
-		filter["expiredTime"] = bson.M{
-			"$gte": find.BeginTime,
-			"$lte": find.EndTime,
-		}
+	if find.CampaignID != "" {
+		filter["campaign_id"] = find.CampaignID
 	}
-	return filter
-}
-
-// insertBatch 插入一批数据，并处理重复 key 错误，确保最终成功插入 size 条
-func (ci CreateInviteCode) insertBatch(ctx context.Context, size int) (int, error) {
-	if size <= 0 {
-		return 0, nil
+	if find.JobID != "" {
+		filter["job_id"] = find.JobID
 	}
-
-	var docs []interface{}
-	for i := 0; i < size; i++ {
-		newCode, err := ci.newInviteCode()
-		if err != nil {
-			return 0, err
-		}
-		docs = append(docs, newCode)
+	if find.IsExpired != 0 {
+		filter[fieldExpiredTime] = expiryCondition(time.Now(), find.IsExpired == 2)
 	}
-
-	res, err := collections.GetInviteCodeCollection().InsertMany(ctx, docs)
-	if err != nil {
-		// 处理批量写入错误
-		var bulkErr mongo.BulkWriteException
-		if errors.As(err, &bulkErr) {
-			dupCount := 0
-			for _, we := range bulkErr.WriteErrors {
-				if strings.Contains(we.Message, "E11000") {
-					dupCount++
-				}
-			}
-
-			successCount := size - dupCount
-
-			// 如果还有重复的，再递归补齐
-			if dupCount > 0 {
-				retryCount, retryErr := ci.insertBatch(ctx, dupCount)
-				if retryErr != nil {
-					return successCount + retryCount, retryErr
-				}
-				return successCount + retryCount, nil
+	// 过期时间区间，无法解析的时间忽略
+	timeRange := bson.M{}
+	if begin, err := UtilsTime.ParseFlexibleTime(find.BeginTime); err == nil && !begin.IsZero() {
+		timeRange["$gte"] = begin
+	}
+	if end, err := UtilsTime.ParseFlexibleTime(find.EndTime); err == nil && !end.IsZero() {
+		timeRange["$lte"] = end
+	}
+	if len(timeRange) > 0 {
+		if cond, ok := filter[fieldExpiredTime].(bson.M); ok {
+			for k, v := range timeRange {
+				cond[k] = v
 			}
-
-			return successCount, nil
+		} else {
+			filter[fieldExpiredTime] = timeRange
 		}
-
-		// 其它错误（例如网络超时、权限问题），直接返回
-		return 0, fmt.Errorf("批量插入失败: %w", err)
 	}
-
-	// 正常插入，返回成功数量
-	return len(res.InsertedIDs), nil
+	return filter
 }
 
-// 构建新的 InviteCode 对象
-func (ci CreateInviteCode) newInviteCode() (CreateInviteCode, error) {
+// 构建新的 InviteCode 对象，有效期与归属取自活动（campaign 为 nil 时使用默认值）
+func (ci CreateInviteCode) newInviteCode(campaign *CampaignInDB, maxUses int) (CreateInviteCode, error) {
 	code, err := generateRandomCode()
 	if err != nil {
 		return ci, err
 	}
+	expireDays := defaultExpireDays // 默认三天后过期
+	campaignID := ""
+	if campaign != nil {
+		campaignID = campaign.ID.Hex()
+		if campaign.ExpireDays > 0 {
+			expireDays = campaign.ExpireDays
+		}
+	}
 	return CreateInviteCode{
 		Code:        code,
-		Username:    "",                         // 初始未绑定用户名
-		Status:      1,                          // 状态 1 表示可用
-		IsExpired:   1,                          // 1 表示未过期
-		ExpiredTime: UtilsTime.AfterNDays000(3), // 默认三天后过期
+		Username:    "", // 初始未绑定用户名
+		Status:      1,  // 状态 1 表示可用
+		IsExpired:   1,  // 1 表示未过期
+		ExpiredTime: UtilsTime.DayStart(expireDays),
 		Deleted:     1,
+		CampaignID:  campaignID,
+		MaxUses:     effectiveMaxUses(maxUses),
+		UsedCount:   0,
 	}, nil
 }
 
-func CheckExpiredCodes() error {
-	today0 := UtilsTime.Today000()
-	collection := collections.GetInviteCodeCollection()
-	ctx, cancel := db.GetCtx()
-	defer cancel()
-
-	update := bson.M{
-		"$set": bson.M{"deleted": 2}, // 标记为已删除
+// validateMaxUses 校验最大使用次数：-1 不限，0 视为单次，其余必须为正数
+func validateMaxUses(maxUses int) error {
+	if maxUses < UnlimitedUses {
+		return errors.New("最大使用次数只能为正数或 -1（不限次数）")
 	}
+	return nil
+}
 
-	filter := bson.M{
-		"username":     "",                     // 未被领取
-		"expired_time": bson.M{"$lte": today0}, // 过期
-		"deleted":      1,                      // 当前状态正常
+// loadCampaign 根据活动ID加载活动，campaignID 为空时返回 nil
+func (ci CreateInviteCode) loadCampaign(ctx context.Context, campaignID string) (*CampaignInDB, error) {
+	if campaignID == "" {
+		return nil, nil
 	}
-
-	result, err := collection.UpdateMany(ctx, filter, update)
+	campaign, err := ci.findCampaign(ctx, campaignID)
 	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// CheckExpiredCodes 将已过期且从未被使用的邀请码标记为过期并删除
+func CheckExpiredCodes() error {
+	return CreateInviteCode{}.CheckExpiredCodes(context.Background())
+}
+
+// CheckExpiredCodes 将已过期且从未被使用的邀请码标记为过期并删除
+func (ci CreateInviteCode) CheckExpiredCodes(ctx context.Context) error {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
 
+	modified, err := ci.codes().ExpireUnclaimed(ctx, UtilsTime.DayStart(0))
+	if err != nil {
 		return err
 	}
-	log.Printf("CheckExpiredCodes: %d codes expired and marked deleted\n", result.ModifiedCount)
+	logger.InfoContext(ctx, "过期邀请码已标记删除", "count", modified)
 	return nil
+}
+
+// expireFilter 过期清理条件：从未被使用、过期时间不晚于 before 且未删除。
+// 多次邀请码不绑定用户名，需按 used_count 判断，否则部分兑换的邀请码会连同兑换记录一起被隐藏；
+// 旧数据没有 used_count 字段，仍按是否绑定用户名判断
+func expireFilter(before time.Time) bson.M {
+	return bson.M{
+		"$or": bson.A{ // 从未被使用
+			bson.M{"used_count": 0},
+			bson.M{"used_count": bson.M{"$exists": false}, "username": ""},
+		},
+		fieldExpiredTime: bson.M{"$lte": before}, // 过期
+		"deleted":        1,                      // 当前状态正常
+	}
+}
+
+// expiryCondition 过期时间条件：expired 为 false 匹配未过期（now 之后过期），为 true 匹配已过期
+func expiryCondition(now time.Time, expired bool) bson.M {
+	if expired {
+		return bson.M{"$lte": now}
+	}
+	return bson.M{"$gt": now}
+}
 
+// expiredFlag 根据过期时间计算是否过期：1 未过期 2 已过期
+func expiredFlag(expiredTime, now time.Time) int {
+	if expiredTime.After(now) {
+		return 1
+	}
+	return 2
 }
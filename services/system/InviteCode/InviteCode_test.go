@@ -0,0 +1,395 @@
+package InviteCodeService
+
+import (
+	"context"
+	"errors"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+// newTestService 使用内存存储的邀请码服务
+func newTestService() CreateInviteCode {
+	return CreateInviteCode{Repos: NewMemoryRepositories()}
+}
+
+// generateCode 生成一个邀请码并返回其规范化后的码值
+func generateCode(t *testing.T, ci CreateInviteCode, opts GenerateOptions) string {
+	t.Helper()
+	id, err := ci.GenerateAndInsertCode(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("GenerateAndInsertCode: %v", err)
+	}
+	code, err := ci.GetInviteCode(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetInviteCode(%s): %v", id, err)
+	}
+	return code.Code
+}
+
+func TestClaimSingleUseCode(t *testing.T) {
+	ci := newTestService()
+	ctx := context.Background()
+	code := generateCode(t, ci, GenerateOptions{})
+
+	claimed, err := ci.ClaimInviteCode(ctx, code, "u1", "alice")
+	if err != nil {
+		t.Fatalf("首次兑换失败: %v", err)
+	}
+	got, err := ci.GetInviteCode(ctx, code)
+	if err != nil {
+		t.Fatalf("GetInviteCode: %v", err)
+	}
+	if got.Username != "alice" || got.Status != 2 || got.UsedCount != 1 {
+		t.Errorf("单次邀请码兑换后应绑定用户并置为已用完，实际 %+v", got)
+	}
+
+	if _, err := ci.ClaimInviteCode(ctx, code, "u2", "bob"); !errors.Is(err, ErrInviteCodeUnavailable) {
+		t.Errorf("重复兑换应返回 ErrInviteCodeUnavailable，实际 %v", err)
+	}
+	if _, err := ci.ClaimInviteCode(ctx, "NOT-EXIST", "u2", "bob"); !errors.Is(err, ErrInviteCodeNotFound) {
+		t.Errorf("不存在的邀请码应返回 ErrInviteCodeNotFound，实际 %v", err)
+	}
+
+	// 撤销兑换后可再次使用，兑换记录随之删除
+	if err := ci.ReleaseInviteCode(ctx, claimed, "u1"); err != nil {
+		t.Fatalf("ReleaseInviteCode: %v", err)
+	}
+	page, err := ci.ListRedemptions(ctx, RedemptionFilter{Code: code, Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("ListRedemptions: %v", err)
+	}
+	if page.Total != 0 {
+		t.Errorf("撤销后兑换记录应为 0，实际 %d", page.Total)
+	}
+	if _, err := ci.ClaimInviteCode(ctx, code, "u2", "bob"); err != nil {
+		t.Errorf("撤销后应可再次兑换: %v", err)
+	}
+}
+
+// failingRedemptions 写入兑换记录总是失败的内存存储
+type failingRedemptions struct {
+	*MemoryInviteCodeRepository
+}
+
+func (failingRedemptions) InsertRedemption(context.Context, RedemptionInDB) error {
+	return errors.New("insert redemption failed")
+}
+
+func TestClaimReleasesOnLaterFailure(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	ci := CreateInviteCode{Repos: repos}
+	code := generateCode(t, ci, GenerateOptions{})
+
+	// 增加使用次数后写入兑换记录失败：本次占用的次数应被归还，单次邀请码不会被浪费
+	repos.Codes = failingRedemptions{repos.Codes.(*MemoryInviteCodeRepository)}
+	if _, err := ci.ClaimInviteCode(ctx, code, "u1", "alice"); err == nil {
+		t.Fatal("写入兑换记录失败时应返回错误")
+	}
+	got, err := ci.GetInviteCode(ctx, code)
+	if err != nil {
+		t.Fatalf("GetInviteCode: %v", err)
+	}
+	if got.UsedCount != 0 || got.Status != 1 || got.Username != "" {
+		t.Errorf("兑换失败后应归还使用次数，实际 %+v", got)
+	}
+}
+
+// duplicateCodes 插入总是违反唯一索引的内存存储
+type duplicateCodes struct {
+	*MemoryInviteCodeRepository
+	inserts int
+}
+
+func (d *duplicateCodes) Insert(context.Context, CreateInviteCode) (primitive.ObjectID, error) {
+	d.inserts++
+	return primitive.NilObjectID, Repository.DuplicateKeyError("code_unique")
+}
+
+func TestGenerateCodeStopsRetryingDuplicates(t *testing.T) {
+	repos := NewMemoryRepositories()
+	codes := &duplicateCodes{MemoryInviteCodeRepository: repos.Codes.(*MemoryInviteCodeRepository)}
+	repos.Codes = codes
+	ci := CreateInviteCode{Repos: repos}
+
+	if _, err := ci.GenerateAndInsertCode(context.Background(), GenerateOptions{}); !errors.Is(err, ErrTooManyDuplicates) {
+		t.Errorf("重复次数过多应返回 ErrTooManyDuplicates，实际 %v", err)
+	}
+	if codes.inserts != maxDuplicateRetries+1 {
+		t.Errorf("应尝试 %d 次，实际 %d", maxDuplicateRetries+1, codes.inserts)
+	}
+}
+
+func TestClaimMultiUseCode(t *testing.T) {
+	ci := newTestService()
+	ctx := context.Background()
+	code := generateCode(t, ci, GenerateOptions{MaxUses: 2})
+
+	for i, user := range []string{"alice", "bob"} {
+		if _, err := ci.ClaimInviteCode(ctx, code, user, user); err != nil {
+			t.Fatalf("第 %d 次兑换失败: %v", i+1, err)
+		}
+	}
+	if _, err := ci.ClaimInviteCode(ctx, code, "carol", "carol"); !errors.Is(err, ErrInviteCodeUnavailable) {
+		t.Errorf("超出使用次数应返回 ErrInviteCodeUnavailable，实际 %v", err)
+	}
+	got, _ := ci.GetInviteCode(ctx, code)
+	if got.Username != "" {
+		t.Errorf("多次邀请码不应绑定用户名，实际 %q", got.Username)
+	}
+
+	page, err := ci.ListRedemptions(ctx, RedemptionFilter{Code: code, Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("ListRedemptions: %v", err)
+	}
+	if page.Total != 2 {
+		t.Errorf("兑换记录应为 2 条，实际 %d", page.Total)
+	}
+}
+
+func TestClaimExpiredCode(t *testing.T) {
+	ci := newTestService()
+	ctx := context.Background()
+	expired, err := ci.newInviteCode(nil, 1)
+	if err != nil {
+		t.Fatalf("newInviteCode: %v", err)
+	}
+	expired.ExpiredTime = time.Now().Add(-48 * time.Hour)
+	if _, err := ci.codes().Insert(ctx, expired); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	if _, err := ci.ClaimInviteCode(ctx, expired.Code, "u1", "alice"); !errors.Is(err, ErrInviteCodeExpired) {
+		t.Errorf("过期邀请码应返回 ErrInviteCodeExpired，实际 %v", err)
+	}
+
+	if err := ci.CheckExpiredCodes(ctx); err != nil {
+		t.Fatalf("CheckExpiredCodes: %v", err)
+	}
+	if _, err := ci.ClaimInviteCode(ctx, expired.Code, "u1", "alice"); !errors.Is(err, ErrInviteCodeDeleted) {
+		t.Errorf("清理后的邀请码应返回 ErrInviteCodeDeleted，实际 %v", err)
+	}
+}
+
+func TestCheckExpiredCodesKeepsUsedMultiUseCodes(t *testing.T) {
+	ci := newTestService()
+	ctx := context.Background()
+	used := generateCode(t, ci, GenerateOptions{MaxUses: 3})
+	unused := generateCode(t, ci, GenerateOptions{MaxUses: 3})
+	if _, err := ci.ClaimInviteCode(ctx, used, "u1", "alice"); err != nil {
+		t.Fatalf("ClaimInviteCode: %v", err)
+	}
+	for _, code := range []string{used, unused} {
+		doc, err := ci.codes().FindByCode(ctx, code)
+		if err != nil {
+			t.Fatalf("FindByCode: %v", err)
+		}
+		if _, _, err := ci.codes().UpdateMany(ctx, []primitive.ObjectID{doc.ID}, bson.M{fieldExpiredTime: time.Now().Add(-48 * time.Hour)}); err != nil {
+			t.Fatalf("UpdateMany: %v", err)
+		}
+	}
+
+	if err := ci.CheckExpiredCodes(ctx); err != nil {
+		t.Fatalf("CheckExpiredCodes: %v", err)
+	}
+	if _, err := ci.ClaimInviteCode(ctx, used, "u2", "bob"); !errors.Is(err, ErrInviteCodeExpired) {
+		t.Errorf("部分兑换的多次邀请码过期后不应被删除，实际 %v", err)
+	}
+	if _, err := ci.ClaimInviteCode(ctx, unused, "u2", "bob"); !errors.Is(err, ErrInviteCodeDeleted) {
+		t.Errorf("从未使用的过期邀请码应被删除，实际 %v", err)
+	}
+	page, err := ci.ListRedemptions(ctx, RedemptionFilter{Code: used, Page: 1, PageSize: 10})
+	if err != nil || page.Total != 1 {
+		t.Errorf("兑换记录应保留，实际 %+v, %v", page, err)
+	}
+}
+
+func TestCampaignQuota(t *testing.T) {
+	ci := newTestService()
+	ctx := context.Background()
+	campaignID, err := ci.CreateCampaign(ctx, CreateCampaign{Name: "春季活动", Channel: "wechat", MaxRedemptions: 1}, "admin-id", "admin")
+	if err != nil {
+		t.Fatalf("CreateCampaign: %v", err)
+	}
+
+	campaign, err := ci.ReserveCampaignQuota(ctx, campaignID)
+	if err != nil {
+		t.Fatalf("首次占用名额失败: %v", err)
+	}
+	if campaign.Channel != "wechat" {
+		t.Errorf("应返回活动渠道，实际 %q", campaign.Channel)
+	}
+	if _, err := ci.ReserveCampaignQuota(ctx, campaignID); !errors.Is(err, ErrCampaignQuotaExceeded) {
+		t.Errorf("名额已满应返回 ErrCampaignQuotaExceeded，实际 %v", err)
+	}
+	if err := ci.ReleaseCampaignQuota(ctx, campaignID); err != nil {
+		t.Fatalf("ReleaseCampaignQuota: %v", err)
+	}
+	if _, err := ci.ReserveCampaignQuota(ctx, campaignID); err != nil {
+		t.Errorf("归还后应可再次占用: %v", err)
+	}
+
+	code := generateCode(t, ci, GenerateOptions{CampaignID: campaignID})
+	generateCode(t, ci, GenerateOptions{CampaignID: campaignID})
+	if _, err := ci.ClaimInviteCode(ctx, code, "u1", "alice"); err != nil {
+		t.Fatalf("ClaimInviteCode: %v", err)
+	}
+	stats, err := ci.GetCampaignStats(ctx, campaignID)
+	if err != nil {
+		t.Fatalf("GetCampaignStats: %v", err)
+	}
+	if stats.Generated != 2 || stats.Redeemed != 1 || stats.Remaining != 0 {
+		t.Errorf("活动统计不正确: %+v", stats)
+	}
+}
+
+func TestGenerateJob(t *testing.T) {
+	ctx := context.Background()
+	ci := newTestService()
+	job, err := ci.StartGenerateJob(ctx, 25, GenerateOptions{MaxUses: 3}, "admin")
+	if err != nil {
+		t.Fatalf("StartGenerateJob: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != JobCompleted {
+		if time.Now().After(deadline) {
+			t.Fatalf("任务未在限定时间内完成: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if job, err = ci.GetGenerateJob(ctx, job.ID); err != nil {
+			t.Fatalf("GetGenerateJob: %v", err)
+		}
+	}
+	if job.Inserted != 25 {
+		t.Errorf("任务应记录 25 条，实际 %d", job.Inserted)
+	}
+
+	filter := CreateInviteFilter{JobID: job.ID}
+	n, err := ci.CountInviteCodes(ctx, filter)
+	if err != nil {
+		t.Fatalf("CountInviteCodes: %v", err)
+	}
+	if n != 25 {
+		t.Errorf("任务应生成 25 个邀请码，实际 %d", n)
+	}
+
+	// 导出按创建顺序逐条回调，回调出错时停止
+	var exported []InviteCode
+	if err := ci.ExportInviteCodes(ctx, filter, func(code InviteCode) error {
+		exported = append(exported, code)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportInviteCodes: %v", err)
+	}
+	if len(exported) != 25 || exported[0].MaxUses != 3 || exported[0].JobID != job.ID {
+		t.Errorf("导出结果不正确: 共 %d 条", len(exported))
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err = ci.ExportInviteCodes(ctx, filter, func(InviteCode) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("回调出错应立即停止，err=%v calls=%d", err, calls)
+	}
+
+	if _, err := ci.CancelGenerateJob(ctx, job.ID); err == nil {
+		t.Error("已完成的任务不应允许取消")
+	}
+}
+
+func TestGenerateJobLease(t *testing.T) {
+	ctx := context.Background()
+	ci := newTestService()
+	job, err := ci.createJob(ctx, 10, GenerateOptions{}, "admin")
+	if err != nil {
+		t.Fatalf("createJob: %v", err)
+	}
+
+	// 模拟另一实例持有租约：本实例既不能执行也不应续跑该任务
+	now := time.Now()
+	if ok, err := ci.jobs().ClaimLease(ctx, job.ID, "other", now.Add(time.Hour), now.Add(2*time.Hour)); !ok || err != nil {
+		t.Fatalf("ClaimLease(other): %v, %v", ok, err)
+	}
+	if err := ci.runJob(ctx, job); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("租约由其他实例持有时应返回 errLeaseLost，实际 %v", err)
+	}
+	if err := ci.ResumeGenerateJobs(ctx); err != nil {
+		t.Fatalf("ResumeGenerateJobs: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := ci.CountInviteCodes(ctx, CreateInviteFilter{JobID: job.ID.Hex()}); n != 0 {
+		t.Fatalf("租约有效期间不应生成邀请码，实际 %d", n)
+	}
+
+	// 租约过期后由本实例续跑，生成数量不超过目标
+	if ok, err := ci.jobs().ClaimLease(ctx, job.ID, "other", now, now.Add(-time.Minute)); !ok || err != nil {
+		t.Fatalf("ClaimLease(expire): %v, %v", ok, err)
+	}
+	if err := ci.ResumeGenerateJobs(ctx); err != nil {
+		t.Fatalf("ResumeGenerateJobs: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stored, err := ci.jobs().FindByID(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		// 任务结束后释放租约
+		if stored.Status == JobCompleted && stored.Owner == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务未在限定时间内完成: %+v", stored)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n, _ := ci.CountInviteCodes(ctx, CreateInviteFilter{JobID: job.ID.Hex()}); n != 10 {
+		t.Errorf("任务应生成 10 个邀请码，实际 %d", n)
+	}
+}
+
+func TestStopGenerateJobs(t *testing.T) {
+	ctx := context.Background()
+	ci := newTestService()
+	job, err := ci.StartGenerateJob(ctx, 50000, GenerateOptions{}, "admin")
+	if err != nil {
+		t.Fatalf("StartGenerateJob: %v", err)
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := StopGenerateJobs(stopCtx); err != nil {
+		t.Fatalf("StopGenerateJobs: %v", err)
+	}
+	if _, ok := runningJobs.Load(job.ID); ok {
+		t.Error("StopGenerateJobs 返回后不应仍有执行中的任务")
+	}
+	// 被取消的任务释放租约，可由其他实例立即续跑
+	id, _ := primitive.ObjectIDFromHex(job.ID)
+	stored, err := ci.jobs().FindByID(ctx, id)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.Owner != "" {
+		t.Errorf("停止后应释放租约，实际 owner=%q", stored.Owner)
+	}
+}
+
+func TestExportRowEscapesFormulas(t *testing.T) {
+	row := ExportRow(InviteCode{ID: "id1", Code: "ABCD", Username: "=cmd|' /C calc'!A0", CampaignID: "@camp", MaxUses: 1})
+	if row[3] != "'=cmd|' /C calc'!A0" {
+		t.Errorf("以 = 开头的用户名应加单引号，实际 %q", row[3])
+	}
+	if row[8] != "'@camp" {
+		t.Errorf("以 @ 开头的活动应加单引号，实际 %q", row[8])
+	}
+	if row[0] != "id1" || row[1] != "ABCD" {
+		t.Errorf("普通内容不应修改: %q", row)
+	}
+}
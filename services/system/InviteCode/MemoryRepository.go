@@ -0,0 +1,497 @@
+package InviteCodeService
+
+import (
+	"bytes"
+	"context"
+	"github.com/StephenChristianW/go-movies-open/db/Repository"
+	"github.com/StephenChristianW/go-movies-open/services/ServiceUtils"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// =======================================
+//          邀请码内存存储
+// =======================================
+
+// MemoryInviteCodeRepository 进程内邀请码与兑换记录存储，用于测试；语义与 MongoDB 实现一致
+type MemoryInviteCodeRepository struct {
+	mu          sync.Mutex
+	codes       []InviteCodeInDB
+	redemptions []RedemptionInDB
+}
+
+// NewMemoryInviteCodeRepository 创建内存邀请码存储
+func NewMemoryInviteCodeRepository() *MemoryInviteCodeRepository {
+	return &MemoryInviteCodeRepository{}
+}
+
+func (r *MemoryInviteCodeRepository) Insert(_ context.Context, code CreateInviteCode) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insert(code)
+}
+
+func (r *MemoryInviteCodeRepository) InsertMany(_ context.Context, codes []CreateInviteCode) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	duplicates := 0
+	for _, code := range codes {
+		if _, err := r.insert(code); err != nil {
+			duplicates++
+		}
+	}
+	return duplicates, nil
+}
+
+func (r *MemoryInviteCodeRepository) insert(code CreateInviteCode) (primitive.ObjectID, error) {
+	if r.indexByCode(code.Code) >= 0 {
+		return primitive.NilObjectID, Repository.DuplicateKeyError("code_unique")
+	}
+	var doc InviteCodeInDB
+	if err := Repository.Convert(code, &doc); err != nil {
+		return primitive.NilObjectID, err
+	}
+	doc.ID = primitive.NewObjectID()
+	r.codes = append(r.codes, doc)
+	return doc.ID, nil
+}
+
+func (r *MemoryInviteCodeRepository) FindByID(_ context.Context, id primitive.ObjectID) (InviteCodeInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.codes {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return InviteCodeInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryInviteCodeRepository) FindByCode(_ context.Context, code string) (InviteCodeInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.indexByCode(code); i >= 0 {
+		return r.codes[i], nil
+	}
+	return InviteCodeInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryInviteCodeRepository) FindByIDs(_ context.Context, ids []primitive.ObjectID) ([]InviteCodeInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []InviteCodeInDB
+	for _, c := range r.codes {
+		if slices.Contains(ids, c.ID) {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+func (r *MemoryInviteCodeRepository) List(_ context.Context, filter CreateInviteFilter) ([]InviteCodeInDB, int, error) {
+	matched := r.match(filter, time.Now())
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].ExpiredTime.After(matched[j].ExpiredTime)
+	})
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+func (r *MemoryInviteCodeRepository) Count(_ context.Context, filter CreateInviteFilter) (int, error) {
+	return len(r.match(filter, time.Now())), nil
+}
+
+func (r *MemoryInviteCodeRepository) Each(ctx context.Context, filter CreateInviteFilter, fn func(code InviteCodeInDB) error) error {
+	matched := r.match(filter, time.Now())
+	sort.SliceStable(matched, func(i, j int) bool {
+		return bytes.Compare(matched[i].ID[:], matched[j].ID[:]) < 0
+	})
+	for _, c := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInviteCodeRepository) UpdateMany(_ context.Context, ids []primitive.ObjectID, set bson.M) (int, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	matched, modified := 0, 0
+	for i := range r.codes {
+		if !slices.Contains(ids, r.codes[i].ID) {
+			continue
+		}
+		matched++
+		changed, err := Repository.ApplySet(&r.codes[i], set)
+		if err != nil {
+			return matched, modified, err
+		}
+		if changed {
+			modified++
+		}
+	}
+	return matched, modified, nil
+}
+
+func (r *MemoryInviteCodeRepository) ExpireUnclaimed(_ context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for i := range r.codes {
+		c := &r.codes[i]
+		if c.UsedCount == 0 && c.Deleted == 1 && !c.ExpiredTime.After(before) {
+			c.Deleted = 2
+			c.IsExpired = 2
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MemoryInviteCodeRepository) Claim(_ context.Context, code string, now time.Time) (InviteCodeInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexByCode(code)
+	if i < 0 {
+		return InviteCodeInDB{}, Repository.ErrNotFound
+	}
+	c := &r.codes[i]
+	maxUses := effectiveMaxUses(c.MaxUses)
+	if c.Status != 1 || c.Deleted != 1 || !c.ExpiredTime.After(now) ||
+		(maxUses != UnlimitedUses && c.UsedCount >= maxUses) {
+		return InviteCodeInDB{}, Repository.ErrNotFound
+	}
+	c.UsedCount++
+	return *c, nil
+}
+
+func (r *MemoryInviteCodeRepository) Release(_ context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.codes {
+		c := &r.codes[i]
+		if c.ID == id && c.UsedCount > 0 {
+			c.UsedCount--
+			c.Status = 1
+			c.Username = ""
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInviteCodeRepository) Stats(_ context.Context, campaignID string, now time.Time) (CodeStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stats CodeStats
+	for _, c := range r.codes {
+		if c.CampaignID != campaignID {
+			continue
+		}
+		stats.Generated++
+		stats.Redeemed += c.UsedCount
+		if c.Status != 2 && !c.ExpiredTime.After(now) {
+			stats.Expired++
+		}
+	}
+	return stats, nil
+}
+
+func (r *MemoryInviteCodeRepository) InsertRedemption(_ context.Context, redemption RedemptionInDB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if redemption.ID.IsZero() {
+		redemption.ID = primitive.NewObjectID()
+	}
+	r.redemptions = append(r.redemptions, redemption)
+	return nil
+}
+
+func (r *MemoryInviteCodeRepository) DeleteRedemption(_ context.Context, codeID primitive.ObjectID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rd := range r.redemptions {
+		if rd.CodeID == codeID && rd.UserID == userID {
+			r.redemptions = slices.Delete(r.redemptions, i, i+1)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInviteCodeRepository) ListRedemptions(_ context.Context, filter RedemptionFilter) ([]RedemptionInDB, int, error) {
+	r.mu.Lock()
+	var matched []RedemptionInDB
+	for _, rd := range r.redemptions {
+		if (filter.Code == "" || rd.Code == filter.Code) && (filter.UserID == "" || rd.UserID == filter.UserID) {
+			matched = append(matched, rd)
+		}
+	}
+	r.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].RedeemedAt.After(matched[j].RedeemedAt)
+	})
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+func (r *MemoryInviteCodeRepository) indexByCode(code string) int {
+	for i, c := range r.codes {
+		if c.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// match 按 buildFilter 相同的规则筛选邀请码
+func (r *MemoryInviteCodeRepository) match(find CreateInviteFilter, now time.Time) []InviteCodeInDB {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(find.IDs) > 0 {
+		if ids := ServiceUtils.StringsToObjectIds(find.IDs); len(ids) > 0 {
+			var matched []InviteCodeInDB
+			for _, c := range r.codes {
+				if slices.Contains(ids, c.ID) {
+					matched = append(matched, c)
+				}
+			}
+			return matched
+		}
+	}
+
+	code := find.Code
+	if normalized, err := NormalizeCode(find.Code); err == nil {
+		code = normalized
+	}
+	begin, _ := UtilsTime.ParseFlexibleTime(find.BeginTime)
+	end, _ := UtilsTime.ParseFlexibleTime(find.EndTime)
+
+	var matched []InviteCodeInDB
+	for _, c := range r.codes {
+		switch {
+		case find.Code != "" && c.Code != code,
+			find.Username != "" && c.Username != find.Username,
+			find.Status != 0 && c.Status != find.Status,
+			find.CampaignID != "" && c.CampaignID != find.CampaignID,
+			find.JobID != "" && c.JobID != find.JobID,
+			find.IsExpired != 0 && expiredFlag(c.ExpiredTime, now) != find.IsExpired,
+			!begin.IsZero() && c.ExpiredTime.Before(begin),
+			!end.IsZero() && c.ExpiredTime.After(end):
+			continue
+		}
+		matched = append(matched, c)
+	}
+	return matched
+}
+
+// =======================================
+//          活动内存存储
+// =======================================
+
+// MemoryCampaignRepository 进程内邀请活动存储，用于测试
+type MemoryCampaignRepository struct {
+	mu        sync.Mutex
+	campaigns []CampaignInDB
+}
+
+// NewMemoryCampaignRepository 创建内存活动存储
+func NewMemoryCampaignRepository() *MemoryCampaignRepository {
+	return &MemoryCampaignRepository{}
+}
+
+func (r *MemoryCampaignRepository) Insert(_ context.Context, campaign CampaignInDB) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	campaign.ID = primitive.NewObjectID()
+	r.campaigns = append(r.campaigns, campaign)
+	return campaign.ID, nil
+}
+
+func (r *MemoryCampaignRepository) FindActive(_ context.Context, id primitive.ObjectID) (CampaignInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(id); i >= 0 {
+		return r.campaigns[i], nil
+	}
+	return CampaignInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryCampaignRepository) List(_ context.Context, filter CampaignFilter) ([]CampaignInDB, int, error) {
+	var name *regexp.Regexp
+	if filter.Name != "" {
+		var err error
+		if name, err = regexp.Compile("(?i)" + filter.Name); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	r.mu.Lock()
+	var matched []CampaignInDB
+	for _, c := range r.campaigns {
+		switch {
+		case c.Deleted != 1,
+			name != nil && !name.MatchString(c.Name),
+			filter.Channel != "" && c.Channel != filter.Channel,
+			filter.Tag != "" && !slices.Contains(c.Tags, filter.Tag):
+			continue
+		}
+		matched = append(matched, c)
+	}
+	r.mu.Unlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].CreateAt.After(matched[j].CreateAt)
+	})
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+func (r *MemoryCampaignRepository) Reserve(_ context.Context, id primitive.ObjectID) (CampaignInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return CampaignInDB{}, Repository.ErrNotFound
+	}
+	c := &r.campaigns[i]
+	if c.MaxRedemptions != 0 && c.Redeemed >= c.MaxRedemptions {
+		return CampaignInDB{}, Repository.ErrNotFound
+	}
+	before := *c
+	c.Redeemed++
+	return before, nil
+}
+
+func (r *MemoryCampaignRepository) Release(_ context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id && r.campaigns[i].Redeemed > 0 {
+			r.campaigns[i].Redeemed--
+		}
+	}
+	return nil
+}
+
+// index 查找未删除的活动
+func (r *MemoryCampaignRepository) index(id primitive.ObjectID) int {
+	for i, c := range r.campaigns {
+		if c.ID == id && c.Deleted == 1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// =======================================
+//          批量生成任务内存存储
+// =======================================
+
+// MemoryGenerateJobRepository 进程内批量生成任务存储，用于测试
+type MemoryGenerateJobRepository struct {
+	mu   sync.Mutex
+	jobs []GenerateJobInDB
+}
+
+// NewMemoryGenerateJobRepository 创建内存任务存储
+func NewMemoryGenerateJobRepository() *MemoryGenerateJobRepository {
+	return &MemoryGenerateJobRepository{}
+}
+
+func (r *MemoryGenerateJobRepository) Insert(_ context.Context, job GenerateJobInDB) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = primitive.NewObjectID()
+	r.jobs = append(r.jobs, job)
+	return job.ID, nil
+}
+
+func (r *MemoryGenerateJobRepository) FindByID(_ context.Context, id primitive.ObjectID) (GenerateJobInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return GenerateJobInDB{}, Repository.ErrNotFound
+}
+
+func (r *MemoryGenerateJobRepository) List(_ context.Context, filter GenerateJobFilter) ([]GenerateJobInDB, int, error) {
+	r.mu.Lock()
+	var matched []GenerateJobInDB
+	for _, job := range r.jobs {
+		if filter.Status == "" || job.Status == filter.Status {
+			matched = append(matched, job)
+		}
+	}
+	r.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].CreateAt.After(matched[j].CreateAt)
+	})
+	return Repository.Paginate(matched, filter.Page, filter.PageSize), len(matched), nil
+}
+
+func (r *MemoryGenerateJobRepository) ListUnfinished(_ context.Context) ([]GenerateJobInDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var jobs []GenerateJobInDB
+	for _, job := range r.jobs {
+		if isUnfinished(job.Status) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (r *MemoryGenerateJobRepository) UpdateUnfinished(_ context.Context, id primitive.ObjectID, set bson.M) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.jobs {
+		if r.jobs[i].ID == id && isUnfinished(r.jobs[i].Status) {
+			_, err := Repository.ApplySet(&r.jobs[i], set)
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryGenerateJobRepository) ClaimLease(_ context.Context, id primitive.ObjectID, owner string, now, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.jobs {
+		job := &r.jobs[i]
+		if job.ID != id || !isUnfinished(job.Status) {
+			continue
+		}
+		if job.Owner != owner && job.LeaseUntil.After(now) {
+			return false, nil
+		}
+		job.Owner, job.LeaseUntil = owner, until
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *MemoryGenerateJobRepository) ReleaseLease(_ context.Context, id primitive.ObjectID, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.jobs {
+		if r.jobs[i].ID == id && r.jobs[i].Owner == owner {
+			r.jobs[i].Owner, r.jobs[i].LeaseUntil = "", time.Time{}
+		}
+	}
+	return nil
+}
+
+func isUnfinished(status string) bool {
+	return status == JobPending || status == JobRunning
+}
@@ -0,0 +1,132 @@
+package InviteCodeService
+
+import (
+	"context"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/utils/UtilsTime"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// 旧版本写入的驼峰字段名
+const (
+	legacyIsExpired   = "isExpired"
+	legacyExpiredTime = "expiredTime"
+)
+
+// MigrateExpirySchema 将旧数据统一为 is_expired / expired_time（时间类型）
+// 旧数据可能使用驼峰字段 isExpired / expiredTime，或以 "2006-01-02 15:04:05" 字符串存储过期时间；
+// 可重复执行，已迁移的文档不会被匹配，返回本次迁移的文档数量；由 migrations 包在启动时调用
+func MigrateExpirySchema(ctx context.Context) (int, error) {
+	collection := collections.GetInviteCodeCollection()
+	cursor, err := collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{legacyIsExpired: bson.M{"$exists": true}},
+		bson.M{legacyExpiredTime: bson.M{"$exists": true}},
+		bson.M{fieldExpiredTime: bson.M{"$type": "string"}},
+		bson.M{fieldExpiredTime: bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return migrated, err
+		}
+		update, err := legacyExpiryUpdate(doc)
+		if err != nil {
+			logger.WarnContext(ctx, "邀请码迁移失败", "id", fmt.Sprint(doc["_id"]), logging.Err(err))
+			continue
+		}
+		if update == nil {
+			continue
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, update); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// RevertExpirySchema 回滚 MigrateExpirySchema：将 expired_time 还原为本地时区的 "2006-01-02 15:04:05" 字符串
+func RevertExpirySchema(ctx context.Context) error {
+	_, err := collections.GetInviteCodeCollection().UpdateMany(ctx,
+		bson.M{fieldExpiredTime: bson.M{"$type": "date"}},
+		bson.A{bson.M{"$set": bson.M{fieldExpiredTime: bson.M{"$dateToString": bson.M{
+			"date":     "$" + fieldExpiredTime,
+			"format":   "%Y-%m-%d %H:%M:%S",
+			"timezone": time.Now().Format("-07:00"),
+		}}}}},
+	)
+	return err
+}
+
+// legacyExpiryUpdate 根据旧文档计算迁移所需的更新，无需迁移时返回 nil
+// 过期时间优先取 expired_time，其次取 expiredTime；都缺失时视为立即过期
+func legacyExpiryUpdate(doc bson.M) (bson.M, error) {
+	set := bson.M{}
+	unset := bson.M{}
+
+	raw, ok := doc[fieldExpiredTime]
+	if !ok || raw == nil {
+		raw = doc[legacyExpiredTime]
+	}
+	switch v := raw.(type) {
+	case primitive.DateTime:
+		if _, ok := doc[fieldExpiredTime].(primitive.DateTime); !ok {
+			set[fieldExpiredTime] = v.Time()
+		}
+	case time.Time:
+		if _, ok := doc[fieldExpiredTime].(time.Time); !ok {
+			set[fieldExpiredTime] = v
+		}
+	case string:
+		t, err := UtilsTime.ParseFlexibleTime(v)
+		if err != nil {
+			return nil, err
+		}
+		if t.IsZero() {
+			t = time.Now()
+		}
+		set[fieldExpiredTime] = t
+	case nil:
+		set[fieldExpiredTime] = time.Now()
+	default:
+		return nil, fmt.Errorf("无法识别的过期时间类型: %T", raw)
+	}
+
+	if _, ok := doc[fieldIsExpired]; !ok {
+		if v, ok := doc[legacyIsExpired]; ok {
+			set[fieldIsExpired] = v
+		} else {
+			set[fieldIsExpired] = 1
+		}
+	}
+	for _, legacy := range []string{legacyIsExpired, legacyExpiredTime} {
+		if _, ok := doc[legacy]; ok {
+			unset[legacy] = ""
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return nil, nil
+	}
+	return update, nil
+}
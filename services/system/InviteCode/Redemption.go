@@ -0,0 +1,169 @@
+package InviteCodeService
+
+import (
+	"context"
+	"errors"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// UnlimitedUses 邀请码不限使用次数
+const UnlimitedUses = -1
+
+// 邀请码兑换失败原因
+var (
+	ErrInviteCodeNotFound    = errors.New("邀请码不存在")
+	ErrInviteCodeDeleted     = errors.New("邀请码已失效")
+	ErrInviteCodeExpired     = errors.New("邀请码已过期")
+	ErrInviteCodeUnavailable = errors.New("邀请码已被使用")
+)
+
+// =======================================
+//          邀请码兑换
+// =======================================
+
+// ClaimInviteCode 为注册用户兑换一次邀请码
+// 使用条件更新原子地校验邀请码存在、未删除、未过期且仍有剩余次数，并增加使用次数，
+// 并发注册不会超出 max_uses；单次邀请码兑换后绑定用户名，次数用完后状态置为 2，
+// 每次兑换写入一条兑换记录。ctx 可以是事务会话上下文，所有写入随事务一起提交或回滚；
+// 不在事务中时，增加使用次数之后的写入失败会归还本次占用的次数，调用方无需再补偿
+func (ci CreateInviteCode) ClaimInviteCode(ctx context.Context, code, userID, username string) (InviteCodeInDB, error) {
+	claimed, err := ci.codes().Claim(ctx, code, time.Now())
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return claimed, ci.unclaimableReason(ctx, code)
+		}
+		return claimed, err
+	}
+
+	maxUses := effectiveMaxUses(claimed.MaxUses)
+	set := bson.M{}
+	if maxUses == 1 {
+		set["username"] = username // 单次邀请码保持与用户一一绑定
+	}
+	if maxUses != UnlimitedUses && claimed.UsedCount >= maxUses {
+		set["status"] = 2 // 使用次数已用完
+	}
+	if len(set) > 0 {
+		if _, _, err = ci.codes().UpdateMany(ctx, []primitive.ObjectID{claimed.ID}, set); err != nil {
+			ci.releaseClaim(ctx, claimed)
+			return claimed, err
+		}
+	}
+
+	err = ci.codes().InsertRedemption(ctx, RedemptionInDB{
+		CodeID:     claimed.ID,
+		Code:       claimed.Code,
+		CampaignID: claimed.CampaignID,
+		UserID:     userID,
+		Username:   username,
+		RedeemedAt: time.Now(),
+	})
+	if err != nil {
+		ci.releaseClaim(ctx, claimed)
+		return claimed, err
+	}
+	return claimed, nil
+}
+
+// releaseClaim 兑换的后续写入失败时归还已占用的使用次数；在事务中时随事务回滚，归还失败只记录日志
+func (ci CreateInviteCode) releaseClaim(ctx context.Context, claimed InviteCodeInDB) {
+	if err := ci.codes().Release(ctx, claimed.ID); err != nil {
+		logger.WarnContext(ctx, "归还邀请码使用次数失败", "code", claimed.Code, logging.Err(err))
+	}
+}
+
+// ReleaseInviteCode 撤销一次兑换，归还使用次数并删除兑换记录
+// 用于不支持事务时注册失败的补偿回滚
+func (ci CreateInviteCode) ReleaseInviteCode(ctx context.Context, claimed InviteCodeInDB, userID string) error {
+	if err := ci.codes().Release(ctx, claimed.ID); err != nil {
+		return err
+	}
+	return ci.codes().DeleteRedemption(ctx, claimed.ID, userID)
+}
+
+// unclaimableReason 兑换失败后查询邀请码当前状态，返回具体原因
+func (ci CreateInviteCode) unclaimableReason(ctx context.Context, code string) error {
+	inviteCode, err := ci.codes().FindByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInviteCodeNotFound
+		}
+		return err
+	}
+	if inviteCode.Deleted != 1 {
+		return ErrInviteCodeDeleted
+	}
+	if expiredFlag(inviteCode.ExpiredTime, time.Now()) == 2 {
+		return ErrInviteCodeExpired
+	}
+	return ErrInviteCodeUnavailable
+}
+
+// claimFilter 可兑换条件：存在、可用、未删除、未过期且仍有剩余次数
+func claimFilter(code string, now time.Time) bson.M {
+	return bson.M{
+		"code":           code,
+		"status":         1,
+		"deleted":        1,
+		fieldExpiredTime: expiryCondition(now, false),
+		"$or": bson.A{
+			bson.M{"max_uses": UnlimitedUses},
+			// 旧数据没有 max_uses / used_count 字段，视为单次邀请码
+			bson.M{"$expr": bson.M{"$lt": bson.A{
+				bson.M{"$ifNull": bson.A{"$used_count", 0}},
+				bson.M{"$ifNull": bson.A{"$max_uses", 1}},
+			}}},
+		},
+	}
+}
+
+// ListRedemptions 分页获取邀请码兑换记录
+func (ci CreateInviteCode) ListRedemptions(ctx context.Context, filter RedemptionFilter) (services.Pagination, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	records, total, err := ci.codes().ListRedemptions(ctx, filter)
+	if err != nil {
+		return services.Pagination{}, err
+	}
+
+	var redemptions []Redemption
+	for _, r := range records {
+		redemptions = append(redemptions, Redemption{
+			ID:         r.ID.Hex(),
+			Code:       r.Code,
+			CampaignID: r.CampaignID,
+			UserID:     r.UserID,
+			Username:   r.Username,
+			RedeemedAt: r.RedeemedAt,
+		})
+	}
+
+	totalPages := (total + filter.PageSize - 1) / filter.PageSize
+	return services.Pagination{
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		Data:       redemptions,
+	}, nil
+}
+
+// effectiveMaxUses 返回邀请码实际的最大使用次数，旧数据（0）视为单次
+func effectiveMaxUses(maxUses int) int {
+	if maxUses == 0 {
+		return 1
+	}
+	return maxUses
+}
+
+// IsSingleUse 邀请码是否为单次邀请码（与唯一用户绑定）
+func IsSingleUse(code InviteCodeInDB) bool {
+	return effectiveMaxUses(code.MaxUses) == 1
+}
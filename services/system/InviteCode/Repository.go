@@ -0,0 +1,484 @@
+package InviteCodeService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/collections"
+	"github.com/StephenChristianW/go-movies-open/db/MongoDBServices/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// Repositories 邀请码服务依赖的存储，字段为 nil 时使用 MongoDB 实现
+type Repositories struct {
+	Codes     InviteCodeRepository
+	Campaigns CampaignRepository
+	Jobs      GenerateJobRepository
+}
+
+// NewMemoryRepositories 创建一组内存存储，用于测试
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Codes:     NewMemoryInviteCodeRepository(),
+		Campaigns: NewMemoryCampaignRepository(),
+		Jobs:      NewMemoryGenerateJobRepository(),
+	}
+}
+
+func (ci CreateInviteCode) codes() InviteCodeRepository {
+	if ci.Repos != nil && ci.Repos.Codes != nil {
+		return ci.Repos.Codes
+	}
+	return mongoInviteCodeRepository{}
+}
+
+func (ci CreateInviteCode) campaigns() CampaignRepository {
+	if ci.Repos != nil && ci.Repos.Campaigns != nil {
+		return ci.Repos.Campaigns
+	}
+	return mongoCampaignRepository{}
+}
+
+func (ci CreateInviteCode) jobs() GenerateJobRepository {
+	if ci.Repos != nil && ci.Repos.Jobs != nil {
+		return ci.Repos.Jobs
+	}
+	return mongoGenerateJobRepository{}
+}
+
+// CodeStats 活动下邀请码的统计
+type CodeStats struct {
+	Generated int `bson:"generated"`
+	Redeemed  int `bson:"redeemed"`
+	Expired   int `bson:"expired"`
+}
+
+// InviteCodeRepository 邀请码与兑换记录存储
+type InviteCodeRepository interface {
+	// Insert 插入邀请码，邀请码重复时返回重复键错误
+	Insert(ctx context.Context, code CreateInviteCode) (primitive.ObjectID, error)
+	// InsertMany 无序批量插入，重复的邀请码跳过，返回因重复未插入的数量
+	InsertMany(ctx context.Context, codes []CreateInviteCode) (duplicates int, err error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (InviteCodeInDB, error)
+	FindByCode(ctx context.Context, code string) (InviteCodeInDB, error)
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]InviteCodeInDB, error)
+	// List 按过期时间倒序分页查询，返回当前页与总数
+	List(ctx context.Context, filter CreateInviteFilter) ([]InviteCodeInDB, int, error)
+	Count(ctx context.Context, filter CreateInviteFilter) (int, error)
+	// Each 按创建顺序遍历，fn 返回错误时停止并返回该错误
+	Each(ctx context.Context, filter CreateInviteFilter, fn func(code InviteCodeInDB) error) error
+	// UpdateMany 按ID批量更新字段，返回匹配与实际修改的数量
+	UpdateMany(ctx context.Context, ids []primitive.ObjectID, set bson.M) (matched, modified int, err error)
+	// ExpireUnclaimed 将过期时间不晚于 before 且从未被使用的邀请码标记为已过期并删除，返回数量
+	ExpireUnclaimed(ctx context.Context, before time.Time) (int, error)
+	// Claim 原子地校验邀请码可兑换并增加一次使用次数，返回增加后的邀请码；不可兑换时返回 mongo.ErrNoDocuments
+	Claim(ctx context.Context, code string, now time.Time) (InviteCodeInDB, error)
+	// Release 归还一次使用次数并解除绑定
+	Release(ctx context.Context, id primitive.ObjectID) error
+	// Stats 统计活动下邀请码的生成、兑换与过期数量
+	Stats(ctx context.Context, campaignID string, now time.Time) (CodeStats, error)
+
+	InsertRedemption(ctx context.Context, redemption RedemptionInDB) error
+	DeleteRedemption(ctx context.Context, codeID primitive.ObjectID, userID string) error
+	// ListRedemptions 按兑换时间倒序分页查询，返回当前页与总数
+	ListRedemptions(ctx context.Context, filter RedemptionFilter) ([]RedemptionInDB, int, error)
+}
+
+// CampaignRepository 邀请活动存储
+type CampaignRepository interface {
+	Insert(ctx context.Context, campaign CampaignInDB) (primitive.ObjectID, error)
+	// FindActive 查询未删除的活动，不存在返回 mongo.ErrNoDocuments
+	FindActive(ctx context.Context, id primitive.ObjectID) (CampaignInDB, error)
+	// List 查询未删除的活动，按创建时间倒序分页，返回当前页与总数
+	List(ctx context.Context, filter CampaignFilter) ([]CampaignInDB, int, error)
+	// Reserve 原子地占用一个兑换名额，返回占用前的活动；活动不存在或名额已满返回 mongo.ErrNoDocuments
+	Reserve(ctx context.Context, id primitive.ObjectID) (CampaignInDB, error)
+	// Release 归还一个兑换名额
+	Release(ctx context.Context, id primitive.ObjectID) error
+}
+
+// GenerateJobRepository 批量生成任务存储
+type GenerateJobRepository interface {
+	Insert(ctx context.Context, job GenerateJobInDB) (primitive.ObjectID, error)
+	// FindByID 不存在返回 mongo.ErrNoDocuments
+	FindByID(ctx context.Context, id primitive.ObjectID) (GenerateJobInDB, error)
+	// List 按创建时间倒序分页查询，返回当前页与总数
+	List(ctx context.Context, filter GenerateJobFilter) ([]GenerateJobInDB, int, error)
+	// ListUnfinished 查询 pending / running 的任务
+	ListUnfinished(ctx context.Context) ([]GenerateJobInDB, error)
+	// UpdateUnfinished 仅当任务仍为 pending / running 时更新字段，返回是否匹配
+	UpdateUnfinished(ctx context.Context, id primitive.ObjectID, set bson.M) (bool, error)
+	// ClaimLease 未结束的任务没有租约、租约已过期或本身为 owner 时，将租约设为 owner 持有至 until，返回是否取得；
+	// 同时用于续约
+	ClaimLease(ctx context.Context, id primitive.ObjectID, owner string, now, until time.Time) (bool, error)
+	// ReleaseLease 释放 owner 持有的租约，使其他实例可立即续跑
+	ReleaseLease(ctx context.Context, id primitive.ObjectID, owner string) error
+}
+
+// =======================================
+//          MongoDB 实现
+// =======================================
+
+type mongoInviteCodeRepository struct{}
+
+func (mongoInviteCodeRepository) Insert(ctx context.Context, code CreateInviteCode) (primitive.ObjectID, error) {
+	res, err := collections.GetInviteCodeCollection().InsertOne(ctx, code)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertedID(res.InsertedID)
+}
+
+func (mongoInviteCodeRepository) InsertMany(ctx context.Context, codes []CreateInviteCode) (int, error) {
+	docs := make([]interface{}, len(codes))
+	for i := range codes {
+		docs[i] = codes[i]
+	}
+	_, err := collections.GetInviteCodeCollection().InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return 0, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return 0, err
+	}
+	for _, we := range bulkErr.WriteErrors {
+		if !strings.Contains(we.Message, "E11000") {
+			return 0, err
+		}
+	}
+	return len(bulkErr.WriteErrors), nil
+}
+
+func (mongoInviteCodeRepository) FindByID(ctx context.Context, id primitive.ObjectID) (InviteCodeInDB, error) {
+	var code InviteCodeInDB
+	err := collections.GetInviteCodeCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&code)
+	return code, err
+}
+
+func (mongoInviteCodeRepository) FindByCode(ctx context.Context, code string) (InviteCodeInDB, error) {
+	var inviteCode InviteCodeInDB
+	err := collections.GetInviteCodeCollection().FindOne(ctx, bson.M{"code": code}).Decode(&inviteCode)
+	return inviteCode, err
+}
+
+func (mongoInviteCodeRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]InviteCodeInDB, error) {
+	cursor, err := collections.GetInviteCodeCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var codes []InviteCodeInDB
+	err = cursor.All(ctx, &codes)
+	return codes, err
+}
+
+func (mongoInviteCodeRepository) List(ctx context.Context, filter CreateInviteFilter) ([]InviteCodeInDB, int, error) {
+	collection := collections.GetInviteCodeCollection()
+	conditions := CreateInviteCode{}.buildFilter(filter)
+	cursor, err := collection.Find(ctx, conditions, db.CalculatePagination(filter.Page, filter.PageSize, fieldExpiredTime, -1))
+	if err != nil {
+		return nil, 0, err
+	}
+	var codes []InviteCodeInDB
+	if err := cursor.All(ctx, &codes); err != nil {
+		return nil, 0, err
+	}
+	total, err := collection.CountDocuments(ctx, conditions)
+	return codes, int(total), err
+}
+
+func (mongoInviteCodeRepository) Count(ctx context.Context, filter CreateInviteFilter) (int, error) {
+	total, err := collections.GetInviteCodeCollection().CountDocuments(ctx, CreateInviteCode{}.buildFilter(filter))
+	return int(total), err
+}
+
+func (mongoInviteCodeRepository) Each(ctx context.Context, filter CreateInviteFilter, fn func(code InviteCodeInDB) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(1000)
+	cursor, err := collections.GetInviteCodeCollection().Find(ctx, CreateInviteCode{}.buildFilter(filter), opts)
+	if err != nil {
+		return err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	for cursor.Next(ctx) {
+		var code InviteCodeInDB
+		if err := cursor.Decode(&code); err != nil {
+			return err
+		}
+		if err := fn(code); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (mongoInviteCodeRepository) UpdateMany(ctx context.Context, ids []primitive.ObjectID, set bson.M) (int, int, error) {
+	res, err := collections.GetInviteCodeCollection().UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": set})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(res.MatchedCount), int(res.ModifiedCount), nil
+}
+
+func (mongoInviteCodeRepository) ExpireUnclaimed(ctx context.Context, before time.Time) (int, error) {
+	res, err := collections.GetInviteCodeCollection().UpdateMany(ctx, expireFilter(before),
+		bson.M{"$set": bson.M{"deleted": 2, fieldIsExpired: 2}}, // 标记为已删除、已过期
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.ModifiedCount), nil
+}
+
+func (mongoInviteCodeRepository) Claim(ctx context.Context, code string, now time.Time) (InviteCodeInDB, error) {
+	var claimed InviteCodeInDB
+	err := collections.GetInviteCodeCollection().FindOneAndUpdate(ctx,
+		claimFilter(code, now),
+		bson.M{"$inc": bson.M{"used_count": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&claimed)
+	return claimed, err
+}
+
+func (mongoInviteCodeRepository) Release(ctx context.Context, id primitive.ObjectID) error {
+	_, err := collections.GetInviteCodeCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "used_count": bson.M{"$gt": 0}},
+		bson.M{
+			"$inc": bson.M{"used_count": -1},
+			"$set": bson.M{"status": 1, "username": ""},
+		},
+	)
+	return err
+}
+
+func (mongoInviteCodeRepository) Stats(ctx context.Context, campaignID string, now time.Time) (CodeStats, error) {
+	var stats CodeStats
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"campaign_id": campaignID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       nil,
+			"generated": bson.M{"$sum": 1},
+			// 多次邀请码按使用次数计；旧数据没有 used_count，已绑定即计一次
+			"redeemed": bson.M{"$sum": bson.M{"$ifNull": bson.A{
+				"$used_count",
+				bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", 2}}, 1, 0}},
+			}}},
+			"expired": bson.M{"$sum": bson.M{
+				"$cond": bson.A{bson.M{"$and": bson.A{
+					bson.M{"$ne": bson.A{"$status", 2}},
+					bson.M{"$lte": bson.A{"$" + fieldExpiredTime, now}},
+				}}, 1, 0},
+			}},
+		}}},
+	}
+	cursor, err := collections.GetInviteCodeCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return stats, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, cursor.Err()
+}
+
+func (mongoInviteCodeRepository) InsertRedemption(ctx context.Context, redemption RedemptionInDB) error {
+	_, err := collections.GetInviteRedemptionCollection().InsertOne(ctx, redemption)
+	return err
+}
+
+func (mongoInviteCodeRepository) DeleteRedemption(ctx context.Context, codeID primitive.ObjectID, userID string) error {
+	_, err := collections.GetInviteRedemptionCollection().DeleteOne(ctx, bson.M{"code_id": codeID, "user_id": userID})
+	return err
+}
+
+func (mongoInviteCodeRepository) ListRedemptions(ctx context.Context, filter RedemptionFilter) ([]RedemptionInDB, int, error) {
+	collection := collections.GetInviteRedemptionCollection()
+	conditions := bson.M{}
+	if filter.Code != "" {
+		conditions["code"] = filter.Code
+	}
+	if filter.UserID != "" {
+		conditions["user_id"] = filter.UserID
+	}
+
+	cursor, err := collection.Find(ctx, conditions, db.CalculatePagination(filter.Page, filter.PageSize, "redeemed_at", -1))
+	if err != nil {
+		return nil, 0, err
+	}
+	var redemptions []RedemptionInDB
+	if err := cursor.All(ctx, &redemptions); err != nil {
+		return nil, 0, err
+	}
+	total, err := collection.CountDocuments(ctx, conditions)
+	return redemptions, int(total), err
+}
+
+type mongoCampaignRepository struct{}
+
+func (mongoCampaignRepository) Insert(ctx context.Context, campaign CampaignInDB) (primitive.ObjectID, error) {
+	res, err := collections.GetInviteCampaignCollection().InsertOne(ctx, campaign)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertedID(res.InsertedID)
+}
+
+func (mongoCampaignRepository) FindActive(ctx context.Context, id primitive.ObjectID) (CampaignInDB, error) {
+	var campaign CampaignInDB
+	err := collections.GetInviteCampaignCollection().FindOne(ctx, bson.M{"_id": id, "deleted": 1}).Decode(&campaign)
+	return campaign, err
+}
+
+func (mongoCampaignRepository) List(ctx context.Context, filter CampaignFilter) ([]CampaignInDB, int, error) {
+	collection := collections.GetInviteCampaignCollection()
+	conditions := bson.M{"deleted": 1}
+	if filter.Name != "" {
+		conditions["name"] = bson.M{"$regex": filter.Name, "$options": "i"}
+	}
+	if filter.Channel != "" {
+		conditions["channel"] = filter.Channel
+	}
+	if filter.Tag != "" {
+		conditions["tags"] = filter.Tag
+	}
+
+	cursor, err := collection.Find(ctx, conditions, db.CalculatePagination(filter.Page, filter.PageSize, "create_at", -1))
+	if err != nil {
+		return nil, 0, err
+	}
+	var campaigns []CampaignInDB
+	if err := cursor.All(ctx, &campaigns); err != nil {
+		return nil, 0, err
+	}
+	total, err := collection.CountDocuments(ctx, conditions)
+	return campaigns, int(total), err
+}
+
+func (mongoCampaignRepository) Reserve(ctx context.Context, id primitive.ObjectID) (CampaignInDB, error) {
+	// 条件更新保证并发注册时不会超出配额
+	var campaign CampaignInDB
+	err := collections.GetInviteCampaignCollection().FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":     id,
+			"deleted": 1,
+			"$or": bson.A{
+				bson.M{"max_redemptions": 0},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$redeemed", "$max_redemptions"}}},
+			},
+		},
+		bson.M{"$inc": bson.M{"redeemed": 1}},
+	).Decode(&campaign)
+	return campaign, err
+}
+
+func (mongoCampaignRepository) Release(ctx context.Context, id primitive.ObjectID) error {
+	_, err := collections.GetInviteCampaignCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "redeemed": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"redeemed": -1}},
+	)
+	return err
+}
+
+type mongoGenerateJobRepository struct{}
+
+// unfinished 未结束的任务状态
+var unfinished = bson.M{"$in": bson.A{JobPending, JobRunning}}
+
+func (mongoGenerateJobRepository) Insert(ctx context.Context, job GenerateJobInDB) (primitive.ObjectID, error) {
+	res, err := collections.GetInviteGenerateJobCollection().InsertOne(ctx, job)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return insertedID(res.InsertedID)
+}
+
+func (mongoGenerateJobRepository) FindByID(ctx context.Context, id primitive.ObjectID) (GenerateJobInDB, error) {
+	var job GenerateJobInDB
+	err := collections.GetInviteGenerateJobCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	return job, err
+}
+
+func (mongoGenerateJobRepository) List(ctx context.Context, filter GenerateJobFilter) ([]GenerateJobInDB, int, error) {
+	collection := collections.GetInviteGenerateJobCollection()
+	conditions := bson.M{}
+	if filter.Status != "" {
+		conditions["status"] = filter.Status
+	}
+
+	cursor, err := collection.Find(ctx, conditions, db.CalculatePagination(filter.Page, filter.PageSize, "create_at", -1))
+	if err != nil {
+		return nil, 0, err
+	}
+	var jobs []GenerateJobInDB
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, 0, err
+	}
+	total, err := collection.CountDocuments(ctx, conditions)
+	return jobs, int(total), err
+}
+
+func (mongoGenerateJobRepository) ListUnfinished(ctx context.Context) ([]GenerateJobInDB, error) {
+	cursor, err := collections.GetInviteGenerateJobCollection().Find(ctx, bson.M{"status": unfinished})
+	if err != nil {
+		return nil, err
+	}
+	var jobs []GenerateJobInDB
+	err = cursor.All(ctx, &jobs)
+	return jobs, err
+}
+
+func (mongoGenerateJobRepository) UpdateUnfinished(ctx context.Context, id primitive.ObjectID, set bson.M) (bool, error) {
+	res, err := collections.GetInviteGenerateJobCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "status": unfinished},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (mongoGenerateJobRepository) ClaimLease(ctx context.Context, id primitive.ObjectID, owner string, now, until time.Time) (bool, error) {
+	err := collections.GetInviteGenerateJobCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": unfinished, "$or": bson.A{
+			bson.M{"lease_until": bson.M{"$exists": false}},
+			bson.M{"lease_until": bson.M{"$lte": now}},
+			bson.M{"owner": owner},
+		}},
+		bson.M{"$set": bson.M{"owner": owner, "lease_until": until}},
+	).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (mongoGenerateJobRepository) ReleaseLease(ctx context.Context, id primitive.ObjectID, owner string) error {
+	_, err := collections.GetInviteGenerateJobCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "owner": owner},
+		bson.M{"$unset": bson.M{"owner": "", "lease_until": ""}},
+	)
+	return err
+}
+
+// insertedID 取出插入结果中的 ObjectID
+func insertedID(id interface{}) (primitive.ObjectID, error) {
+	if oid, ok := id.(primitive.ObjectID); ok {
+		return oid, nil
+	}
+	return primitive.NilObjectID, fmt.Errorf("unexpected InsertedID type: %T", id)
+}
@@ -1,14 +1,29 @@
 package InviteCodeService
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// 邀请码集合中的过期相关字段名，查询、排序与更新统一引用，与下方结构体的 bson 标签保持一致
+const (
+	fieldIsExpired   = "is_expired"
+	fieldExpiredTime = "expired_time"
+)
 
 type CreateInviteCode struct {
-	Code        string `json:"code" bson:"code"`                // 邀请码
-	Username    string `json:"username" bson:"username"`        // 绑定的用户名，""为未绑定
-	Status      int    `json:"status" bson:"status"`            // 使用状态 1 已使用 2 未过期
-	IsExpired   int    `json:"expired" bson:"is_expired"`       // 是否过期 1 未过期 2 已过期
-	ExpiredTime string `json:"expiredTime" bson:"expired_time"` // 过期时间字符串
-	Deleted     int    `json:"deleted" bson:"deleted"`          // 是否删除 1 未删除 2 已删除
+	Code        string    `json:"code" bson:"code"`                // 邀请码
+	Username    string    `json:"username" bson:"username"`        // 绑定的用户名，""为未绑定
+	Status      int       `json:"status" bson:"status"`            // 使用状态 1 已使用 2 未过期
+	IsExpired   int       `json:"expired" bson:"is_expired"`       // 是否过期 1 未过期 2 已过期
+	ExpiredTime time.Time `json:"expiredTime" bson:"expired_time"` // 过期时间
+	Deleted     int       `json:"deleted" bson:"deleted"`          // 是否删除 1 未删除 2 已删除
+	CampaignID  string    `json:"campaign_id" bson:"campaign_id"`  // 所属活动ID，""为无活动
+	MaxUses     int       `json:"max_uses" bson:"max_uses"`        // 最大使用次数 1 单次 -1 不限
+	UsedCount   int       `json:"used_count" bson:"used_count"`    // 已使用次数
+	JobID       string    `json:"job_id" bson:"job_id,omitempty"`  // 批量生成任务ID，单个生成为空
+
+	Repos *Repositories `json:"-" bson:"-"` // 服务使用的存储，nil 时使用 MongoDB
 }
 
 type UpdateInviteCode struct {
@@ -18,12 +33,14 @@ type UpdateInviteCode struct {
 }
 
 type CreateInviteFilter struct {
-	IDs       []string `json:"ids" bson:"_ids"`
-	Code      string   `json:"code" bson:"code"`          // 邀请码
-	Username  string   `json:"username" bson:"username"`  // 绑定的用户名，""为未绑定
-	Status    int      `json:"status" bson:"status"`      // 使用状态 1 已使用 2 未过期
-	IsExpired int      `json:"expired" bson:"is_expired"` // 是否过期 1 未过期 2 已过期
-	Deleted   int      `json:"deleted" bson:"deleted"`    // 是否删除 1 未删除 2 已删除
+	IDs        []string `json:"ids" bson:"_ids"`
+	Code       string   `json:"code" bson:"code"`               // 邀请码
+	Username   string   `json:"username" bson:"username"`       // 绑定的用户名，""为未绑定
+	Status     int      `json:"status" bson:"status"`           // 使用状态 1 已使用 2 未过期
+	IsExpired  int      `json:"expired" bson:"is_expired"`      // 是否过期 1 未过期 2 已过期
+	Deleted    int      `json:"deleted" bson:"deleted"`         // 是否删除 1 未删除 2 已删除
+	CampaignID string   `json:"campaign_id" bson:"campaign_id"` // 所属活动ID
+	JobID      string   `json:"job_id" bson:"job_id"`           // 批量生成任务ID
 
 	BeginTime string `json:"beginTime" bson:"beginTime"`
 	EndTime   string `json:"endTime" bson:"endTime"`
@@ -33,24 +50,185 @@ type CreateInviteFilter struct {
 
 type InviteCodeInDB struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	Code        string             `json:"code" bson:"code" `              // 邀请码
-	Username    string             `json:"username" bson:"username"`       // 绑定的用户名，""为未绑定
-	Status      int                `json:"status" bson:"status"`           // 使用状态 1 已使用 2 未过期
-	IsExpired   int                `json:"expired" bson:"isExpired"`       // 是否过期 1 未过期 2 已过期
-	ExpiredTime string             `json:"expiredTime" bson:"expiredTime"` // 过期时间字符串
-	Deleted     int                `json:"deleted" bson:"deleted"`         // 是否删除 1 未删除 2 已删除
+	Code        string             `json:"code" bson:"code" `               // 邀请码
+	Username    string             `json:"username" bson:"username"`        // 绑定的用户名，""为未绑定
+	Status      int                `json:"status" bson:"status"`            // 使用状态 1 已使用 2 未过期
+	IsExpired   int                `json:"expired" bson:"is_expired"`       // 是否过期 1 未过期 2 已过期
+	ExpiredTime time.Time          `json:"expiredTime" bson:"expired_time"` // 过期时间
+	Deleted     int                `json:"deleted" bson:"deleted"`          // 是否删除 1 未删除 2 已删除
+	CampaignID  string             `json:"campaign_id" bson:"campaign_id"`  // 所属活动ID
+	MaxUses     int                `json:"max_uses" bson:"max_uses"`        // 最大使用次数 1 单次 -1 不限，缺省视为 1
+	UsedCount   int                `json:"used_count" bson:"used_count"`    // 已使用次数
+	JobID       string             `json:"job_id" bson:"job_id"`            // 批量生成任务ID
 }
 type InviteCode struct {
-	ID          string `json:"id" bson:"_id"`
-	Code        string `json:"code" bson:"code" `              // 邀请码
-	Username    string `json:"username" bson:"username"`       // 绑定的用户名，""为未绑定
-	Status      int    `json:"status" bson:"status"`           // 使用状态 1 已使用 2 未过期
-	IsExpired   int    `json:"expired" bson:"isExpired"`       // 是否过期 1 未过期 2 已过期
-	ExpiredTime string `json:"expiredTime" bson:"expiredTime"` // 过期时间字符串
-	Deleted     int    `json:"deleted" bson:"deleted"`         // 是否删除 1 未删除 2 已删除
+	ID          string    `json:"id" bson:"_id"`
+	Code        string    `json:"code" bson:"code" `               // 邀请码
+	Username    string    `json:"username" bson:"username"`        // 绑定的用户名，""为未绑定
+	Status      int       `json:"status" bson:"status"`            // 使用状态 1 已使用 2 未过期
+	IsExpired   int       `json:"expired" bson:"is_expired"`       // 是否过期 1 未过期 2 已过期
+	ExpiredTime time.Time `json:"expiredTime" bson:"expired_time"` // 过期时间
+	Deleted     int       `json:"deleted" bson:"deleted"`          // 是否删除 1 未删除 2 已删除
+	CampaignID  string    `json:"campaign_id" bson:"campaign_id"`  // 所属活动ID
+	MaxUses     int       `json:"max_uses" bson:"max_uses"`        // 最大使用次数 1 单次 -1 不限
+	UsedCount   int       `json:"used_count" bson:"used_count"`    // 已使用次数
+	JobID       string    `json:"job_id" bson:"job_id"`            // 批量生成任务ID
+}
+
+// GenerateOptions 生成邀请码选项
+type GenerateOptions struct {
+	CampaignID string // 所属活动ID，""为无活动
+	MaxUses    int    // 最大使用次数：0 或 1 为单次，N 为 N 次，-1 为不限次数
+}
+
+// RedemptionInDB 邀请码兑换记录，每次注册成功兑换一条
+type RedemptionInDB struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CodeID     primitive.ObjectID `json:"code_id" bson:"code_id"`         // 邀请码ID
+	Code       string             `json:"code" bson:"code"`               // 邀请码
+	CampaignID string             `json:"campaign_id" bson:"campaign_id"` // 所属活动ID
+	UserID     string             `json:"user_id" bson:"user_id"`         // 注册用户ID
+	Username   string             `json:"username" bson:"username"`       // 注册用户名
+	RedeemedAt time.Time          `json:"redeemed_at" bson:"redeemed_at"` // 兑换时间
+}
+
+// Redemption 对外展示的兑换记录
+type Redemption struct {
+	ID         string    `json:"id"`
+	Code       string    `json:"code"`
+	CampaignID string    `json:"campaign_id"`
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// RedemptionFilter 分页查询兑换记录请求体
+type RedemptionFilter struct {
+	Code     string `json:"code"`    // 可选，邀请码
+	UserID   string `json:"user_id"` // 可选，用户ID
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
 }
 
 // InviteCodeRequest 获取邀请码请求体
 type InviteCodeRequest struct {
 	Code string `form:"code" binding:"required"`
 }
+
+//======================== 邀请活动 ========================
+
+// CreateCampaign 创建邀请活动请求体
+type CreateCampaign struct {
+	Name           string   `json:"name" binding:"required"` // 活动名称
+	Channel        string   `json:"channel"`                 // 推广渠道，用于统计注册来源
+	Tags           []string `json:"tags"`                    // 活动标签
+	ExpireDays     int      `json:"expire_days"`             // 邀请码有效天数，0 使用默认值
+	MaxRedemptions int      `json:"max_redemptions"`         // 最大兑换次数，0 为不限
+}
+
+// CampaignInDB 数据库存储的邀请活动
+type CampaignInDB struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name           string             `json:"name" bson:"name"`                       // 活动名称
+	Channel        string             `json:"channel" bson:"channel"`                 // 推广渠道
+	Tags           []string           `json:"tags" bson:"tags"`                       // 活动标签
+	CreatedBy      string             `json:"created_by" bson:"created_by"`           // 创建活动的管理员用户名
+	CreatedByID    string             `json:"created_by_id" bson:"created_by_id"`     // 创建活动的管理员ID
+	ExpireDays     int                `json:"expire_days" bson:"expire_days"`         // 邀请码有效天数
+	MaxRedemptions int                `json:"max_redemptions" bson:"max_redemptions"` // 最大兑换次数 0 为不限
+	Redeemed       int                `json:"redeemed" bson:"redeemed"`               // 已兑换次数
+	CreateAt       time.Time          `json:"create_at" bson:"create_at"`             // 创建时间
+	Deleted        int                `json:"deleted" bson:"deleted"`                 // 是否删除 1 未删除 2 已删除
+}
+
+// Campaign 对外展示的邀请活动
+type Campaign struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Channel        string    `json:"channel"`
+	Tags           []string  `json:"tags"`
+	CreatedBy      string    `json:"created_by"`
+	ExpireDays     int       `json:"expire_days"`
+	MaxRedemptions int       `json:"max_redemptions"`
+	Redeemed       int       `json:"redeemed"`
+	CreateAt       time.Time `json:"create_at"`
+}
+
+// CampaignFilter 分页查询邀请活动请求体
+type CampaignFilter struct {
+	Name     string `json:"name"`    // 可选，名称模糊筛选
+	Channel  string `json:"channel"` // 可选，渠道筛选
+	Tag      string `json:"tag"`     // 可选，标签筛选
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
+
+// CampaignStats 邀请活动统计
+type CampaignStats struct {
+	CampaignID     string `json:"campaign_id"`
+	Name           string `json:"name"`
+	Channel        string `json:"channel"`
+	Generated      int    `json:"generated"`       // 已生成邀请码数量
+	Redeemed       int    `json:"redeemed"`        // 已兑换（注册）数量
+	Expired        int    `json:"expired"`         // 已过期且未兑换数量
+	MaxRedemptions int    `json:"max_redemptions"` // 最大兑换次数 0 为不限
+	Remaining      int    `json:"remaining"`       // 剩余可兑换次数，-1 为不限
+}
+
+// CampaignStatsRequest 获取活动统计请求体
+type CampaignStatsRequest struct {
+	ID string `form:"id" binding:"required"`
+}
+
+// 批量生成任务状态
+const (
+	JobPending   = "pending"   // 已创建，等待执行
+	JobRunning   = "running"   // 执行中
+	JobCompleted = "completed" // 已完成
+	JobCancelled = "cancelled" // 已取消
+	JobFailed    = "failed"    // 执行失败
+)
+
+// GenerateJobInDB 邀请码批量生成任务
+type GenerateJobInDB struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Total      int                `json:"total" bson:"total"`             // 目标生成数量
+	Inserted   int                `json:"inserted" bson:"inserted"`       // 已生成数量
+	CampaignID string             `json:"campaign_id" bson:"campaign_id"` // 所属活动ID
+	MaxUses    int                `json:"max_uses" bson:"max_uses"`       // 每个邀请码最大使用次数
+	Status     string             `json:"status" bson:"status"`           // 任务状态
+	Error      string             `json:"error" bson:"error"`             // 失败原因
+	CreatedBy  string             `json:"created_by" bson:"created_by"`   // 创建人（管理员用户名）
+	CreateAt   time.Time          `json:"create_at" bson:"create_at"`
+	UpdateAt   time.Time          `json:"update_at" bson:"update_at"`
+	// Owner / LeaseUntil 执行任务的实例及其租约到期时间，租约有效期间其他实例不会续跑该任务
+	Owner      string    `json:"owner" bson:"owner,omitempty"`
+	LeaseUntil time.Time `json:"lease_until" bson:"lease_until,omitempty"`
+}
+
+// GenerateJob 批量生成任务（返回前端）
+type GenerateJob struct {
+	ID         string    `json:"id"`
+	Total      int       `json:"total"`       // 目标生成数量
+	Inserted   int       `json:"inserted"`    // 已生成数量
+	Progress   float64   `json:"progress"`    // 进度百分比 0-100
+	CampaignID string    `json:"campaign_id"` // 所属活动ID
+	MaxUses    int       `json:"max_uses"`    // 每个邀请码最大使用次数
+	Status     string    `json:"status"`      // 任务状态 pending / running / completed / cancelled / failed
+	Error      string    `json:"error"`       // 失败原因
+	CreatedBy  string    `json:"created_by"`  // 创建人
+	CreateAt   time.Time `json:"create_at"`
+	UpdateAt   time.Time `json:"update_at"`
+}
+
+// GenerateJobFilter 批量生成任务筛选条件
+type GenerateJobFilter struct {
+	Status   string `json:"status"` // 任务状态
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
+
+// GenerateJobRequest 按ID查询或取消批量生成任务
+type GenerateJobRequest struct {
+	ID string `form:"id" binding:"required"` // 任务ID
+}
@@ -0,0 +1,218 @@
+package InviteCodeService
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStoredDoc 按创建流程生成邀请码，并返回写入数据库时的 bson 文档
+func newStoredDoc(t *testing.T) (CreateInviteCode, bson.M) {
+	t.Helper()
+	created, err := CreateInviteCode{}.newInviteCode(nil, 0)
+	if err != nil {
+		t.Fatalf("newInviteCode: %v", err)
+	}
+	created.JobID = primitive.NewObjectID().Hex()
+	raw, err := bson.Marshal(created)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return created, doc
+}
+
+// assertFieldsStored 递归检查查询条件引用的字段都存在于写入的文档中，
+// 且过期时间的比较值为时间类型（与存储类型一致）
+func assertFieldsStored(t *testing.T, name string, filter bson.M, doc bson.M) {
+	t.Helper()
+	for key, value := range filter {
+		switch {
+		case key == "$or" || key == "$and":
+			for _, sub := range value.(bson.A) {
+				assertFieldsStored(t, name, sub.(bson.M), doc)
+			}
+		case strings.HasPrefix(key, "$"), key == "_id":
+		default:
+			if _, ok := doc[key]; !ok {
+				t.Errorf("%s: 查询字段 %q 不存在于写入的文档中", name, key)
+			}
+			if key == fieldExpiredTime {
+				cond, ok := value.(bson.M)
+				if !ok {
+					t.Errorf("%s: %s 条件应为比较操作，实际为 %T", name, key, value)
+					continue
+				}
+				for op, operand := range cond {
+					if _, ok := operand.(time.Time); !ok {
+						t.Errorf("%s: %s %s 的比较值应为 time.Time，实际为 %T", name, key, op, operand)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCreatedCodeDecodesIntoDBSchema(t *testing.T) {
+	created, doc := newStoredDoc(t)
+
+	if _, ok := doc[fieldExpiredTime].(primitive.DateTime); !ok {
+		t.Fatalf("%s 应以时间类型存储，实际为 %T", fieldExpiredTime, doc[fieldExpiredTime])
+	}
+	for _, legacy := range []string{legacyIsExpired, legacyExpiredTime} {
+		if _, ok := doc[legacy]; ok {
+			t.Errorf("不应再写入旧字段 %q", legacy)
+		}
+	}
+
+	raw, _ := bson.Marshal(doc)
+	var stored InviteCodeInDB
+	if err := bson.Unmarshal(raw, &stored); err != nil {
+		t.Fatalf("decode InviteCodeInDB: %v", err)
+	}
+	if stored.Code != created.Code {
+		t.Errorf("code = %q, want %q", stored.Code, created.Code)
+	}
+	if !stored.ExpiredTime.Equal(created.ExpiredTime) {
+		t.Errorf("expired_time = %v, want %v", stored.ExpiredTime, created.ExpiredTime)
+	}
+	if stored.IsExpired != created.IsExpired {
+		t.Errorf("is_expired = %d, want %d", stored.IsExpired, created.IsExpired)
+	}
+	if stored.MaxUses != 1 || stored.JobID != created.JobID {
+		t.Errorf("max_uses/job_id = %d/%q, want 1/%q", stored.MaxUses, stored.JobID, created.JobID)
+	}
+}
+
+func TestQueriesAgreeWithStoredSchema(t *testing.T) {
+	_, doc := newStoredDoc(t)
+	now := time.Now()
+
+	list := CreateInviteCode{}.buildFilter(CreateInviteFilter{
+		Code:       "ABCD-EFGH-J",
+		Username:   "user",
+		Status:     1,
+		IsExpired:  1,
+		CampaignID: primitive.NewObjectID().Hex(),
+		JobID:      primitive.NewObjectID().Hex(),
+		BeginTime:  "2025-01-01",
+		EndTime:    "2030-01-01 00:00:00",
+	})
+	assertFieldsStored(t, "list", list, doc)
+	assertFieldsStored(t, "expire", expireFilter(now), doc)
+	assertFieldsStored(t, "claim", claimFilter("ABCD-EFGH-J", now), doc)
+
+	// 列表排序字段
+	if _, ok := doc[fieldExpiredTime]; !ok {
+		t.Errorf("list 排序字段 %q 不存在于写入的文档中", fieldExpiredTime)
+	}
+
+	cond := list[fieldExpiredTime].(bson.M)
+	for _, op := range []string{"$gt", "$gte", "$lte"} {
+		if _, ok := cond[op]; !ok {
+			t.Errorf("list: 过期状态与时间区间应合并到同一条件，缺少 %s", op)
+		}
+	}
+}
+
+func TestGetReflectsExpiry(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name        string
+		expiredTime time.Time
+		want        int
+	}{
+		{"未过期", now.Add(time.Hour), 1},
+		{"已过期", now.Add(-time.Hour), 2},
+		{"缺失过期时间", time.Time{}, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 数据库中的 is_expired 可能尚未被每日任务更新，返回值以过期时间为准
+			dto := CreateInviteCode{}.toDTO(InviteCodeInDB{IsExpired: 1, ExpiredTime: tc.expiredTime})
+			if dto.IsExpired != tc.want {
+				t.Errorf("IsExpired = %d, want %d", dto.IsExpired, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpireFilterMatchesCodesCreatedForToday(t *testing.T) {
+	created, _ := newStoredDoc(t)
+	before := expireFilter(created.ExpiredTime)[fieldExpiredTime].(bson.M)["$lte"].(time.Time)
+	if created.ExpiredTime.After(before) {
+		t.Errorf("过期时间为 %v 的邀请码应在 %v 被清理", created.ExpiredTime, before)
+	}
+}
+
+func TestLegacyExpiryUpdate(t *testing.T) {
+	expiry := time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		name      string
+		doc       bson.M
+		wantSet   bson.M
+		wantUnset []string
+	}{
+		{
+			name:      "驼峰字段与字符串时间",
+			doc:       bson.M{legacyIsExpired: int32(2), legacyExpiredTime: "2025-01-02 00:00:00"},
+			wantSet:   bson.M{fieldExpiredTime: expiry, fieldIsExpired: int32(2)},
+			wantUnset: []string{legacyIsExpired, legacyExpiredTime},
+		},
+		{
+			name:    "下划线字段与字符串时间",
+			doc:     bson.M{fieldIsExpired: int32(1), fieldExpiredTime: "2025-01-02 00:00:00"},
+			wantSet: bson.M{fieldExpiredTime: expiry},
+		},
+		{
+			name:      "已是时间类型但残留旧字段",
+			doc:       bson.M{fieldExpiredTime: primitive.NewDateTimeFromTime(expiry), legacyIsExpired: int32(1)},
+			wantSet:   bson.M{fieldIsExpired: int32(1)},
+			wantUnset: []string{legacyIsExpired},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			update, err := legacyExpiryUpdate(tc.doc)
+			if err != nil {
+				t.Fatalf("legacyExpiryUpdate: %v", err)
+			}
+			set, _ := update["$set"].(bson.M)
+			if len(set) != len(tc.wantSet) {
+				t.Errorf("$set = %v, want %v", set, tc.wantSet)
+			}
+			for k, want := range tc.wantSet {
+				got := set[k]
+				if wt, ok := want.(time.Time); ok {
+					if gt, ok := got.(time.Time); !ok || !gt.Equal(wt) {
+						t.Errorf("$set.%s = %v, want %v", k, got, want)
+					}
+				} else if got != want {
+					t.Errorf("$set.%s = %v, want %v", k, got, want)
+				}
+			}
+			unset, _ := update["$unset"].(bson.M)
+			if len(unset) != len(tc.wantUnset) {
+				t.Errorf("$unset = %v, want %v", unset, tc.wantUnset)
+			}
+			for _, k := range tc.wantUnset {
+				if _, ok := unset[k]; !ok {
+					t.Errorf("$unset 缺少 %s", k)
+				}
+			}
+		})
+	}
+
+	migrated := bson.M{fieldIsExpired: int32(1), fieldExpiredTime: primitive.NewDateTimeFromTime(expiry)}
+	if update, err := legacyExpiryUpdate(migrated); err != nil || update != nil {
+		t.Errorf("已迁移文档不应再更新: update=%v err=%v", update, err)
+	}
+	if _, err := legacyExpiryUpdate(bson.M{legacyExpiredTime: "not a time"}); err == nil {
+		t.Error("无法解析的时间应返回错误")
+	}
+}
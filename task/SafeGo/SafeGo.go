@@ -1,52 +1,171 @@
+// Package SafeGo panic 恢复与崩溃报告
+//
+// HTTP 请求由 Middlewares.Recovery 恢复，后台 goroutine 由 SafeGo 启动，同步执行的任务由 RunWithRecovery 包装；
+// 三者在 panic 时都调用 Report：通过结构化日志输出调用栈与 ctx 中的请求 ID、路由和用户，
+// 并在 crash.dir（CRASH_REPORT_DIR）中写入 JSON 格式的崩溃报告，只保留最新的 crash.max_files 个
 package SafeGo
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"os"
+	"path/filepath"
 	"runtime/debug"
+	"sort"
+	"sync"
 	"time"
 )
 
-// LogPanic 将 panic 信息写入 exe 当前目录下的日志文件
-func LogPanic(r interface{}) {
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("panic_%s.log", timestamp)
+var logger = logging.For("panic")
 
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("无法创建 panic 文件:", err)
-		return
+// 崩溃报告文件名为 crash_<UTC 时间>_<随机串>.json，按文件名排序即按时间排序
+const (
+	filePrefix = "crash_"
+	fileSuffix = ".json"
+	timeLayout = "20060102T150405.000000000Z"
+)
+
+// CrashReport 一次 panic 的崩溃报告
+type CrashReport struct {
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"` // 发生位置，如 http、task.CheckExpiredCodes、invite.generate_job
+	Panic     string    `json:"panic"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Route     string    `json:"route,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	AdminID   string    `json:"admin_id,omitempty"`
+	Stack     string    `json:"stack"`
+	File      string    `json:"-"` // 报告文件路径，未写入文件时为空
+}
+
+// PanicError RunWithRecovery 将 panic 转换成的错误
+type PanicError struct {
+	Value  any
+	Report CrashReport
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Report 记录一次 panic：输出 error 日志并写入崩溃报告文件，写文件失败时只输出日志
+// 须在 recover 所在的 defer 函数中调用，调用栈才包含 panic 发生的位置
+func Report(ctx context.Context, source string, r any) CrashReport {
+	report := CrashReport{
+		Time:      time.Now(),
+		Source:    source,
+		Panic:     fmt.Sprint(r),
+		RequestID: logging.RequestID(ctx),
+		TraceID:   tracing.TraceID(ctx),
+		Route:     logging.Route(ctx),
+		UserID:    logging.UserID(ctx),
+		AdminID:   logging.AdminID(ctx),
+		Stack:     string(debug.Stack()),
 	}
-	defer f.Close()
+	trace.SpanFromContext(ctx).RecordError(fmt.Errorf("panic: %s", report.Panic))
 
-	f.WriteString("========== PANIC ==========\n")
-	f.WriteString(fmt.Sprintf("Panic: %v\n", r))
-	f.WriteString(string(debug.Stack()))
-	f.WriteString("\n===========================\n")
+	file, err := write(config.Crash(), report)
+	if err != nil {
+		logger.ErrorContext(ctx, "写入崩溃报告失败", logging.Err(err))
+	}
+	report.File = file
 
-	fmt.Println("程序发生 panic，已记录到", filename)
+	attrs := []any{"source", source, "panic", report.Panic, "stack", report.Stack}
+	if report.Route != "" {
+		attrs = append(attrs, logging.KeyRoute, report.Route)
+	}
+	if file != "" {
+		attrs = append(attrs, "report", file)
+	}
+	logger.ErrorContext(ctx, "程序发生 panic", attrs...)
+	return report
 }
 
-// SafeGo 包装 goroutine，自动捕获 panic
-func SafeGo(fn func()) {
+// SafeGo 在新 goroutine 中执行 fn，panic 时记录崩溃报告而不是使进程退出；
+// ctx 用于在报告中带上发起方的请求 ID 与用户，source 标明 goroutine 的用途
+func SafeGo(ctx context.Context, source string, fn func()) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				LogPanic(r)
+				Report(ctx, source, r)
 			}
 		}()
 		fn()
 	}()
 }
 
-// RunWithRecovery 用于主函数中运行任意函数，捕获 panic 并阻塞等待
-func RunWithRecovery(fn func()) {
+// RunWithRecovery 在当前 goroutine 中执行 fn，panic 时记录崩溃报告并返回 *PanicError，不阻塞也不退出进程
+func RunWithRecovery(ctx context.Context, source string, fn func() error) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			LogPanic(r)
-			fmt.Println("程序发生 panic，按回车退出...")
-			fmt.Scanln()
+			err = &PanicError{Value: r, Report: Report(ctx, source, r)}
 		}
 	}()
-	fn()
+	return fn()
+}
+
+// =======================================
+//          崩溃报告文件
+// =======================================
+
+// writeMu 串行写入与清理，避免并发 panic 时清理掉刚写入的报告
+var writeMu sync.Mutex
+
+// write 将报告写入 cfg.Dir 并删除超出 cfg.MaxFiles 的旧报告，cfg.Dir 为空时不写入
+func write(cfg config.CrashConfig, report CrashReport) (string, error) {
+	if cfg.Dir == "" {
+		return "", nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return "", err
+	}
+	// CreateTemp 以 0600 权限创建，调用栈中可能含有敏感信息
+	f, err := os.CreateTemp(cfg.Dir, filePrefix+report.Time.UTC().Format(timeLayout)+"_*"+fileSuffix)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := rotate(cfg.Dir, cfg.MaxFiles); err != nil {
+		logger.Warn("清理旧崩溃报告失败", logging.Err(err))
+	}
+	return f.Name(), nil
+}
+
+// rotate 只保留 dir 中最新的 keep 个崩溃报告
+func rotate(dir string, keep int) error {
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	var errs []error
+	for _, old := range files[:max(len(files)-keep, 0)] {
+		if err := os.Remove(old); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d 个文件删除失败: %w", len(errs), errs[0])
+	}
+	return nil
 }
@@ -0,0 +1,76 @@
+package SafeGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/StephenChristianW/go-movies-open/config"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunWithRecoveryReportsContext(t *testing.T) {
+	// 默认报告目录为相对路径，切换到临时目录避免在源码目录中写入文件
+	t.Chdir(t.TempDir())
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	ctx = logging.WithRoute(ctx, "/user/rename")
+	ctx = logging.WithUserID(ctx, "u1")
+
+	err := RunWithRecovery(ctx, "test", func() error { panic("boom") })
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("应返回 *PanicError，实际 %v", err)
+	}
+	report := pe.Report
+	if report.Panic != "boom" || report.RequestID != "req-1" || report.Route != "/user/rename" || report.UserID != "u1" {
+		t.Errorf("报告字段不正确: %+v", report)
+	}
+	if !strings.Contains(report.Stack, "SafeGo_test.go") {
+		t.Errorf("调用栈应包含 panic 发生的位置:\n%s", report.Stack)
+	}
+
+	data, err := os.ReadFile(report.File)
+	if err != nil {
+		t.Fatalf("读取崩溃报告: %v", err)
+	}
+	var saved CrashReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("崩溃报告不是 JSON: %v", err)
+	}
+	if saved.RequestID != "req-1" || saved.Source != "test" {
+		t.Errorf("崩溃报告内容不正确: %+v", saved)
+	}
+
+	if err := RunWithRecovery(ctx, "test", func() error { return nil }); err != nil {
+		t.Errorf("未 panic 时应返回 fn 的结果，实际 %v", err)
+	}
+}
+
+func TestWriteRotatesOldReports(t *testing.T) {
+	cfg := config.CrashConfig{Dir: t.TempDir(), MaxFiles: 2}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var files []string
+	for i := 0; i < 3; i++ {
+		file, err := write(cfg, CrashReport{Time: start.Add(time.Duration(i) * time.Second), Panic: "boom"})
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		files = append(files, file)
+	}
+
+	left, _ := filepath.Glob(filepath.Join(cfg.Dir, filePrefix+"*"+fileSuffix))
+	if len(left) != 2 {
+		t.Fatalf("应保留 2 个报告，实际 %d", len(left))
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("最旧的报告应被删除")
+	}
+
+	if file, err := write(config.CrashConfig{MaxFiles: 2}, CrashReport{}); file != "" || err != nil {
+		t.Errorf("未配置目录时不应写入文件: %q, %v", file, err)
+	}
+}
@@ -1,42 +1,86 @@
 package task
 
 import (
-	"log"
+	"context"
+	"github.com/StephenChristianW/go-movies-open/logging"
+	"github.com/StephenChristianW/go-movies-open/metrics"
+	"github.com/StephenChristianW/go-movies-open/task/SafeGo"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const taskLog = "[TASK] "
+var logger = logging.For("task")
+
+var (
+	// stopCtx 关闭时取消，等待中的定时任务不再执行
+	stopCtx, stopAll = context.WithCancel(context.Background())
+	// running 已启动的定时任务 goroutine，Stop 时等待其结束
+	running sync.WaitGroup
+	// scheduled 已注册的定时任务数量
+	scheduled atomic.Int32
+)
 
 // RunDaily 安全启动每日零点执行任务
 // job: 要执行的函数，返回 error 表示是否成功
 // taskName: 任务名称，用于日志
+// 调用 Stop 后不再调度；正在执行的任务会执行完毕
 func RunDaily(taskName string, job func() error) {
+	running.Add(1)
+	scheduled.Add(1)
 	go func() {
+		defer running.Done()
 		for {
 			// 计算到下一天零点的时间间隔
 			now := time.Now()
 			next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-			sleepDuration := next.Sub(now)
-
-			log.Printf(taskLog+"定时任务 [%s] 下一次执行时间: %s\n", taskName, next.Format("2006-01-02 15:04:05"))
-			time.Sleep(sleepDuration)
-
-			// 执行任务
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf(taskLog+"[%s] panic recovered: %v\n", taskName, r)
-					}
-				}()
-
-				log.Printf(taskLog+"开始执行任务 [%s] 执行时间: %s", taskName, time.Now().Format("2006-01-02 15:04:05"))
-
-				if err := job(); err != nil {
-					log.Printf(taskLog+"[%s] 执行出错: %v\n", taskName, err)
-				} else {
-					log.Printf(taskLog+"[%s] 执行完成: %s\n", taskName, time.Now().Format("2006-01-02 15:04:05"))
-				}
-			}()
+
+			logger.Info("等待下一次执行", "task", taskName, "next", next)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-stopCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			// 执行任务，panic 时记录崩溃报告并按失败处理，不影响下一次调度
+			start := time.Now()
+			logger.Info("开始执行任务", "task", taskName)
+			err := SafeGo.RunWithRecovery(context.Background(), "task."+taskName, job)
+			metrics.ObserveTask(taskName, time.Since(start), err == nil)
+			if err != nil {
+				logger.Error("任务执行出错", "task", taskName, "duration", time.Since(start).String(), logging.Err(err))
+			} else {
+				logger.Info("任务执行完成", "task", taskName, "duration", time.Since(start).String())
+			}
 		}
 	}()
 }
+
+// Stop 停止调度全部定时任务，并等待正在执行的任务结束，ctx 到期时返回 ctx 的错误
+func Stop(ctx context.Context) error {
+	stopAll()
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info("定时任务已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Scheduled 已注册的定时任务数量
+func Scheduled() int {
+	return int(scheduled.Load())
+}
+
+// Stopped 是否已调用 Stop 停止调度
+func Stopped() bool {
+	return stopCtx.Err() != nil
+}
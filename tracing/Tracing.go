@@ -0,0 +1,93 @@
+// Package tracing OpenTelemetry 链路追踪
+//
+// HTTP 中间件为每个请求创建 span 并写入请求 context，服务层经 context 传递到 MongoDB、Redis 与 bcrypt，
+// 各自在此基础上创建子 span；未调用 Init 时使用 OpenTelemetry 默认的空实现，不产生任何开销以外的影响。
+// 本包不依赖项目内其他包，由 main 按配置调用 Init
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// instrumentation 本项目创建的 span 所属的 instrumentation scope
+const instrumentation = "github.com/StephenChristianW/go-movies-open"
+
+// Options 链路追踪配置
+type Options struct {
+	Endpoint    string  // OTLP/HTTP 地址，如 http://localhost:4318
+	ServiceName string  // 上报的服务名
+	Environment string  // 运行环境，记录为 deployment.environment.name
+	SampleRatio float64 // 采样比例 0-1
+}
+
+// Init 创建 OTLP/HTTP 导出器，设置全局 TracerProvider 与 W3C Trace Context 传播格式；
+// 返回的关闭函数导出剩余的 span，服务退出时调用
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(
+			semconv.ServiceName(opts.ServiceName),
+			semconv.DeploymentEnvironmentName(opts.Environment),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// 上游已决定采样时沿用其决定，保证同一链路要么完整要么不采样
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	Install(tp)
+	return tp.Shutdown, nil
+}
+
+// Install 设置全局 TracerProvider 与传播格式，Init 与测试（使用内存导出器）共用
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Start 在 ctx 中的 span 下创建子 span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 不为 nil 时记录错误并标记为失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract 从请求头（traceparent / baggage）中恢复上游的链路信息
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceID 返回 ctx 中 span 的 trace ID，没有时为空
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"testing"
+)
+
+// memoryExporter 安装使用内存导出器的 TracerProvider，测试结束后恢复
+func memoryExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	prev := otel.GetTracerProvider()
+	exporter := tracetest.NewInMemoryExporter()
+	Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exporter
+}
+
+func TestStartContinuesUpstreamTrace(t *testing.T) {
+	exporter := memoryExporter(t)
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, parent := Start(Extract(context.Background(), header), "GET /user/login")
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("应沿用上游 trace ID，实际 %q", got)
+	}
+	_, child := Start(ctx, "bcrypt.compare")
+	End(child, errors.New("boom"))
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("应导出 2 个 span，实际 %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Parent.SpanID() != p.SpanContext.SpanID() {
+		t.Errorf("bcrypt.compare 应为请求 span 的子 span")
+	}
+	if p.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("请求 span 的父 span 应为上游 span，实际 %s", p.Parent.SpanID())
+	}
+	if c.Status.Code != codes.Error || len(c.Events) == 0 {
+		t.Errorf("出错的 span 应标记失败并记录错误: %+v", c.Status)
+	}
+	if p.Status.Code == codes.Error {
+		t.Errorf("成功的 span 不应标记失败")
+	}
+}
+
+func TestTraceIDWithoutSpan(t *testing.T) {
+	if got := TraceID(context.Background()); got != "" {
+		t.Errorf("没有 span 时 trace ID 应为空，实际 %q", got)
+	}
+}
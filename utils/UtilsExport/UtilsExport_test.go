@@ -0,0 +1,155 @@
+package UtilsExport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// sheet sheet1.xml 中的行与单元格
+type sheet struct {
+	Rows []struct {
+		R     string `xml:"r,attr"`
+		Cells []struct {
+			R    string `xml:"r,attr"`
+			T    string `xml:"t,attr"`
+			Text string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"邀请码", "备注", "空格"},
+		{"ABCD-EFGH", `a&b <c> "d" 'e'`, "  前后空格  "},
+		{"ÄÖÜ 😀", "", "line1\nline2"},
+	}
+	var buf bytes.Buffer
+	w, err := NewRowWriter(&buf, "XLSX")
+	if err != nil {
+		t.Fatalf("NewRowWriter: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("输出不是有效的 zip: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if files[name] == nil {
+			t.Fatalf("缺少 %s", name)
+		}
+	}
+	rc, err := files["xl/worksheets/sheet1.xml"].Open()
+	if err != nil {
+		t.Fatalf("打开 sheet1.xml: %v", err)
+	}
+	raw, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		t.Fatalf("读取 sheet1.xml: %v", err)
+	}
+	if !bytes.Contains(raw, []byte("a&amp;b &lt;c&gt;")) {
+		t.Errorf("特殊字符应转义:\n%s", raw)
+	}
+
+	var got sheet
+	if err := xml.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("sheet1.xml 不是有效的 XML: %v", err)
+	}
+	if len(got.Rows) != len(rows) {
+		t.Fatalf("应有 %d 行，实际 %d", len(rows), len(got.Rows))
+	}
+	for i, row := range got.Rows {
+		if want := string(rune('1' + i)); row.R != want {
+			t.Errorf("第 %d 行行号为 %q，应为 %q", i, row.R, want)
+		}
+		if len(row.Cells) != len(rows[i]) {
+			t.Fatalf("第 %d 行应有 %d 个单元格，实际 %d", i, len(rows[i]), len(row.Cells))
+		}
+		for j, c := range row.Cells {
+			if want := columnName(j) + row.R; c.R != want || c.T != "inlineStr" {
+				t.Errorf("单元格引用为 %q（t=%q），应为 %q", c.R, c.T, want)
+			}
+			if c.Text != rows[i][j] {
+				t.Errorf("单元格 %s 为 %q，应为 %q", c.R, c.Text, rows[i][j])
+			}
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q，应为 %q", i, got, want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRowWriter(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("NewRowWriter: %v", err)
+	}
+	rows := [][]string{{"邀请码", "备注"}, {"ABCD", `a,"b"`}}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out, ok := strings.CutPrefix(buf.String(), "\uFEFF")
+	if !ok {
+		t.Fatal("CSV 应以 UTF-8 BOM 开头")
+	}
+	got, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV: %v", err)
+	}
+	if len(got) != 2 || got[1][1] != `a,"b"` || got[0][0] != "邀请码" {
+		t.Errorf("CSV 内容不正确: %q", got)
+	}
+
+	if _, err := NewRowWriter(&buf, "pdf"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"alice":                   "alice",
+		"=HYPERLINK(\"x\",\"y\")": "'=HYPERLINK(\"x\",\"y\")",
+		"+1+1":                    "'+1+1",
+		"-2+3":                    "'-2+3",
+		"@SUM(A1)":                "'@SUM(A1)",
+		"\tcmd":                   "'\tcmd",
+		"\rcmd":                   "'\rcmd",
+		"a=b":                     "a=b",
+		"中文=1":                    "中文=1",
+	}
+	for in, want := range tests {
+		if got := EscapeFormula(in); got != want {
+			t.Errorf("EscapeFormula(%q) = %q，应为 %q", in, got, want)
+		}
+	}
+}
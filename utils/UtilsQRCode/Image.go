@@ -0,0 +1,108 @@
+package UtilsQRCode
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// 图片渲染参数（像素）
+const (
+	moduleScale = 4 // 每个模块边长
+	quietZone   = 4 // 静区宽度（模块数），规范要求至少 4
+	labelScale  = 2 // 标注文字放大倍数
+	labelGap    = 6 // 二维码与标注之间的间距
+	cellPadding = 16
+)
+
+// Image 渲染为灰度图，scale 为每个模块的像素边长，border 为静区模块数
+func (q *QRCode) Image(scale, border int) *image.Gray {
+	side := (q.Size + border*2) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	q.drawAt(img, image.Pt(0, 0), scale, border)
+	return img
+}
+
+func (q *QRCode) drawAt(img *image.Gray, origin image.Point, scale, border int) {
+	for y, row := range q.Modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			px := origin.X + (x+border)*scale
+			py := origin.Y + (y+border)*scale
+			draw.Draw(img, image.Rect(px, py, px+scale, py+scale), image.Black, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// SheetItem 打印页中的一个二维码
+type SheetItem struct {
+	Content string // 二维码内容，如注册链接
+	Label   string // 二维码下方标注，如邀请码
+}
+
+// WriteSheetPNG 将多个二维码按 columns 列排版为一张可打印的 PNG 写入 w
+// 所有二维码按最大版本统一单元格尺寸，标注使用内置点阵字体
+func WriteSheetPNG(w io.Writer, items []SheetItem, columns int) error {
+	if columns <= 0 {
+		columns = 4
+	}
+	codes := make([]*QRCode, len(items))
+	maxSize, maxLabel := 0, 0
+	for i, item := range items {
+		q, err := Encode(item.Content)
+		if err != nil {
+			return err
+		}
+		codes[i] = q
+		maxSize = max(maxSize, q.Size)
+		maxLabel = max(maxLabel, len([]rune(item.Label)))
+	}
+
+	qrSide := (maxSize + quietZone*2) * moduleScale
+	labelWidth := maxLabel * (glyphWidth + 1) * labelScale
+	cellW := max(qrSide, labelWidth) + cellPadding*2
+	cellH := qrSide + labelGap + glyphHeight*labelScale + cellPadding*2
+
+	columns = max(min(columns, len(items)), 1)
+	rows := (len(items) + columns - 1) / columns
+	img := image.NewGray(image.Rect(0, 0, max(cellW*columns, 1), max(cellH*rows, 1)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for i, q := range codes {
+		x0 := (i % columns) * cellW
+		y0 := (i / columns) * cellH
+		// 小版本二维码在单元格内居中
+		side := (q.Size + quietZone*2) * moduleScale
+		q.drawAt(img, image.Pt(x0+(cellW-side)/2, y0+cellPadding+(qrSide-side)/2), moduleScale, quietZone)
+
+		label := []rune(items[i].Label)
+		textW := len(label) * (glyphWidth + 1) * labelScale
+		drawText(img, image.Pt(x0+(cellW-textW)/2, y0+cellPadding+qrSide+labelGap), label)
+	}
+	return png.Encode(w, img)
+}
+
+// drawText 使用内置 5x7 点阵字体绘制文字
+func drawText(img *image.Gray, origin image.Point, text []rune) {
+	for i, r := range text {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		gx := origin.X + i*(glyphWidth+1)*labelScale
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px := gx + col*labelScale
+				py := origin.Y + row*labelScale
+				draw.Draw(img, image.Rect(px, py, px+labelScale, py+labelScale), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
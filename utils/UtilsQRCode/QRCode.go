@@ -0,0 +1,480 @@
+// Package UtilsQRCode 纯 Go 实现的二维码编码（字节模式、纠错等级 M、版本 1-10）
+// 不依赖第三方库，离线环境可用；版本 10 最多容纳 213 字节，足够编码注册链接
+package UtilsQRCode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDataTooLong 内容超出支持的最大版本容量
+var ErrDataTooLong = errors.New("二维码内容过长")
+
+// QRCode 二维码矩阵，Modules[y][x] 为 true 表示深色模块
+type QRCode struct {
+	Version int
+	Size    int
+	Modules [][]bool
+}
+
+// versionBlock 纠错等级 M 下各版本的分块参数
+type versionBlock struct {
+	ecPerBlock int // 每块纠错码字数
+	group1     int // 第一组块数
+	data1      int // 第一组每块数据码字数
+	group2     int // 第二组块数（每块数据码字数为 data1+1）
+}
+
+// blocksM 纠错等级 M，下标为版本号
+var blocksM = [...]versionBlock{
+	{},
+	{10, 1, 16, 0},
+	{16, 1, 28, 0},
+	{26, 1, 44, 0},
+	{18, 2, 32, 0},
+	{24, 2, 43, 0},
+	{16, 4, 27, 0},
+	{18, 4, 31, 0},
+	{22, 2, 38, 2},
+	{22, 3, 36, 2},
+	{26, 4, 43, 1},
+}
+
+// alignmentPositions 各版本校正图形中心坐标
+var alignmentPositions = [...][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// MaxVersion 支持的最大版本
+const MaxVersion = len(blocksM) - 1
+
+func (b versionBlock) dataCodewords() int {
+	return b.group1*b.data1 + b.group2*(b.data1+1)
+}
+
+// Encode 以字节模式、纠错等级 M 编码内容，自动选择最小版本与最优掩码
+func Encode(content string) (*QRCode, error) {
+	data := []byte(content)
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= blocksM[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: %d 字节", ErrDataTooLong, len(data))
+	}
+
+	codewords := interleave(version, encodeData(version, data))
+
+	q := newQRCode(version)
+	q.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			bestMask, bestPenalty = mask, p
+		}
+		q.applyMask(mask) // 异或两次还原
+	}
+	q.applyMask(bestMask)
+	q.drawFormatBits(bestMask)
+
+	return &QRCode{Version: q.version, Size: q.size, Modules: q.modules}, nil
+}
+
+// =======================================
+//          数据编码
+// =======================================
+
+// encodeData 生成数据码字：模式指示 + 长度 + 数据 + 终止符 + 填充
+func encodeData(version int, data []byte) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4) // 字节模式
+	if version >= 10 {
+		bb.append(len(data), 16)
+	} else {
+		bb.append(len(data), 8)
+	}
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := blocksM[version].dataCodewords() * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	out := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			out[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return out
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(val, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, (val>>i)&1 != 0)
+	}
+}
+
+// interleave 分块计算纠错码并交织
+func interleave(version int, data []byte) []byte {
+	vb := blocksM[version]
+	divisor := rsDivisor(vb.ecPerBlock)
+
+	var blocks, ecc [][]byte
+	offset := 0
+	for i := 0; i < vb.group1+vb.group2; i++ {
+		size := vb.data1
+		if i >= vb.group1 {
+			size++
+		}
+		block := data[offset : offset+size]
+		offset += size
+		blocks = append(blocks, block)
+		ecc = append(ecc, rsRemainder(block, divisor))
+	}
+
+	var out []byte
+	for i := 0; i <= vb.data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < vb.ecPerBlock; i++ {
+		for _, e := range ecc {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// =======================================
+//          Reed-Solomon（GF(256)，本原多项式 0x11D）
+// =======================================
+
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMul(coef, factor)
+		}
+	}
+	return result
+}
+
+// =======================================
+//          矩阵绘制
+// =======================================
+
+type builder struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newQRCode(version int) *builder {
+	size := version*4 + 17
+	q := &builder{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+	q.drawFunctionPatterns()
+	return q
+}
+
+func (q *builder) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+// drawFunctionPatterns 绘制定位、分隔、时序、校正图形，并预留格式与版本信息区域
+func (q *builder) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	pos := alignmentPositions[q.version]
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // 与定位图形重叠
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormatBits(0) // 先占位，选定掩码后重绘
+	q.drawVersion()
+}
+
+func (q *builder) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= q.size || y < 0 || y >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// formatBits 15 位格式信息：纠错等级 M（指示位 00）与掩码号的 BCH(15,5) 编码，再与 0x5412 异或
+func formatBits(mask int) int {
+	data := mask // M 级为 0
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormatBits 绘制格式信息
+func (q *builder) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true) // 固定深色模块
+}
+
+// versionBits 18 位版本信息：版本号的 BCH(18,6) 编码
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// drawVersion 版本 7 及以上绘制版本信息
+func (q *builder) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+	bits := versionBits(q.version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 != 0
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords 按之字形顺序填充数据与纠错码字
+func (q *builder) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // 跳过竖直时序图形
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.isFunction[y][x] && i < len(data)*8 {
+					q.modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *builder) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty 按规范四条规则计算掩码惩罚分
+func (q *builder) penalty() int {
+	n := q.size
+	score := 0
+	at := func(x, y int, horizontal bool) bool {
+		if horizontal {
+			return q.modules[y][x]
+		}
+		return q.modules[x][y]
+	}
+
+	for _, horizontal := range []bool{true, false} {
+		for y := 0; y < n; y++ {
+			// 规则 1：同色连续 5 个及以上
+			run := 1
+			for x := 1; x < n; x++ {
+				if at(x, y, horizontal) == at(x-1, y, horizontal) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			// 规则 3：类定位图形 1:1:3:1:1 且一侧有 4 个浅色
+			for x := 0; x+10 < n; x++ {
+				if matchFinderLike(func(i int) bool { return at(x+i, y, horizontal) }) {
+					score += 40
+				}
+			}
+		}
+	}
+
+	// 规则 2：2x2 同色块
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// 规则 4：深色比例偏离 50%
+	total := n * n
+	k := (abs(dark*20-total*10) + total - 1) / total
+	score += max(k-1, 0) * 10
+	return score
+}
+
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func matchFinderLike(get func(i int) bool) bool {
+	for _, pattern := range finderLike {
+		ok := true
+		for i, want := range pattern {
+			if get(i) != want {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,134 @@
+package UtilsQRCode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" 版本 1-M 的数据码字与纠错码字，见 thonky.com QR Code Tutorial 中的示例
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("纠错码字不正确:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// ISO/IEC 18004 纠错等级 M 各掩码的格式信息
+	want := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, w := range want {
+		if got := fmt.Sprintf("%015b", formatBits(mask)); got != w {
+			t.Errorf("掩码 %d 格式信息为 %s，应为 %s", mask, got, w)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	// ISO/IEC 18004 版本信息
+	want := map[int]string{
+		7:  "000111110010010100",
+		8:  "001000010110111100",
+		9:  "001001101010011001",
+		10: "001010010011010011",
+	}
+	for version, w := range want {
+		if got := fmt.Sprintf("%018b", versionBits(version)); got != w {
+			t.Errorf("版本 %d 版本信息为 %s，应为 %s", version, got, w)
+		}
+	}
+}
+
+// helloWorldM "HELLO WORLD" 字节模式、版本 1-M 的完整模块矩阵（掩码 4），# 为深色
+// 由按 ISO/IEC 18004 独立实现的参考编码器生成，其纠错码字与格式信息与上面的已知答案一致
+const helloWorldM = `
+	#######.##..#.#######
+	#.....#....#..#.....#
+	#.###.#..#.#..#.###.#
+	#.###.#.#..#..#.###.#
+	#.###.#.###.#.#.###.#
+	#.....#.#..#..#.....#
+	#######.#.#.#.#######
+	........#..##........
+	#...#.######.#####..#
+	...#....#.###....####
+	..######..##.##.#..#.
+	#####...##...#.......
+	#####.#.#.#.#.##..##.
+	........#.#.####.#.##
+	#######.###.#.#.##.#.
+	#.....#..#.###.##..##
+	#.###.#.##.#.##...##.
+	#.###.#..#..#...##.##
+	#.###.#..###...###...
+	#.....#....#.#.......
+	#######.#########.#.#
+`
+
+func TestEncodeMatrix(t *testing.T) {
+	q, err := Encode("HELLO WORLD")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if q.Version != 1 || q.Size != 21 {
+		t.Fatalf("应为版本 1（21x21），实际版本 %d（%dx%d）", q.Version, q.Size, q.Size)
+	}
+	want := strings.Fields(helloWorldM)
+	for y, row := range q.Modules {
+		var b strings.Builder
+		for _, dark := range row {
+			if dark {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		if b.String() != want[y] {
+			t.Errorf("第 %d 行:\n got %s\nwant %s", y, b.String(), want[y])
+		}
+	}
+}
+
+func TestEncodeCapacity(t *testing.T) {
+	q, err := Encode(strings.Repeat("a", 213))
+	if err != nil {
+		t.Fatalf("版本 10 应可容纳 213 字节: %v", err)
+	}
+	if q.Version != MaxVersion || q.Size != MaxVersion*4+17 {
+		t.Errorf("应选择版本 %d，实际 %d", MaxVersion, q.Version)
+	}
+	if _, err := Encode(strings.Repeat("a", 214)); !errors.Is(err, ErrDataTooLong) {
+		t.Errorf("超出容量应返回 ErrDataTooLong，实际 %v", err)
+	}
+}
+
+func TestWriteSheetPNG(t *testing.T) {
+	items := []SheetItem{
+		{Content: "https://example.com/register?code=ABCD-EFGH", Label: "ABCD-EFGH"},
+		{Content: "HELLO WORLD", Label: "HELLO"},
+		{Content: "x", Label: "X"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSheetPNG(&buf, items, 2); err != nil {
+		t.Fatalf("WriteSheetPNG: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("输出不是有效的 PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() == 0 || b.Dy() == 0 || b.Dx() >= b.Dy()*2 {
+		t.Errorf("3 个二维码按 2 列排版应为 2 行，实际尺寸 %v", b)
+	}
+
+	buf.Reset()
+	if err := WriteSheetPNG(&buf, []SheetItem{{Content: strings.Repeat("a", 214)}}, 1); !errors.Is(err, ErrDataTooLong) {
+		t.Errorf("内容过长应返回 ErrDataTooLong，实际 %v", err)
+	}
+}
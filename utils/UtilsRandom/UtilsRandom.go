@@ -22,6 +22,22 @@ func RandomAlphaNumString(length int) (string, error) {
 	return string(result), nil
 }
 
+// RandomString 从给定字符集中随机生成指定长度的字符串
+// alphabet: 可选字符集
+// length: 要生成的字符串长度
+// 返回值: 随机生成的字符串，可能的错误（如 rand 出错）
+func RandomString(alphabet string, length int) (string, error) {
+	result := make([]byte, length)
+	for i := 0; i < length; i++ {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		result[i] = alphabet[int(num.Int64())]
+	}
+	return string(result), nil
+}
+
 // RandomInt 生成 [0, max) 范围内的随机整数
 // max: 随机数上限（不包含 max）
 // 返回值: 随机整数，可能的错误
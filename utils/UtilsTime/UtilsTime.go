@@ -15,14 +15,27 @@ func NowTime() string {
 // AfterNDays000 返回当前时间往后 after 天的零点时间，格式化为 "YYYY-MM-DD 00:00:00"
 // after: 往后推的天数，可以为负数表示往前
 func AfterNDays000(after int) string {
-	t := time.Now().AddDate(0, 0, after)
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Format(format)
+	return DayStart(after).Format(format)
 }
 
 // Today000 返回今天的零点时间，格式化为 "YYYY-MM-DD 00:00:00"
 func Today000() string {
-	t := time.Now().AddDate(0, 0, 0)
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Format(format)
+	return DayStart(0).Format(format)
+}
+
+// DayStart 返回当前时间往后 after 天的零点时间（本地时区）
+// after: 往后推的天数，可以为负数表示往前
+func DayStart(after int) time.Time {
+	t := time.Now().AddDate(0, 0, after)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Format 将时间格式化为 "YYYY-MM-DD HH:MM:SS"，零值返回空字符串
+func Format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(format)
 }
 
 // DaysToTimeDuration 将天数转换为对应的 time.Duration